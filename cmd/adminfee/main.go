@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// adminfee charges the monthly admin fee for one period. Accounts already
+// charged for the period are skipped, so it is safe to run again.
+func main() {
+	lastMonth := models.BusinessDate(time.Now().AddDate(0, 0, -1))[:7]
+	period := flag.String("period", lastMonth, "Period to charge, YYYY-MM; defaults to the month of yesterday")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	feePolicy, err := service.LoadFeePolicy(cfg.FeeRulesFile)
+	if err != nil {
+		log.Fatalf("Invalid FEE_RULES_FILE: %v", err)
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	fees := service.NewFeeService(repo, feePolicy, customLogger)
+
+	charged, err := fees.ChargeMonthlyAdminFees(context.Background(), *period)
+	if err != nil {
+		log.Fatalf("Admin fee batch failed: %v", err)
+	}
+
+	fmt.Printf("period:  %s\n", *period)
+	fmt.Printf("charged: %d\n", charged)
+}
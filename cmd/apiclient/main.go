@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// apiclient registers a partner API client and prints its credentials once
+func main() {
+	name := flag.String("name", "", "Partner name")
+	routes := flag.String("routes", "", `Comma separated allowed routes, e.g. "POST /v1/accounts/:noRekening/deposits,GET /v1/accounts/:noRekening/balance" or "*"`)
+	ips := flag.String("ips", "", "Comma separated IP addresses or CIDR ranges allowed to call; empty allows any")
+	roles := flag.String("roles", "", "Comma separated back-office roles, e.g. \"teller\" or \"teller,supervisor\"")
+	accounts := flag.String("accounts", "", "Comma separated account numbers the partner may read over SNAP and subscribe to, or \"*\" for all")
+	publicKeyPath := flag.String("public-key", "", "PEM file with the partner's RSA public key for SNAP access-token requests")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+
+	var publicKey string
+	if *publicKeyPath != "" {
+		pem, err := os.ReadFile(*publicKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to read public key: %v", err)
+		}
+		publicKey = string(pem)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	// Registration does not issue tokens, so no token manager is needed
+	partners := service.NewPartnerService(repo, nil, cfg.PartnerSignatureWindow, customLogger)
+
+	client, secret, err := partners.RegisterClient(context.Background(), *name, splitFlag(*routes), splitFlag(*ips), splitFlag(*roles), splitFlag(*accounts), publicKey)
+	if err != nil {
+		log.Fatalf("Failed to register client: %v", err)
+	}
+
+	fmt.Printf("client_id: %s\n", client.ClientID)
+	fmt.Printf("secret:    %s\n", secret)
+	fmt.Println("Store the secret now, it cannot be retrieved again.")
+}
+
+func splitFlag(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
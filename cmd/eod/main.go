@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// eod closes one business date: it snapshots every balance and records the
+// day's totals. A date can only be closed once; running it again fails.
+func main() {
+	yesterday := models.BusinessDate(time.Now().AddDate(0, 0, -1))
+	date := flag.String("date", yesterday, "Business date to close, YYYY-MM-DD; defaults to yesterday")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	eod := service.NewEODService(repo, customLogger)
+
+	run, err := eod.RunEndOfDay(context.Background(), *date)
+	if err != nil {
+		log.Fatalf("End of day failed: %v", err)
+	}
+
+	fmt.Printf("business date:     %s\n", run.BusinessDate)
+	fmt.Printf("accounts:          %d\n", run.AccountCount)
+	fmt.Printf("total balance:     %.2f\n", run.TotalBalance)
+	fmt.Printf("total deposits:    %.2f\n", run.TotalDeposits)
+	fmt.Printf("total withdrawals: %.2f\n", run.TotalWithdrawals)
+	fmt.Printf("total fees:        %.2f\n", run.TotalFees)
+}
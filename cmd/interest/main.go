@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// interest runs the daily interest batch: accrual for one business date and,
+// on the last day of a month, the monthly posting. Safe to run again for the
+// same date.
+func main() {
+	yesterday := models.BusinessDate(time.Now().AddDate(0, 0, -1))
+	date := flag.String("date", yesterday, "Business date to accrue, YYYY-MM-DD in Asia/Jakarta")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	interestPolicy, err := service.LoadInterestPolicy(cfg.InterestRatesFile)
+	if err != nil {
+		log.Fatalf("Invalid INTEREST_RATES_FILE: %v", err)
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	interest := service.NewInterestService(repo, interestPolicy, customLogger)
+
+	result, err := interest.RunBatch(context.Background(), *date)
+	if err != nil {
+		log.Fatalf("Interest batch failed: %v", err)
+	}
+
+	fmt.Printf("business date: %s\n", result.BusinessDate)
+	fmt.Printf("accrued:       %d\n", result.Accrued)
+	if result.PostedPeriod != "" {
+		fmt.Printf("posted %s:  %d\n", result.PostedPeriod, result.Posted)
+	}
+}
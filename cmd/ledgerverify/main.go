@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/ledger"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// ledgerverify walks the hash-chained ledger and reports the first broken
+// link. It exits with status 2 when the chain is broken. With -checkpoint it
+// signs a checkpoint of the chain head instead.
+func main() {
+	checkpoint := flag.Bool("checkpoint", false, "Sign a checkpoint of the current chain head and exit")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	var signingKey *rsa.PrivateKey
+	var publicKey *rsa.PublicKey
+	if cfg.LedgerSigningKeyPath != "" {
+		if signingKey, err = ledger.LoadSigningKey(cfg.LedgerSigningKeyPath); err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		publicKey = &signingKey.PublicKey
+	}
+	if cfg.LedgerPublicKeyPath != "" {
+		if publicKey, err = ledger.LoadVerifyKey(cfg.LedgerPublicKeyPath); err != nil {
+			log.Fatalf("Failed to load public key: %v", err)
+		}
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	ledgerSvc := service.NewLedgerService(repo, signingKey, customLogger)
+	ctx := context.Background()
+
+	if *checkpoint {
+		cp, created, err := ledgerSvc.CreateCheckpoint(ctx)
+		if err != nil {
+			log.Fatalf("Checkpoint failed: %v", err)
+		}
+		if cp == nil {
+			fmt.Println("chain is empty, nothing to checkpoint")
+			return
+		}
+		fmt.Printf("seq:     %d\n", cp.Seq)
+		fmt.Printf("hash:    %s\n", cp.GlobalHash)
+		fmt.Printf("created: %t\n", created)
+		return
+	}
+
+	result, err := ledgerSvc.VerifyChain(ctx, publicKey)
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+
+	fmt.Printf("entries:     %d\n", result.EntriesChecked)
+	fmt.Printf("unchained:   %d\n", result.UnchainedEntries)
+	fmt.Printf("checkpoints: %d\n", result.CheckpointsChecked)
+	if publicKey == nil {
+		fmt.Println("signatures:  not checked, no key configured")
+	}
+	fmt.Printf("head:        %d %s\n", result.HeadSeq, result.HeadHash)
+
+	if brk := result.Break; brk != nil {
+		fmt.Printf("BROKEN %s link at seq %d", brk.Kind, brk.Seq)
+		if brk.TransactionID != 0 {
+			fmt.Printf(" (transaction %d, account %s)", brk.TransactionID, brk.AccountNumber)
+		}
+		fmt.Printf("\n  expected: %s\n  actual:   %s\n", brk.Expected, brk.Actual)
+		cfg.DBConnection.Close()
+		os.Exit(2)
+	}
+	fmt.Println("chain intact")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/reconciliation"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// reconcile recomputes every account's balance from the ledger, writes the
+// discrepancies to a report and, with -freeze, freezes the accounts that do
+// not match. It exits with status 2 when discrepancies were found.
+func main() {
+	freeze := flag.Bool("freeze", false, "Freeze accounts whose balance does not match the ledger")
+	dir := flag.String("dir", "reports", "Directory the report is written to")
+	format := flag.String("format", reconciliation.FormatJSON, "Report format: json or csv")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *format != reconciliation.FormatJSON && *format != reconciliation.FormatCSV {
+		log.Fatalf("Unknown format %q", *format)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	reconciler := service.NewReconciliationService(repo, cfg.ReconcileChunkSize, customLogger)
+
+	report, err := reconciler.Reconcile(context.Background(), *freeze)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+	path, err := reconciliation.Save(*dir, report, *format)
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	frozen := 0
+	for _, d := range report.Discrepancies {
+		if d.Frozen {
+			frozen++
+		}
+	}
+	fmt.Printf("checked:       %d\n", report.AccountsChecked)
+	fmt.Printf("discrepancies: %d\n", len(report.Discrepancies))
+	fmt.Printf("frozen:        %d\n", frozen)
+	fmt.Printf("report:        %s\n", path)
+
+	if len(report.Discrepancies) > 0 {
+		cfg.DBConnection.Close()
+		os.Exit(2)
+	}
+}
@@ -2,17 +2,28 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/grpcapi"
 	"github.com/alfaa19/service-account-test/internal/handler"
+	"github.com/alfaa19/service-account-test/internal/ledger"
+	appmw "github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
+	"github.com/alfaa19/service-account-test/internal/reconciliation"
 	"github.com/alfaa19/service-account-test/internal/repository"
 	"github.com/alfaa19/service-account-test/internal/routes"
 	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/alfaa19/service-account-test/internal/stream"
+	"github.com/alfaa19/service-account-test/internal/webhook"
 	logger "github.com/alfaa19/service-account-test/pkg/logrus"
 	"github.com/labstack/echo/v4"
 )
@@ -30,6 +41,21 @@ func main() {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 
+	// Load token signing keys
+	tokens, err := auth.NewTokenManager(cfg.GetAuthConfig())
+	if err != nil {
+		customLogger.Fatal("Failed to initialize token manager (set JWT_PRIVATE_KEY_PATH, or JWT_ALLOW_EPHEMERAL_KEY=true for local development): ", err)
+	}
+	if cfg.JWTPrivateKeyPath == "" {
+		customLogger.LogWarning(context.Background(), "JWT_PRIVATE_KEY_PATH not set, using an ephemeral signing key; tokens will not survive a restart", nil)
+	}
+
+	// Build the rules the services enforce
+	policies, err := servicePolicies(cfg)
+	if err != nil {
+		customLogger.Fatal("Failed to load service policies: ", err)
+	}
+
 	// Connect to database
 	err = cfg.OpenDatabase()
 	if err != nil {
@@ -43,17 +69,145 @@ func main() {
 		customLogger.Fatal("Failed to ping database: ", err)
 	}
 	// Initialize dependencies
-	repo := repository.NewRepository(cfg.DBConnection, customLogger)
-	svc := service.NewService(repo, customLogger)
-	h := handler.NewAccountHandler(svc, customLogger)
+	// Committed ledger entries are fanned out to balance streams in-process
+	balanceBus := stream.NewBus(cfg.BalanceStreamBuffer)
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger,
+		repository.WithLedgerListener(balanceBus))
+	svc := service.NewService(repo, policies, customLogger)
+	authSvc := service.NewAuthService(repo, tokens, customLogger)
+	partnerSvc := service.NewPartnerService(repo, tokens, cfg.PartnerSignatureWindow, customLogger)
+	approvalSvc := service.NewApprovalService(repo, svc, service.ApprovalPolicy{
+		Threshold: cfg.ApprovalThreshold,
+		TTL:       cfg.ApprovalTTL,
+	}, customLogger)
+	h := handler.NewAccountHandler(svc, approvalSvc, customLogger)
+	v1Handler := handler.NewAccountV1Handler(svc, approvalSvc, customLogger)
+	authHandler := handler.NewAuthHandler(authSvc, customLogger)
+	snapHandler := handler.NewSnapHandler(svc, partnerSvc, customLogger)
+	holdHandler := handler.NewHoldHandler(svc, customLogger)
+	approvalHandler := handler.NewApprovalHandler(approvalSvc, customLogger)
+	auditHandler := handler.NewAuditHandler(service.NewAuditService(repo, customLogger), customLogger)
+	webhookSvc := service.NewWebhookService(repo, webhook.NewSender(cfg.WebhookTimeout), service.WebhookPolicy{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		RetryBase:   cfg.WebhookRetryBase,
+		MaxDelay:    cfg.WebhookMaxRetryDelay,
+	}, customLogger)
+	webhookHandler := handler.NewWebhookHandler(webhookSvc, customLogger)
+	streamHandler := handler.NewStreamHandler(service.NewBalanceStreamService(repo, balanceBus, customLogger),
+		cfg.BalanceStreamHeartbeat, customLogger)
+
+	// Release expired holds in the background until shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	holdSweeper := service.NewHoldSweeper(svc, cfg.HoldSweepInterval, cfg.HoldSweepBatch, customLogger)
+	go holdSweeper.Run(workerCtx)
+	approvalSweeper := service.NewApprovalSweeper(approvalSvc, cfg.ApprovalSweepInterval, cfg.ApprovalSweepBatch, customLogger)
+	go approvalSweeper.Run(workerCtx)
+	webhookDispatcher := service.NewWebhookDispatcher(webhookSvc, cfg.WebhookDispatchInterval, cfg.WebhookBatchSize, customLogger)
+	go webhookDispatcher.Run(workerCtx)
+
+	// Sign checkpoints of the ledger chain, when a signing key is configured
+	if cfg.LedgerSigningKeyPath != "" {
+		signingKey, err := ledger.LoadSigningKey(cfg.LedgerSigningKeyPath)
+		if err != nil {
+			customLogger.Fatal("Failed to load ledger signing key: ", err)
+		}
+		ledgerSvc := service.NewLedgerService(repo, signingKey, customLogger)
+		go service.NewLedgerCheckpointer(ledgerSvc, cfg.LedgerCheckpointInterval, customLogger).Run(workerCtx)
+	}
+
+	// Reconcile balances against the ledger on a schedule, when enabled
+	if cfg.ReconcileInterval > 0 {
+		reconciler := service.NewReconciliationService(repo, cfg.ReconcileChunkSize, customLogger)
+		reconcileJob := reconciliation.NewJob(reconciler, cfg.ReconcileInterval, cfg.ReconcileFreeze,
+			cfg.ReconcileReportDir, cfg.ReconcileReportFormat, customLogger)
+		go reconcileJob.Run(workerCtx)
+	}
+
+	// Relay outbox events to the configured publisher
+	if cfg.OutboxPublisher != "" {
+		publisher, err := events.NewPublisher(cfg.OutboxPublisher, cfg.OutboxTarget)
+		if err != nil {
+			customLogger.Fatal("Failed to create event publisher: ", err)
+		}
+		defer publisher.Close()
+		relay := service.NewOutboxRelay(repo, publisher, cfg.OutboxRelayInterval, cfg.OutboxBatchSize, customLogger)
+		go relay.Run(workerCtx)
+	}
+
+	rateLimits := routes.RateLimits{
+		Register:     cfg.RateLimitRegister,
+		Login:        cfg.RateLimitLogin,
+		LoginAccount: cfg.RateLimitLoginAccount,
+		Balance:      cfg.RateLimitBalance,
+		Withdraw:     cfg.RateLimitWithdraw,
+	}
+	if cfg.RateLimitEnabled {
+		rateLimits.Store = ratelimit.NewMemoryStore()
+	}
+
+	docsHandler, err := handler.NewDocsHandler(routes.Spec())
+	if err != nil {
+		customLogger.Fatal("Failed to encode the OpenAPI document: ", err)
+	}
 
 	// Initialize Echo
 	e := echo.New()
+	e.IPExtractor = appmw.IPExtractor(cfg.TrustedProxies)
 
 	// Setup routes
-	routes.NewRouter(h, e)
+	routes.NewRouter(routes.Dependencies{
+		Account:  h,
+		V1:       v1Handler,
+		Auth:     authHandler,
+		Tokens:   tokens,
+		Log:      customLogger,
+		Snap:     snapHandler,
+		Hold:     holdHandler,
+		Approval: approvalHandler,
+		Audit:    auditHandler,
+		Webhook:  webhookHandler,
+		Stream:   streamHandler,
+		Docs:     docsHandler,
+		Partners: partnerSvc,
+
+		PartnerAuthEnabled: cfg.PartnerAuthEnabled,
+		RateLimits:         rateLimits,
+		Legacy: appmw.Deprecation{
+			Since:  cfg.LegacyDeprecatedAt,
+			Sunset: cfg.LegacySunset,
+		},
+	}, e)
 
-	// Start server
+	// Serve the account operations over gRPC as well
+	grpcServer := grpcapi.NewServer(grpcapi.Dependencies{
+		Service:   svc,
+		Approvals: approvalSvc,
+		Tokens:    tokens,
+		Log:       customLogger,
+		Partners:  partnerSvc,
+
+		PartnerAuthEnabled: cfg.PartnerAuthEnabled,
+		RateLimits: grpcapi.RateLimits{
+			Store:    rateLimits.Store,
+			Register: rateLimits.Register,
+			Balance:  rateLimits.Balance,
+			Withdraw: rateLimits.Withdraw,
+		},
+	})
+
+	// Start servers
+	grpcListener, err := net.Listen("tcp", cfg.GetGRPCAddress())
+	if err != nil {
+		customLogger.Fatal("Failed to listen for gRPC: ", err)
+	}
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			customLogger.LogOperation(context.Background(), "main", "error", map[string]interface{}{
+				"error": "gRPC server failed: " + err.Error(),
+			})
+		}
+	}()
 	go func() {
 		if err := e.Start(cfg.GetServerAddress()); err != nil {
 			customLogger.LogOperation(context.Background(), "main", "error", map[string]interface{}{
@@ -66,6 +220,10 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	stopWorkers()
+	// End the open balance streams, which would otherwise hold Shutdown until
+	// its timeout
+	balanceBus.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -75,8 +233,46 @@ func main() {
 			"error": "Server shutdown failed: " + err.Error(),
 		})
 	}
+	if err := grpcServer.Shutdown(ctx); err != nil {
+		customLogger.LogOperation(ctx, "main", "error", map[string]interface{}{
+			"error": "gRPC server shutdown failed: " + err.Error(),
+		})
+	}
 
 	customLogger.LogOperation(ctx, "main", "success", map[string]interface{}{
 		"message": "Server shutdown completed",
 	})
 }
+
+// servicePolicies builds the rules enforced by the account service from cfg,
+// loading the limit and fee files it names
+func servicePolicies(cfg *config.Config) (service.Policies, error) {
+	policies := service.Policies{
+		Pin: service.PinPolicy{
+			MaxAttempts:  cfg.PinMaxAttempts,
+			LockDuration: cfg.PinLockDuration,
+		},
+		IdentityReuse: service.IdentityReusePolicy{
+			Mode:     cfg.IdentityReusePolicy,
+			Cooldown: cfg.IdentityReuseCooldown,
+		},
+		Hold: service.HoldPolicy{
+			DefaultTTL: cfg.HoldDefaultTTL,
+			MaxTTL:     cfg.HoldMaxTTL,
+		},
+	}
+	if err := policies.IdentityReuse.Validate(); err != nil {
+		return policies, fmt.Errorf("invalid IDENTITY_REUSE_POLICY: %v", err)
+	}
+
+	var err error
+	policies.Limits, err = service.LoadLimitPolicy(cfg.WithdrawalLimitsFile)
+	if err != nil {
+		return policies, fmt.Errorf("invalid WITHDRAWAL_LIMITS_FILE: %v", err)
+	}
+	policies.Fees, err = service.LoadFeePolicy(cfg.FeeRulesFile)
+	if err != nil {
+		return policies, fmt.Errorf("invalid FEE_RULES_FILE: %v", err)
+	}
+	return policies, nil
+}
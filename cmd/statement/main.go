@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/config"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/alfaa19/service-account-test/internal/statement"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// statement writes the statements of every account open during one month,
+// including those closed in it, into a directory, one file per account and
+// format
+func main() {
+	now := models.BusinessTime(time.Now())
+	lastMonth := now.AddDate(0, 0, -now.Day()).Format("2006-01")
+	period := flag.String("period", lastMonth, "Statement period, YYYY-MM; defaults to last month")
+	dir := flag.String("dir", "statements", "Directory the statements are written to")
+	formats := flag.String("format", "pdf,csv", "Comma separated output formats: pdf, csv")
+
+	// Load configuration (parses the flags above as well)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	var outputs []string
+	for _, format := range strings.Split(*formats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format != statement.FormatPDF && format != statement.FormatCSV {
+			log.Fatalf("Unknown format %q", format)
+		}
+		outputs = append(outputs, format)
+	}
+	if err := os.MkdirAll(*dir, 0o750); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	customLogger, err := logger.NewLogger(cfg.GetLoggerConfig())
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	if err := cfg.OpenDatabase(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer cfg.DBConnection.Close()
+
+	repo := repository.NewRepository(cfg.DBConnection, cfg.GetStatusPolicy(), customLogger)
+	// Statements need none of the policies of withdrawals, holds or fees
+	svc := service.NewService(repo, service.Policies{}, customLogger)
+
+	from, to, err := models.PeriodBounds(*period)
+	if err != nil {
+		log.Fatalf("Invalid period %q: %v", *period, err)
+	}
+
+	ctx := context.Background()
+	written := 0
+	after := ""
+	for {
+		accounts, err := repo.GetStatementAccounts(ctx, from, to, after, 500)
+		if err != nil {
+			log.Fatalf("Failed to list accounts: %v", err)
+		}
+		if len(accounts) == 0 {
+			break
+		}
+
+		for _, account := range accounts {
+			st, err := svc.GetStatement(ctx, account.AccountNumber, *period)
+			if err != nil {
+				log.Fatalf("Failed to build statement for %s: %v", account.AccountNumber, err)
+			}
+			for _, format := range outputs {
+				if err := writeFile(filepath.Join(*dir, statement.FileName(st, format)), st, format); err != nil {
+					log.Fatalf("Failed to write statement for %s: %v", account.AccountNumber, err)
+				}
+				written++
+			}
+		}
+		after = accounts[len(accounts)-1].AccountNumber
+	}
+
+	fmt.Printf("period:  %s\n", *period)
+	fmt.Printf("written: %d files to %s\n", written, *dir)
+}
+
+func writeFile(path string, st *models.Statement, format string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if err := statement.Write(f, st, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
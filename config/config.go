@@ -5,10 +5,16 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
 	logger "github.com/alfaa19/service-account-test/pkg/logrus"
 	_ "github.com/lib/pq"
 )
@@ -19,6 +25,9 @@ type Config struct {
 	Host string
 	Port int
 
+	// GRPCPort is the port of the gRPC API, served next to the REST API
+	GRPCPort int
+
 	// Database settings
 	DB           *pgsql
 	DBConnection *sql.DB
@@ -28,6 +37,98 @@ type Config struct {
 	LogToConsole bool
 	LogToFile    bool
 	LogFilePath  string
+
+	// Auth settings
+	JWTIssuer         string
+	JWTPrivateKeyPath string
+	JWTPublicKeyPath  string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	// JWTAllowEphemeralKey signs with a key generated at startup when no
+	// private key is configured; for local development only
+	JWTAllowEphemeralKey bool
+
+	// Transaction PIN settings
+	PinMaxAttempts  int
+	PinLockDuration time.Duration
+
+	// Partner API settings
+	PartnerAuthEnabled     bool
+	PartnerSignatureWindow time.Duration
+
+	// Account status settings
+	FrozenAcceptsCredits bool
+
+	// Account closure settings
+	IdentityReusePolicy   string
+	IdentityReuseCooldown time.Duration
+
+	// Fund hold settings
+	HoldDefaultTTL    time.Duration
+	HoldMaxTTL        time.Duration
+	HoldSweepInterval time.Duration
+	HoldSweepBatch    int
+
+	// Withdrawal limit settings
+	WithdrawalLimitsFile string
+
+	// Interest settings
+	InterestRatesFile string
+
+	// Fee settings
+	FeeRulesFile string
+
+	// Maker-checker settings; a zero threshold disables approvals
+	ApprovalThreshold     float64
+	ApprovalTTL           time.Duration
+	ApprovalSweepInterval time.Duration
+	ApprovalSweepBatch    int
+
+	// Reconciliation settings; a zero interval disables the scheduled job
+	ReconcileInterval     time.Duration
+	ReconcileChunkSize    int
+	ReconcileFreeze       bool
+	ReconcileReportDir    string
+	ReconcileReportFormat string
+
+	// Ledger chain settings
+	LedgerSigningKeyPath     string
+	LedgerPublicKeyPath      string
+	LedgerCheckpointInterval time.Duration
+
+	// Outbox settings; no publisher leaves events in the outbox
+	OutboxPublisher     string
+	OutboxTarget        string
+	OutboxRelayInterval time.Duration
+	OutboxBatchSize     int
+
+	// Balance stream settings
+	BalanceStreamHeartbeat time.Duration
+	BalanceStreamBuffer    int
+
+	// Webhook settings
+	WebhookMaxAttempts      int
+	WebhookRetryBase        time.Duration
+	WebhookMaxRetryDelay    time.Duration
+	WebhookTimeout          time.Duration
+	WebhookDispatchInterval time.Duration
+	WebhookBatchSize        int
+
+	// Deprecation of the unversioned routes, replaced by /v1
+	LegacyDeprecatedAt time.Time
+	LegacySunset       time.Time
+
+	// Rate limit settings, each policy written as "requests/window"
+	RateLimitEnabled      bool
+	RateLimitRegister     ratelimit.Policy
+	RateLimitLogin        ratelimit.Policy
+	RateLimitLoginAccount ratelimit.Policy
+	RateLimitBalance      ratelimit.Policy
+	RateLimitWithdraw     ratelimit.Policy
+
+	// Proxies whose X-Forwarded-For is trusted for the client IP; without
+	// any the IP is the address of the connection
+	TrustedProxies []*net.IPNet
 }
 
 // LoadConfig loads configuration from environment variables and command line arguments
@@ -57,6 +158,266 @@ func NewConfig() (*Config, error) {
 		// Use logs directory in the same directory as the executable
 		cfg.LogFilePath = filepath.Join(filepath.Dir(execPath), "logs", "application.log")
 	}
+
+	// Auth settings from environment variables
+	cfg.JWTIssuer = getEnv("JWT_ISSUER", "service-account")
+	cfg.JWTPrivateKeyPath = getEnv("JWT_PRIVATE_KEY_PATH", "")
+	cfg.JWTPublicKeyPath = getEnv("JWT_PUBLIC_KEY_PATH", "")
+	cfg.JWTAllowEphemeralKey = getEnv("JWT_ALLOW_EPHEMERAL_KEY", "false") == "true"
+
+	var err error
+	cfg.AccessTokenTTL, err = time.ParseDuration(getEnv("JWT_ACCESS_TTL", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_ACCESS_TTL: %v", err)
+	}
+	cfg.RefreshTokenTTL, err = time.ParseDuration(getEnv("JWT_REFRESH_TTL", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_REFRESH_TTL: %v", err)
+	}
+
+	// Transaction PIN settings from environment variables
+	cfg.PinMaxAttempts, err = strconv.Atoi(getEnv("PIN_MAX_ATTEMPTS", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PIN_MAX_ATTEMPTS: %v", err)
+	}
+	if cfg.PinMaxAttempts <= 0 {
+		return nil, fmt.Errorf("invalid PIN_MAX_ATTEMPTS: must be positive")
+	}
+	cfg.PinLockDuration, err = time.ParseDuration(getEnv("PIN_LOCK_DURATION", "30m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PIN_LOCK_DURATION: %v", err)
+	}
+
+	// Partner API settings from environment variables
+	cfg.PartnerAuthEnabled = getEnv("PARTNER_AUTH_ENABLED", "true") == "true"
+	cfg.PartnerSignatureWindow, err = time.ParseDuration(getEnv("PARTNER_SIGNATURE_WINDOW", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PARTNER_SIGNATURE_WINDOW: %v", err)
+	}
+
+	// Account status settings from environment variables
+	cfg.FrozenAcceptsCredits = getEnv("FROZEN_ACCEPTS_CREDITS", "false") == "true"
+
+	// Account closure settings from environment variables
+	cfg.IdentityReusePolicy = strings.ToLower(getEnv("IDENTITY_REUSE_POLICY", "never"))
+	cfg.IdentityReuseCooldown, err = time.ParseDuration(getEnv("IDENTITY_REUSE_COOLDOWN", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IDENTITY_REUSE_COOLDOWN: %v", err)
+	}
+
+	// Fund hold settings from environment variables
+	cfg.HoldDefaultTTL, err = time.ParseDuration(getEnv("HOLD_DEFAULT_TTL", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HOLD_DEFAULT_TTL: %v", err)
+	}
+	cfg.HoldMaxTTL, err = time.ParseDuration(getEnv("HOLD_MAX_TTL", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HOLD_MAX_TTL: %v", err)
+	}
+	cfg.HoldSweepInterval, err = time.ParseDuration(getEnv("HOLD_SWEEP_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HOLD_SWEEP_INTERVAL: %v", err)
+	}
+	if cfg.HoldSweepInterval <= 0 {
+		return nil, fmt.Errorf("invalid HOLD_SWEEP_INTERVAL: must be positive")
+	}
+	cfg.HoldSweepBatch, err = strconv.Atoi(getEnv("HOLD_SWEEP_BATCH", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HOLD_SWEEP_BATCH: %v", err)
+	}
+	if cfg.HoldSweepBatch <= 0 {
+		return nil, fmt.Errorf("invalid HOLD_SWEEP_BATCH: must be positive")
+	}
+
+	// Withdrawal limit settings from environment variables
+	cfg.WithdrawalLimitsFile = getEnv("WITHDRAWAL_LIMITS_FILE", "")
+
+	// Interest settings from environment variables
+	cfg.InterestRatesFile = getEnv("INTEREST_RATES_FILE", "")
+
+	// Fee settings from environment variables
+	cfg.FeeRulesFile = getEnv("FEE_RULES_FILE", "")
+
+	// Maker-checker settings from environment variables
+	cfg.ApprovalThreshold, err = strconv.ParseFloat(getEnv("APPROVAL_THRESHOLD", "10000000"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPROVAL_THRESHOLD: %v", err)
+	}
+	cfg.ApprovalTTL, err = time.ParseDuration(getEnv("APPROVAL_TTL", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPROVAL_TTL: %v", err)
+	}
+	cfg.ApprovalSweepInterval, err = time.ParseDuration(getEnv("APPROVAL_SWEEP_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPROVAL_SWEEP_INTERVAL: %v", err)
+	}
+	if cfg.ApprovalSweepInterval <= 0 {
+		return nil, fmt.Errorf("invalid APPROVAL_SWEEP_INTERVAL: must be positive")
+	}
+	cfg.ApprovalSweepBatch, err = strconv.Atoi(getEnv("APPROVAL_SWEEP_BATCH", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPROVAL_SWEEP_BATCH: %v", err)
+	}
+	if cfg.ApprovalSweepBatch <= 0 {
+		return nil, fmt.Errorf("invalid APPROVAL_SWEEP_BATCH: must be positive")
+	}
+
+	// Reconciliation settings from environment variables
+	cfg.ReconcileInterval, err = time.ParseDuration(getEnv("RECONCILE_INTERVAL", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECONCILE_INTERVAL: %v", err)
+	}
+	cfg.ReconcileChunkSize, err = strconv.Atoi(getEnv("RECONCILE_CHUNK_SIZE", "500"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECONCILE_CHUNK_SIZE: %v", err)
+	}
+	if cfg.ReconcileChunkSize <= 0 {
+		return nil, fmt.Errorf("invalid RECONCILE_CHUNK_SIZE: must be positive")
+	}
+	cfg.ReconcileFreeze = getEnv("RECONCILE_FREEZE", "false") == "true"
+	cfg.ReconcileReportDir = getEnv("RECONCILE_REPORT_DIR", "reports")
+	cfg.ReconcileReportFormat = getEnv("RECONCILE_REPORT_FORMAT", "json")
+	if cfg.ReconcileReportFormat != "json" && cfg.ReconcileReportFormat != "csv" {
+		return nil, fmt.Errorf("invalid RECONCILE_REPORT_FORMAT: must be json or csv")
+	}
+
+	// Ledger chain settings from environment variables
+	cfg.LedgerSigningKeyPath = getEnv("LEDGER_SIGNING_KEY_PATH", "")
+	cfg.LedgerPublicKeyPath = getEnv("LEDGER_PUBLIC_KEY_PATH", "")
+	cfg.LedgerCheckpointInterval, err = time.ParseDuration(getEnv("LEDGER_CHECKPOINT_INTERVAL", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LEDGER_CHECKPOINT_INTERVAL: %v", err)
+	}
+	if cfg.LedgerCheckpointInterval <= 0 {
+		return nil, fmt.Errorf("invalid LEDGER_CHECKPOINT_INTERVAL: must be positive")
+	}
+
+	// Outbox settings from environment variables
+	cfg.OutboxPublisher = getEnv("OUTBOX_PUBLISHER", "")
+	cfg.OutboxTarget = getEnv("OUTBOX_TARGET", "")
+	cfg.OutboxRelayInterval, err = time.ParseDuration(getEnv("OUTBOX_RELAY_INTERVAL", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_RELAY_INTERVAL: %v", err)
+	}
+	if cfg.OutboxRelayInterval <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX_RELAY_INTERVAL: must be positive")
+	}
+	cfg.OutboxBatchSize, err = strconv.Atoi(getEnv("OUTBOX_BATCH_SIZE", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_BATCH_SIZE: %v", err)
+	}
+	if cfg.OutboxBatchSize <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX_BATCH_SIZE: must be positive")
+	}
+
+	// gRPC settings from environment variables
+	cfg.GRPCPort, err = strconv.Atoi(getEnv("GRPC_PORT", "9090"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GRPC_PORT: %v", err)
+	}
+
+	// Balance stream settings from environment variables
+	cfg.BalanceStreamHeartbeat, err = time.ParseDuration(getEnv("BALANCE_STREAM_HEARTBEAT", "15s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BALANCE_STREAM_HEARTBEAT: %v", err)
+	}
+	if cfg.BalanceStreamHeartbeat <= 0 {
+		return nil, fmt.Errorf("invalid BALANCE_STREAM_HEARTBEAT: must be positive")
+	}
+	cfg.BalanceStreamBuffer, err = strconv.Atoi(getEnv("BALANCE_STREAM_BUFFER", "64"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BALANCE_STREAM_BUFFER: %v", err)
+	}
+	if cfg.BalanceStreamBuffer <= 0 {
+		return nil, fmt.Errorf("invalid BALANCE_STREAM_BUFFER: must be positive")
+	}
+
+	// Webhook settings from environment variables
+	cfg.WebhookMaxAttempts, err = strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %v", err)
+	}
+	if cfg.WebhookMaxAttempts <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: must be positive")
+	}
+	cfg.WebhookRetryBase, err = time.ParseDuration(getEnv("WEBHOOK_RETRY_BASE", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE: %v", err)
+	}
+	if cfg.WebhookRetryBase <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_RETRY_BASE: must be positive")
+	}
+	cfg.WebhookMaxRetryDelay, err = time.ParseDuration(getEnv("WEBHOOK_MAX_RETRY_DELAY", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_RETRY_DELAY: %v", err)
+	}
+	if cfg.WebhookMaxRetryDelay <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_RETRY_DELAY: must be positive")
+	}
+	cfg.WebhookTimeout, err = time.ParseDuration(getEnv("WEBHOOK_TIMEOUT", "10s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT: %v", err)
+	}
+	if cfg.WebhookTimeout <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT: must be positive")
+	}
+	cfg.WebhookDispatchInterval, err = time.ParseDuration(getEnv("WEBHOOK_DISPATCH_INTERVAL", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_DISPATCH_INTERVAL: %v", err)
+	}
+	if cfg.WebhookDispatchInterval <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_DISPATCH_INTERVAL: must be positive")
+	}
+	cfg.WebhookBatchSize, err = strconv.Atoi(getEnv("WEBHOOK_BATCH_SIZE", "50"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_BATCH_SIZE: %v", err)
+	}
+	if cfg.WebhookBatchSize <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_BATCH_SIZE: must be positive")
+	}
+
+	// Legacy route deprecation from environment variables
+	cfg.LegacyDeprecatedAt, err = time.Parse(time.DateOnly, getEnv("LEGACY_API_DEPRECATED_AT", "2026-11-01"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LEGACY_API_DEPRECATED_AT: %v", err)
+	}
+	cfg.LegacySunset, err = time.Parse(time.DateOnly, getEnv("LEGACY_API_SUNSET", "2027-05-01"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LEGACY_API_SUNSET: %v", err)
+	}
+	if !cfg.LegacySunset.After(cfg.LegacyDeprecatedAt) {
+		return nil, fmt.Errorf("invalid LEGACY_API_SUNSET: must be after LEGACY_API_DEPRECATED_AT")
+	}
+
+	// Rate limit settings from environment variables
+	cfg.RateLimitEnabled = getEnv("RATE_LIMIT_ENABLED", "true") == "true"
+	cfg.RateLimitRegister, err = ratelimit.ParsePolicy("register", getEnv("RATE_LIMIT_REGISTER", "5/1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_REGISTER: %v", err)
+	}
+	cfg.RateLimitLogin, err = ratelimit.ParsePolicy("login", getEnv("RATE_LIMIT_LOGIN", "20/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_LOGIN: %v", err)
+	}
+	cfg.RateLimitLoginAccount, err = ratelimit.ParsePolicy("login_account", getEnv("RATE_LIMIT_LOGIN_ACCOUNT", "5/15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_LOGIN_ACCOUNT: %v", err)
+	}
+	cfg.RateLimitBalance, err = ratelimit.ParsePolicy("balance", getEnv("RATE_LIMIT_BALANCE", "30/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BALANCE: %v", err)
+	}
+	cfg.RateLimitWithdraw, err = ratelimit.ParsePolicy("withdraw", getEnv("RATE_LIMIT_WITHDRAW", "10/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_WITHDRAW: %v", err)
+	}
+
+	// Client IP settings from environment variables
+	cfg.TrustedProxies, err = parseNetworks(getEnv("TRUSTED_PROXIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	cfg.DB = newPostgres()
 	return cfg, nil
 }
@@ -67,6 +428,11 @@ func NewConfig() (*Config, error) {
 func (c *Config) GetServerAddress() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
+
+// GetGRPCAddress returns the address the gRPC API listens on
+func (c *Config) GetGRPCAddress() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.GRPCPort)
+}
 func (c *Config) OpenDatabase() error {
 	if c.DBConnection == nil {
 		db, err := c.DB.openPostgres()
@@ -92,7 +458,56 @@ func (c *Config) GetLoggerConfig() logger.Config {
 	}
 }
 
+// GetAuthConfig returns the token signing configuration
+func (c *Config) GetAuthConfig() auth.Config {
+	return auth.Config{
+		Issuer:            c.JWTIssuer,
+		PrivateKeyPath:    c.JWTPrivateKeyPath,
+		PublicKeyPath:     c.JWTPublicKeyPath,
+		AccessTokenTTL:    c.AccessTokenTTL,
+		RefreshTokenTTL:   c.RefreshTokenTTL,
+		AllowEphemeralKey: c.JWTAllowEphemeralKey,
+	}
+}
+
+// GetStatusPolicy returns which account statuses accept debits and credits
+func (c *Config) GetStatusPolicy() models.StatusPolicy {
+	return models.StatusPolicy{
+		FrozenAcceptsCredits: c.FrozenAcceptsCredits,
+	}
+}
+
 // Helper function to get environment variables with fallback
+// parseNetworks parses a comma separated list of CIDR ranges and single IP
+// addresses
+func parseNetworks(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
 func getEnv(key, fallback string) string {
 	value, exists := os.LookupEnv(key)
 	if !exists {
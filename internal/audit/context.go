@@ -0,0 +1,78 @@
+// Package audit carries who is behind a request, and through which channel,
+// from the HTTP layer down to the repository, which records it with every
+// state change in audit_events.
+package audit
+
+import (
+	"context"
+	"strings"
+)
+
+// Channels a state change can come through
+const (
+	ChannelAPI     = "API"
+	ChannelPartner = "PARTNER"
+	ChannelSNAP    = "SNAP"
+	ChannelSystem  = "SYSTEM"
+)
+
+// Actors of requests without an authenticated principal
+const (
+	ActorSystem    = "system"
+	ActorAnonymous = "anonymous"
+)
+
+// Metadata describes the origin of a request
+type Metadata struct {
+	Actor     string
+	Channel   string
+	IP        string
+	RequestID string
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying md
+func NewContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, contextKey{}, md)
+}
+
+// FromContext returns the metadata of ctx. Contexts without any, such as
+// those of the batch jobs and background workers, belong to the system.
+func FromContext(ctx context.Context) Metadata {
+	md, ok := ctx.Value(contextKey{}).(Metadata)
+	if !ok {
+		return Metadata{Actor: ActorSystem, Channel: ChannelSystem}
+	}
+	return md
+}
+
+// WithActor returns ctx with the authenticated actor of the request
+func WithActor(ctx context.Context, actor string) context.Context {
+	md := FromContext(ctx)
+	md.Actor = actor
+	return NewContext(ctx, md)
+}
+
+// WithChannel returns ctx with the channel the request came through
+func WithChannel(ctx context.Context, channel string) context.Context {
+	md := FromContext(ctx)
+	md.Channel = channel
+	return NewContext(ctx, md)
+}
+
+// Actor combines the authenticated actor of ctx with the operator a request
+// names, e.g. "partner:core-banking/teller.sari". Names that already start
+// with the authenticated actor are kept as they are, and system jobs are
+// identified by the name alone.
+func Actor(ctx context.Context, named string) string {
+	actor := FromContext(ctx).Actor
+	switch {
+	case named == "":
+		return actor
+	case actor == ActorSystem, actor == ActorAnonymous, strings.HasPrefix(named, actor):
+		return named
+	default:
+		return actor + "/" + named
+	}
+}
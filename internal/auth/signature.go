@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SigningKey derives the HMAC key of a partner from its secret. The database
+// stores this key unencrypted and it signs requests as well as the secret
+// itself does, so it is a plaintext equivalent of the secret rather than a
+// protective hash and api_clients.signing_key must be guarded like the secret.
+func SigningKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// BodyHash returns the hex-encoded SHA-256 of a request body
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// StringToSign builds the canonical string covered by a partner signature:
+// method, path (with query), body hash, timestamp and nonce separated by newlines
+func StringToSign(method, path, bodyHash, timestamp, nonce string) string {
+	return strings.Join([]string{strings.ToUpper(method), path, bodyHash, timestamp, nonce}, "\n")
+}
+
+// Sign computes the hex-encoded HMAC-SHA256 of stringToSign with the signing key
+func Sign(signingKey, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares a presented signature in constant time
+func VerifySignature(signingKey, stringToSign, signature string) bool {
+	expected := Sign(signingKey, stringToSign)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SnapAccessTokenStringToSign is the string a partner signs with its private
+// key when requesting a SNAP B2B access token: client key and X-TIMESTAMP
+func SnapAccessTokenStringToSign(clientKey, timestamp string) string {
+	return clientKey + "|" + timestamp
+}
+
+// VerifySnapAsymmetric checks a base64 SHA256withRSA signature made with the
+// partner's private key against its registered PEM public key
+func VerifySnapAsymmetric(publicKeyPEM, stringToSign, signature string) error {
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKeyPEM))
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(stringToSign))
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], sig)
+}
+
+// SnapStringToSign builds the SNAP symmetric string to sign for transactional
+// requests: METHOD:PATH:AccessToken:lowercase(hex(sha256(minify(body)))):X-TIMESTAMP
+func SnapStringToSign(method, path, accessToken string, body []byte, timestamp string) (string, error) {
+	minified := &bytes.Buffer{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Compact(minified, body); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(minified.Bytes())
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		accessToken,
+		strings.ToLower(hex.EncodeToString(sum[:])),
+		timestamp,
+	}, ":"), nil
+}
+
+// SignSnapSymmetric returns the base64 HMAC-SHA512 of stringToSign
+func SignSnapSymmetric(signingKey, stringToSign string) string {
+	mac := hmac.New(sha512.New, []byte(signingKey))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySnapSymmetric compares a presented SNAP symmetric signature in constant time
+func VerifySnapSymmetric(signingKey, stringToSign, signature string) bool {
+	expected := SignSnapSymmetric(signingKey, stringToSign)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
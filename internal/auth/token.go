@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token audiences keep customer tokens and partner (SNAP) tokens apart
+const (
+	AudienceCustomer = "customer"
+	AudiencePartner  = "partner"
+)
+
+// Config holds token signing configuration
+type Config struct {
+	Issuer          string
+	PrivateKeyPath  string
+	PublicKeyPath   string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// AllowEphemeralKey lets a manager without a private key path sign with a
+	// key generated at startup, for local development only
+	AllowEphemeralKey bool
+}
+
+// Claims are the JWT claims carried by an access token. The subject is the
+// account number for customer tokens and the client ID for partner tokens.
+type Claims struct {
+	jwt.RegisteredClaims
+}
+
+// TokenManager issues and verifies RS256 access tokens
+type TokenManager struct {
+	cfg        Config
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// NewTokenManager loads the signing keys from the configured PEM files. Without
+// a private key path it fails, unless AllowEphemeralKey lets it generate a key
+// whose tokens do not survive a restart and are not accepted by other replicas.
+func NewTokenManager(cfg Config) (*TokenManager, error) {
+	tm := &TokenManager{cfg: cfg}
+
+	if cfg.PrivateKeyPath == "" {
+		if !cfg.AllowEphemeralKey {
+			return nil, errors.New("no private key path configured")
+		}
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		tm.privateKey = key
+		tm.publicKey = &key.PublicKey
+		return tm, nil
+	}
+
+	privatePEM, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	tm.privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	tm.publicKey = &tm.privateKey.PublicKey
+
+	if cfg.PublicKeyPath != "" {
+		publicPEM, err := os.ReadFile(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+		tm.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+	}
+
+	return tm, nil
+}
+
+// IssueAccessToken signs a short-lived access token for the given account
+func (tm *TokenManager) IssueAccessToken(accountNumber string) (string, time.Time, error) {
+	return tm.issue(accountNumber, AudienceCustomer)
+}
+
+// IssuePartnerToken signs a short-lived access token for a partner client
+func (tm *TokenManager) IssuePartnerToken(clientID string) (string, time.Time, error) {
+	return tm.issue(clientID, AudiencePartner)
+}
+
+func (tm *TokenManager) issue(subject, audience string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(tm.cfg.AccessTokenTTL)
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tm.cfg.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(tm.privateKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken verifies the signature and validity window of a customer access token
+func (tm *TokenManager) ParseAccessToken(tokenString string) (*Claims, error) {
+	return tm.parse(tokenString, AudienceCustomer)
+}
+
+// ParsePartnerToken verifies the signature and validity window of a partner access token
+func (tm *TokenManager) ParsePartnerToken(tokenString string) (*Claims, error) {
+	return tm.parse(tokenString, AudiencePartner)
+}
+
+func (tm *TokenManager) parse(tokenString, audience string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return tm.publicKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(tm.cfg.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+// NewRefreshToken returns a random opaque refresh token together with the
+// hash that should be persisted, and its expiry
+func (tm *TokenManager) NewRefreshToken() (token string, tokenHash string, expiresAt time.Time, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", time.Time{}, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), time.Now().Add(tm.cfg.RefreshTokenTTL), nil
+}
+
+// AccessTokenTTL returns the configured lifetime of access tokens
+func (tm *TokenManager) AccessTokenTTL() time.Duration {
+	return tm.cfg.AccessTokenTTL
+}
+
+// HashRefreshToken returns the hex-encoded SHA-256 of a refresh token
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
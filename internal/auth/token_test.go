@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestManager(t *testing.T, cfg Config) *TokenManager {
+	t.Helper()
+	if cfg.Issuer == "" {
+		cfg.Issuer = "test"
+	}
+	if cfg.AccessTokenTTL == 0 {
+		cfg.AccessTokenTTL = time.Minute
+	}
+	cfg.AllowEphemeralKey = cfg.PrivateKeyPath == ""
+	tm, err := NewTokenManager(cfg)
+	if err != nil {
+		t.Fatalf("token manager: %v", err)
+	}
+	return tm
+}
+
+// writeKeyPair writes a new RSA key pair as PEM files and returns their paths
+func writeKeyPair(t *testing.T) (privatePath, publicPath string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	dir := t.TempDir()
+	privatePath, publicPath = filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	if err := os.WriteFile(privatePath, privatePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, publicPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+func TestAccessTokenIsRS256(t *testing.T) {
+	tm := newTestManager(t, Config{})
+	token, expiresAt, err := tm.IssueAccessToken("1000000001")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if alg := parsed.Header["alg"]; alg != "RS256" {
+		t.Errorf("alg = %v, want RS256", alg)
+	}
+
+	claims, err := tm.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if claims.Subject != "1000000001" || !claims.ExpiresAt.Time.Equal(expiresAt.Truncate(time.Second)) {
+		t.Errorf("claims = subject %q expiring %v, want 1000000001 expiring %v", claims.Subject, claims.ExpiresAt, expiresAt)
+	}
+}
+
+func TestParseAccessTokenRejectsForgedTokens(t *testing.T) {
+	privatePath, publicPath := writeKeyPair(t)
+	tm := newTestManager(t, Config{PrivateKeyPath: privatePath, PublicKeyPath: publicPath})
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.RegisteredClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(method, Claims{RegisteredClaims: claims}).SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    "test",
+			Subject:   "1000000001",
+			Audience:  jwt.ClaimStrings{AudienceCustomer},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		}
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePEM, err := os.ReadFile(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, wrongIssuer, noExpiry, noSubject := valid(), valid(), valid(), valid()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer.Issuer = "someone-else"
+	noExpiry.ExpiresAt = nil
+	noSubject.Subject = ""
+	partnerToken, _, err := tm.IssuePartnerToken("partner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"signed with another key", sign(jwt.SigningMethodRS256, otherKey, valid())},
+		{"HS256 keyed with the public key", sign(jwt.SigningMethodHS256, publicPEM, valid())},
+		{"unsigned", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid())},
+		{"expired", sign(jwt.SigningMethodRS256, ownKey, expired)},
+		{"wrong issuer", sign(jwt.SigningMethodRS256, ownKey, wrongIssuer)},
+		{"no expiry", sign(jwt.SigningMethodRS256, ownKey, noExpiry)},
+		{"no subject", sign(jwt.SigningMethodRS256, ownKey, noSubject)},
+		{"partner audience", partnerToken},
+	}
+	for _, tt := range tests {
+		if _, err := tm.ParseAccessToken(tt.token); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+	if _, err := tm.ParseAccessToken(sign(jwt.SigningMethodRS256, ownKey, valid())); err != nil {
+		t.Errorf("token signed with the configured key: %v", err)
+	}
+}
+
+func TestPublicKeyFileVerifiesTokensOfPrivateKey(t *testing.T) {
+	privatePath, publicPath := writeKeyPair(t)
+	issuer := newTestManager(t, Config{PrivateKeyPath: privatePath})
+	token, _, err := issuer.IssueAccessToken("1000000001")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	verifier := newTestManager(t, Config{PrivateKeyPath: privatePath, PublicKeyPath: publicPath})
+	if _, err := verifier.ParseAccessToken(token); err != nil {
+		t.Errorf("matching public key: %v", err)
+	}
+
+	_, otherPublic := writeKeyPair(t)
+	mismatched := newTestManager(t, Config{PrivateKeyPath: privatePath, PublicKeyPath: otherPublic})
+	if _, err := mismatched.ParseAccessToken(token); err == nil {
+		t.Error("token accepted with a public key of another pair")
+	}
+}
+
+func TestNewTokenManagerRequiresKey(t *testing.T) {
+	if _, err := NewTokenManager(Config{Issuer: "test"}); err == nil {
+		t.Error("manager created without a private key or ephemeral key allowed")
+	}
+}
@@ -0,0 +1,92 @@
+// Package errcode gives the errors returned to API clients a stable code,
+// which clients can match on and package i18n translates into the caller's
+// language.
+package errcode
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Codes of errors without a code of their own, by HTTP status
+const (
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeUnprocessable    = "UNPROCESSABLE"
+	CodeLocked           = "LOCKED"
+	CodeTooManyRequests  = "TOO_MANY_REQUESTS"
+	CodeInternal         = "INTERNAL_ERROR"
+)
+
+// Error is an error with a code. Message is the English text of Error(),
+// kept for logs; clients get the catalog's translation of Code.
+type Error struct {
+	Code    string
+	Message string
+	// Params fill the placeholders of the translated message, such as the
+	// request field an error is about
+	Params map[string]string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// With returns a copy of e with params and message, for errors whose
+// translation names values of the failed request
+func (e *Error) With(message string, params map[string]string) *Error {
+	return &Error{Code: e.Code, Message: message, Params: params}
+}
+
+// New returns an error with code, for declaring sentinel errors
+func New(code, message string) error {
+	return &Error{Code: code, Message: message}
+}
+
+// Invalid returns the error of a request field or parameter that could not
+// be parsed; cause, if any, only goes into the message
+func Invalid(field string, cause error) error {
+	message := "invalid " + field
+	if cause != nil {
+		message += ": " + cause.Error()
+	}
+	return &Error{Code: CodeInvalidParameter, Message: message, Params: map[string]string{"field": field}}
+}
+
+// Of returns the coded error in err's chain
+func Of(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// ForStatus returns the code of an error without one answered with status
+func ForStatus(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusLocked:
+		return CodeLocked
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	default:
+		if status >= http.StatusInternalServerError {
+			return CodeInternal
+		}
+		return CodeInvalidRequest
+	}
+}
@@ -0,0 +1,76 @@
+// Package events defines the domain events other services consume, their
+// versioned schemas and the publishers that deliver them. Events are written
+// to the outbox in the transaction of the change they describe and relayed to
+// a publisher afterwards, at least once.
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types
+const (
+	AccountCreated       = "AccountCreated"
+	AccountStatusChanged = "AccountStatusChanged"
+	Deposited            = "Deposited"
+	Withdrawn            = "Withdrawn"
+	BalanceChanged       = "BalanceChanged"
+)
+
+// Versions holds the current schema version of each event type. A change
+// that is not backwards compatible, such as removing or renaming a field,
+// adds a new payload type and bumps the version here; consumers switch on
+// Envelope.Version.
+var Versions = map[string]int{
+	AccountCreated:       1,
+	AccountStatusChanged: 1,
+	Deposited:            1,
+	Withdrawn:            1,
+	BalanceChanged:       1,
+}
+
+// Envelope is what publishers deliver. ID is unique per event, so consumers
+// can drop the duplicates at-least-once delivery produces.
+type Envelope struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Version     int             `json:"version"`
+	AggregateID string          `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// AccountCreatedV1 is version 1 of the AccountCreated payload
+type AccountCreatedV1 struct {
+	AccountNumber string    `json:"account_number"`
+	Name          string    `json:"name"`
+	Product       string    `json:"product"`
+	AccountType   string    `json:"account_type"`
+	KYCTier       string    `json:"kyc_tier"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AccountStatusChangedV1 is version 1 of the AccountStatusChanged payload
+type AccountStatusChangedV1 struct {
+	AccountNumber string    `json:"account_number"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	Reason        string    `json:"reason"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+// BalanceChangedV1 is version 1 of the Deposited, Withdrawn and
+// BalanceChanged payloads. BalanceChanged is published for every ledger
+// entry; Type and Direction tell which kind it was.
+type BalanceChangedV1 struct {
+	AccountNumber string    `json:"account_number"`
+	TransactionID int64     `json:"transaction_id"`
+	Reference     string    `json:"reference"`
+	Type          string    `json:"type"`
+	Direction     string    `json:"direction"`
+	Amount        float64   `json:"amount"`
+	BalanceAfter  float64   `json:"balance_after"`
+	Description   string    `json:"description,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
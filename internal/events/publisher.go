@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Publisher delivers events to consumers. Publish returns nil only once the
+// event was accepted; the relay retries anything else later.
+type Publisher interface {
+	Publish(ctx context.Context, event *Envelope) error
+	Close() error
+}
+
+// Factory creates a publisher for a target, such as a file path or URL
+type Factory func(target string) (Publisher, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register makes a publisher available under name. Brokers such as NATS or
+// Kafka plug in by registering a factory.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewPublisher creates the publisher registered under name
+func NewPublisher(name, target string) (Publisher, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown publisher %q, registered: %v", name, Publishers())
+	}
+	return factory(target)
+}
+
+// Publishers lists the registered publisher names
+func Publishers() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("stdout", NewStdoutPublisher)
+	Register("file", NewFilePublisher)
+	Register("webhook", NewWebhookPublisher)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers sent with every webhook delivery
+const (
+	HeaderEventID      = "X-Event-ID"
+	HeaderEventType    = "X-Event-Type"
+	HeaderEventVersion = "X-Event-Version"
+)
+
+// webhookTimeout bounds a single delivery
+const webhookTimeout = 10 * time.Second
+
+// WebhookPublisher POSTs each event as JSON to a URL. Any 2xx response
+// accepts the event.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher posts events to the URL in target
+func NewWebhookPublisher(target string) (Publisher, error) {
+	if target == "" {
+		return nil, fmt.Errorf("webhook publisher needs a URL")
+	}
+	return &WebhookPublisher{
+		url:    target,
+		client: &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event *Envelope) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, event.ID)
+	req.Header.Set(HeaderEventType, event.Type)
+	req.Header.Set(HeaderEventVersion, strconv.Itoa(event.Version))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *WebhookPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterPublisher writes each event as a line of JSON. It backs the stdout
+// and file publishers, which suit local development and tests.
+type WriterPublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewWriterPublisher writes events to w
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewStdoutPublisher writes events to standard output; target is ignored
+func NewStdoutPublisher(string) (Publisher, error) {
+	return NewWriterPublisher(os.Stdout), nil
+}
+
+// NewFilePublisher appends events to the file at target
+func NewFilePublisher(target string) (Publisher, error) {
+	if target == "" {
+		return nil, fmt.Errorf("file publisher needs a file path")
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterPublisher{w: file, closer: file}, nil
+}
+
+func (p *WriterPublisher) Publish(_ context.Context, event *Envelope) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+func (p *WriterPublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
@@ -0,0 +1,140 @@
+package grpcapi
+
+import (
+	"context"
+	"time"
+
+	accountv1 "github.com/alfaa19/service-account-test/api/account/v1"
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type accountServer struct {
+	accountv1.UnimplementedAccountServiceServer
+	service   service.Service
+	approvals service.ApprovalService
+	log       *logger.CustomLogger
+}
+
+func (s *accountServer) CreateAccount(ctx context.Context, req *accountv1.CreateAccountRequest) (*accountv1.CreateAccountResponse, error) {
+	account, err := s.service.CreateAccount(ctx, &dto.AccountRegistration{
+		Nama:     req.GetName(),
+		NIK:      req.GetNik(),
+		NoHP:     req.GetPhoneNumber(),
+		Password: req.GetPassword(),
+		Pin:      req.GetPin(),
+	})
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &accountv1.CreateAccountResponse{AccountNumber: account.AccountNumber}, nil
+}
+
+func (s *accountServer) GetBalance(ctx context.Context, req *accountv1.GetBalanceRequest) (*accountv1.Balance, error) {
+	return s.balance(ctx, req.GetAccountNumber())
+}
+
+func (s *accountServer) Deposit(ctx context.Context, req *accountv1.DepositRequest) (*accountv1.Balance, error) {
+	if err := s.service.UpdateBalanceDeposit(ctx, req.GetAccountNumber(), req.GetAmount()); err != nil {
+		return nil, statusOf(err)
+	}
+	return s.balance(ctx, req.GetAccountNumber())
+}
+
+// Withdraw goes through maker-checker approval like the REST withdrawal, so
+// high-value withdrawals cannot skip it over gRPC
+func (s *accountServer) Withdraw(ctx context.Context, req *accountv1.WithdrawRequest) (*accountv1.WithdrawResponse, error) {
+	pending, err := s.approvals.Withdraw(ctx, req.GetAccountNumber(), req.GetAmount(), req.GetPin(), makerOf(ctx, req.GetOperator()))
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	if pending != nil {
+		return &accountv1.WithdrawResponse{Result: &accountv1.WithdrawResponse_PendingApproval{
+			PendingApproval: &accountv1.PendingApproval{
+				Id:        pending.ID,
+				Status:    pending.Status,
+				ExpiresAt: timestamppb.New(pending.ExpiresAt),
+			},
+		}}, nil
+	}
+
+	balance, err := s.balance(ctx, req.GetAccountNumber())
+	if err != nil {
+		return nil, err
+	}
+	return &accountv1.WithdrawResponse{Result: &accountv1.WithdrawResponse_Balance{Balance: balance}}, nil
+}
+
+func (s *accountServer) ListTransactions(ctx context.Context, req *accountv1.ListTransactionsRequest) (*accountv1.ListTransactionsResponse, error) {
+	to := time.Now()
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime()
+	}
+	from := to.Add(-defaultHistoryAge)
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime()
+	}
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	offset := int(req.GetOffset())
+	if offset < 0 {
+		offset = 0
+	}
+
+	transactions, err := s.service.GetTransactionHistory(ctx, req.GetAccountNumber(), from, to, limit, offset)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+
+	resp := &accountv1.ListTransactionsResponse{Transactions: make([]*accountv1.Transaction, 0, len(transactions))}
+	for i := range transactions {
+		resp.Transactions = append(resp.Transactions, transactionMessage(&transactions[i]))
+	}
+	return resp, nil
+}
+
+func (s *accountServer) balance(ctx context.Context, accountNumber string) (*accountv1.Balance, error) {
+	account, err := s.service.GetAccountByNoRekening(ctx, accountNumber)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &accountv1.Balance{
+		AccountNumber:    account.AccountNumber,
+		Balance:          account.Balance,
+		AvailableBalance: account.AvailableBalance(),
+	}, nil
+}
+
+func transactionMessage(trx *models.Transaction) *accountv1.Transaction {
+	return &accountv1.Transaction{
+		Id:           trx.ID,
+		Reference:    trx.Reference,
+		Type:         trx.Type,
+		Direction:    trx.Direction,
+		Amount:       trx.Amount,
+		BalanceAfter: trx.BalanceAfter,
+		Description:  trx.Description,
+		CreatedAt:    timestamppb.New(trx.CreatedAt),
+	}
+}
+
+// makerOf identifies the maker of a call like actorOf does for REST requests:
+// the authenticated caller, followed by the operator the request names. The
+// operator never replaces the caller, so a request cannot pose as another
+// maker.
+func makerOf(ctx context.Context, operator string) string {
+	actor := audit.FromContext(ctx).Actor
+	if operator == "" {
+		return actor
+	}
+	return actor + "/" + operator
+}
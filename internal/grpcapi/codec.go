@@ -0,0 +1,54 @@
+package grpcapi
+
+import (
+	"sync"
+
+	accountv1 "github.com/alfaa19/service-account-test/api/account/v1"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// accountPackage is the protobuf package of the account service messages
+var accountPackage = accountv1.File_api_account_v1_account_proto.Package()
+
+// rawBodyCodec is the protobuf codec that also keeps the bytes each account
+// service request was decoded from, so partnerSignature can hash the message
+// exactly as the partner sent and signed it rather than a re-encoding of it.
+// The bytes are keyed by the decoded message, which gRPC passes on to the
+// interceptors, and are taken out by the first call to rawBody.
+type rawBodyCodec struct {
+	encoding.CodecV2
+	bodies sync.Map
+}
+
+func newRawBodyCodec() *rawBodyCodec {
+	return &rawBodyCodec{CodecV2: encoding.GetCodecV2("proto")}
+}
+
+func (c *rawBodyCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	// data is freed once Unmarshal returns, so the retained bytes are a copy
+	raw := data.Materialize()
+	if err := c.CodecV2.Unmarshal(mem.BufferSlice{mem.SliceBuffer(raw)}, v); err != nil {
+		return err
+	}
+	if msg, ok := v.(proto.Message); ok && packageOf(msg) == accountPackage {
+		c.bodies.Store(v, raw)
+	}
+	return nil
+}
+
+// rawBody returns and forgets the bytes req was decoded from
+func (c *rawBodyCodec) rawBody(req any) ([]byte, bool) {
+	raw, ok := c.bodies.LoadAndDelete(req)
+	if !ok {
+		return nil, false
+	}
+	return raw.([]byte), true
+}
+
+func packageOf(msg proto.Message) protoreflect.FullName {
+	return msg.ProtoReflect().Descriptor().ParentFile().Package()
+}
@@ -0,0 +1,36 @@
+package grpcapi
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusOf maps a service error to the gRPC status the REST API's
+// errorStatus would answer with
+func statusOf(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, service.ErrInvalidPin):
+		code = codes.Unauthenticated
+	case errors.Is(err, service.ErrPinLocked),
+		errors.Is(err, repository.ErrAccountFrozen),
+		errors.Is(err, repository.ErrAccountDormant),
+		errors.Is(err, repository.ErrAccountClosed):
+		code = codes.PermissionDenied
+	case errors.Is(err, service.ErrLimitExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		code = codes.FailedPrecondition
+	case errors.Is(err, repository.ErrAccountNotFound),
+		errors.Is(err, sql.ErrNoRows):
+		code = codes.NotFound
+	default:
+		code = codes.InvalidArgument
+	}
+	return status.Error(code, err.Error())
+}
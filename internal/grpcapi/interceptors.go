@@ -0,0 +1,237 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	accountv1 "github.com/alfaa19/service-account-test/api/account/v1"
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys of a call; the partner signature keys match the REST headers
+const (
+	metadataRequestID     = "x-request-id"
+	metadataClientID      = "x-client-id"
+	metadataTimestamp     = "x-timestamp"
+	metadataNonce         = "x-nonce"
+	metadataSignature     = "x-signature"
+	metadataAuthorization = "authorization"
+)
+
+// ownerMethods act on the account of the customer whose access token the
+// call carries, like the REST routes behind AccountOwner
+var ownerMethods = map[string]bool{
+	accountv1.AccountService_GetBalance_FullMethodName:       true,
+	accountv1.AccountService_Withdraw_FullMethodName:         true,
+	accountv1.AccountService_ListTransactions_FullMethodName: true,
+}
+
+// requestContext tags the call context with a request ID, taken from the
+// x-request-id metadata or generated, and with the caller's IP, then logs the
+// outcome of the call
+func requestContext(log *logger.CustomLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		requestID := metadataValue(ctx, metadataRequestID)
+		if requestID == "" {
+			requestID = models.NewReference()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, requestID))
+
+		ctx = logger.ContextWithRequestID(ctx, requestID)
+		ctx = audit.NewContext(ctx, audit.Metadata{
+			Actor:     audit.ActorAnonymous,
+			Channel:   audit.ChannelAPI,
+			IP:        peerIP(ctx),
+			RequestID: requestID,
+		})
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		fields := map[string]interface{}{
+			"method":      info.FullMethod,
+			"code":        status.Code(err).String(),
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			log.LogOperation(ctx, "GRPC", "error", fields)
+		} else {
+			log.LogOperation(ctx, "GRPC", "success", fields)
+		}
+		return resp, err
+	}
+}
+
+// partnerSignature verifies the partner signature of a call. It is the one
+// of REST requests with method POST, the full gRPC method as path and route,
+// and the hash of the request message bytes as sent, which codec kept, as
+// body hash. Health checks are not signed.
+func partnerSignature(partners service.PartnerService, codec *rawBodyCodec) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		body, ok := codec.rawBody(req)
+		if strings.HasPrefix(info.FullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+		if !ok {
+			return nil, status.Error(codes.Internal, "request bytes not retained")
+		}
+
+		client, err := partners.Authenticate(ctx, &service.PartnerRequest{
+			ClientID:  metadataValue(ctx, metadataClientID),
+			Method:    "POST",
+			Route:     info.FullMethod,
+			Path:      info.FullMethod,
+			BodyHash:  auth.BodyHash(body),
+			Timestamp: metadataValue(ctx, metadataTimestamp),
+			Nonce:     metadataValue(ctx, metadataNonce),
+			Signature: metadataValue(ctx, metadataSignature),
+			IP:        peerIP(ctx),
+		})
+		if err != nil {
+			return nil, status.Error(partnerErrorCode(err), err.Error())
+		}
+
+		ctx = logger.ContextWithClientID(ctx, client.ClientID)
+		ctx = audit.WithChannel(audit.WithActor(ctx, "partner:"+client.ClientID), audit.ChannelPartner)
+		return handler(ctx, req)
+	}
+}
+
+func partnerErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, service.ErrRouteNotAllowed), errors.Is(err, service.ErrIPNotAllowed):
+		return codes.PermissionDenied
+	case errors.Is(err, service.ErrUnknownClient),
+		errors.Is(err, service.ErrInvalidSignature),
+		errors.Is(err, service.ErrStaleTimestamp),
+		errors.Is(err, service.ErrNonceReused),
+		errors.Is(err, service.ErrMissingNonce),
+		errors.Is(err, service.ErrMalformedTimestamp):
+		return codes.Unauthenticated
+	default:
+		return codes.Internal
+	}
+}
+
+// accountOwner requires the calls of ownerMethods to carry a customer access
+// token, as "authorization: Bearer <token>" metadata, for the account they
+// name
+func accountOwner(tokens *auth.TokenManager, log *logger.CustomLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !ownerMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		tokenString, found := strings.CutPrefix(metadataValue(ctx, metadataAuthorization), "Bearer ")
+		if !found || tokenString == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		claims, err := tokens.ParseAccessToken(tokenString)
+		if err != nil {
+			log.LogOperation(ctx, "JWTAuth", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+
+		account, ok := req.(interface{ GetAccountNumber() string })
+		if !ok || account.GetAccountNumber() != claims.Subject {
+			log.LogOperation(ctx, "AccountOwner", "error", map[string]interface{}{
+				"error":  "token subject does not match account",
+				"method": info.FullMethod,
+			})
+			return nil, status.Error(codes.PermissionDenied, "account does not belong to token holder")
+		}
+
+		return handler(audit.WithActor(ctx, "customer:"+claims.Subject), req)
+	}
+}
+
+// RateLimits holds the rate limit policies of the RPCs that have a limited
+// REST equivalent; a nil Store disables rate limiting. Sharing the Store of
+// the REST routes makes both count against the same limits.
+type RateLimits struct {
+	Store    ratelimit.Store
+	Register ratelimit.Policy
+	Balance  ratelimit.Policy
+	Withdraw ratelimit.Policy
+}
+
+// rateLimit rejects calls over their method's policy with RESOURCE_EXHAUSTED
+// and a retry-after header, keyed like the REST routes: CreateAccount by
+// caller IP, GetBalance and Withdraw by account. Store errors fail closed
+// with UNAVAILABLE.
+func rateLimit(limits RateLimits, log *logger.CustomLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var policy ratelimit.Policy
+		var key string
+		switch info.FullMethod {
+		case accountv1.AccountService_CreateAccount_FullMethodName:
+			policy, key = limits.Register, peerIP(ctx)
+		case accountv1.AccountService_GetBalance_FullMethodName:
+			policy, key = limits.Balance, req.(*accountv1.GetBalanceRequest).GetAccountNumber()
+		case accountv1.AccountService_Withdraw_FullMethodName:
+			policy, key = limits.Withdraw, req.(*accountv1.WithdrawRequest).GetAccountNumber()
+		default:
+			return handler(ctx, req)
+		}
+
+		allowed, retryAfter, err := limits.Store.Allow(ctx, policy, key)
+		if err != nil {
+			log.LogOperation(ctx, "RateLimit", "error", map[string]interface{}{
+				"error":  err.Error(),
+				"policy": policy.Name,
+			})
+			return nil, status.Error(codes.Unavailable, "rate limit cannot be checked")
+		}
+		if !allowed {
+			log.LogOperation(ctx, "RateLimit", "warning", map[string]interface{}{
+				"policy": policy.Name,
+				"ip":     peerIP(ctx),
+			})
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+			return nil, status.Error(codes.ResourceExhausted, "too many requests")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
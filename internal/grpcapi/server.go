@@ -0,0 +1,103 @@
+// Package grpcapi serves the account operations over gRPC for internal
+// services. It calls the same services as the REST handlers, and its
+// interceptors apply the same request context, partner signature, customer
+// token and rate limit checks as the Echo middleware.
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"time"
+
+	accountv1 "github.com/alfaa19/service-account-test/api/account/v1"
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Dependencies groups the collaborators of the gRPC server
+type Dependencies struct {
+	Service   service.Service
+	Approvals service.ApprovalService
+	Tokens    *auth.TokenManager
+	Log       *logger.CustomLogger
+
+	// Partners verifies the partner signature of every call when
+	// PartnerAuthEnabled is set
+	Partners           service.PartnerService
+	PartnerAuthEnabled bool
+
+	RateLimits RateLimits
+}
+
+// Server is the gRPC server with the account service, health checking and
+// reflection
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+func NewServer(deps Dependencies) *Server {
+	var options []grpc.ServerOption
+	interceptors := []grpc.UnaryServerInterceptor{requestContext(deps.Log)}
+	if deps.PartnerAuthEnabled {
+		codec := newRawBodyCodec()
+		options = append(options, grpc.ForceServerCodecV2(codec))
+		interceptors = append(interceptors, partnerSignature(deps.Partners, codec))
+	}
+	interceptors = append(interceptors, accountOwner(deps.Tokens, deps.Log))
+	if deps.RateLimits.Store != nil {
+		interceptors = append(interceptors, rateLimit(deps.RateLimits, deps.Log))
+	}
+
+	options = append(options, grpc.ChainUnaryInterceptor(interceptors...))
+	srv := grpc.NewServer(options...)
+	accountv1.RegisterAccountServiceServer(srv, &accountServer{
+		service:   deps.Service,
+		approvals: deps.Approvals,
+		log:       deps.Log,
+	})
+
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus(accountv1.AccountService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	reflection.Register(srv)
+
+	return &Server{grpc: srv, health: healthSrv}
+}
+
+// Serve accepts connections on lis until the server is shut down
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown reports the server as not serving, then waits for in-flight calls
+// to finish. Calls still running when ctx is done are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+// Defaults of ListTransactions
+const (
+	defaultHistoryAge   = 30 * 24 * time.Hour
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
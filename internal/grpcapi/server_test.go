@@ -0,0 +1,336 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	accountv1 "github.com/alfaa19/service-account-test/api/account/v1"
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	testAccount    = "1000000001"
+	testClientID   = "partner-1"
+	testSigningKey = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+)
+
+// accountService serves accounts with a fixed balance that deposits do not
+// change
+type accountService struct {
+	service.Service
+}
+
+func (accountService) GetAccountByNoRekening(ctx context.Context, noRekening string) (*models.Account, error) {
+	return &models.Account{AccountNumber: noRekening, Balance: 500000, Status: models.StatusActive}, nil
+}
+
+func (accountService) UpdateBalanceDeposit(ctx context.Context, accountNumber string, amount float64) error {
+	return nil
+}
+
+// approvalService records the maker of each withdrawal and executes it
+// without approval
+type approvalService struct {
+	service.ApprovalService
+	makers []string
+}
+
+func (s *approvalService) Withdraw(ctx context.Context, accountNumber string, amount float64, pin, maker string) (*models.PendingOperation, error) {
+	s.makers = append(s.makers, maker)
+	return nil, nil
+}
+
+// partnerRepo knows one partner client and the nonces it used
+type partnerRepo struct {
+	repository.Repository
+	nonces map[string]bool
+}
+
+func (r *partnerRepo) GetAPIClient(ctx context.Context, clientID string) (*models.APIClient, error) {
+	return &models.APIClient{
+		ClientID:      testClientID,
+		SigningKey:    testSigningKey,
+		AllowedRoutes: []string{service.AllRoutes},
+		Active:        true,
+	}, nil
+}
+
+func (r *partnerRepo) UseNonce(ctx context.Context, clientID, nonce string, expiresAt time.Time) (bool, error) {
+	if r.nonces[nonce] {
+		return false, nil
+	}
+	r.nonces[nonce] = true
+	return true, nil
+}
+
+func testLogger(t *testing.T) *logger.CustomLogger {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testTokens(t *testing.T) *auth.TokenManager {
+	t.Helper()
+	tokens, err := auth.NewTokenManager(auth.Config{
+		Issuer:            "test",
+		AccessTokenTTL:    time.Minute,
+		RefreshTokenTTL:   time.Hour,
+		AllowEphemeralKey: true,
+	})
+	if err != nil {
+		t.Fatalf("token manager: %v", err)
+	}
+	return tokens
+}
+
+// testDependencies returns the dependencies of a server without partner
+// authentication or rate limits
+func testDependencies(t *testing.T) Dependencies {
+	t.Helper()
+	log := testLogger(t)
+	return Dependencies{
+		Service:   accountService{},
+		Approvals: &approvalService{},
+		Tokens:    testTokens(t),
+		Log:       log,
+		Partners:  service.NewPartnerService(&partnerRepo{nonces: map[string]bool{}}, nil, time.Minute, log),
+	}
+}
+
+// dial serves deps over an in-memory listener and connects to it
+func dial(t *testing.T, deps Dependencies, options ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	_, conn := serve(t, deps, options...)
+	return conn
+}
+
+func serve(t *testing.T, deps Dependencies, options ...grpc.DialOption) (*Server, *grpc.ClientConn) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(deps)
+	go srv.Serve(lis)
+	t.Cleanup(srv.grpc.Stop)
+
+	options = append(options,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", options...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+func withToken(t *testing.T, ctx context.Context, tokens *auth.TokenManager, accountNumber string) context.Context {
+	t.Helper()
+	token, _, err := tokens.IssueAccessToken(accountNumber)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, metadataAuthorization, "Bearer "+token)
+}
+
+func wantCode(t *testing.T, what string, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("%s: code = %v (%v), want %v", what, got, err, want)
+	}
+}
+
+// reversedFields encodes messages with their fields in reverse field number
+// order. It is valid protobuf, but not the encoding the server would produce
+// when marshalling the decoded message again.
+func reversedFields(msg proto.Message) ([]byte, error) {
+	encoded, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var fields [][]byte
+	for len(encoded) > 0 {
+		_, _, n := protowire.ConsumeField(encoded)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		fields = append(fields, encoded[:n])
+		encoded = encoded[n:]
+	}
+	slices.Reverse(fields)
+	return slices.Concat(fields...), nil
+}
+
+// reversingCodec sends requests encoded by reversedFields
+type reversingCodec struct {
+	encoding.CodecV2
+}
+
+func (c reversingCodec) Marshal(v any) (mem.BufferSlice, error) {
+	body, err := reversedFields(v.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	return mem.BufferSlice{mem.SliceBuffer(body)}, nil
+}
+
+// signWith adds the partner signature over the request bytes that encode
+// produces to every call
+func signWith(encode func(proto.Message) ([]byte, error)) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		body, err := encode(req.(proto.Message))
+		if err != nil {
+			return err
+		}
+		nonce := models.NewReference()
+		timestamp := time.Now().UTC().Format(time.RFC3339)
+		stringToSign := auth.StringToSign("POST", method, auth.BodyHash(body), timestamp, nonce)
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			metadataClientID, testClientID,
+			metadataTimestamp, timestamp,
+			metadataNonce, nonce,
+			metadataSignature, auth.Sign(testSigningKey, stringToSign),
+		)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func TestPartnerSignatureCoversSentBytes(t *testing.T) {
+	deps := testDependencies(t)
+	deps.PartnerAuthEnabled = true
+	codec := grpc.WithDefaultCallOptions(grpc.ForceCodecV2(reversingCodec{encoding.GetCodecV2("proto")}))
+	req := &accountv1.WithdrawRequest{AccountNumber: testAccount, Amount: 50000, Pin: "123456", Operator: "teller-7"}
+
+	signed := accountv1.NewAccountServiceClient(dial(t, deps, codec, grpc.WithUnaryInterceptor(signWith(reversedFields))))
+	ctx := withToken(t, context.Background(), deps.Tokens, testAccount)
+	if _, err := signed.Withdraw(ctx, req); err != nil {
+		t.Fatalf("withdraw signed over the bytes sent: %v", err)
+	}
+
+	// a signature over the canonical re-encoding is not one over the bytes sent
+	canonical := func(msg proto.Message) ([]byte, error) {
+		return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	}
+	resigned := accountv1.NewAccountServiceClient(dial(t, deps, codec, grpc.WithUnaryInterceptor(signWith(canonical))))
+	_, err := resigned.Withdraw(ctx, req)
+	wantCode(t, "withdraw signed over other bytes", err, codes.Unauthenticated)
+	if status.Convert(err).Message() != service.ErrInvalidSignature.Error() {
+		t.Errorf("withdraw signed over other bytes: %v, want %v", err, service.ErrInvalidSignature)
+	}
+
+	unsigned := dial(t, deps)
+	_, err = accountv1.NewAccountServiceClient(unsigned).GetBalance(ctx, &accountv1.GetBalanceRequest{AccountNumber: testAccount})
+	wantCode(t, "unsigned call", err, codes.Unauthenticated)
+
+	health, err := healthpb.NewHealthClient(unsigned).Check(context.Background(),
+		&healthpb.HealthCheckRequest{Service: accountv1.AccountService_ServiceDesc.ServiceName})
+	if err != nil || health.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("unsigned health check = %v, %v, want SERVING", health.GetStatus(), err)
+	}
+}
+
+func TestAccountOwnerRequiresTokenOfAccount(t *testing.T) {
+	deps := testDependencies(t)
+	client := accountv1.NewAccountServiceClient(dial(t, deps))
+	req := &accountv1.GetBalanceRequest{AccountNumber: testAccount}
+
+	_, err := client.GetBalance(context.Background(), req)
+	wantCode(t, "no token", err, codes.Unauthenticated)
+
+	_, err = client.GetBalance(metadata.AppendToOutgoingContext(context.Background(), metadataAuthorization, "Bearer garbage"), req)
+	wantCode(t, "invalid token", err, codes.Unauthenticated)
+
+	_, err = client.GetBalance(withToken(t, context.Background(), deps.Tokens, "1000000002"), req)
+	wantCode(t, "token of another account", err, codes.PermissionDenied)
+
+	balance, err := client.GetBalance(withToken(t, context.Background(), deps.Tokens, testAccount), req)
+	if err != nil || balance.GetBalance() != 500000 {
+		t.Errorf("owner's balance = %v, %v, want 500000", balance.GetBalance(), err)
+	}
+}
+
+func TestRateLimitExhaustsLikeREST(t *testing.T) {
+	deps := testDependencies(t)
+	deps.RateLimits = RateLimits{
+		Store:   ratelimit.NewMemoryStore(),
+		Balance: ratelimit.Policy{Name: "balance", Requests: 1, Window: time.Minute},
+	}
+	client := accountv1.NewAccountServiceClient(dial(t, deps))
+	ctx := withToken(t, context.Background(), deps.Tokens, testAccount)
+	req := &accountv1.GetBalanceRequest{AccountNumber: testAccount}
+
+	if _, err := client.GetBalance(ctx, req); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	var header metadata.MD
+	_, err := client.GetBalance(ctx, req, grpc.Header(&header))
+	wantCode(t, "second call", err, codes.ResourceExhausted)
+	if retryAfter := header.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] == "0" {
+		t.Errorf("retry-after = %v, want the seconds left in the window", retryAfter)
+	}
+
+	// deposits have no limit
+	for i := 0; i < 3; i++ {
+		if _, err := client.Deposit(context.Background(), &accountv1.DepositRequest{AccountNumber: testAccount, Amount: 1}); status.Code(err) == codes.ResourceExhausted {
+			t.Fatalf("deposit %d was rate limited", i+1)
+		}
+	}
+}
+
+func TestWithdrawMakerIsTokenHolder(t *testing.T) {
+	deps := testDependencies(t)
+	approvals := deps.Approvals.(*approvalService)
+	client := accountv1.NewAccountServiceClient(dial(t, deps))
+	ctx := withToken(t, context.Background(), deps.Tokens, testAccount)
+
+	for _, operator := range []string{"", "customer:1000000002"} {
+		req := &accountv1.WithdrawRequest{AccountNumber: testAccount, Amount: 50000, Pin: "123456", Operator: operator}
+		if _, err := client.Withdraw(ctx, req); err != nil {
+			t.Fatalf("withdraw with operator %q: %v", operator, err)
+		}
+	}
+	want := []string{"customer:" + testAccount, "customer:" + testAccount + "/customer:1000000002"}
+	if !slices.Equal(approvals.makers, want) {
+		t.Errorf("makers = %v, want %v", approvals.makers, want)
+	}
+}
+
+func TestHealthReportsServingUntilShutdown(t *testing.T) {
+	srv, conn := serve(t, testDependencies(t))
+	health := healthpb.NewHealthClient(conn)
+	req := &healthpb.HealthCheckRequest{Service: accountv1.AccountService_ServiceDesc.ServiceName}
+
+	resp, err := health.Check(context.Background(), req)
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health = %v, %v, want SERVING", resp.GetStatus(), err)
+	}
+
+	srv.health.Shutdown()
+	resp, err = health.Check(context.Background(), req)
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("health after shutdown = %v, %v, want NOT_SERVING", resp.GetStatus(), err)
+	}
+}
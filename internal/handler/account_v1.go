@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+type accountV1Handler struct {
+	account *accountHandler
+}
+
+// AccountV1Handler serves the customer account routes of /v1, where the
+// account is a path resource and amounts and balances have their own fields
+type AccountV1Handler interface {
+	CreateAccount(ctx echo.Context) error
+	GetBalance(ctx echo.Context) error
+	Withdraw(ctx echo.Context) error
+	Deposit(ctx echo.Context) error
+	UpdatePin(ctx echo.Context) error
+	CloseAccount(ctx echo.Context) error
+	GetStatement(ctx echo.Context) error
+}
+
+func NewAccountV1Handler(service service.Service, approvals service.ApprovalService, log *logger.CustomLogger) *accountV1Handler {
+	return &accountV1Handler{
+		account: NewAccountHandler(service, approvals, log),
+	}
+}
+
+// CreateAccount opens an account and points Location at it
+func (h *accountV1Handler) CreateAccount(c echo.Context) error {
+	req := &dto.AccountRegistration{}
+	if err := c.Bind(req); err != nil {
+		h.account.log.Error("Failed to bind account: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	account, err := h.account.service.CreateAccount(c.Request().Context(), req)
+	if err != nil {
+		h.account.log.Error("Failed to create account: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, "/v1/accounts/"+account.AccountNumber)
+	return c.JSON(http.StatusCreated, dto.AccountResponse{NoRekening: account.AccountNumber})
+}
+
+// GetBalance serves the balance of an account, or with ?tanggal= its ledger
+// balance at the end of that business date
+func (h *accountV1Handler) GetBalance(c echo.Context) error {
+	return h.account.balance(c, c.Param("noRekening"), c.QueryParam("tanggal"))
+}
+
+func (h *accountV1Handler) Withdraw(c echo.Context) error {
+	req := &dto.WithdrawalRequest{}
+	if err := c.Bind(req); err != nil {
+		h.account.log.Error("Failed to bind withdraw request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	noRekening := c.Param("noRekening")
+	pending, err := h.account.withdraw(c, noRekening, req.Jumlah, req.Pin, req.Aktor)
+	if err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	if pending != nil {
+		return c.JSON(http.StatusAccepted, pendingOperationResponse(pending))
+	}
+
+	account, err := h.account.currentAccount(c, noRekening)
+	if err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusOK, dto.MutationResponse{
+		NoRekening:    noRekening,
+		Jumlah:        req.Jumlah,
+		Saldo:         account.Balance,
+		SaldoTersedia: account.AvailableBalance(),
+	})
+}
+
+func (h *accountV1Handler) Deposit(c echo.Context) error {
+	req := &dto.DepositRequest{}
+	if err := c.Bind(req); err != nil {
+		h.account.log.Error("Failed to bind deposit request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	noRekening := c.Param("noRekening")
+	if err := h.account.deposit(c, noRekening, req.Jumlah); err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	account, err := h.account.currentAccount(c, noRekening)
+	if err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusOK, dto.MutationResponse{
+		NoRekening:    noRekening,
+		Jumlah:        req.Jumlah,
+		Saldo:         account.Balance,
+		SaldoTersedia: account.AvailableBalance(),
+	})
+}
+
+func (h *accountV1Handler) UpdatePin(c echo.Context) error {
+	req := &dto.UpdatePinRequest{}
+	if err := c.Bind(req); err != nil {
+		h.account.log.Error("Failed to bind change PIN request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	return h.account.changePin(c, c.Param("noRekening"), req.PinLama, req.PinBaru)
+}
+
+func (h *accountV1Handler) CloseAccount(c echo.Context) error {
+	req := &dto.AccountClosureRequest{}
+	if err := c.Bind(req); err != nil {
+		h.account.log.Error("Failed to bind close account request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	return h.account.closeAccount(c, c.Param("noRekening"), *req)
+}
+
+// GetStatement serves the rekening koran of the :periode month as
+// ?format=pdf (default) or csv
+func (h *accountV1Handler) GetStatement(c echo.Context) error {
+	return h.account.statement(c, c.Param("noRekening"), c.Param("periode"), c.QueryParam("format"))
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+type approvalHandler struct {
+	approvals service.ApprovalService
+	log       *logger.CustomLogger
+}
+
+type ApprovalHandler interface {
+	ListPending(ctx echo.Context) error
+	Approve(ctx echo.Context) error
+	Reject(ctx echo.Context) error
+}
+
+func NewApprovalHandler(approvals service.ApprovalService, log *logger.CustomLogger) *approvalHandler {
+	return &approvalHandler{
+		approvals: approvals,
+		log:       log,
+	}
+}
+
+func (h *approvalHandler) ListPending(c echo.Context) error {
+	ops, err := h.approvals.ListPending(c.Request().Context())
+	if err != nil {
+		h.log.Error("Failed to list pending operations: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	resp := make([]dto.PendingOperationResponse, 0, len(ops))
+	for i := range ops {
+		resp = append(resp, pendingOperationResponse(&ops[i]))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *approvalHandler) Approve(c echo.Context) error {
+	req := &dto.DecisionRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind approval request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if !hasRole(c, models.RoleChecker) {
+		return i18n.ErrorResponse(c, http.StatusForbidden, service.ErrCheckerRequired)
+	}
+
+	op, err := h.approvals.Approve(c.Request().Context(), c.Param("idPersetujuan"), actorOf(c, req.Aktor))
+	if err != nil {
+		h.log.Error("Failed to approve operation: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	return c.JSON(http.StatusOK, pendingOperationResponse(op))
+}
+
+func (h *approvalHandler) Reject(c echo.Context) error {
+	req := &dto.DecisionRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind rejection request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if !hasRole(c, models.RoleChecker) {
+		return i18n.ErrorResponse(c, http.StatusForbidden, service.ErrCheckerRequired)
+	}
+
+	op, err := h.approvals.Reject(c.Request().Context(), c.Param("idPersetujuan"), actorOf(c, req.Aktor), req.Alasan)
+	if err != nil {
+		h.log.Error("Failed to reject operation: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	return c.JSON(http.StatusOK, pendingOperationResponse(op))
+}
+
+// hasRole reports whether the calling partner client was granted role
+func hasRole(c echo.Context, role string) bool {
+	client, _ := c.Get(middleware.APIClientKey).(*models.APIClient)
+	return client != nil && client.HasRole(role)
+}
+
+// actorOf identifies the person behind a request, as maker-checker and the
+// back-office records know it: the partner client ID, followed by the named
+// operator when the request has one. Requests without a partner client are made by the signed-in customer.
+func actorOf(c echo.Context, aktor string) string {
+	var actor string
+	if client, _ := c.Get(middleware.APIClientKey).(*models.APIClient); client != nil {
+		actor = "partner:" + client.ClientID
+	} else if claims, _ := c.Get(middleware.ClaimsKey).(*auth.Claims); claims != nil {
+		actor = "customer:" + claims.Subject
+	}
+	if aktor != "" {
+		if actor == "" {
+			return aktor
+		}
+		actor += "/" + aktor
+	}
+	return actor
+}
+
+func pendingOperationResponse(op *models.PendingOperation) dto.PendingOperationResponse {
+	return dto.PendingOperationResponse{
+		IDPersetujuan: op.ID,
+		Operasi:       op.Operation,
+		NoRekening:    op.AccountNumber,
+		Jumlah:        op.Amount,
+		Status:        op.Status,
+		Pembuat:       op.Maker,
+		Pemeriksa:     op.Checker,
+		Catatan:       op.Note,
+		Hasil:         op.Result,
+		BerlakuSampai: op.ExpiresAt,
+	}
+}
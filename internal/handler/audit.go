@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+type auditHandler struct {
+	audit service.AuditService
+	log   *logger.CustomLogger
+}
+
+type AuditHandler interface {
+	SearchEvents(ctx echo.Context) error
+}
+
+func NewAuditHandler(audit service.AuditService, log *logger.CustomLogger) *auditHandler {
+	return &auditHandler{
+		audit: audit,
+		log:   log,
+	}
+}
+
+// SearchEvents serves the audit log to compliance, filtered by
+// ?no_rekening=, ?aktor= and the ?dari= / ?sampai= time range. Times are
+// RFC 3339 or YYYY-MM-DD business dates, where sampai includes the whole day.
+// ?limit= and ?offset= page through the result.
+func (h *auditHandler) SearchEvents(c echo.Context) error {
+	if !hasRole(c, models.RoleCompliance) {
+		return i18n.ErrorResponse(c, http.StatusForbidden, service.ErrComplianceRequired)
+	}
+
+	filter := models.AuditFilter{
+		AccountNumber: c.QueryParam("no_rekening"),
+		Actor:         c.QueryParam("aktor"),
+	}
+	var err error
+	if filter.From, err = parseAuditTime(c.QueryParam("dari"), false); err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("dari", err))
+	}
+	if filter.To, err = parseAuditTime(c.QueryParam("sampai"), true); err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("sampai", err))
+	}
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("limit", err))
+	}
+	if filter.Offset, err = queryInt(c, "offset"); err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("offset", err))
+	}
+
+	events, err := h.audit.SearchEvents(c.Request().Context(), filter)
+	if err != nil {
+		h.log.Error("Failed to search audit events: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	resp := make([]dto.AuditEventResponse, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, dto.AuditEventResponse{
+			ID:           event.ID,
+			Aksi:         event.Action,
+			Entitas:      event.EntityType,
+			IDEntitas:    event.EntityID,
+			NoRekening:   event.AccountNumber,
+			Aktor:        event.Actor,
+			Kanal:        event.Channel,
+			IP:           event.IP,
+			IDPermintaan: event.RequestID,
+			Sebelum:      event.Before,
+			Sesudah:      event.After,
+			Alasan:       event.Reason,
+			Waktu:        event.CreatedAt,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// parseAuditTime reads an RFC 3339 time or a business date, which stands for
+// the start of the day, or its end when end is set
+func parseAuditTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(time.Local), nil
+	}
+	start, dayEnd, err := models.BusinessDayBounds(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		return dayEnd, nil
+	}
+	return start, nil
+}
+
+func queryInt(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+type authHandler struct {
+	service service.AuthService
+	log     *logger.CustomLogger
+}
+
+type AuthHandler interface {
+	Login(ctx echo.Context) error
+	Refresh(ctx echo.Context) error
+}
+
+func NewAuthHandler(service service.AuthService, log *logger.CustomLogger) *authHandler {
+	return &authHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+func (h *authHandler) Login(c echo.Context) error {
+	req := &dto.LoginRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind login request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	tokens, err := h.service.Login(c.Request().Context(), req)
+	if err != nil {
+		h.log.Error("Failed to login: ", err)
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return i18n.ErrorResponse(c, http.StatusUnauthorized, err)
+		}
+		if errors.Is(err, repository.ErrAccountClosed) {
+			return i18n.ErrorResponse(c, http.StatusForbidden, err)
+		}
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (h *authHandler) Refresh(c echo.Context) error {
+	req := &dto.RefreshTokenRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind refresh request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	tokens, err := h.service.Refresh(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		h.log.Error("Failed to refresh token: ", err)
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			return i18n.ErrorResponse(c, http.StatusUnauthorized, err)
+		}
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
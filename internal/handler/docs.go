@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/openapi"
+	"github.com/labstack/echo/v4"
+)
+
+type docsHandler struct {
+	spec []byte
+}
+
+type DocsHandler interface {
+	Spec(ctx echo.Context) error
+	UI(ctx echo.Context) error
+}
+
+// NewDocsHandler serves doc, encoded once as it does not change while the
+// service runs
+func NewDocsHandler(doc *openapi.Document) (*docsHandler, error) {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &docsHandler{spec: spec}, nil
+}
+
+// Spec serves the OpenAPI document
+func (h *docsHandler) Spec(c echo.Context) error {
+	return c.JSONBlob(http.StatusOK, h.spec)
+}
+
+// UI serves Swagger UI rendering the OpenAPI document
+func (h *docsHandler) UI(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Banking Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
@@ -1,17 +1,32 @@
 package handler
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/models"
 	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/repository"
 	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/alfaa19/service-account-test/internal/statement"
 	logger "github.com/alfaa19/service-account-test/pkg/logrus"
 	"github.com/labstack/echo/v4"
 )
 
+// accountHandler serves the unversioned routes. Its customer routes are
+// deprecated in favour of the /v1 routes of AccountV1Handler; they only
+// translate the legacy request and response bodies and share the rest with
+// the v1 handlers.
 type accountHandler struct {
-	service service.Service
-	log     *logger.CustomLogger
+	service   service.Service
+	approvals service.ApprovalService
+	log       *logger.CustomLogger
 }
 
 type AccountHandler interface {
@@ -19,12 +34,18 @@ type AccountHandler interface {
 	GetSaldo(ctx echo.Context) error
 	Withdraw(ctx echo.Context) error
 	Deposit(ctx echo.Context) error
+	ChangePin(ctx echo.Context) error
+	ChangeStatus(ctx echo.Context) error
+	CloseAccount(ctx echo.Context) error
+	GetStatement(ctx echo.Context) error
+	ReverseTransaction(ctx echo.Context) error
 }
 
-func NewAccountHandler(service service.Service, log *logger.CustomLogger) *accountHandler {
+func NewAccountHandler(service service.Service, approvals service.ApprovalService, log *logger.CustomLogger) *accountHandler {
 	return &accountHandler{
-		service: service,
-		log:     log,
+		service:   service,
+		approvals: approvals,
+		log:       log,
 	}
 }
 
@@ -32,81 +53,269 @@ func (h *accountHandler) CreateAccount(c echo.Context) error {
 	reqAccount := &dto.AccountRegistration{}
 	if err := c.Bind(reqAccount); err != nil {
 		h.log.Error("Failed to bind account: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{Remark: err.Error()})
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
 	}
 
 	createdAccount, err := h.service.CreateAccount(c.Request().Context(), reqAccount)
 
 	if err != nil {
 		h.log.Error("Failed to create account: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{Remark: err.Error()})
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
 	}
 
 	return c.JSON(http.StatusCreated, dto.AccountResponse{NoRekening: createdAccount.AccountNumber})
 }
 
 func (h *accountHandler) GetSaldo(c echo.Context) error {
-	noRekening := c.Param("noRekening")
-	account, err := h.service.GetAccountByNoRekening(c.Request().Context(), noRekening)
-	if err != nil {
-		h.log.Error("Failed to get saldo: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Remark: err.Error(),
-		})
-	}
-
-	return c.JSON(http.StatusOK, dto.BalanceResponse{
-		Saldo: account.Balance})
+	return h.balance(c, c.Param("noRekening"), c.QueryParam("tanggal"))
 }
 
 func (h *accountHandler) Withdraw(c echo.Context) error {
 	req := &dto.WithdrawDepositRequest{}
 	if err := c.Bind(&req); err != nil {
 		h.log.Error("Failed to bind withdraw request: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{})
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
 	}
 
-	if err := h.service.UpdateBalanceWithdraw(c.Request().Context(), req.NoRekening, req.Amount); err != nil {
-		h.log.Error("Failed to withdraw: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Remark: err.Error(),
-		})
+	pending, err := h.withdraw(c, req.NoRekening, req.Amount, req.Pin, req.Aktor)
+	if err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	if pending != nil {
+		return c.JSON(http.StatusAccepted, pendingOperationResponse(pending))
 	}
 
-	account, err := h.service.GetAccountByNoRekening(c.Request().Context(), req.NoRekening)
+	account, err := h.currentAccount(c, req.NoRekening)
 	if err != nil {
-		h.log.Error("Failed to get saldo: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Remark: err.Error(),
-		})
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
 	}
 
 	return c.JSON(http.StatusOK, dto.BalanceResponse{
-		Saldo: account.Balance})
+		Saldo:         account.Balance,
+		SaldoTersedia: account.AvailableBalance(),
+	})
 }
 
 func (h *accountHandler) Deposit(c echo.Context) error {
 	req := &dto.WithdrawDepositRequest{}
 	if err := c.Bind(&req); err != nil {
 		h.log.Error("Failed to bind deposit request: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{})
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
 	}
 
-	if err := h.service.UpdateBalanceDeposit(c.Request().Context(), req.NoRekening, req.Amount); err != nil {
-		h.log.Error("Failed to deposit: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Remark: err.Error(),
-		})
+	if err := h.deposit(c, req.NoRekening, req.Amount); err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	account, err := h.currentAccount(c, req.NoRekening)
+	if err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.BalanceResponse{
+		Saldo:         account.Balance,
+		SaldoTersedia: account.AvailableBalance(),
+	})
+}
+
+func (h *accountHandler) ChangePin(c echo.Context) error {
+	req := &dto.ChangePinRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind change PIN request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
 	}
 
-	account, err := h.service.GetAccountByNoRekening(c.Request().Context(), req.NoRekening)
+	return h.changePin(c, req.NoRekening, req.PinLama, req.PinBaru)
+}
+
+func (h *accountHandler) ChangeStatus(c echo.Context) error {
+	req := &dto.ChangeStatusRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind change status request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	change, err := h.service.ChangeAccountStatus(c.Request().Context(), c.Param("noRekening"), req.Status, req.Alasan, actorOf(c, req.Aktor))
+	if err != nil {
+		h.log.Error("Failed to change account status: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusOK, dto.AccountStatusResponse{
+		NoRekening: change.AccountNumber,
+		StatusLama: change.FromStatus,
+		Status:     change.ToStatus,
+	})
+}
+
+func (h *accountHandler) CloseAccount(c echo.Context) error {
+	req := &dto.CloseAccountRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind close account request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	return h.closeAccount(c, req.NoRekening, dto.AccountClosureRequest{
+		Pin:             req.Pin,
+		MetodePencairan: req.MetodePencairan,
+		RekeningTujuan:  req.RekeningTujuan,
+		Alasan:          req.Alasan,
+	})
+}
+
+// GetStatement serves the rekening koran of ?periode=YYYY-MM (last month by
+// default) as ?format=pdf (default) or csv
+func (h *accountHandler) GetStatement(c echo.Context) error {
+	return h.statement(c, c.Param("noRekening"), c.QueryParam("periode"), c.QueryParam("format"))
+}
+
+// balance serves the current balance of an account, or with tanggal its
+// ledger balance at the end of that business date
+func (h *accountHandler) balance(c echo.Context, noRekening, tanggal string) error {
+	if tanggal != "" {
+		saldo, err := h.service.GetBalanceOnDate(c.Request().Context(), noRekening, tanggal)
+		if err != nil {
+			h.log.Error("Failed to get saldo: ", err)
+			return i18n.ErrorResponse(c, errorStatus(err), err)
+		}
+		return c.JSON(http.StatusOK, dto.HistoricalBalanceResponse{Tanggal: tanggal, Saldo: saldo})
+	}
+
+	account, err := h.service.GetAccountByNoRekening(c.Request().Context(), noRekening)
 	if err != nil {
 		h.log.Error("Failed to get saldo: ", err)
-		return c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Remark: err.Error(),
-		})
+		return i18n.ErrorResponse(c, errorStatus(err), err)
 	}
 
 	return c.JSON(http.StatusOK, dto.BalanceResponse{
-		Saldo: account.Balance})
+		Saldo:         account.Balance,
+		SaldoTersedia: account.AvailableBalance(),
+	})
+}
+
+// withdraw withdraws amount, or puts it up for approval. It returns the
+// pending operation when a checker has to approve it first.
+func (h *accountHandler) withdraw(c echo.Context, noRekening string, amount float64, pin, aktor string) (*models.PendingOperation, error) {
+	pending, err := h.approvals.Withdraw(c.Request().Context(), noRekening, amount, pin, actorOf(c, aktor))
+	if err != nil {
+		h.log.Error("Failed to withdraw: ", err)
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (h *accountHandler) deposit(c echo.Context, noRekening string, amount float64) error {
+	if err := h.service.UpdateBalanceDeposit(c.Request().Context(), noRekening, amount); err != nil {
+		h.log.Error("Failed to deposit: ", err)
+		return err
+	}
+	return nil
+}
+
+// currentAccount returns an account after a withdrawal or deposit, for the
+// balance of the response
+func (h *accountHandler) currentAccount(c echo.Context, noRekening string) (*models.Account, error) {
+	account, err := h.service.GetAccountByNoRekening(c.Request().Context(), noRekening)
+	if err != nil {
+		h.log.Error("Failed to get saldo: ", err)
+		return nil, err
+	}
+	return account, nil
+}
+
+func (h *accountHandler) changePin(c echo.Context, noRekening, pinLama, pinBaru string) error {
+	if err := h.service.ChangePin(c.Request().Context(), noRekening, pinLama, pinBaru); err != nil {
+		h.log.Error("Failed to change PIN: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *accountHandler) closeAccount(c echo.Context, noRekening string, req dto.AccountClosureRequest) error {
+	closure, err := h.service.CloseAccount(c.Request().Context(), noRekening, req.Pin, req.MetodePencairan, req.RekeningTujuan, req.Alasan)
+	if err != nil {
+		h.log.Error("Failed to close account: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusOK, dto.CloseAccountResponse{
+		NoRekening:      closure.AccountNumber,
+		Status:          models.StatusClosed,
+		MetodePencairan: closure.PayoutMethod,
+		SaldoDicairkan:  closure.PayoutAmount,
+		RekeningTujuan:  closure.Beneficiary,
+		Referensi:       closure.Reference,
+	})
+}
+
+// statement serves the rekening koran of period, YYYY-MM or last month when
+// empty, as format pdf (default) or csv
+func (h *accountHandler) statement(c echo.Context, noRekening, period, format string) error {
+	if period == "" {
+		now := models.BusinessTime(time.Now())
+		period = now.AddDate(0, 0, -now.Day()).Format("2006-01")
+	}
+	format = strings.ToLower(format)
+	if format == "" {
+		format = statement.FormatPDF
+	}
+	if format != statement.FormatPDF && format != statement.FormatCSV {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("format", nil))
+	}
+
+	st, err := h.service.GetStatement(c.Request().Context(), noRekening, period)
+	if err != nil {
+		h.log.Error("Failed to get statement: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	var buf bytes.Buffer
+	if err := statement.Write(&buf, st, format); err != nil {
+		h.log.Error("Failed to render statement: ", err)
+		return i18n.ErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+statement.FileName(st, format)+`"`)
+	return c.Blob(http.StatusOK, statement.ContentType(format), buf.Bytes())
+}
+
+// errorStatus maps PIN and account state failures to their HTTP status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPin),
+		errors.Is(err, service.ErrInvalidAccessToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrPinLocked):
+		return http.StatusLocked
+	case errors.Is(err, service.ErrLimitExceeded),
+		errors.Is(err, service.ErrReversalOverdraft):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrAccountFrozen),
+		errors.Is(err, repository.ErrAccountDormant),
+		errors.Is(err, repository.ErrAccountClosed),
+		errors.Is(err, service.ErrSameMakerChecker),
+		errors.Is(err, service.ErrAccountNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidStatusTransition),
+		errors.Is(err, service.ErrClosureRequired),
+		errors.Is(err, service.ErrHoldNotActive),
+		errors.Is(err, service.ErrHoldExpired),
+		errors.Is(err, service.ErrActiveHolds),
+		errors.Is(err, service.ErrReversalOfReversal),
+		errors.Is(err, repository.ErrAlreadyReversed),
+		errors.Is(err, service.ErrOperationNotPending),
+		errors.Is(err, service.ErrOperationExpired):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrAccountNotFound),
+		errors.Is(err, repository.ErrHoldNotFound),
+		errors.Is(err, repository.ErrTransactionNotFound),
+		errors.Is(err, repository.ErrPendingOperationNotFound),
+		errors.Is(err, repository.ErrWebhookSubscriptionNotFound),
+		errors.Is(err, repository.ErrWebhookDeliveryNotFound),
+		errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
 }
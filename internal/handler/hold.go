@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+type holdHandler struct {
+	service service.Service
+	log     *logger.CustomLogger
+}
+
+type HoldHandler interface {
+	CreateHold(ctx echo.Context) error
+	CaptureHold(ctx echo.Context) error
+	ReleaseHold(ctx echo.Context) error
+}
+
+func NewHoldHandler(service service.Service, log *logger.CustomLogger) *holdHandler {
+	return &holdHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+func (h *holdHandler) CreateHold(c echo.Context) error {
+	req := &dto.CreateHoldRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind hold request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	var ttl time.Duration
+	if req.MasaBerlaku != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.MasaBerlaku)
+		if err != nil {
+			return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("masa_berlaku", err))
+		}
+	}
+
+	hold, err := h.service.CreateHold(c.Request().Context(), req.NoRekening, req.Jumlah, ttl, req.Keterangan)
+	if err != nil {
+		h.log.Error("Failed to create hold: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusCreated, holdResponse(hold, ""))
+}
+
+func (h *holdHandler) CaptureHold(c echo.Context) error {
+	req := &dto.CaptureHoldRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind capture request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	hold, trx, err := h.service.CaptureHold(c.Request().Context(), c.Param("idHold"), req.Jumlah, req.Keterangan)
+	if err != nil {
+		h.log.Error("Failed to capture hold: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusOK, holdResponse(hold, trx.Reference))
+}
+
+func (h *holdHandler) ReleaseHold(c echo.Context) error {
+	hold, err := h.service.ReleaseHold(c.Request().Context(), c.Param("idHold"))
+	if err != nil {
+		h.log.Error("Failed to release hold: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	return c.JSON(http.StatusOK, holdResponse(hold, ""))
+}
+
+func holdResponse(hold *models.Hold, reference string) dto.HoldResponse {
+	remaining := hold.Remaining()
+	if hold.Status != models.HoldActive {
+		remaining = 0
+	}
+	return dto.HoldResponse{
+		IDHold:         hold.ID,
+		NoRekening:     hold.AccountNumber,
+		Jumlah:         hold.Amount,
+		JumlahTerpakai: hold.CapturedAmount,
+		Sisa:           remaining,
+		Status:         hold.Status,
+		BerlakuSampai:  hold.ExpiresAt,
+		Referensi:      reference,
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+// ReverseTransaction posts the compensating entries of a ledger transaction.
+// Only partners with the supervisor role may force a reversal that
+// overdraws an account.
+func (h *accountHandler) ReverseTransaction(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("idTransaksi"), 10, 64)
+	if err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("id_transaksi", err))
+	}
+	req := &dto.ReversalRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind reversal request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if req.Paksa && !hasRole(c, models.RoleSupervisor) {
+		return i18n.ErrorResponse(c, http.StatusForbidden, service.ErrSupervisorRequired)
+	}
+
+	actor := actorOf(c, req.Aktor)
+	reversal, pending, err := h.approvals.ReverseTransaction(c.Request().Context(), id, req.Alasan, actor, req.Paksa, actor)
+	if err != nil {
+		h.log.Error("Failed to reverse transaction: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	if pending != nil {
+		return c.JSON(http.StatusAccepted, pendingOperationResponse(pending))
+	}
+
+	resp := dto.ReversalResponse{
+		Referensi:   reversal.Reference,
+		IDTransaksi: reversal.TransactionID,
+		Alasan:      reversal.Reason,
+		Paksa:       reversal.Forced,
+		Mutasi:      make([]dto.ReversalEntry, 0, len(reversal.Entries)),
+	}
+	for _, trx := range reversal.Entries {
+		resp.Mutasi = append(resp.Mutasi, dto.ReversalEntry{
+			IDTransaksi: trx.ID,
+			Koreksi:     *trx.ReversalOf,
+			NoRekening:  trx.AccountNumber,
+			Arah:        trx.Direction,
+			Jumlah:      trx.Amount,
+			SaldoAkhir:  trx.BalanceAfter,
+		})
+	}
+	return c.JSON(http.StatusCreated, resp)
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/alfaa19/service-account-test/internal/snap"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	snapCurrency          = "IDR"
+	snapDefaultPageSize   = 10
+	snapMaxPageSize       = 100
+	snapDefaultHistoryAge = 30 * 24 * time.Hour
+)
+
+type snapHandler struct {
+	service  service.Service
+	partners service.PartnerService
+	log      *logger.CustomLogger
+}
+
+type SnapHandler interface {
+	AccessToken(ctx echo.Context) error
+	BalanceInquiry(ctx echo.Context) error
+	TransferIntrabank(ctx echo.Context) error
+	TransactionHistory(ctx echo.Context) error
+}
+
+func NewSnapHandler(service service.Service, partners service.PartnerService, log *logger.CustomLogger) *snapHandler {
+	return &snapHandler{
+		service:  service,
+		partners: partners,
+		log:      log,
+	}
+}
+
+func (h *snapHandler) AccessToken(c echo.Context) error {
+	req := &dto.SnapAccessTokenRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind SNAP access token request: ", err)
+		return snap.InvalidField(c, snap.ServiceAccessToken, snap.CaseInvalidFieldFormat, "body")
+	}
+	if req.GrantType != "client_credentials" {
+		return snap.InvalidField(c, snap.ServiceAccessToken, snap.CaseInvalidFieldFormat, "grantType")
+	}
+
+	header := c.Request().Header
+	token, expiresIn, err := h.partners.IssueSnapAccessToken(c.Request().Context(),
+		header.Get(snap.HeaderClientKey),
+		header.Get(snap.HeaderTimestamp),
+		header.Get(snap.HeaderSignature),
+		c.RealIP(),
+	)
+	if err != nil {
+		h.log.Error("Failed to issue SNAP access token: ", err)
+		return snap.ErrorResponse(c, snap.ServiceAccessToken, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.SnapAccessTokenResponse{
+		SnapResponse: snap.Success(snap.ServiceAccessToken),
+		AccessToken:  token,
+		TokenType:    "Bearer",
+		ExpiresIn:    strconv.Itoa(int(expiresIn.Seconds())),
+	})
+}
+
+func (h *snapHandler) BalanceInquiry(c echo.Context) error {
+	req := &dto.SnapBalanceInquiryRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind SNAP balance inquiry: ", err)
+		return snap.InvalidField(c, snap.ServiceBalanceInquiry, snap.CaseInvalidFieldFormat, "body")
+	}
+	if req.AccountNo == "" {
+		return snap.InvalidField(c, snap.ServiceBalanceInquiry, snap.CaseInvalidMandatoryField, "accountNo")
+	}
+	if !partnerMayAccess(c, req.AccountNo) {
+		return snap.ErrorResponse(c, snap.ServiceBalanceInquiry, service.ErrAccountNotAllowed)
+	}
+
+	account, err := h.service.GetAccountByNoRekening(c.Request().Context(), req.AccountNo)
+	if err != nil {
+		h.log.Error("Failed SNAP balance inquiry: ", err)
+		return snap.ErrorResponse(c, snap.ServiceBalanceInquiry, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.SnapBalanceInquiryResponse{
+		SnapResponse:       snap.Success(snap.ServiceBalanceInquiry),
+		ReferenceNo:        models.NewReference(),
+		PartnerReferenceNo: req.PartnerReferenceNo,
+		AccountNo:          account.AccountNumber,
+		Name:               account.Name,
+		AccountInfos: []dto.SnapAccountInfo{{
+			BalanceType:      "Cash",
+			Amount:           snapAmount(account.Balance),
+			AvailableBalance: snapAmount(account.AvailableBalance()),
+			Status:           "0001",
+		}},
+	})
+}
+
+func (h *snapHandler) TransferIntrabank(c echo.Context) error {
+	req := &dto.SnapTransferIntrabankRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind SNAP transfer: ", err)
+		return snap.InvalidField(c, snap.ServiceTransferIntrabank, snap.CaseInvalidFieldFormat, "body")
+	}
+	switch {
+	case req.PartnerReferenceNo == "":
+		return snap.InvalidField(c, snap.ServiceTransferIntrabank, snap.CaseInvalidMandatoryField, "partnerReferenceNo")
+	case req.SourceAccountNo == "":
+		return snap.InvalidField(c, snap.ServiceTransferIntrabank, snap.CaseInvalidMandatoryField, "sourceAccountNo")
+	case req.BeneficiaryAccountNo == "":
+		return snap.InvalidField(c, snap.ServiceTransferIntrabank, snap.CaseInvalidMandatoryField, "beneficiaryAccountNo")
+	case req.Amount.Currency != snapCurrency:
+		return snap.InvalidField(c, snap.ServiceTransferIntrabank, snap.CaseInvalidFieldFormat, "amount.currency")
+	}
+	amount, err := strconv.ParseFloat(req.Amount.Value, 64)
+	if err != nil {
+		return snap.InvalidField(c, snap.ServiceTransferIntrabank, snap.CaseInvalidFieldFormat, "amount.value")
+	}
+	if !partnerMayAccess(c, req.SourceAccountNo) {
+		return snap.ErrorResponse(c, snap.ServiceTransferIntrabank, service.ErrAccountNotAllowed)
+	}
+
+	trx, err := h.service.Transfer(c.Request().Context(),
+		req.SourceAccountNo,
+		req.BeneficiaryAccountNo,
+		amount,
+		req.AdditionalInfo.Pin,
+		req.Remark,
+	)
+	if err != nil {
+		h.log.Error("Failed SNAP transfer: ", err)
+		return snap.ErrorResponse(c, snap.ServiceTransferIntrabank, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.SnapTransferIntrabankResponse{
+		SnapResponse:         snap.Success(snap.ServiceTransferIntrabank),
+		ReferenceNo:          trx.Reference,
+		PartnerReferenceNo:   req.PartnerReferenceNo,
+		Amount:               snapAmount(trx.Amount),
+		BeneficiaryAccountNo: req.BeneficiaryAccountNo,
+		SourceAccountNo:      req.SourceAccountNo,
+		TransactionDate:      trx.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (h *snapHandler) TransactionHistory(c echo.Context) error {
+	req := &dto.SnapTransactionHistoryRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind SNAP transaction history: ", err)
+		return snap.InvalidField(c, snap.ServiceTransactionHistory, snap.CaseInvalidFieldFormat, "body")
+	}
+	if req.AdditionalInfo.AccountNo == "" {
+		return snap.InvalidField(c, snap.ServiceTransactionHistory, snap.CaseInvalidMandatoryField, "additionalInfo.accountNo")
+	}
+	if !partnerMayAccess(c, req.AdditionalInfo.AccountNo) {
+		return snap.ErrorResponse(c, snap.ServiceTransactionHistory, service.ErrAccountNotAllowed)
+	}
+
+	to := time.Now()
+	if req.ToDateTime != "" {
+		parsed, err := time.Parse(time.RFC3339, req.ToDateTime)
+		if err != nil {
+			return snap.InvalidField(c, snap.ServiceTransactionHistory, snap.CaseInvalidFieldFormat, "toDateTime")
+		}
+		to = parsed
+	}
+	from := to.Add(-snapDefaultHistoryAge)
+	if req.FromDateTime != "" {
+		parsed, err := time.Parse(time.RFC3339, req.FromDateTime)
+		if err != nil {
+			return snap.InvalidField(c, snap.ServiceTransactionHistory, snap.CaseInvalidFieldFormat, "fromDateTime")
+		}
+		from = parsed
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = snapDefaultPageSize
+	}
+	if pageSize > snapMaxPageSize {
+		pageSize = snapMaxPageSize
+	}
+	pageNumber := req.PageNumber
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+
+	transactions, err := h.service.GetTransactionHistory(c.Request().Context(),
+		req.AdditionalInfo.AccountNo, from, to, pageSize, (pageNumber-1)*pageSize)
+	if err != nil {
+		h.log.Error("Failed SNAP transaction history: ", err)
+		return snap.ErrorResponse(c, snap.ServiceTransactionHistory, err)
+	}
+
+	details := make([]dto.SnapTransactionDetail, 0, len(transactions))
+	for _, trx := range transactions {
+		entryType := "CREDIT"
+		if trx.Direction == models.DirectionDebit {
+			entryType = "DEBIT"
+		}
+		details = append(details, dto.SnapTransactionDetail{
+			DateTime: trx.CreatedAt.Format(time.RFC3339),
+			Amount:   snapAmount(trx.Amount),
+			Remark:   trx.Description,
+			Status:   "SUCCESS",
+			Type:     entryType,
+			AdditionalInfo: map[string]string{
+				"referenceNo":     trx.Reference,
+				"transactionType": trx.Type,
+				"balanceAfter":    snapAmount(trx.BalanceAfter).Value,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.SnapTransactionHistoryResponse{
+		SnapResponse:       snap.Success(snap.ServiceTransactionHistory),
+		ReferenceNo:        models.NewReference(),
+		PartnerReferenceNo: req.PartnerReferenceNo,
+		DetailData:         details,
+	})
+}
+
+// partnerMayAccess reports whether the partner authenticated by SnapAuth was
+// granted accountNumber
+func partnerMayAccess(c echo.Context, accountNumber string) bool {
+	client, _ := c.Get(middleware.APIClientKey).(*models.APIClient)
+	return client != nil && client.CanAccessAccount(accountNumber)
+}
+
+func snapAmount(value float64) dto.SnapAmount {
+	return dto.SnapAmount{Value: fmt.Sprintf("%.2f", value), Currency: snapCurrency}
+}
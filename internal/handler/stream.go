@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+// balanceEvent is the SSE event name of a balance change
+const balanceEvent = "saldo"
+
+type streamHandler struct {
+	streams   service.BalanceStreamService
+	heartbeat time.Duration
+	log       *logger.CustomLogger
+}
+
+type StreamHandler interface {
+	StreamBalance(ctx echo.Context) error
+}
+
+func NewStreamHandler(streams service.BalanceStreamService, heartbeat time.Duration, log *logger.CustomLogger) *streamHandler {
+	return &streamHandler{
+		streams:   streams,
+		heartbeat: heartbeat,
+		log:       log,
+	}
+}
+
+// StreamBalance pushes the balance changes of an account as Server-Sent
+// Events. Each event's ID is the ledger entry's transaction ID; a client
+// reconnecting with Last-Event-ID first gets every entry after it. A comment
+// line is sent every heartbeat to keep proxies from closing an idle stream.
+// The stream ends when the access token expires, and at the first heartbeat
+// after the account was closed or no longer belongs to the token holder, so
+// it never outlives the access that opened it.
+func (h *streamHandler) StreamBalance(c echo.Context) error {
+	noRekening := c.Param("noRekening")
+	claims, ok := c.Get(middleware.ClaimsKey).(*auth.Claims)
+	if !ok || claims.ExpiresAt == nil {
+		return i18n.ErrorResponse(c, http.StatusUnauthorized, middleware.ErrMissingToken)
+	}
+	var lastID int64
+	if header := c.Request().Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("Last-Event-ID", err))
+		}
+		lastID = id
+	}
+
+	ctx := c.Request().Context()
+	if err := h.streams.CheckAccess(ctx, noRekening, claims.Subject); err != nil {
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	sub := h.streams.Follow(ctx, noRekening)
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	send := func(trx *models.Transaction) error {
+		if err := writeBalanceEvent(res, trx); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	}
+
+	if lastID > 0 {
+		var err error
+		if lastID, err = h.streams.Replay(ctx, noRekening, lastID, send); err != nil {
+			h.log.Error("Failed to replay balance stream: ", err)
+			return nil
+		}
+	}
+
+	expiry := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+	defer expiry.Stop()
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-expiry.C:
+			// the client reconnects with a fresh access token
+			return nil
+		case <-ticker.C:
+			if err := h.streams.CheckAccess(ctx, noRekening, claims.Subject); err != nil {
+				return nil
+			}
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case trx, ok := <-sub.C:
+			if !ok {
+				// dropped for lagging behind, or shutting down; the client
+				// reconnects and resumes from its last event
+				return nil
+			}
+			if trx.ID <= lastID {
+				continue
+			}
+			if err := send(&trx); err != nil {
+				return nil
+			}
+			lastID = trx.ID
+		}
+	}
+}
+
+func writeBalanceEvent(w http.ResponseWriter, trx *models.Transaction) error {
+	data, err := json.Marshal(dto.BalanceChangeEvent{
+		IDTransaksi: trx.ID,
+		NoRekening:  trx.AccountNumber,
+		Referensi:   trx.Reference,
+		Tipe:        trx.Type,
+		Arah:        trx.Direction,
+		Jumlah:      trx.Amount,
+		SaldoAkhir:  trx.BalanceAfter,
+		Keterangan:  trx.Description,
+		Waktu:       trx.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", trx.ID, balanceEvent, data)
+	return err
+}
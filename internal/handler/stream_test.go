@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/stream"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const streamAccount = "1000000001"
+
+// streamService follows accounts on a bus; closed makes its account closed
+type streamService struct {
+	bus    *stream.Bus
+	closed atomic.Bool
+	checks atomic.Int32
+}
+
+func (s *streamService) Follow(ctx context.Context, accountNumber string) *stream.Subscription {
+	return s.bus.Subscribe(accountNumber)
+}
+
+func (s *streamService) Replay(ctx context.Context, accountNumber string, afterID int64, fn func(trx *models.Transaction) error) (int64, error) {
+	return afterID, nil
+}
+
+func (s *streamService) CheckAccess(ctx context.Context, accountNumber, subject string) error {
+	s.checks.Add(1)
+	if s.closed.Load() {
+		return repository.ErrAccountClosed
+	}
+	return nil
+}
+
+func testLogger(t *testing.T) *logger.CustomLogger {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// openStream runs StreamBalance for a token expiring at expiresAt and
+// returns the recorder and a channel closed once the stream ended
+func openStream(t *testing.T, h *streamHandler, expiresAt time.Time) (*httptest.ResponseRecorder, <-chan struct{}) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req := httptest.NewRequest(http.MethodGet, "/v1/accounts/"+streamAccount+"/balance/stream", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("noRekening")
+	c.SetParamValues(streamAccount)
+	c.Set(middleware.ClaimsKey, &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   streamAccount,
+		ExpiresAt: &jwt.NumericDate{Time: expiresAt},
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := h.StreamBalance(c); err != nil {
+			t.Errorf("stream: %v", err)
+		}
+	}()
+	return rec, done
+}
+
+func waitEnded(t *testing.T, done <-chan struct{}, within time.Duration) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(within):
+		t.Fatalf("stream still open after %v", within)
+	}
+}
+
+func TestStreamBalanceEndsAtTokenExpiry(t *testing.T) {
+	streams := &streamService{bus: stream.NewBus(8)}
+	h := NewStreamHandler(streams, time.Hour, testLogger(t))
+
+	start := time.Now()
+	expiresAt := start.Add(200 * time.Millisecond)
+	rec, done := openStream(t, h, expiresAt)
+	waitEnded(t, done, 5*time.Second)
+
+	if ended := time.Now(); ended.Before(expiresAt) {
+		t.Errorf("stream ended %v after opening, before the token expired", ended.Sub(start))
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestStreamBalanceRejectsExpiredToken(t *testing.T) {
+	streams := &streamService{bus: stream.NewBus(8)}
+	h := NewStreamHandler(streams, time.Hour, testLogger(t))
+
+	rec, done := openStream(t, h, time.Now().Add(-time.Second))
+	waitEnded(t, done, 5*time.Second)
+	if body := rec.Body.String(); strings.Contains(body, "event:") {
+		t.Errorf("expired token streamed %q", body)
+	}
+}
+
+func TestStreamBalanceEndsWhenAccountClosed(t *testing.T) {
+	streams := &streamService{bus: stream.NewBus(8)}
+	h := NewStreamHandler(streams, 20*time.Millisecond, testLogger(t))
+
+	rec, done := openStream(t, h, time.Now().Add(time.Hour))
+	for streams.checks.Load() < 3 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	streams.closed.Store(true)
+	waitEnded(t, done, 5*time.Second)
+
+	if !strings.Contains(rec.Body.String(), ": ping") {
+		t.Errorf("body = %q, want heartbeats while the account was open", rec.Body.String())
+	}
+}
+
+func TestStreamBalanceRefusesClosedAccount(t *testing.T) {
+	streams := &streamService{bus: stream.NewBus(8)}
+	streams.closed.Store(true)
+	h := NewStreamHandler(streams, time.Hour, testLogger(t))
+
+	rec, done := openStream(t, h, time.Now().Add(time.Hour))
+	waitEnded(t, done, 5*time.Second)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
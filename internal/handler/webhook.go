@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+type webhookHandler struct {
+	webhooks service.WebhookService
+	log      *logger.CustomLogger
+}
+
+type WebhookHandler interface {
+	Subscribe(ctx echo.Context) error
+	ListSubscriptions(ctx echo.Context) error
+	Unsubscribe(ctx echo.Context) error
+	ListDeliveries(ctx echo.Context) error
+	GetDelivery(ctx echo.Context) error
+	Redeliver(ctx echo.Context) error
+}
+
+func NewWebhookHandler(webhooks service.WebhookService, log *logger.CustomLogger) *webhookHandler {
+	return &webhookHandler{
+		webhooks: webhooks,
+		log:      log,
+	}
+}
+
+func (h *webhookHandler) Subscribe(c echo.Context) error {
+	clientID, ok := partnerID(c)
+	if !ok {
+		return i18n.ErrorResponse(c, http.StatusForbidden, errNoPartner)
+	}
+	req := &dto.WebhookSubscriptionRequest{}
+	if err := c.Bind(req); err != nil {
+		h.log.Error("Failed to bind webhook subscription request: ", err)
+		return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	sub, err := h.webhooks.Subscribe(c.Request().Context(), clientID, req.URL, req.Event, req.NoRekening)
+	if err != nil {
+		h.log.Error("Failed to subscribe webhook: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	resp := webhookSubscriptionResponse(sub)
+	resp.Rahasia = sub.SigningSecret
+	return c.JSON(http.StatusCreated, resp)
+}
+
+func (h *webhookHandler) ListSubscriptions(c echo.Context) error {
+	clientID, ok := partnerID(c)
+	if !ok {
+		return i18n.ErrorResponse(c, http.StatusForbidden, errNoPartner)
+	}
+	subs, err := h.webhooks.ListSubscriptions(c.Request().Context(), clientID)
+	if err != nil {
+		h.log.Error("Failed to list webhook subscriptions: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	resp := make([]dto.WebhookSubscriptionResponse, 0, len(subs))
+	for i := range subs {
+		resp = append(resp, webhookSubscriptionResponse(&subs[i]))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *webhookHandler) Unsubscribe(c echo.Context) error {
+	clientID, ok := partnerID(c)
+	if !ok {
+		return i18n.ErrorResponse(c, http.StatusForbidden, errNoPartner)
+	}
+	if err := h.webhooks.Unsubscribe(c.Request().Context(), clientID, c.Param("idLangganan")); err != nil {
+		h.log.Error("Failed to unsubscribe webhook: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListDeliveries serves the delivery log, filtered by ?status= (DEAD for the
+// dead-letter list) and paged with ?limit= and ?offset=
+func (h *webhookHandler) ListDeliveries(c echo.Context) error {
+	clientID, ok := partnerID(c)
+	if !ok {
+		return i18n.ErrorResponse(c, http.StatusForbidden, errNoPartner)
+	}
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("limit", err))
+	}
+	offset, err := queryInt(c, "offset")
+	if err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("offset", err))
+	}
+
+	status := strings.ToUpper(c.QueryParam("status"))
+	deliveries, err := h.webhooks.ListDeliveries(c.Request().Context(), clientID, status, limit, offset)
+	if err != nil {
+		h.log.Error("Failed to list webhook deliveries: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+
+	resp := make([]dto.WebhookDeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		resp = append(resp, webhookDeliveryResponse(&deliveries[i], nil))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *webhookHandler) GetDelivery(c echo.Context) error {
+	clientID, ok := partnerID(c)
+	if !ok {
+		return i18n.ErrorResponse(c, http.StatusForbidden, errNoPartner)
+	}
+	id, err := strconv.ParseInt(c.Param("idPengiriman"), 10, 64)
+	if err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("id_pengiriman", err))
+	}
+
+	delivery, attempts, err := h.webhooks.GetDelivery(c.Request().Context(), clientID, id)
+	if err != nil {
+		h.log.Error("Failed to get webhook delivery: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	resp := webhookDeliveryResponse(delivery, attempts)
+	resp.Payload = delivery.Payload
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *webhookHandler) Redeliver(c echo.Context) error {
+	clientID, ok := partnerID(c)
+	if !ok {
+		return i18n.ErrorResponse(c, http.StatusForbidden, errNoPartner)
+	}
+	id, err := strconv.ParseInt(c.Param("idPengiriman"), 10, 64)
+	if err != nil {
+		return i18n.ErrorResponse(c, http.StatusBadRequest, errcode.Invalid("id_pengiriman", err))
+	}
+
+	delivery, err := h.webhooks.Redeliver(c.Request().Context(), clientID, id)
+	if err != nil {
+		h.log.Error("Failed to redeliver webhook: ", err)
+		return i18n.ErrorResponse(c, errorStatus(err), err)
+	}
+	return c.JSON(http.StatusAccepted, webhookDeliveryResponse(delivery, nil))
+}
+
+// errNoPartner rejects webhook requests without a signed partner, such as
+// those of a server running with partner authentication disabled
+var errNoPartner = errcode.New("PARTNER_REQUIRED", "webhooks are only available to authenticated partners")
+
+// partnerID returns the client ID of the calling partner, who owns the
+// subscriptions and deliveries the request is about
+func partnerID(c echo.Context) (string, bool) {
+	client, _ := c.Get(middleware.APIClientKey).(*models.APIClient)
+	if client == nil {
+		return "", false
+	}
+	return client.ClientID, true
+}
+
+func webhookSubscriptionResponse(sub *models.WebhookSubscription) dto.WebhookSubscriptionResponse {
+	return dto.WebhookSubscriptionResponse{
+		IDLangganan: sub.ID,
+		URL:         sub.URL,
+		Event:       sub.EventTypes,
+		NoRekening:  sub.AccountNumbers,
+		Aktif:       sub.Active,
+		DibuatPada:  sub.CreatedAt,
+	}
+}
+
+func webhookDeliveryResponse(delivery *models.WebhookDelivery, attempts []models.WebhookAttempt) dto.WebhookDeliveryResponse {
+	resp := dto.WebhookDeliveryResponse{
+		IDPengiriman:       delivery.ID,
+		IDLangganan:        delivery.SubscriptionID,
+		IDEvent:            delivery.EventID,
+		Event:              delivery.EventType,
+		Status:             delivery.Status,
+		Percobaan:          delivery.Attempts,
+		KesalahanTerakhir:  delivery.LastError,
+		KodeStatusTerakhir: delivery.LastStatusCode,
+		TerkirimPada:       delivery.DeliveredAt,
+		DibuatPada:         delivery.CreatedAt,
+	}
+	if delivery.Status == models.WebhookPending {
+		next := delivery.NextAttemptAt
+		resp.PercobaanBerikutnya = &next
+	}
+	for _, attempt := range attempts {
+		resp.Log = append(resp.Log, dto.WebhookAttemptResponse{
+			Percobaan:  attempt.Attempt,
+			KodeStatus: attempt.StatusCode,
+			Kesalahan:  attempt.Error,
+			DurasiMs:   attempt.DurationMs,
+			Waktu:      attempt.CreatedAt,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,444 @@
+// Package i18n translates error codes into the language of the caller, picked
+// from the Accept-Language header.
+package i18n
+
+import "strings"
+
+// Supported languages
+const (
+	Indonesian = "id-ID"
+	English    = "en-US"
+)
+
+// DefaultLanguage answers callers that ask for no supported language
+const DefaultLanguage = English
+
+// Languages lists the supported languages
+var Languages = []string{Indonesian, English}
+
+// catalog holds the message of every error code in every language
+var catalog = map[string]map[string]string{
+	// Errors without a code of their own
+	"INVALID_REQUEST": {
+		Indonesian: "Permintaan tidak valid",
+		English:    "Invalid request",
+	},
+	"INVALID_PARAMETER": {
+		Indonesian: "Nilai {field} tidak valid",
+		English:    "Invalid {field}",
+	},
+	"UNAUTHORIZED": {
+		Indonesian: "Autentikasi diperlukan",
+		English:    "Authentication required",
+	},
+	"FORBIDDEN": {
+		Indonesian: "Akses ditolak",
+		English:    "Access denied",
+	},
+	"NOT_FOUND": {
+		Indonesian: "Data tidak ditemukan",
+		English:    "Not found",
+	},
+	"CONFLICT": {
+		Indonesian: "Permintaan bertentangan dengan kondisi saat ini",
+		English:    "Request conflicts with the current state",
+	},
+	"UNPROCESSABLE": {
+		Indonesian: "Permintaan tidak dapat diproses",
+		English:    "Request cannot be processed",
+	},
+	"LOCKED": {
+		Indonesian: "Sumber daya terkunci",
+		English:    "Resource is locked",
+	},
+	"TOO_MANY_REQUESTS": {
+		Indonesian: "Terlalu banyak permintaan",
+		English:    "Too many requests",
+	},
+	"INTERNAL_ERROR": {
+		Indonesian: "Terjadi kesalahan internal",
+		English:    "Internal error",
+	},
+
+	// Accounts and balances
+	"ACCOUNT_NOT_FOUND": {
+		Indonesian: "Rekening tidak ditemukan",
+		English:    "Account not found",
+	},
+	"ACCOUNT_FROZEN": {
+		Indonesian: "Rekening sedang dibekukan",
+		English:    "Account is frozen",
+	},
+	"ACCOUNT_DORMANT": {
+		Indonesian: "Rekening tidak aktif (dormant)",
+		English:    "Account is dormant",
+	},
+	"ACCOUNT_CLOSED": {
+		Indonesian: "Rekening sudah ditutup",
+		English:    "Account is closed",
+	},
+	"INSUFFICIENT_BALANCE": {
+		Indonesian: "Saldo tidak mencukupi",
+		English:    "Insufficient balance",
+	},
+	"BALANCE_NOT_UPDATED": {
+		Indonesian: "Saldo gagal diperbarui",
+		English:    "Balance was not updated",
+	},
+	"INVALID_AMOUNT": {
+		Indonesian: "Jumlah harus lebih dari nol",
+		English:    "Amount must be greater than zero",
+	},
+	"SAME_ACCOUNT": {
+		Indonesian: "Rekening sumber dan rekening tujuan harus berbeda",
+		English:    "Source and beneficiary account must differ",
+	},
+	"NIK_EXISTS": {
+		Indonesian: "NIK sudah terdaftar",
+		English:    "NIK already exists",
+	},
+	"PHONE_NUMBER_EXISTS": {
+		Indonesian: "Nomor HP sudah terdaftar",
+		English:    "Phone number already exists",
+	},
+	"LIMIT_EXCEEDED": {
+		Indonesian: "Batas penarikan terlampaui",
+		English:    "Withdrawal limit exceeded",
+	},
+	"PER_TRANSACTION_LIMIT_EXCEEDED": {
+		Indonesian: "Penarikan {channel} melebihi batas per transaksi sebesar {max}, maksimal {left}",
+		English:    "Withdrawal via {channel} exceeds the per transaction limit of {max}, at most {left} allowed",
+	},
+	"DAILY_LIMIT_EXCEEDED": {
+		Indonesian: "Batas penarikan {channel} harian sebesar {max} terlampaui, sisa {left} hari ini",
+		English:    "Daily {channel} withdrawal limit of {max} exceeded, {left} left today",
+	},
+	"DAILY_COUNT_LIMIT_EXCEEDED": {
+		Indonesian: "Batas {max} kali penarikan {channel} harian tercapai, sisa {left} kali hari ini",
+		English:    "Daily {channel} withdrawal count limit of {max} reached, {left} withdrawals left today",
+	},
+
+	// Credentials, PIN and tokens
+	"PASSWORD_TOO_SHORT": {
+		Indonesian: "Kata sandi minimal 6 karakter",
+		English:    "Password must be at least 6 characters",
+	},
+	"INVALID_CREDENTIALS": {
+		Indonesian: "Nomor rekening atau kata sandi salah",
+		English:    "Invalid account number or password",
+	},
+	"INVALID_REFRESH_TOKEN": {
+		Indonesian: "Refresh token tidak valid atau sudah kedaluwarsa",
+		English:    "Invalid or expired refresh token",
+	},
+	"INVALID_PIN_FORMAT": {
+		Indonesian: "PIN harus terdiri dari 6 digit angka",
+		English:    "PIN must be exactly 6 digits",
+	},
+	"INVALID_PIN": {
+		Indonesian: "PIN salah",
+		English:    "Invalid PIN",
+	},
+	"PIN_LOCKED": {
+		Indonesian: "PIN terblokir, silakan coba lagi nanti",
+		English:    "PIN is locked, try again later",
+	},
+	"MISSING_TOKEN": {
+		Indonesian: "Token bearer tidak ada",
+		English:    "Missing bearer token",
+	},
+	"INVALID_ACCESS_TOKEN": {
+		Indonesian: "Token akses tidak valid",
+		English:    "Invalid access token",
+	},
+	"ACCOUNT_NOT_OWNED": {
+		Indonesian: "Rekening bukan milik pemegang token",
+		English:    "Account does not belong to token holder",
+	},
+	"RATE_LIMITED": {
+		Indonesian: "Terlalu banyak permintaan, silakan coba lagi nanti",
+		English:    "Too many requests, try again later",
+	},
+	"RATE_LIMIT_UNAVAILABLE": {
+		Indonesian: "Batas permintaan tidak dapat diperiksa, silakan coba lagi nanti",
+		English:    "The rate limit cannot be checked, try again later",
+	},
+
+	// Account status and closure
+	"UNKNOWN_ACCOUNT_STATUS": {
+		Indonesian: "Status rekening tidak dikenal",
+		English:    "Unknown account status",
+	},
+	"STATUS_TRANSITION_NOT_ALLOWED": {
+		Indonesian: "Perubahan status tidak diizinkan",
+		English:    "Status transition not allowed",
+	},
+	"CLOSURE_REQUIRED": {
+		Indonesian: "Rekening ditutup melalui penutupan rekening",
+		English:    "Accounts are closed through the account closure",
+	},
+	"REASON_REQUIRED": {
+		Indonesian: "Alasan wajib diisi",
+		English:    "Reason is required",
+	},
+	"ACTOR_REQUIRED": {
+		Indonesian: "Aktor wajib diisi",
+		English:    "Actor is required",
+	},
+	"INVALID_PAYOUT_METHOD": {
+		Indonesian: "Metode pencairan harus TRANSFER atau TUNAI",
+		English:    "Payout method must be TRANSFER or TUNAI",
+	},
+	"BENEFICIARY_REQUIRED": {
+		Indonesian: "Rekening tujuan wajib diisi untuk pencairan melalui transfer",
+		English:    "Beneficiary account is required for a transfer payout",
+	},
+	"UNKNOWN_IDENTITY_REUSE_POLICY": {
+		Indonesian: "Kebijakan penggunaan ulang identitas harus never, immediate, atau cooldown",
+		English:    "Identity reuse policy must be never, immediate or cooldown",
+	},
+
+	// Holds
+	"HOLD_NOT_FOUND": {
+		Indonesian: "Penahanan dana tidak ditemukan",
+		English:    "Hold not found",
+	},
+	"HOLD_NOT_ACTIVE": {
+		Indonesian: "Penahanan dana sudah tidak aktif",
+		English:    "Hold is no longer active",
+	},
+	"HOLD_EXPIRED": {
+		Indonesian: "Penahanan dana sudah kedaluwarsa",
+		English:    "Hold has expired",
+	},
+	"CAPTURE_EXCEEDS_HOLD": {
+		Indonesian: "Jumlah yang didebit melebihi sisa penahanan dana",
+		English:    "Capture amount exceeds the remaining hold",
+	},
+	"INVALID_HOLD_TTL": {
+		Indonesian: "Masa berlaku penahanan dana melebihi batas maksimum",
+		English:    "Hold expiry exceeds the maximum allowed",
+	},
+	"ACTIVE_HOLDS": {
+		Indonesian: "Rekening masih memiliki penahanan dana yang aktif",
+		English:    "Account still has active holds",
+	},
+
+	// Reversals and approvals
+	"TRANSACTION_NOT_FOUND": {
+		Indonesian: "Transaksi tidak ditemukan",
+		English:    "Transaction not found",
+	},
+	"TRANSACTION_ALREADY_REVERSED": {
+		Indonesian: "Transaksi sudah pernah dibatalkan",
+		English:    "Transaction has already been reversed",
+	},
+	"REVERSAL_OF_REVERSAL": {
+		Indonesian: "Pembatalan transaksi tidak dapat dibatalkan",
+		English:    "A reversal cannot be reversed",
+	},
+	"REVERSAL_OVERDRAFT": {
+		Indonesian: "Pembatalan akan membuat saldo rekening minus",
+		English:    "Reversal would overdraw the account",
+	},
+	"SUPERVISOR_REQUIRED": {
+		Indonesian: "Pembatalan paksa memerlukan peran supervisor",
+		English:    "Forcing a reversal requires the supervisor role",
+	},
+	"PENDING_OPERATION_NOT_FOUND": {
+		Indonesian: "Operasi yang menunggu persetujuan tidak ditemukan",
+		English:    "Pending operation not found",
+	},
+	"OPERATION_NOT_PENDING": {
+		Indonesian: "Operasi sudah tidak menunggu persetujuan",
+		English:    "Operation is no longer pending",
+	},
+	"OPERATION_EXPIRED": {
+		Indonesian: "Operasi sudah kedaluwarsa",
+		English:    "Operation has expired",
+	},
+	"SAME_MAKER_CHECKER": {
+		Indonesian: "Operasi harus disetujui oleh orang selain pembuatnya",
+		English:    "An operation must be approved by someone other than its maker",
+	},
+	"CHECKER_REQUIRED": {
+		Indonesian: "Menyetujui operasi memerlukan peran checker",
+		English:    "Approving operations requires the checker role",
+	},
+	"MAKER_REQUIRED": {
+		Indonesian: "Pembuat wajib diisi",
+		English:    "Maker is required",
+	},
+
+	// Statements, end of day and ledger
+	"INVALID_PERIOD": {
+		Indonesian: "Periode harus berformat YYYY-MM",
+		English:    "Period must be formatted YYYY-MM",
+	},
+	"FUTURE_PERIOD": {
+		Indonesian: "Periode belum dimulai",
+		English:    "Period has not started yet",
+	},
+	"INVALID_DATE": {
+		Indonesian: "Tanggal harus berformat YYYY-MM-DD",
+		English:    "Date must be formatted YYYY-MM-DD",
+	},
+	"FUTURE_DATE": {
+		Indonesian: "Tanggal belum dimulai",
+		English:    "Date has not started yet",
+	},
+	"BUSINESS_DAY_OPEN": {
+		Indonesian: "Hari kerja belum berakhir",
+		English:    "Business date has not ended yet",
+	},
+	"EOD_ALREADY_RUN": {
+		Indonesian: "Proses akhir hari sudah dijalankan untuk tanggal ini",
+		English:    "End of day has already run for this business date",
+	},
+	"NO_SIGNING_KEY": {
+		Indonesian: "Kunci penandatanganan ledger belum dikonfigurasi",
+		English:    "No ledger signing key configured",
+	},
+
+	// Audit log
+	"INVALID_TIME_RANGE": {
+		Indonesian: "Awal rentang waktu harus sebelum akhirnya",
+		English:    "Start of the time range must be before its end",
+	},
+	"COMPLIANCE_REQUIRED": {
+		Indonesian: "Membaca log audit memerlukan peran compliance",
+		English:    "Reading the audit log requires the compliance role",
+	},
+
+	// Partner authentication
+	"UNKNOWN_CLIENT": {
+		Indonesian: "Klien tidak dikenal atau tidak aktif",
+		English:    "Unknown or inactive client",
+	},
+	"INVALID_SIGNATURE": {
+		Indonesian: "Tanda tangan tidak valid",
+		English:    "Invalid signature",
+	},
+	"STALE_TIMESTAMP": {
+		Indonesian: "Timestamp di luar rentang waktu yang diizinkan",
+		English:    "Timestamp outside the allowed window",
+	},
+	"NONCE_REUSED": {
+		Indonesian: "Nonce sudah pernah digunakan",
+		English:    "Nonce already used",
+	},
+	"MISSING_NONCE": {
+		Indonesian: "Nonce tidak ada",
+		English:    "Missing nonce",
+	},
+	"MALFORMED_TIMESTAMP": {
+		Indonesian: "Timestamp harus berformat RFC3339",
+		English:    "Timestamp must be RFC3339",
+	},
+	"ACCOUNT_NOT_ALLOWED": {
+		Indonesian: "Klien tidak diizinkan mengakses rekening ini",
+		English:    "Account not allowed for client",
+	},
+	"ROUTE_NOT_ALLOWED": {
+		Indonesian: "Klien tidak diizinkan mengakses rute ini",
+		English:    "Route not allowed for client",
+	},
+	"IP_NOT_ALLOWED": {
+		Indonesian: "Alamat IP tidak diizinkan untuk klien ini",
+		English:    "IP address not allowed for client",
+	},
+	"PARTNER_MISMATCH": {
+		Indonesian: "ID partner tidak sesuai dengan token akses",
+		English:    "Partner ID does not match access token",
+	},
+	"MISSING_EXTERNAL_ID": {
+		Indonesian: "External ID tidak ada",
+		English:    "Missing external ID",
+	},
+	"DUPLICATE_EXTERNAL_ID": {
+		Indonesian: "External ID sudah digunakan hari ini",
+		English:    "External ID already used today",
+	},
+	"PARTNER_REQUIRED": {
+		Indonesian: "Webhook hanya tersedia bagi partner yang terautentikasi",
+		English:    "Webhooks are only available to authenticated partners",
+	},
+
+	// Webhooks
+	"INVALID_WEBHOOK_URL": {
+		Indonesian: "URL webhook harus berupa URL http atau https yang lengkap",
+		English:    "Webhook url must be an absolute http or https URL",
+	},
+	"UNKNOWN_EVENT_TYPE": {
+		Indonesian: "Jenis event tidak dikenal",
+		English:    "Unknown event type",
+	},
+	"UNKNOWN_WEBHOOK_STATUS": {
+		Indonesian: "Status pengiriman webhook tidak dikenal",
+		English:    "Unknown webhook delivery status",
+	},
+	"SUBSCRIPTION_NOT_ACTIVE": {
+		Indonesian: "Langganan webhook sudah tidak aktif",
+		English:    "Webhook subscription is no longer active",
+	},
+	"WEBHOOK_ACCOUNTS_REQUIRED": {
+		Indonesian: "Langganan webhook harus mencantumkan minimal satu rekening",
+		English:    "Webhook subscription must list at least one account",
+	},
+	"WEBHOOK_ADDRESS_FORBIDDEN": {
+		Indonesian: "URL webhook mengarah ke alamat loopback, privat atau link-local",
+		English:    "Webhook url resolves to a loopback, private or link-local address",
+	},
+	"WEBHOOK_SUBSCRIPTION_NOT_FOUND": {
+		Indonesian: "Langganan webhook tidak ditemukan",
+		English:    "Webhook subscription not found",
+	},
+	"WEBHOOK_DELIVERY_NOT_FOUND": {
+		Indonesian: "Pengiriman webhook tidak ditemukan",
+		English:    "Webhook delivery not found",
+	},
+	"WEBHOOK_SIGNATURE_MISSING": {
+		Indonesian: "Header tanda tangan webhook tidak ada",
+		English:    "Webhook signature headers missing",
+	},
+	"WEBHOOK_SIGNATURE_INVALID": {
+		Indonesian: "Tanda tangan webhook tidak valid",
+		English:    "Invalid webhook signature",
+	},
+	"WEBHOOK_TIMESTAMP_STALE": {
+		Indonesian: "Timestamp webhook di luar rentang waktu yang diizinkan",
+		English:    "Webhook timestamp outside the allowed window",
+	},
+}
+
+// Message returns the message of code in lang, with each {name} placeholder
+// replaced by params[name]. Unknown languages get DefaultLanguage and unknown
+// codes the code itself.
+func Message(lang, code string, params map[string]string) string {
+	messages, ok := catalog[code]
+	if !ok {
+		return code
+	}
+	message, ok := messages[lang]
+	if !ok {
+		message = messages[DefaultLanguage]
+	}
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	return message
+}
+
+// Translated reports whether the catalog has a message of code in lang
+func Translated(lang, code string) bool {
+	return catalog[code][lang] != ""
+}
+
+// Codes returns every code in the catalog
+func Codes() []string {
+	codes := make([]string, 0, len(catalog))
+	for code := range catalog {
+		codes = append(codes, code)
+	}
+	return codes
+}
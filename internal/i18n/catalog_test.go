@@ -0,0 +1,174 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sourceRoot holds the packages whose errors reach API clients
+const sourceRoot = ".."
+
+// declaredCodes returns every code declared in sourceRoot, with where it is
+// declared: the first argument of each errcode.New and the Code constants of
+// package errcode. Sentinel errors still declared with errors.New or
+// fmt.Errorf fail t, as they carry no code to translate.
+func declaredCodes(t *testing.T) map[string][]string {
+	t.Helper()
+	codes := make(map[string][]string)
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(sourceRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				for i, name := range value.Names {
+					if i >= len(value.Values) {
+						break
+					}
+					where := fset.Position(name.Pos()).String()
+					if gen.Tok == token.CONST && file.Name.Name == "errcode" && strings.HasPrefix(name.Name, "Code") {
+						if code, ok := stringLiteral(value.Values[i]); ok {
+							codes[code] = append(codes[code], where)
+						}
+						continue
+					}
+					if isSentinel(name.Name) && isCall(value.Values[i], "errors", "New", "fmt", "Errorf") {
+						t.Errorf("%s: %s has no code; declare it with errcode.New", where, name.Name)
+					}
+				}
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || !isCall(call, "errcode", "New") || len(call.Args) == 0 {
+				return true
+			}
+			where := fset.Position(call.Pos()).String()
+			code, ok := stringLiteral(call.Args[0])
+			if !ok {
+				t.Errorf("%s: errcode.New code must be a string literal", where)
+				return true
+			}
+			codes[code] = append(codes[code], where)
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("parse sources: %v", err)
+	}
+	return codes
+}
+
+func isSentinel(name string) bool {
+	return strings.HasPrefix(name, "Err") || strings.HasPrefix(name, "err")
+}
+
+// isCall reports whether expr calls one of the package functions given as
+// package, function pairs
+func isCall(expr ast.Expr, pairs ...string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pkg.Name == pairs[i] && sel.Sel.Name == pairs[i+1] {
+			return true
+		}
+	}
+	return false
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+func TestEveryErrorTranslated(t *testing.T) {
+	codes := declaredCodes(t)
+	if len(codes) == 0 {
+		t.Fatal("no error codes found")
+	}
+	for code, where := range codes {
+		if len(where) > 1 {
+			t.Errorf("code %s declared more than once: %s", code, strings.Join(where, ", "))
+		}
+		for _, lang := range Languages {
+			if !Translated(lang, code) {
+				t.Errorf("code %s (%s) has no %s translation", code, where[0], lang)
+			}
+		}
+	}
+	for _, code := range Codes() {
+		if _, ok := codes[code]; !ok {
+			t.Errorf("catalog translates %s, which no error declares", code)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", DefaultLanguage},
+		{"id-ID", Indonesian},
+		{"id", Indonesian},
+		{"in", Indonesian},
+		{"en-GB", English},
+		{"fr-FR", DefaultLanguage},
+		{"*", DefaultLanguage},
+		{"fr-FR, id;q=0.5", Indonesian},
+		{"en;q=0.4, id;q=0.8", Indonesian},
+		{"id;q=0, en", English},
+		{"id-ID,id;q=0.9,en-US;q=0.8,en;q=0.7", Indonesian},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMessageParams(t *testing.T) {
+	if got := Message(Indonesian, "INVALID_PARAMETER", map[string]string{"field": "limit"}); got != "Nilai limit tidak valid" {
+		t.Errorf("Indonesian message = %q", got)
+	}
+	if got := Message("fr-FR", "INVALID_PARAMETER", map[string]string{"field": "limit"}); got != "Invalid limit" {
+		t.Errorf("fallback message = %q", got)
+	}
+	if got := Message(English, "NO_SUCH_CODE", nil); got != "NO_SUCH_CODE" {
+		t.Errorf("unknown code message = %q", got)
+	}
+}
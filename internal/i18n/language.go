@@ -0,0 +1,66 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate picks the supported language the Accept-Language header prefers,
+// such as id-ID for "id;q=0.9, en;q=0.8". A primary subtag matches a
+// supported language of the same subtag, so "en-GB" gets en-US and "in", the
+// former code of Indonesian, gets id-ID.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if lang, ok := match(c.tag); ok {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// match returns the supported language of tag
+func match(tag string) (string, bool) {
+	if tag == "*" {
+		return DefaultLanguage, true
+	}
+	primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	if primary == "in" {
+		primary = "id"
+	}
+	for _, lang := range Languages {
+		if strings.EqualFold(lang, tag) {
+			return lang, true
+		}
+	}
+	for _, lang := range Languages {
+		if p, _, _ := strings.Cut(strings.ToLower(lang), "-"); p == primary {
+			return lang, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,30 @@
+package i18n
+
+import (
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/labstack/echo/v4"
+)
+
+// Headers negotiating the language of errors
+const (
+	HeaderAcceptLanguage  = "Accept-Language"
+	HeaderContentLanguage = "Content-Language"
+)
+
+// ErrorResponse writes err with status as an ErrorResponse carrying its code
+// and the message of the code in the caller's language. Errors without a code
+// get the generic code of status, so internal error text never reaches the
+// caller.
+func ErrorResponse(c echo.Context, status int, err error) error {
+	code, params := errcode.ForStatus(status), map[string]string(nil)
+	if e, ok := errcode.Of(err); ok {
+		code, params = e.Code, e.Params
+	}
+
+	lang := Negotiate(c.Request().Header.Get(HeaderAcceptLanguage))
+	header := c.Response().Header()
+	header.Set(HeaderContentLanguage, lang)
+	header.Add(echo.HeaderVary, HeaderAcceptLanguage)
+	return c.JSON(status, dto.ErrorResponse{Code: code, Remark: Message(lang, code, params)})
+}
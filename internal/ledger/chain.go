@@ -0,0 +1,43 @@
+// Package ledger computes the hash chains that make the ledger tamper
+// evident and signs checkpoints of the global chain.
+//
+// Every entry is hashed twice: onto the previous entry of the same account
+// and onto the previous entry of the whole ledger. Each hash is
+// hex(sha256(previous hash || canonical content)), starting from GenesisHash.
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// GenesisHash is the previous hash of the first entry of every chain
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Canonical returns the content of an entry that is hashed: a JSON array of
+// its fields, so no field value can be confused with a separator
+func Canonical(entry *models.LedgerEntry) []byte {
+	content, _ := json.Marshal([]interface{}{
+		entry.Seq,
+		entry.Reference,
+		entry.AccountNumber,
+		entry.Type,
+		entry.Direction,
+		entry.Amount,
+		entry.BalanceAfter,
+		entry.Description,
+		entry.CreatedAt,
+	})
+	return content
+}
+
+// Hash chains an entry onto the previous hash
+func Hash(previous string, entry *models.LedgerEntry) string {
+	h := sha256.New()
+	h.Write([]byte(previous))
+	h.Write(Canonical(entry))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// checkpointPayload is what a checkpoint signature covers
+func checkpointPayload(cp *models.LedgerCheckpoint) []byte {
+	return []byte(fmt.Sprintf("%d|%s", cp.Seq, cp.GlobalHash))
+}
+
+// LoadSigningKey reads an RSA private key from a PEM file
+func LoadSigningKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadVerifyKey reads an RSA public key from a PEM file
+func LoadVerifyKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return key, nil
+}
+
+// SignCheckpoint sets the base64 SHA256withRSA signature of a checkpoint
+func SignCheckpoint(key *rsa.PrivateKey, cp *models.LedgerCheckpoint) error {
+	digest := sha256.Sum256(checkpointPayload(cp))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return err
+	}
+	cp.Signature = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// VerifyCheckpoint checks the signature of a checkpoint
+func VerifyCheckpoint(key *rsa.PublicKey, cp *models.LedgerCheckpoint) error {
+	sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(checkpointPayload(cp))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+}
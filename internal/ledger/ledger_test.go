@@ -0,0 +1,204 @@
+package ledger
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// testChain returns entries of two accounts with both hashes set the way the
+// database chains them
+func testChain() []models.LedgerEntry {
+	entries := []models.LedgerEntry{
+		{Seq: 1, TransactionID: 11, Reference: "REF-1", AccountNumber: "1001", Type: "DEPOSIT", Direction: "C", Amount: "100000.00", BalanceAfter: "100000.00", CreatedAt: "2026-10-01T09:00:00.000000"},
+		{Seq: 2, TransactionID: 12, Reference: "REF-2", AccountNumber: "1002", Type: "DEPOSIT", Direction: "C", Amount: "75000.00", BalanceAfter: "75000.00", CreatedAt: "2026-10-01T09:05:00.000000"},
+		{Seq: 3, TransactionID: 13, Reference: "REF-3", AccountNumber: "1001", Type: "WITHDRAWAL", Direction: "D", Amount: "50000.00", BalanceAfter: "50000.00", CreatedAt: "2026-10-01T10:00:00.000000"},
+		{Seq: 4, TransactionID: 14, Reference: "REF-3", AccountNumber: "1001", Type: "FEE", Direction: "D", Amount: "6500.00", BalanceAfter: "43500.00", Description: "Biaya tarik tunai", CreatedAt: "2026-10-01T10:00:00.000000"},
+	}
+	global := GenesisHash
+	accounts := map[string]string{}
+	for i := range entries {
+		entry := &entries[i]
+		previous, ok := accounts[entry.AccountNumber]
+		if !ok {
+			previous = GenesisHash
+		}
+		entry.AccountHash = Hash(previous, entry)
+		entry.GlobalHash = Hash(global, entry)
+		global, accounts[entry.AccountNumber] = entry.GlobalHash, entry.AccountHash
+	}
+	return entries
+}
+
+// firstBreak recomputes the global chain over entries in the order given
+// and returns the index of the first entry whose stored hash does not match,
+// or -1 when the chain is intact
+func firstBreak(entries []models.LedgerEntry) int {
+	previous := GenesisHash
+	for i := range entries {
+		if Hash(previous, &entries[i]) != entries[i].GlobalHash {
+			return i
+		}
+		previous = entries[i].GlobalHash
+	}
+	return -1
+}
+
+func TestHashDetectsTamperedEntry(t *testing.T) {
+	if i := firstBreak(testChain()); i != -1 {
+		t.Fatalf("intact chain breaks at %d", i)
+	}
+
+	tampers := map[string]func(*models.LedgerEntry){
+		"amount":         func(e *models.LedgerEntry) { e.Amount = "5000.00" },
+		"balance after":  func(e *models.LedgerEntry) { e.BalanceAfter = "95000.00" },
+		"direction":      func(e *models.LedgerEntry) { e.Direction = "C" },
+		"account number": func(e *models.LedgerEntry) { e.AccountNumber = "1002" },
+		"reference":      func(e *models.LedgerEntry) { e.Reference = "REF-9" },
+		"type":           func(e *models.LedgerEntry) { e.Type = "FEE" },
+		"description":    func(e *models.LedgerEntry) { e.Description = "Koreksi" },
+		"created at":     func(e *models.LedgerEntry) { e.CreatedAt = "2026-10-02T10:00:00.000000" },
+		"seq":            func(e *models.LedgerEntry) { e.Seq = 7 },
+	}
+	for name, tamper := range tampers {
+		entries := testChain()
+		tamper(&entries[2])
+		if i := firstBreak(entries); i != 2 {
+			t.Errorf("tampered %s: chain breaks at %d, want 2", name, i)
+		}
+	}
+}
+
+func TestHashDetectsReorderedEntries(t *testing.T) {
+	entries := testChain()
+	entries[1], entries[2] = entries[2], entries[1]
+	if i := firstBreak(entries); i != 1 {
+		t.Fatalf("swapped entries: chain breaks at %d, want 1", i)
+	}
+
+	// renumbering the swapped entries and rehashing the moved one still
+	// leaves the entry after it pointing at the old predecessor
+	entries[1].Seq, entries[2].Seq = 2, 3
+	entries[1].GlobalHash = Hash(entries[0].GlobalHash, &entries[1])
+	if i := firstBreak(entries); i != 2 {
+		t.Fatalf("renumbered entries: chain breaks at %d, want 2", i)
+	}
+}
+
+func TestHashCoversTheAccountChain(t *testing.T) {
+	entries := testChain()
+	// the fee follows the withdrawal on account 1001, not the deposit of 1002
+	if entries[3].AccountHash != Hash(entries[2].AccountHash, &entries[3]) {
+		t.Fatal("account hash does not chain onto the account's previous entry")
+	}
+	if entries[3].AccountHash == Hash(entries[1].AccountHash, &entries[3]) {
+		t.Fatal("account hash chains onto another account's entry")
+	}
+}
+
+func TestCanonicalKeepsFieldBoundaries(t *testing.T) {
+	a := models.LedgerEntry{Seq: 1, Reference: "REF-1", Description: "Setor|tunai"}
+	b := models.LedgerEntry{Seq: 1, Reference: "REF-1|Setor", Description: "tunai"}
+	if string(Canonical(&a)) == string(Canonical(&b)) {
+		t.Fatalf("entries with shifted field contents hash the same content %s", Canonical(&a))
+	}
+}
+
+// writeKeyPair writes a new RSA key pair as PEM files and returns their paths
+func writeKeyPair(t *testing.T) (privatePath, publicPath string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	dir := t.TempDir()
+	privatePath, publicPath = filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	if err := os.WriteFile(privatePath, privatePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, publicPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+func TestCheckpointSignatureVerifies(t *testing.T) {
+	privatePath, publicPath := writeKeyPair(t)
+	signingKey, err := LoadSigningKey(privatePath)
+	if err != nil {
+		t.Fatalf("load signing key: %v", err)
+	}
+	verifyKey, err := LoadVerifyKey(publicPath)
+	if err != nil {
+		t.Fatalf("load verify key: %v", err)
+	}
+
+	entries := testChain()
+	cp := &models.LedgerCheckpoint{Seq: 4, GlobalHash: entries[3].GlobalHash, CreatedAt: time.Now()}
+	if err := SignCheckpoint(signingKey, cp); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := VerifyCheckpoint(verifyKey, cp); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	otherPrivate, _ := writeKeyPair(t)
+	otherKey, err := LoadSigningKey(otherPrivate)
+	if err != nil {
+		t.Fatalf("load other key: %v", err)
+	}
+	forged := *cp
+	if err := SignCheckpoint(otherKey, &forged); err != nil {
+		t.Fatalf("sign with other key: %v", err)
+	}
+
+	tampered := map[string]models.LedgerCheckpoint{
+		"global hash":  {Seq: cp.Seq, GlobalHash: entries[2].GlobalHash, Signature: cp.Signature},
+		"seq":          {Seq: 3, GlobalHash: cp.GlobalHash, Signature: cp.Signature},
+		"other key":    forged,
+		"not base64":   {Seq: cp.Seq, GlobalHash: cp.GlobalHash, Signature: "not a signature!"},
+		"no signature": {Seq: cp.Seq, GlobalHash: cp.GlobalHash},
+		"truncated":    {Seq: cp.Seq, GlobalHash: cp.GlobalHash, Signature: cp.Signature[:40]},
+	}
+	for name, checkpoint := range tampered {
+		if err := VerifyCheckpoint(verifyKey, &checkpoint); err == nil {
+			t.Errorf("%s: checkpoint verified", name)
+		}
+	}
+
+	// the signature covers the chain position, not when it was taken
+	later := *cp
+	later.CreatedAt = cp.CreatedAt.Add(time.Hour)
+	if err := VerifyCheckpoint(verifyKey, &later); err != nil {
+		t.Errorf("checkpoint with another created_at: %v", err)
+	}
+}
+
+func TestLoadKeysReportsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{garbage, filepath.Join(dir, "missing.pem")} {
+		if _, err := LoadSigningKey(path); err == nil {
+			t.Errorf("signing key %s loaded", filepath.Base(path))
+		}
+		if _, err := LoadVerifyKey(path); err == nil {
+			t.Errorf("verify key %s loaded", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/alfaa19/service-account-test/internal/audit"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestContext tags the request context with the request ID, set by echo's
+// RequestID middleware which must run before it, and with the caller's IP as
+// decided by IPExtractor, so audit events cannot be given a forged address.
+// Requests start out anonymous on the API channel; the authentication
+// middleware fill in who the caller is.
+func RequestContext() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+
+			ctx := logger.ContextWithRequestID(req.Context(), requestID)
+			ctx = audit.NewContext(ctx, audit.Metadata{
+				Actor:     audit.ActorAnonymous,
+				Channel:   audit.ChannelAPI,
+				IP:        c.RealIP(),
+				RequestID: requestID,
+			})
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequestContextRecordsConnectionIP(t *testing.T) {
+	e := echo.New()
+	e.IPExtractor = IPExtractor(nil)
+
+	var recorded audit.Metadata
+	e.GET("/audited", func(c echo.Context) error {
+		recorded = audit.FromContext(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	}, RequestContext())
+
+	req := httptest.NewRequest(http.MethodGet, "/audited", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.1")
+	req.Header.Set(echo.HeaderXRealIP, "198.51.100.2")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	if recorded.IP != "203.0.113.7" {
+		t.Errorf("audit IP = %q, want the connection address 203.0.113.7", recorded.IP)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+// ClaimsKey is the echo context key holding the verified *auth.Claims
+const ClaimsKey = "auth_claims"
+
+// Errors rejecting customer requests
+var (
+	ErrMissingToken    = errcode.New("MISSING_TOKEN", "missing bearer token")
+	ErrNotAccountOwner = errcode.New("ACCOUNT_NOT_OWNED", "account does not belong to token holder")
+)
+
+// JWTAuth verifies the bearer access token, stores its claims in the echo
+// context under ClaimsKey and makes the customer the actor of the request
+func JWTAuth(tokens *auth.TokenManager, log *logger.CustomLogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			tokenString, found := strings.CutPrefix(header, "Bearer ")
+			if !found || tokenString == "" {
+				return i18n.ErrorResponse(c, http.StatusUnauthorized, ErrMissingToken)
+			}
+
+			claims, err := tokens.ParseAccessToken(tokenString)
+			if err != nil {
+				log.LogOperation(c.Request().Context(), "JWTAuth", "error", map[string]interface{}{
+					"error": err.Error(),
+				})
+				return i18n.ErrorResponse(c, http.StatusUnauthorized, service.ErrInvalidAccessToken)
+			}
+
+			c.Set(ClaimsKey, claims)
+			req := c.Request()
+			c.SetRequest(req.WithContext(audit.WithActor(req.Context(), "customer:"+claims.Subject)))
+			return next(c)
+		}
+	}
+}
+
+// AccountOwner rejects requests whose account number, taken from the
+// :noRekening path parameter or the no_rekening body field, differs from the
+// subject of the access token. It must run after JWTAuth.
+func AccountOwner(log *logger.CustomLogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := c.Get(ClaimsKey).(*auth.Claims)
+			if !ok {
+				return i18n.ErrorResponse(c, http.StatusUnauthorized, ErrMissingToken)
+			}
+
+			accountNumber, err := requestAccountNumber(c)
+			if err != nil {
+				return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+			}
+
+			if accountNumber != claims.Subject {
+				log.LogOperation(c.Request().Context(), "AccountOwner", "error", map[string]interface{}{
+					"error":      "token subject does not match account",
+					"account_id": accountNumber,
+				})
+				return i18n.ErrorResponse(c, http.StatusForbidden, ErrNotAccountOwner)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// requestAccountNumber reads the account number of the request without
+// consuming the body, so handlers can still bind it afterwards
+func requestAccountNumber(c echo.Context) (string, error) {
+	if noRekening := c.Param("noRekening"); noRekening != "" {
+		return noRekening, nil
+	}
+
+	req := c.Request()
+	if req.Body == nil {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		NoRekening string `json:"no_rekening"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return "", err
+		}
+	}
+	return payload.NoRekening, nil
+}
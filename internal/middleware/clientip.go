@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor decides the client IP returned by c.RealIP(). Without trusted
+// proxies it is the address of the connection, so X-Forwarded-For and
+// X-Real-IP sent by clients are ignored. With trusted proxies it is the
+// rightmost X-Forwarded-For address not in one of them; loopback, link-local
+// and private addresses are only trusted when listed.
+func IPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, network := range trustedProxies {
+		options = append(options, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
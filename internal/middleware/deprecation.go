@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Deprecation is when routes were deprecated and when they stop being served
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+}
+
+// Deprecated marks the responses of a deprecated route with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers, and links the route replacing it.
+// successor is an echo path; its parameters are filled in from the path,
+// query or no_rekening body field of the request, and the link is left out
+// when one of them is missing.
+func Deprecated(d Deprecation, successor string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+			if !d.Sunset.IsZero() {
+				header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if link, ok := successorPath(c, successor); ok {
+				header.Add("Link", "<"+link+`>; rel="successor-version"`)
+			}
+			return next(c)
+		}
+	}
+}
+
+func successorPath(c echo.Context, path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name, found := strings.CutPrefix(segment, ":")
+		if !found {
+			continue
+		}
+		value := c.Param(name)
+		if value == "" {
+			value = c.QueryParam(name)
+		}
+		if value == "" && name == "noRekening" {
+			value, _ = requestAccountNumber(c)
+		}
+		if value == "" {
+			return "", false
+		}
+		segments[i] = url.PathEscape(value)
+	}
+	return strings.Join(segments, "/"), true
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// ErrRateLimited answers requests over the rate limit
+	ErrRateLimited = errcode.New("RATE_LIMITED", "too many requests")
+	// ErrRateLimitUnavailable answers requests whose limit cannot be checked
+	ErrRateLimitUnavailable = errcode.New("RATE_LIMIT_UNAVAILABLE", "rate limit cannot be checked")
+)
+
+// KeyFunc extracts the rate limit key of a request
+type KeyFunc func(c echo.Context) (string, error)
+
+// KeyByIP limits per client IP address
+func KeyByIP(c echo.Context) (string, error) {
+	return c.RealIP(), nil
+}
+
+// KeyByAccount limits per account number, taken from the :noRekening path
+// parameter or the no_rekening body field
+func KeyByAccount(c echo.Context) (string, error) {
+	return requestAccountNumber(c)
+}
+
+// RateLimit rejects requests over policy with 429 Too Many Requests and a
+// Retry-After header. Store errors fail closed with 503 Service Unavailable,
+// so an unavailable shared store does not lift the limits.
+func RateLimit(store ratelimit.Store, policy ratelimit.Policy, key KeyFunc, log *logger.CustomLogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			k, err := key(c)
+			if err != nil {
+				return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+			}
+
+			allowed, retryAfter, err := store.Allow(ctx, policy, k)
+			if err != nil {
+				log.LogOperation(ctx, "RateLimit", "error", map[string]interface{}{
+					"error":  err.Error(),
+					"policy": policy.Name,
+				})
+				return i18n.ErrorResponse(c, http.StatusServiceUnavailable, ErrRateLimitUnavailable)
+			}
+			if !allowed {
+				log.LogOperation(ctx, "RateLimit", "warning", map[string]interface{}{
+					"policy": policy.Name,
+					"ip":     c.RealIP(),
+				})
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+				return i18n.ErrorResponse(c, http.StatusTooManyRequests, ErrRateLimited)
+			}
+
+			return next(c)
+		}
+	}
+}
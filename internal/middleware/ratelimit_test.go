@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+func testLogger(t *testing.T) *logger.CustomLogger {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// limitedServer serves GET /limited limited to one request per IP per hour
+func limitedServer(t *testing.T, trustedProxies []*net.IPNet) *echo.Echo {
+	t.Helper()
+	e := echo.New()
+	e.IPExtractor = IPExtractor(trustedProxies)
+	policy := ratelimit.Policy{Name: "test", Requests: 1, Window: time.Hour}
+	e.GET("/limited", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.RealIP())
+	}, RateLimit(ratelimit.NewMemoryStore(), policy, KeyByIP, testLogger(t)))
+	return e
+}
+
+func get(e *echo.Echo, remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	e := limitedServer(t, nil)
+
+	if rec := get(e, "203.0.113.7:5000", nil); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", rec.Code)
+	}
+	spoofed := []map[string]string{
+		{echo.HeaderXForwardedFor: "198.51.100.1"},
+		{echo.HeaderXForwardedFor: "198.51.100.2, 198.51.100.3"},
+		{echo.HeaderXRealIP: "198.51.100.4"},
+	}
+	for _, header := range spoofed {
+		if rec := get(e, "203.0.113.7:5001", header); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("request with %v status = %d, want 429", header, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitUsesForwardedForOfTrustedProxy(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := limitedServer(t, []*net.IPNet{proxies})
+
+	// the client prepends a forged address; the proxy appends the real one
+	header := map[string]string{echo.HeaderXForwardedFor: "198.51.100.1, 203.0.113.7"}
+	rec := get(e, "10.0.0.2:5000", header)
+	if rec.Code != http.StatusOK || rec.Body.String() != "203.0.113.7" {
+		t.Fatalf("first request = %d %q, want 200 from 203.0.113.7", rec.Code, rec.Body.String())
+	}
+	header[echo.HeaderXForwardedFor] = "198.51.100.9, 203.0.113.7"
+	if rec := get(e, "10.0.0.3:5000", header); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("forged leftmost address status = %d, want 429", rec.Code)
+	}
+	if rec := get(e, "10.0.0.2:5000", map[string]string{echo.HeaderXForwardedFor: "203.0.113.8"}); rec.Code != http.StatusOK {
+		t.Errorf("another client status = %d, want 200", rec.Code)
+	}
+
+	// private addresses outside TRUSTED_PROXIES do not get to forward
+	if rec := get(e, "192.168.1.5:5000", map[string]string{echo.HeaderXForwardedFor: "203.0.113.9"}); rec.Body.String() != "192.168.1.5" {
+		t.Errorf("untrusted private peer client IP = %q, want 192.168.1.5", rec.Body.String())
+	}
+}
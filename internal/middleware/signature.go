@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+// Partner signature headers
+const (
+	HeaderClientID  = "X-Client-ID"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// APIClientKey is the echo context key holding the authenticated *models.APIClient
+const APIClientKey = "api_client"
+
+// PartnerSignature verifies the HMAC-SHA256 signature of server-to-server
+// requests and tags the request context with the calling client, so every
+// LogOperation entry of the request carries its client_id and audit events
+// name the partner as actor
+func PartnerSignature(partners service.PartnerService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(req.Body)
+				if err != nil {
+					return i18n.ErrorResponse(c, http.StatusBadRequest, err)
+				}
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			client, err := partners.Authenticate(req.Context(), &service.PartnerRequest{
+				ClientID:  req.Header.Get(HeaderClientID),
+				Method:    req.Method,
+				Route:     c.Path(),
+				Path:      req.URL.RequestURI(),
+				BodyHash:  auth.BodyHash(body),
+				Timestamp: req.Header.Get(HeaderTimestamp),
+				Nonce:     req.Header.Get(HeaderNonce),
+				Signature: req.Header.Get(HeaderSignature),
+				IP:        c.RealIP(),
+			})
+			if err != nil {
+				return i18n.ErrorResponse(c, partnerErrorStatus(err), err)
+			}
+
+			c.Set(APIClientKey, client)
+			ctx := logger.ContextWithClientID(req.Context(), client.ClientID)
+			ctx = audit.WithChannel(audit.WithActor(ctx, "partner:"+client.ClientID), audit.ChannelPartner)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func partnerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrRouteNotAllowed), errors.Is(err, service.ErrIPNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUnknownClient),
+		errors.Is(err, service.ErrInvalidSignature),
+		errors.Is(err, service.ErrStaleTimestamp),
+		errors.Is(err, service.ErrNonceReused),
+		errors.Is(err, service.ErrMissingNonce),
+		errors.Is(err, service.ErrMalformedTimestamp):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
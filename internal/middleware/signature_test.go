@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+// clientRepo serves a single API client
+type clientRepo struct {
+	repository.Repository
+	client *models.APIClient
+}
+
+func (r *clientRepo) GetAPIClient(ctx context.Context, clientID string) (*models.APIClient, error) {
+	return r.client, nil
+}
+
+func TestPartnerSignatureChecksAllowlistAgainstConnectionIP(t *testing.T) {
+	repo := &clientRepo{client: &models.APIClient{
+		ClientID:      "partner",
+		SigningKey:    "key",
+		IPAllowlist:   []string{"203.0.113.7"},
+		AllowedRoutes: []string{"POST /partner"},
+		Active:        true,
+	}}
+	partners := service.NewPartnerService(repo, nil, time.Minute, testLogger(t))
+
+	e := echo.New()
+	e.IPExtractor = IPExtractor(nil)
+	e.POST("/partner", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, PartnerSignature(partners))
+
+	call := func(remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/partner", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(HeaderClientID, "partner")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	spoofed := []map[string]string{
+		{echo.HeaderXForwardedFor: "203.0.113.7"},
+		{echo.HeaderXRealIP: "203.0.113.7"},
+	}
+	for _, header := range spoofed {
+		if rec := call("198.51.100.1:5000", header); rec.Code != http.StatusForbidden {
+			t.Errorf("request with %v status = %d, want 403", header, rec.Code)
+		}
+	}
+
+	// the allowed address gets past the allowlist to the signature check
+	if rec := call("203.0.113.7:5000", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("allowed IP status = %d, want 401 for the missing signature", rec.Code)
+	}
+}
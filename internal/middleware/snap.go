@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/alfaa19/service-account-test/internal/snap"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+// SnapAuth verifies the B2B access token, the symmetric X-SIGNATURE and the
+// X-EXTERNAL-ID of SNAP transactional requests. serviceCode is used to build
+// the SNAP response code of rejected requests.
+func SnapAuth(partners service.PartnerService, serviceCode string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(req.Body)
+				if err != nil {
+					return snap.ErrorResponse(c, serviceCode, err)
+				}
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			accessToken, _ := strings.CutPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer ")
+			client, err := partners.AuthenticateSnap(req.Context(), &service.SnapRequest{
+				AccessToken: accessToken,
+				PartnerID:   req.Header.Get(snap.HeaderPartnerID),
+				ExternalID:  req.Header.Get(snap.HeaderExternalID),
+				Method:      req.Method,
+				Route:       c.Path(),
+				Path:        req.URL.RequestURI(),
+				Body:        body,
+				Timestamp:   req.Header.Get(snap.HeaderTimestamp),
+				Signature:   req.Header.Get(snap.HeaderSignature),
+				IP:          c.RealIP(),
+			})
+			if err != nil {
+				return snap.ErrorResponse(c, serviceCode, err)
+			}
+
+			c.Set(APIClientKey, client)
+			ctx := logger.ContextWithClientID(req.Context(), client.ClientID)
+			ctx = audit.WithChannel(audit.WithActor(ctx, "partner:"+client.ClientID), audit.ChannelSNAP)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
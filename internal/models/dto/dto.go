@@ -1,25 +1,270 @@
 package dto
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type AccountResponse struct {
 	NoRekening string `json:"no_rekening"`
 }
 
+// BalanceResponse carries the ledger balance and the part of it not reserved
+// by holds
 type BalanceResponse struct {
-	Saldo float64 `json:"saldo"`
+	Saldo         float64 `json:"saldo"`
+	SaldoTersedia float64 `json:"saldo_tersedia"`
+}
+
+// HistoricalBalanceResponse carries the balance at the end of a business date
+type HistoricalBalanceResponse struct {
+	Tanggal string  `json:"tanggal"`
+	Saldo   float64 `json:"saldo"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
+	Code   string `json:"code"`
 	Remark string `json:"remark"`
 }
 
 type AccountRegistration struct {
-	Nama string `json:"nama"`
-	NIK  string `json:"nik"`
-	NoHP string `json:"no_hp"`
+	Nama     string `json:"nama"`
+	NIK      string `json:"nik"`
+	NoHP     string `json:"no_hp"`
+	Password string `json:"password"`
+	Pin      string `json:"pin"`
 }
 
 type WithdrawDepositRequest struct {
 	NoRekening string  `json:"no_rekening"`
-	Amount     float64 `json:"saldo"`
+	Amount     float64 `json:"saldo" doc:"Amount to deposit or withdraw, not the resulting balance"`
+	Pin        string  `json:"pin,omitempty" doc:"Transaction PIN, required for withdrawals"`
+	Aktor      string  `json:"aktor,omitempty" doc:"Operator behind the request, recorded as maker when approval is needed"`
+}
+
+type ChangePinRequest struct {
+	NoRekening string `json:"no_rekening"`
+	PinLama    string `json:"pin_lama"`
+	PinBaru    string `json:"pin_baru"`
+}
+
+type LoginRequest struct {
+	NoRekening string `json:"no_rekening"`
+	Password   string `json:"password"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type ChangeStatusRequest struct {
+	Status string `json:"status" doc:"ACTIVE, FROZEN or DORMANT; accounts are closed through the closure route"`
+	Alasan string `json:"alasan"`
+	Aktor  string `json:"aktor" doc:"Operator behind the request, recorded after the calling partner client"`
+}
+
+type AccountStatusResponse struct {
+	NoRekening string `json:"no_rekening"`
+	StatusLama string `json:"status_lama"`
+	Status     string `json:"status"`
+}
+
+type CloseAccountRequest struct {
+	NoRekening      string `json:"no_rekening"`
+	Pin             string `json:"pin"`
+	MetodePencairan string `json:"metode_pencairan" doc:"TRANSFER or TUNAI"`
+	RekeningTujuan  string `json:"rekening_tujuan,omitempty" doc:"Beneficiary account of a TRANSFER payout"`
+	Alasan          string `json:"alasan"`
+}
+
+type CloseAccountResponse struct {
+	NoRekening      string  `json:"no_rekening"`
+	Status          string  `json:"status"`
+	MetodePencairan string  `json:"metode_pencairan"`
+	SaldoDicairkan  float64 `json:"saldo_dicairkan"`
+	RekeningTujuan  string  `json:"rekening_tujuan,omitempty"`
+	Referensi       string  `json:"referensi,omitempty"`
+}
+
+type CreateHoldRequest struct {
+	NoRekening  string  `json:"no_rekening"`
+	Jumlah      float64 `json:"jumlah"`
+	MasaBerlaku string  `json:"masa_berlaku,omitempty" doc:"Hold lifetime such as 30m or 24h, HOLD_DEFAULT_TTL when empty"`
+	Keterangan  string  `json:"keterangan"`
+}
+
+type CaptureHoldRequest struct {
+	Jumlah     float64 `json:"jumlah"`
+	Keterangan string  `json:"keterangan"`
+}
+
+type HoldResponse struct {
+	IDHold         string    `json:"id_hold"`
+	NoRekening     string    `json:"no_rekening"`
+	Jumlah         float64   `json:"jumlah"`
+	JumlahTerpakai float64   `json:"jumlah_terpakai"`
+	Sisa           float64   `json:"sisa"`
+	Status         string    `json:"status"`
+	BerlakuSampai  time.Time `json:"berlaku_sampai"`
+	Referensi      string    `json:"referensi,omitempty"`
+}
+
+type ReversalRequest struct {
+	Alasan string `json:"alasan"`
+	Aktor  string `json:"aktor" doc:"Operator behind the request, recorded after the calling partner client"`
+	Paksa  bool   `json:"paksa"`
+}
+
+type ReversalEntry struct {
+	IDTransaksi int64   `json:"id_transaksi"`
+	Koreksi     int64   `json:"koreksi_dari"`
+	NoRekening  string  `json:"no_rekening"`
+	Arah        string  `json:"arah"`
+	Jumlah      float64 `json:"jumlah"`
+	SaldoAkhir  float64 `json:"saldo_akhir"`
+}
+
+type ReversalResponse struct {
+	Referensi   string          `json:"referensi"`
+	IDTransaksi int64           `json:"id_transaksi"`
+	Alasan      string          `json:"alasan"`
+	Paksa       bool            `json:"paksa"`
+	Mutasi      []ReversalEntry `json:"mutasi"`
+}
+
+type DecisionRequest struct {
+	Aktor  string `json:"aktor"`
+	Alasan string `json:"alasan,omitempty"`
+}
+
+// PendingOperationResponse describes an operation waiting for, or decided
+// by, a checker
+type PendingOperationResponse struct {
+	IDPersetujuan string    `json:"id_persetujuan"`
+	Operasi       string    `json:"operasi"`
+	NoRekening    string    `json:"no_rekening"`
+	Jumlah        float64   `json:"jumlah"`
+	Status        string    `json:"status"`
+	Pembuat       string    `json:"pembuat"`
+	Pemeriksa     string    `json:"pemeriksa,omitempty"`
+	Catatan       string    `json:"catatan,omitempty"`
+	Hasil         string    `json:"hasil,omitempty"`
+	BerlakuSampai time.Time `json:"berlaku_sampai"`
+}
+
+// AuditEventResponse is one entry of the audit log
+type AuditEventResponse struct {
+	ID           int64           `json:"id"`
+	Aksi         string          `json:"aksi"`
+	Entitas      string          `json:"entitas"`
+	IDEntitas    string          `json:"id_entitas"`
+	NoRekening   string          `json:"no_rekening,omitempty"`
+	Aktor        string          `json:"aktor"`
+	Kanal        string          `json:"kanal"`
+	IP           string          `json:"ip,omitempty"`
+	IDPermintaan string          `json:"id_permintaan,omitempty"`
+	Sebelum      json.RawMessage `json:"sebelum,omitempty"`
+	Sesudah      json.RawMessage `json:"sesudah,omitempty"`
+	Alasan       string          `json:"alasan,omitempty"`
+	Waktu        time.Time       `json:"waktu"`
+}
+
+type WebhookSubscriptionRequest struct {
+	URL        string   `json:"url"`
+	Event      []string `json:"event,omitempty" doc:"Event types to receive, all when empty"`
+	NoRekening []string `json:"no_rekening" doc:"Accounts to receive events of, each one the partner may access"`
+}
+
+type WebhookSubscriptionResponse struct {
+	IDLangganan string    `json:"id_langganan"`
+	URL         string    `json:"url"`
+	Event       []string  `json:"event"`
+	NoRekening  []string  `json:"no_rekening"`
+	Aktif       bool      `json:"aktif"`
+	DibuatPada  time.Time `json:"dibuat_pada"`
+	Rahasia     string    `json:"rahasia,omitempty" doc:"Secret the deliveries are signed with, only returned when subscribing"`
+}
+
+// WebhookDeliveryResponse describes a delivery and, when a single delivery
+// is requested, its log of attempts
+type WebhookDeliveryResponse struct {
+	IDPengiriman        int64                    `json:"id_pengiriman"`
+	IDLangganan         string                   `json:"id_langganan"`
+	IDEvent             string                   `json:"id_event"`
+	Event               string                   `json:"event"`
+	Status              string                   `json:"status"`
+	Percobaan           int                      `json:"percobaan"`
+	KesalahanTerakhir   string                   `json:"kesalahan_terakhir,omitempty"`
+	KodeStatusTerakhir  int                      `json:"kode_status_terakhir,omitempty"`
+	PercobaanBerikutnya *time.Time               `json:"percobaan_berikutnya,omitempty"`
+	TerkirimPada        *time.Time               `json:"terkirim_pada,omitempty"`
+	DibuatPada          time.Time                `json:"dibuat_pada"`
+	Payload             json.RawMessage          `json:"payload,omitempty"`
+	Log                 []WebhookAttemptResponse `json:"log,omitempty"`
+}
+
+type WebhookAttemptResponse struct {
+	Percobaan  int       `json:"percobaan"`
+	KodeStatus int       `json:"kode_status,omitempty"`
+	Kesalahan  string    `json:"kesalahan,omitempty"`
+	DurasiMs   int64     `json:"durasi_ms"`
+	Waktu      time.Time `json:"waktu"`
+}
+
+// BalanceChangeEvent is the data of a balance stream event, one per ledger
+// entry of the account
+type BalanceChangeEvent struct {
+	IDTransaksi int64     `json:"id_transaksi"`
+	NoRekening  string    `json:"no_rekening"`
+	Referensi   string    `json:"referensi"`
+	Tipe        string    `json:"tipe"`
+	Arah        string    `json:"arah"`
+	Jumlah      float64   `json:"jumlah"`
+	SaldoAkhir  float64   `json:"saldo_akhir"`
+	Keterangan  string    `json:"keterangan"`
+	Waktu       time.Time `json:"waktu"`
+}
+
+// DepositRequest is the body of a v1 deposit, the account being in the path
+type DepositRequest struct {
+	Jumlah float64 `json:"jumlah" doc:"Amount to deposit"`
+}
+
+// WithdrawalRequest is the body of a v1 withdrawal, the account being in the
+// path
+type WithdrawalRequest struct {
+	Jumlah float64 `json:"jumlah" doc:"Amount to withdraw"`
+	Pin    string  `json:"pin"`
+	Aktor  string  `json:"aktor,omitempty" doc:"Operator behind the request, recorded as maker when approval is needed"`
+}
+
+// MutationResponse is the outcome of a v1 deposit or withdrawal: the amount
+// moved and the balances after it
+type MutationResponse struct {
+	NoRekening    string  `json:"no_rekening"`
+	Jumlah        float64 `json:"jumlah"`
+	Saldo         float64 `json:"saldo"`
+	SaldoTersedia float64 `json:"saldo_tersedia"`
+}
+
+// UpdatePinRequest is the body of a v1 PIN change
+type UpdatePinRequest struct {
+	PinLama string `json:"pin_lama"`
+	PinBaru string `json:"pin_baru"`
+}
+
+// AccountClosureRequest is the body of a v1 account closure
+type AccountClosureRequest struct {
+	Pin             string `json:"pin"`
+	MetodePencairan string `json:"metode_pencairan" doc:"TRANSFER or TUNAI"`
+	RekeningTujuan  string `json:"rekening_tujuan,omitempty" doc:"Beneficiary account of a TRANSFER payout"`
+	Alasan          string `json:"alasan"`
 }
@@ -0,0 +1,100 @@
+package dto
+
+// SnapResponse carries the response code and message present in every SNAP response
+type SnapResponse struct {
+	ResponseCode    string `json:"responseCode"`
+	ResponseMessage string `json:"responseMessage"`
+}
+
+type SnapAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+type SnapAccessTokenRequest struct {
+	GrantType      string                 `json:"grantType"`
+	AdditionalInfo map[string]interface{} `json:"additionalInfo,omitempty"`
+}
+
+type SnapAccessTokenResponse struct {
+	SnapResponse
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType"`
+	ExpiresIn   string `json:"expiresIn"`
+}
+
+type SnapBalanceInquiryRequest struct {
+	PartnerReferenceNo string   `json:"partnerReferenceNo"`
+	AccountNo          string   `json:"accountNo"`
+	BalanceTypes       []string `json:"balanceTypes,omitempty"`
+}
+
+type SnapAccountInfo struct {
+	BalanceType      string     `json:"balanceType"`
+	Amount           SnapAmount `json:"amount"`
+	AvailableBalance SnapAmount `json:"availableBalance"`
+	Status           string     `json:"status"`
+}
+
+type SnapBalanceInquiryResponse struct {
+	SnapResponse
+	ReferenceNo        string            `json:"referenceNo"`
+	PartnerReferenceNo string            `json:"partnerReferenceNo"`
+	AccountNo          string            `json:"accountNo"`
+	Name               string            `json:"name"`
+	AccountInfos       []SnapAccountInfo `json:"accountInfos"`
+}
+
+type SnapTransferAdditionalInfo struct {
+	Pin string `json:"pin"`
+}
+
+type SnapTransferIntrabankRequest struct {
+	PartnerReferenceNo   string                     `json:"partnerReferenceNo"`
+	Amount               SnapAmount                 `json:"amount"`
+	BeneficiaryAccountNo string                     `json:"beneficiaryAccountNo"`
+	SourceAccountNo      string                     `json:"sourceAccountNo"`
+	TransactionDate      string                     `json:"transactionDate"`
+	Remark               string                     `json:"remark"`
+	AdditionalInfo       SnapTransferAdditionalInfo `json:"additionalInfo"`
+}
+
+type SnapTransferIntrabankResponse struct {
+	SnapResponse
+	ReferenceNo          string     `json:"referenceNo"`
+	PartnerReferenceNo   string     `json:"partnerReferenceNo"`
+	Amount               SnapAmount `json:"amount"`
+	BeneficiaryAccountNo string     `json:"beneficiaryAccountNo"`
+	SourceAccountNo      string     `json:"sourceAccountNo"`
+	TransactionDate      string     `json:"transactionDate"`
+}
+
+type SnapHistoryAdditionalInfo struct {
+	AccountNo string `json:"accountNo"`
+}
+
+type SnapTransactionHistoryRequest struct {
+	PartnerReferenceNo string                    `json:"partnerReferenceNo"`
+	FromDateTime       string                    `json:"fromDateTime"`
+	ToDateTime         string                    `json:"toDateTime"`
+	PageSize           int                       `json:"pageSize"`
+	PageNumber         int                       `json:"pageNumber"`
+	AdditionalInfo     SnapHistoryAdditionalInfo `json:"additionalInfo"`
+}
+
+type SnapTransactionDetail struct {
+	DateTime string     `json:"dateTime"`
+	Amount   SnapAmount `json:"amount"`
+	Remark   string     `json:"remark"`
+	Status   string     `json:"status"`
+	Type     string     `json:"type"`
+	// AdditionalInfo carries our own ledger fields
+	AdditionalInfo map[string]string `json:"additionalInfo"`
+}
+
+type SnapTransactionHistoryResponse struct {
+	SnapResponse
+	ReferenceNo        string                  `json:"referenceNo"`
+	PartnerReferenceNo string                  `json:"partnerReferenceNo"`
+	DetailData         []SnapTransactionDetail `json:"detailData"`
+}
@@ -3,6 +3,11 @@ package models
 // internal/db/models.go
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -14,6 +19,695 @@ type Account struct {
 	NIK           string    `json:"nik"`
 	PhoneNumber   string    `json:"phone_number"`
 	Balance       float64   `json:"balance"`
+	HeldAmount    float64   `json:"held_amount"`
+	Status        string    `json:"status"`
+	AccountType   string    `json:"account_type"`
+	Product       string    `json:"product"`
+	KYCTier       string    `json:"kyc_tier"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
+
+// Default account type and KYC tier of new accounts. Other values are
+// assigned outside this service and only matter to the limit rules.
+const (
+	AccountTypeRegular = "REGULAR"
+	KYCTierBasic       = "BASIC"
+)
+
+// ProductTabungan is the savings product every new account is opened with
+const ProductTabungan = "TABUNGAN"
+
+// AvailableBalance is the ledger balance minus the funds reserved by holds
+func (a *Account) AvailableBalance() float64 {
+	return a.Balance - a.HeldAmount
+}
+
+// Account statuses
+const (
+	StatusActive  = "ACTIVE"
+	StatusFrozen  = "FROZEN"
+	StatusDormant = "DORMANT"
+	StatusClosed  = "CLOSED"
+)
+
+// statusTransitions lists the statuses each status may move to. CLOSED is final.
+var statusTransitions = map[string][]string{
+	StatusActive:  {StatusFrozen, StatusDormant, StatusClosed},
+	StatusFrozen:  {StatusActive, StatusClosed},
+	StatusDormant: {StatusActive, StatusFrozen, StatusClosed},
+	StatusClosed:  {},
+}
+
+// IsValidStatus reports whether status is a known account status
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an account may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusPolicy decides which account statuses accept debits and credits
+type StatusPolicy struct {
+	// FrozenAcceptsCredits lets frozen accounts keep receiving money
+	FrozenAcceptsCredits bool
+}
+
+// DebitStatuses are the statuses an account must have to be debited
+func (p StatusPolicy) DebitStatuses() []string {
+	return []string{StatusActive}
+}
+
+// CreditStatuses are the statuses an account must have to be credited
+func (p StatusPolicy) CreditStatuses() []string {
+	statuses := []string{StatusActive, StatusDormant}
+	if p.FrozenAcceptsCredits {
+		statuses = append(statuses, StatusFrozen)
+	}
+	return statuses
+}
+
+// Hold statuses. A hold stays ACTIVE until it is fully captured, released or
+// expired.
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+	HoldExpired  = "EXPIRED"
+)
+
+// Hold reserves part of an account's balance until it is captured or
+// released
+type Hold struct {
+	ID             string    `json:"id"`
+	AccountNumber  string    `json:"account_number"`
+	Amount         float64   `json:"amount"`
+	CapturedAmount float64   `json:"captured_amount"`
+	Status         string    `json:"status"`
+	Description    string    `json:"description"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Remaining is the part of the hold that is still reserved
+func (h *Hold) Remaining() float64 {
+	return h.Amount - h.CapturedAmount
+}
+
+// Transaction channels. Cash and transfer withdrawals have their own limits;
+// fees are set per channel.
+const (
+	ChannelCash     = "CASH"
+	ChannelTransfer = "TRANSFER"
+	ChannelDeposit  = "DEPOSIT"
+)
+
+// WithdrawalUsage is what an account has withdrawn through one channel on
+// one business day
+type WithdrawalUsage struct {
+	AccountNumber string  `json:"account_number"`
+	BusinessDate  string  `json:"business_date"`
+	Channel       string  `json:"channel"`
+	TotalAmount   float64 `json:"total_amount"`
+	Count         int     `json:"count"`
+}
+
+// EndOfDayBalance is an account's ledger balance at the end of a business day
+type EndOfDayBalance struct {
+	AccountNumber string  `json:"account_number"`
+	Product       string  `json:"product"`
+	Balance       float64 `json:"balance"`
+}
+
+// InterestAccrual is one day of interest earned by an account, posted at
+// the end of the month
+type InterestAccrual struct {
+	AccountNumber    string  `json:"account_number"`
+	BusinessDate     string  `json:"business_date"`
+	Product          string  `json:"product"`
+	Balance          float64 `json:"balance"`
+	AnnualRate       float64 `json:"annual_rate"`
+	Amount           float64 `json:"amount"`
+	PostingReference string  `json:"posting_reference,omitempty"`
+}
+
+// InterestPosting is the monthly credit of accrued interest and the tax
+// withheld from it
+type InterestPosting struct {
+	AccountNumber string    `json:"account_number"`
+	Period        string    `json:"period"`
+	Gross         float64   `json:"gross"`
+	Tax           float64   `json:"tax"`
+	Net           float64   `json:"net"`
+	Reference     string    `json:"reference"`
+	PostedAt      time.Time `json:"posted_at"`
+}
+
+// AdminFeeCharge is the monthly admin fee taken from an account
+type AdminFeeCharge struct {
+	AccountNumber string    `json:"account_number"`
+	Period        string    `json:"period"`
+	Amount        float64   `json:"amount"`
+	Reference     string    `json:"reference"`
+	ChargedAt     time.Time `json:"charged_at"`
+}
+
+// Statement is an account's rekening koran for one month: the opening
+// balance, every ledger entry and the closing balance
+type Statement struct {
+	AccountNumber  string        `json:"account_number"`
+	Name           string        `json:"name"`
+	MaskedNIK      string        `json:"masked_nik"`
+	Period         string        `json:"period"`
+	OpeningBalance float64       `json:"opening_balance"`
+	ClosingBalance float64       `json:"closing_balance"`
+	TotalDebit     float64       `json:"total_debit"`
+	TotalCredit    float64       `json:"total_credit"`
+	Transactions   []Transaction `json:"transactions"`
+	GeneratedAt    time.Time     `json:"generated_at"`
+}
+
+// BalanceSnapshot is an account's ledger balance at the close of a business
+// day
+type BalanceSnapshot struct {
+	BusinessDate  string  `json:"business_date"`
+	AccountNumber string  `json:"account_number"`
+	Balance       float64 `json:"balance"`
+}
+
+// EOD run statuses
+const (
+	EODRunning   = "RUNNING"
+	EODCompleted = "COMPLETED"
+)
+
+// EODRun is the end-of-day close of one business date with its totals
+type EODRun struct {
+	BusinessDate     string     `json:"business_date"`
+	Status           string     `json:"status"`
+	AccountCount     int        `json:"account_count"`
+	TotalBalance     float64    `json:"total_balance"`
+	TotalDeposits    float64    `json:"total_deposits"`
+	TotalWithdrawals float64    `json:"total_withdrawals"`
+	TotalFees        float64    `json:"total_fees"`
+	StartedAt        time.Time  `json:"started_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
+
+// LedgerBalance compares an account's stored balance with the balance
+// recomputed from its ledger entries
+type LedgerBalance struct {
+	AccountNumber string  `json:"account_number"`
+	Status        string  `json:"status"`
+	Balance       float64 `json:"balance"`
+	LedgerBalance float64 `json:"ledger_balance"`
+	EntryCount    int     `json:"entry_count"`
+}
+
+// Difference is the stored balance minus the ledger balance
+func (b LedgerBalance) Difference() float64 {
+	return math.Round((b.Balance-b.LedgerBalance)*100) / 100
+}
+
+// Discrepancy is an account whose stored balance does not match its ledger
+type Discrepancy struct {
+	LedgerBalance
+	Difference float64 `json:"difference"`
+	Frozen     bool    `json:"frozen"`
+}
+
+// ReconciliationReport is the result of one ledger reconciliation run
+type ReconciliationReport struct {
+	StartedAt       time.Time     `json:"started_at"`
+	FinishedAt      time.Time     `json:"finished_at"`
+	AccountsChecked int           `json:"accounts_checked"`
+	Discrepancies   []Discrepancy `json:"discrepancies"`
+}
+
+// Reversal compensates one ledger transaction, or both legs of a transfer,
+// with entries linked to the originals
+type Reversal struct {
+	Reference     string        `json:"reference"`
+	TransactionID int64         `json:"transaction_id"`
+	Reason        string        `json:"reason"`
+	Actor         string        `json:"actor"`
+	Forced        bool          `json:"forced"`
+	Entries       []Transaction `json:"entries"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// Operations that can wait for a checker's approval
+const (
+	OperationWithdrawal = "WITHDRAWAL"
+	OperationReversal   = "REVERSAL"
+)
+
+// Pending operation statuses. APPROVED means a checker approved it and it is
+// being executed; it ends as EXECUTED or FAILED.
+const (
+	PendingStatusPending  = "PENDING"
+	PendingStatusApproved = "APPROVED"
+	PendingStatusExecuted = "EXECUTED"
+	PendingStatusFailed   = "FAILED"
+	PendingStatusRejected = "REJECTED"
+	PendingStatusExpired  = "EXPIRED"
+)
+
+// PendingOperation is a high-value operation held until a checker other than
+// its maker approves or rejects it
+type PendingOperation struct {
+	ID            string          `json:"id"`
+	Operation     string          `json:"operation"`
+	AccountNumber string          `json:"account_number"`
+	Amount        float64         `json:"amount"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Maker         string          `json:"maker"`
+	Checker       string          `json:"checker,omitempty"`
+	Note          string          `json:"note,omitempty"`
+	Result        string          `json:"result,omitempty"`
+	ExpiresAt     time.Time       `json:"expires_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DecidedAt     *time.Time      `json:"decided_at,omitempty"`
+}
+
+// WithdrawalPayload is the stored request of a pending withdrawal
+type WithdrawalPayload struct {
+	AccountNumber string  `json:"account_number"`
+	Amount        float64 `json:"amount"`
+}
+
+// ReversalPayload is the stored request of a pending reversal
+type ReversalPayload struct {
+	TransactionID int64  `json:"transaction_id"`
+	Reason        string `json:"reason"`
+	Actor         string `json:"actor"`
+	Force         bool   `json:"force"`
+}
+
+// PendingOperationEvent is one step in the audit trail of a pending operation
+type PendingOperationEvent struct {
+	OperationID string    `json:"operation_id"`
+	Status      string    `json:"status"`
+	Actor       string    `json:"actor"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Actions recorded in the audit log
+const (
+	AuditAccountCreated   = "ACCOUNT_CREATED"
+	AuditBalanceChanged   = "BALANCE_CHANGED"
+	AuditStatusChanged    = "STATUS_CHANGED"
+	AuditHoldCreated      = "HOLD_CREATED"
+	AuditHoldReleased     = "HOLD_RELEASED"
+	AuditReversalCreated  = "REVERSAL_CREATED"
+	AuditAPIClientCreated = "API_CLIENT_CREATED"
+	AuditApprovalPrefix   = "APPROVAL_"
+)
+
+// Kinds of entity an audit event is about
+const (
+	EntityAccount          = "ACCOUNT"
+	EntityTransaction      = "TRANSACTION"
+	EntityHold             = "HOLD"
+	EntityReversal         = "REVERSAL"
+	EntityAPIClient        = "API_CLIENT"
+	EntityPendingOperation = "PENDING_OPERATION"
+)
+
+// AuditEvent is one append-only record of a state change: who made it,
+// through which channel, and the state before and after
+type AuditEvent struct {
+	ID            int64           `json:"id"`
+	Action        string          `json:"action"`
+	EntityType    string          `json:"entity_type"`
+	EntityID      string          `json:"entity_id"`
+	AccountNumber string          `json:"account_number,omitempty"`
+	Actor         string          `json:"actor"`
+	Channel       string          `json:"channel"`
+	IP            string          `json:"ip,omitempty"`
+	RequestID     string          `json:"request_id,omitempty"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	Reason        string          `json:"reason,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit events. Empty fields and zero times match
+// everything.
+type AuditFilter struct {
+	AccountNumber string
+	Actor         string
+	From          time.Time
+	To            time.Time
+	Limit         int
+	Offset        int
+}
+
+// OutboxEvent is a domain event waiting in the outbox to be published
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	SchemaVersion int             `json:"schema_version"`
+	AggregateID   string          `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Statuses of a webhook delivery. A delivery that failed too often is DEAD
+// until the partner asks for it again.
+const (
+	WebhookPending   = "PENDING"
+	WebhookDelivered = "DELIVERED"
+	WebhookDead      = "DEAD"
+)
+
+// WebhookSubscription sends a partner the events of the listed types on the
+// listed accounts; an empty type list matches every type. Deliveries are
+// signed with SigningSecret, which the partner only sees when subscribing.
+type WebhookSubscription struct {
+	ID             string    `json:"id"`
+	ClientID       string    `json:"client_id"`
+	URL            string    `json:"url"`
+	EventTypes     []string  `json:"event_types"`
+	AccountNumbers []string  `json:"account_numbers"`
+	SigningSecret  string    `json:"-"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event on its way to one subscription
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookAttempt is an entry of the delivery log
+type WebhookAttempt struct {
+	ID         int64     `json:"id"`
+	DeliveryID int64     `json:"delivery_id"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LedgerEntry is a chained ledger entry in the exact text form the database
+// stores, which is what its hashes are computed over
+type LedgerEntry struct {
+	Seq           int64
+	TransactionID int64
+	Reference     string
+	AccountNumber string
+	Type          string
+	Direction     string
+	Amount        string
+	BalanceAfter  string
+	Description   string
+	CreatedAt     string
+	AccountHash   string
+	GlobalHash    string
+}
+
+// LedgerCheckpoint is a signed statement of the global chain head
+type LedgerCheckpoint struct {
+	Seq        int64     `json:"seq"`
+	GlobalHash string    `json:"global_hash"`
+	Signature  string    `json:"signature"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Kinds of broken links reported by the chain verifier
+const (
+	ChainBreakSequence   = "sequence"
+	ChainBreakGlobal     = "global"
+	ChainBreakAccount    = "account"
+	ChainBreakHead       = "head"
+	ChainBreakCheckpoint = "checkpoint"
+)
+
+// ChainBreak is the first broken link the verifier found
+type ChainBreak struct {
+	Kind          string `json:"kind"`
+	Seq           int64  `json:"seq"`
+	TransactionID int64  `json:"transaction_id,omitempty"`
+	AccountNumber string `json:"account_number,omitempty"`
+	Expected      string `json:"expected"`
+	Actual        string `json:"actual"`
+}
+
+// ChainVerification is the result of walking the ledger chains
+type ChainVerification struct {
+	EntriesChecked     int64       `json:"entries_checked"`
+	UnchainedEntries   int64       `json:"unchained_entries"`
+	CheckpointsChecked int         `json:"checkpoints_checked"`
+	HeadSeq            int64       `json:"head_seq"`
+	HeadHash           string      `json:"head_hash"`
+	Break              *ChainBreak `json:"break,omitempty"`
+}
+
+// How the remaining balance is paid out when an account is closed
+const (
+	PayoutTransfer = "TRANSFER"
+	PayoutCash     = "TUNAI"
+)
+
+// AccountClosure is the outcome of closing an account
+type AccountClosure struct {
+	AccountNumber string    `json:"account_number"`
+	PayoutMethod  string    `json:"payout_method"`
+	PayoutAmount  float64   `json:"payout_amount"`
+	Beneficiary   string    `json:"beneficiary,omitempty"`
+	Reference     string    `json:"reference,omitempty"`
+	ClosedAt      time.Time `json:"closed_at"`
+}
+
+// AccountStatusChange records one status transition of an account
+type AccountStatusChange struct {
+	ID            int       `json:"id"`
+	AccountNumber string    `json:"account_number"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Credential holds the hashed login secret (PIN or password) of an account
+type Credential struct {
+	AccountNumber string    `json:"account_number"`
+	PasswordHash  string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RefreshToken represents an issued refresh token, stored by its hash only
+type RefreshToken struct {
+	ID            int        `json:"id"`
+	TokenHash     string     `json:"-"`
+	AccountNumber string     `json:"account_number"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// AccountPin holds the hashed transaction PIN of an account and its lockout state
+type AccountPin struct {
+	AccountNumber  string     `json:"account_number"`
+	PinHash        string     `json:"-"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PinAttempt is an audit record of a single PIN verification
+type PinAttempt struct {
+	ID            int       `json:"id"`
+	AccountNumber string    `json:"account_number"`
+	Operation     string    `json:"operation"`
+	Success       bool      `json:"success"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// APIClient is a registered partner system allowed to call the API with
+// HMAC-signed requests. AllowedAccounts lists the account numbers it may read
+// and subscribe to, AllAccounts granting every account. SigningKey is the
+// plaintext equivalent of the client secret, see auth.SigningKey.
+type APIClient struct {
+	ClientID        string    `json:"client_id"`
+	Name            string    `json:"name"`
+	SigningKey      string    `json:"-"`
+	AllowedRoutes   []string  `json:"allowed_routes"`
+	IPAllowlist     []string  `json:"ip_allowlist"`
+	Roles           []string  `json:"roles"`
+	AllowedAccounts []string  `json:"allowed_accounts"`
+	PublicKey       string    `json:"-"`
+	Active          bool      `json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Roles a partner client can be granted for back-office operations
+const (
+	RoleTeller     = "teller"
+	RoleSupervisor = "supervisor"
+	RoleChecker    = "checker"
+	RoleCompliance = "compliance"
+)
+
+// HasRole reports whether the client was granted role
+func (c *APIClient) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AllAccounts grants a client every account
+const AllAccounts = "*"
+
+// CanAccessAccount reports whether the client was granted accountNumber
+func (c *APIClient) CanAccessAccount(accountNumber string) bool {
+	for _, allowed := range c.AllowedAccounts {
+		if allowed == AllAccounts || allowed == accountNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// Transaction types recorded in the ledger
+const (
+	TransactionDeposit       = "DEPOSIT"
+	TransactionWithdrawal    = "WITHDRAWAL"
+	TransactionTransferIn    = "TRANSFER_IN"
+	TransactionTransferOut   = "TRANSFER_OUT"
+	TransactionClosurePayout = "CLOSURE_PAYOUT"
+	TransactionHoldCapture   = "HOLD_CAPTURE"
+	TransactionInterest      = "INTEREST"
+	TransactionInterestTax   = "INTEREST_TAX"
+	TransactionFee           = "FEE"
+	TransactionAdminFee      = "ADMIN_FEE"
+	TransactionReversal      = "REVERSAL"
+	// TransactionOpeningBalance carries the balance of accounts opened
+	// before the ledger; only the migration writes it
+	TransactionOpeningBalance = "OPENING_BALANCE"
+)
+
+// Ledger entry directions
+const (
+	DirectionDebit  = "D"
+	DirectionCredit = "C"
+)
+
+// businessLocation is the time zone business days are counted in. Jakarta
+// has no daylight saving, so a fixed offset is exact when tzdata is missing.
+var businessLocation = loadBusinessLocation()
+
+func loadBusinessLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// BusinessDate returns the calendar day of t in Asia/Jakarta as YYYY-MM-DD
+func BusinessDate(t time.Time) string {
+	return t.In(businessLocation).Format("2006-01-02")
+}
+
+// BusinessDayBounds returns the start and end of a YYYY-MM-DD business day.
+// Timestamps are stored without a time zone in the server's local time, so
+// the bounds are returned in time.Local to compare against them.
+func BusinessDayBounds(date string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", date, businessLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start.In(time.Local), start.AddDate(0, 0, 1).In(time.Local), nil
+}
+
+// PeriodBounds returns the start and end of a YYYY-MM month of business days,
+// in time.Local like BusinessDayBounds
+func PeriodBounds(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", period, businessLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start.In(time.Local), start.AddDate(0, 1, 0).In(time.Local), nil
+}
+
+// BusinessTime returns t in Asia/Jakarta for display
+func BusinessTime(t time.Time) time.Time {
+	return t.In(businessLocation)
+}
+
+// MaskNIK keeps the first and last four digits of a NIK
+func MaskNIK(nik string) string {
+	if len(nik) <= 8 {
+		return strings.Repeat("*", len(nik))
+	}
+	return nik[:4] + strings.Repeat("*", len(nik)-8) + nik[len(nik)-4:]
+}
+
+// MaskPhone keeps the last four digits of a phone number
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// NewReference returns a unique reference shared by the ledger entries of
+// one business transaction
+func NewReference() string {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	return time.Now().Format("20060102150405") + hex.EncodeToString(buf)
+}
+
+// Transaction is a single ledger entry on one account
+type Transaction struct {
+	ID            int64     `json:"id"`
+	Reference     string    `json:"reference"`
+	AccountNumber string    `json:"account_number"`
+	Type          string    `json:"type"`
+	Direction     string    `json:"direction"`
+	Amount        float64   `json:"amount"`
+	BalanceAfter  float64   `json:"balance_after"`
+	Description   string    `json:"description"`
+	ReversalOf    *int64    `json:"reversal_of,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,231 @@
+// Package openapi builds the OpenAPI 3 document of the REST API. Request and
+// response schemas are derived from the Go types the handlers bind and
+// return, so the document follows the dto package as it changes.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operation describes one route
+type Operation struct {
+	Method      string
+	Path        string // echo path, e.g. /saldo/:noRekening
+	Tag         string
+	Summary     string
+	Description string
+	// Security names the security schemes the route requires, all of them
+	Security []string
+	Query    []Param
+	Headers  []Param
+	// Request is a value of the JSON request body type, nil without a body
+	Request   interface{}
+	Responses []Response
+	// Deprecated marks routes kept only for existing clients
+	Deprecated bool
+}
+
+// Param is a query or header parameter. Path parameters are taken from the
+// path.
+type Param struct {
+	Name        string
+	Description string
+	Type        string // string when empty
+	Required    bool
+}
+
+// Response is one possible response of an operation
+type Response struct {
+	Status      int
+	Description string
+	// Body is a value of the response type, or OneOf; nil without a body
+	Body interface{}
+	// ContentTypes defaults to application/json. Without a Body the content
+	// is binary.
+	ContentTypes []string
+}
+
+// OneOf is a response body that is any one of several types
+type OneOf []interface{}
+
+// Info is the title and version of the document
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityScheme is an OpenAPI security scheme object
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Path converts an echo path to an OpenAPI path, :id becoming {id}
+func Path(echoPath string) string {
+	return pathParam.ReplaceAllString(echoPath, "{$1}")
+}
+
+// Build assembles the document of ops
+func Build(info Info, schemes map[string]SecurityScheme, ops []Operation) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]map[string]*operation),
+	}
+
+	for _, op := range ops {
+		path := Path(op.Path)
+		out := &operation{
+			Summary:     op.Summary,
+			Description: op.Description,
+			OperationID: operationID(op.Method, op.Path),
+			Responses:   make(map[string]response),
+			Security:    []map[string][]string{},
+			Deprecated:  op.Deprecated,
+		}
+		if op.Tag != "" {
+			out.Tags = []string{op.Tag}
+		}
+		if len(op.Security) > 0 {
+			requirement := make(map[string][]string, len(op.Security))
+			for _, name := range op.Security {
+				requirement[name] = []string{}
+			}
+			out.Security = append(out.Security, requirement)
+		}
+
+		for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			out.Parameters = append(out.Parameters, parameter{
+				Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+		for _, p := range op.Query {
+			out.Parameters = append(out.Parameters, p.parameter("query"))
+		}
+		for _, p := range op.Headers {
+			out.Parameters = append(out.Parameters, p.parameter("header"))
+		}
+
+		if op.Request != nil {
+			out.RequestBody = &requestBody{
+				Required: true,
+				Content:  map[string]mediaType{"application/json": {Schema: g.schema(op.Request)}},
+			}
+		}
+
+		for _, r := range op.Responses {
+			description := r.Description
+			if description == "" {
+				description = http.StatusText(r.Status)
+			}
+			resp := response{Description: description}
+			if r.Body != nil || len(r.ContentTypes) > 0 {
+				schema := &Schema{Type: "string", Format: "binary"}
+				if r.Body != nil {
+					schema = g.schema(r.Body)
+				}
+				types := r.ContentTypes
+				if len(types) == 0 {
+					types = []string{"application/json"}
+				}
+				resp.Content = make(map[string]mediaType, len(types))
+				for _, t := range types {
+					resp.Content[t] = mediaType{Schema: schema}
+				}
+			}
+			out.Responses[strconv.Itoa(r.Status)] = resp
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*operation)
+		}
+		doc.Paths[path][strings.ToLower(op.Method)] = out
+	}
+
+	doc.Components = components{Schemas: g.schemas, SecuritySchemes: schemes}
+	return doc
+}
+
+func (p Param) parameter(in string) parameter {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	return parameter{
+		Name:        p.Name,
+		In:          in,
+		Description: p.Description,
+		Required:    p.Required,
+		Schema:      &Schema{Type: typ},
+	}
+}
+
+// operationID derives a stable ID such as postHoldIdHoldCapture
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '-' || r == '.' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+// Package ratelimit provides token bucket rate limiting behind a pluggable
+// Store, so the in-process store can later be swapped for a shared one.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Policy allows Requests per Window for each key, with bursts up to Requests
+type Policy struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+}
+
+// ParsePolicy parses a "requests/window" string such as "10/1m"
+func ParsePolicy(name, value string) (Policy, error) {
+	requests, window, found := strings.Cut(value, "/")
+	if !found {
+		return Policy{}, fmt.Errorf("rate limit %q must look like 10/1m", value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q has an invalid request count", value)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q has an invalid window", value)
+	}
+	return Policy{Name: name, Requests: n, Window: d}, nil
+}
+
+// Store decides whether a request identified by key may proceed under a
+// policy. When it may not, retryAfter tells how long until it would.
+type Store interface {
+	Allow(ctx context.Context, policy Policy, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type visitor struct {
+	limiter  *rate.Limiter
+	window   time.Duration
+	lastSeen time.Time
+}
+
+// MemoryStore keeps one token bucket per policy and key in process memory.
+// Buckets idle for longer than their window are dropped.
+type MemoryStore struct {
+	mu          sync.Mutex
+	visitors    map[string]*visitor
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		visitors: map[string]*visitor{},
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Allow(ctx context.Context, policy Policy, key string) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.cleanup(now)
+
+	id := policy.Name + ":" + key
+	v, ok := s.visitors[id]
+	if !ok {
+		every := rate.Every(policy.Window / time.Duration(policy.Requests))
+		v = &visitor{limiter: rate.NewLimiter(every, policy.Requests), window: policy.Window}
+		s.visitors[id] = v
+	}
+	v.lastSeen = now
+
+	reservation := v.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay, nil
+	}
+	return true, 0, nil
+}
+
+// cleanup drops buckets idle for longer than their window; such a bucket is
+// full again, so forgetting it does not change any decision
+func (s *MemoryStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < time.Minute {
+		return
+	}
+	s.lastCleanup = now
+	for id, v := range s.visitors {
+		if now.Sub(v.lastSeen) > v.window {
+			delete(s.visitors, id)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package reconciliation
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// Job reconciles the ledger every interval and saves each report
+type Job struct {
+	service  service.ReconciliationService
+	interval time.Duration
+	freeze   bool
+	dir      string
+	format   string
+	log      *logger.CustomLogger
+}
+
+func NewJob(service service.ReconciliationService, interval time.Duration, freeze bool, dir, format string, log *logger.CustomLogger) *Job {
+	return &Job{
+		service:  service,
+		interval: interval,
+		freeze:   freeze,
+		dir:      dir,
+		format:   format,
+		log:      log,
+	}
+}
+
+// Run reconciles every interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.run(ctx)
+		}
+	}
+}
+
+func (j *Job) run(ctx context.Context) {
+	report, err := j.service.Reconcile(ctx, j.freeze)
+	if err != nil {
+		j.log.LogWarning(ctx, "Reconciliation failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	path, err := Save(j.dir, report, j.format)
+	if err != nil {
+		j.log.LogWarning(ctx, "Failed to save reconciliation report", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(report.Discrepancies) > 0 {
+		j.log.LogWarning(ctx, "Ledger discrepancies found", map[string]interface{}{
+			"count":  len(report.Discrepancies),
+			"report": path,
+		})
+	}
+}
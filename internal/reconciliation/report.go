@@ -0,0 +1,85 @@
+// Package reconciliation writes ledger reconciliation reports and runs the
+// reconciliation on a schedule.
+package reconciliation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// Output formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// FileName returns the file name a report is saved under
+func FileName(report *models.ReconciliationReport, format string) string {
+	return fmt.Sprintf("rekonsiliasi-%s.%s", models.BusinessTime(report.StartedAt).Format("20060102-150405"), format)
+}
+
+// Write renders a report in the given format
+func Write(w io.Writer, report *models.ReconciliationReport, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, report)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// WriteCSV writes one row per discrepancy
+func WriteCSV(w io.Writer, report *models.ReconciliationReport) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"No Rekening", "Status", "Saldo", "Saldo Ledger", "Selisih", "Jumlah Mutasi", "Dibekukan"},
+	}
+	for _, d := range report.Discrepancies {
+		rows = append(rows, []string{
+			d.AccountNumber,
+			d.Status,
+			amount(d.Balance),
+			amount(d.LedgerBalance.LedgerBalance),
+			amount(d.Difference),
+			strconv.Itoa(d.EntryCount),
+			strconv.FormatBool(d.Frozen),
+		})
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Save writes a report into dir and returns its path
+func Save(dir string, report *models.ReconciliationReport, format string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, FileName(report, format))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := Write(f, report, format); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+func (r *repository) CreateAPIClient(ctx context.Context, client *models.APIClient) error {
+	query := `INSERT INTO api_clients (client_id, name, signing_key, allowed_routes, ip_allowlist, roles, allowed_accounts, public_key, active, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	r.log.LogOperation(ctx, "CreateAPIClient", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		_, err := tx.DB.ExecContext(ctx, query,
+			client.ClientID,
+			client.Name,
+			client.SigningKey,
+			strings.Join(client.AllowedRoutes, ","),
+			strings.Join(client.IPAllowlist, ","),
+			strings.Join(client.Roles, ","),
+			strings.Join(client.AllowedAccounts, ","),
+			client.PublicKey,
+			client.Active,
+			client.CreatedAt,
+			client.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, &models.AuditEvent{
+			Action:     models.AuditAPIClientCreated,
+			EntityType: models.EntityAPIClient,
+			EntityID:   client.ClientID,
+			After:      auditState(client),
+			CreatedAt:  client.CreatedAt,
+		})
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateAPIClient", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateAPIClient", "success", map[string]interface{}{
+		"api_client_id": client.ClientID,
+	})
+	return nil
+}
+
+func (r *repository) GetAPIClient(ctx context.Context, clientID string) (*models.APIClient, error) {
+	var client models.APIClient
+	var allowedRoutes, ipAllowlist, roles, allowedAccounts string
+	query := `SELECT client_id, name, signing_key, allowed_routes, ip_allowlist, roles, allowed_accounts, public_key, active, created_at, updated_at
+			 FROM api_clients WHERE client_id = $1`
+
+	r.log.LogOperation(ctx, "GetAPIClient", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.DB.QueryRowContext(ctx, query, clientID).Scan(
+		&client.ClientID,
+		&client.Name,
+		&client.SigningKey,
+		&allowedRoutes,
+		&ipAllowlist,
+		&roles,
+		&allowedAccounts,
+		&client.PublicKey,
+		&client.Active,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetAPIClient", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	client.AllowedRoutes = splitList(allowedRoutes)
+	client.IPAllowlist = splitList(ipAllowlist)
+	client.Roles = splitList(roles)
+	client.AllowedAccounts = splitList(allowedAccounts)
+
+	r.log.LogOperation(ctx, "GetAPIClient", "success", map[string]interface{}{
+		"api_client_id": client.ClientID,
+	})
+	return &client, nil
+}
+
+// UseNonce records a nonce for a client. It returns false when the nonce was
+// already used and has not expired yet.
+func (r *repository) UseNonce(ctx context.Context, clientID, nonce string, expiresAt time.Time) (bool, error) {
+	cleanup := `DELETE FROM api_nonces WHERE client_id = $1 AND expires_at < CURRENT_TIMESTAMP`
+	query := `INSERT INTO api_nonces (client_id, nonce, expires_at) VALUES ($1, $2, $3)
+			 ON CONFLICT (client_id, nonce) DO NOTHING`
+
+	if _, err := r.DB.ExecContext(ctx, cleanup, clientID); err != nil {
+		r.log.LogOperation(ctx, "UseNonce", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, clientID, nonce, expiresAt)
+	if err != nil {
+		r.log.LogOperation(ctx, "UseNonce", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	return rowAffected == 1, nil
+}
+
+// UseExternalID records a partner's X-EXTERNAL-ID for a business date. It
+// returns false when the same ID was already used by that partner on that date.
+func (r *repository) UseExternalID(ctx context.Context, partnerID, externalID string, businessDate time.Time) (bool, error) {
+	query := `INSERT INTO external_ids (partner_id, external_id, business_date) VALUES ($1, $2, $3)
+			 ON CONFLICT (partner_id, external_id, business_date) DO NOTHING`
+
+	result, err := r.DB.ExecContext(ctx, query, partnerID, externalID, businessDate.Format("2006-01-02"))
+	if err != nil {
+		r.log.LogOperation(ctx, "UseExternalID", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	return rowAffected == 1, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
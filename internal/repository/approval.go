@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+var ErrPendingOperationNotFound = errcode.New("PENDING_OPERATION_NOT_FOUND", "pending operation not found")
+
+const pendingOperationColumns = `id, operation, account_number, amount, payload, status, maker, checker, note, result, expires_at, created_at, decided_at`
+
+func (r *repository) CreatePendingOperation(ctx context.Context, op *models.PendingOperation) error {
+	query := `INSERT INTO pending_operations (id, operation, account_number, amount, payload, status, maker, expires_at, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	r.log.LogOperation(ctx, "CreatePendingOperation", "start", map[string]interface{}{
+		"account_id": op.AccountNumber,
+		"operation":  op.Operation,
+	})
+
+	_, err := r.DB.ExecContext(ctx, query,
+		op.ID,
+		op.Operation,
+		op.AccountNumber,
+		op.Amount,
+		[]byte(op.Payload),
+		op.Status,
+		op.Maker,
+		op.ExpiresAt,
+		op.CreatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreatePendingOperation", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreatePendingOperation", "success", map[string]interface{}{
+		"pending_id": op.ID,
+	})
+	return nil
+}
+
+// GetPendingOperationForUpdate locks a pending operation until the end of the
+// transaction
+func (r *repository) GetPendingOperationForUpdate(ctx context.Context, id string) (*models.PendingOperation, error) {
+	query := `SELECT ` + pendingOperationColumns + ` FROM pending_operations WHERE id = $1 FOR UPDATE`
+
+	r.log.LogOperation(ctx, "GetPendingOperationForUpdate", "start", map[string]interface{}{
+		"pending_id": id,
+	})
+
+	op, err := scanPendingOperation(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrPendingOperationNotFound
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetPendingOperationForUpdate", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetPendingOperationForUpdate", "success", map[string]interface{}{
+		"pending_id": id,
+	})
+	return op, nil
+}
+
+// UpdatePendingOperation stores the decision and outcome of an operation
+func (r *repository) UpdatePendingOperation(ctx context.Context, op *models.PendingOperation) error {
+	query := `UPDATE pending_operations SET status = $1, checker = $2, note = $3, result = $4, decided_at = $5
+			 WHERE id = $6`
+
+	r.log.LogOperation(ctx, "UpdatePendingOperation", "start", map[string]interface{}{
+		"pending_id": op.ID,
+		"status":     op.Status,
+	})
+
+	_, err := r.DB.ExecContext(ctx, query, op.Status, op.Checker, op.Note, op.Result, op.DecidedAt, op.ID)
+	if err != nil {
+		r.log.LogOperation(ctx, "UpdatePendingOperation", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "UpdatePendingOperation", "success", map[string]interface{}{
+		"pending_id": op.ID,
+	})
+	return nil
+}
+
+// GetPendingOperations returns up to limit operations with a status, oldest
+// first
+func (r *repository) GetPendingOperations(ctx context.Context, status string, limit int) ([]models.PendingOperation, error) {
+	query := `SELECT ` + pendingOperationColumns + ` FROM pending_operations
+			 WHERE status = $1 ORDER BY created_at LIMIT $2`
+
+	return r.queryPendingOperations(ctx, "GetPendingOperations", query, status, limit)
+}
+
+// GetExpiredPendingOperations locks up to limit operations still pending
+// past their expiry, skipping rows a checker is deciding on
+func (r *repository) GetExpiredPendingOperations(ctx context.Context, now time.Time, limit int) ([]models.PendingOperation, error) {
+	query := `SELECT ` + pendingOperationColumns + ` FROM pending_operations
+			 WHERE status = $1 AND expires_at <= $2
+			 ORDER BY expires_at LIMIT $3 FOR UPDATE SKIP LOCKED`
+
+	return r.queryPendingOperations(ctx, "GetExpiredPendingOperations", query, models.PendingStatusPending, now, limit)
+}
+
+func (r *repository) queryPendingOperations(ctx context.Context, op, query string, args ...interface{}) ([]models.PendingOperation, error) {
+	r.log.LogOperation(ctx, op, "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []models.PendingOperation{}
+	for rows.Next() {
+		pending, err := scanPendingOperation(rows)
+		if err != nil {
+			r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		ops = append(ops, *pending)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, op, "success", map[string]interface{}{
+		"count": len(ops),
+	})
+	return ops, nil
+}
+
+// CreatePendingOperationEvent appends a step to an operation's audit trail
+// and to the audit log
+func (r *repository) CreatePendingOperationEvent(ctx context.Context, event *models.PendingOperationEvent) error {
+	query := `INSERT INTO pending_operation_events (operation_id, status, actor, note, created_at)
+			 VALUES ($1, $2, $3, $4, $5)
+			 RETURNING (SELECT account_number FROM pending_operations WHERE id = $1)`
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	err := r.inTx(ctx, func(tx *repository) error {
+		var accountNumber string
+		err := tx.DB.QueryRowContext(ctx, query, event.OperationID, event.Status, event.Actor, event.Note, event.CreatedAt).Scan(&accountNumber)
+		if err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, &models.AuditEvent{
+			Action:        models.AuditApprovalPrefix + event.Status,
+			EntityType:    models.EntityPendingOperation,
+			EntityID:      event.OperationID,
+			AccountNumber: accountNumber,
+			Actor:         event.Actor,
+			After:         auditState(map[string]string{"status": event.Status}),
+			Reason:        event.Note,
+			CreatedAt:     event.CreatedAt,
+		})
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreatePendingOperationEvent", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+func scanPendingOperation(row rowScanner) (*models.PendingOperation, error) {
+	var op models.PendingOperation
+	var payload []byte
+	err := row.Scan(
+		&op.ID,
+		&op.Operation,
+		&op.AccountNumber,
+		&op.Amount,
+		&payload,
+		&op.Status,
+		&op.Maker,
+		&op.Checker,
+		&op.Note,
+		&op.Result,
+		&op.ExpiresAt,
+		&op.CreatedAt,
+		&op.DecidedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	op.Payload = payload
+	return &op, nil
+}
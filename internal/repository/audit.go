@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/audit"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+const auditEventColumns = `id, action, entity_type, entity_id, account_number, actor, channel, ip, request_id, before_state, after_state, reason, created_at`
+
+// recordAudit appends an audit event in the repository's transaction, so the
+// event is kept exactly when the change it describes is. event.Actor may name
+// the operator behind the change; the actor, channel, IP and request ID of
+// the request are taken from ctx.
+func (r *repository) recordAudit(ctx context.Context, event *models.AuditEvent) error {
+	query := `INSERT INTO audit_events (action, entity_type, entity_id, account_number, actor, channel, ip, request_id, before_state, after_state, reason, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+
+	md := audit.FromContext(ctx)
+	event.Actor = audit.Actor(ctx, event.Actor)
+	event.Channel = md.Channel
+	event.IP = md.IP
+	event.RequestID = md.RequestID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	err := r.DB.QueryRowContext(ctx, query,
+		event.Action,
+		event.EntityType,
+		event.EntityID,
+		event.AccountNumber,
+		event.Actor,
+		event.Channel,
+		event.IP,
+		event.RequestID,
+		nullJSON(event.Before),
+		nullJSON(event.After),
+		event.Reason,
+		event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		r.log.LogOperation(ctx, "RecordAudit", "error", map[string]interface{}{
+			"error":  err.Error(),
+			"action": event.Action,
+		})
+		return err
+	}
+	return nil
+}
+
+// maskedAccount returns a copy of account with its NIK and phone number
+// masked. The audit log is kept for years and read by compliance, so it
+// must not hold identity numbers in full.
+func maskedAccount(account *models.Account) *models.Account {
+	masked := *account
+	masked.NIK = models.MaskNIK(account.NIK)
+	masked.PhoneNumber = models.MaskPhone(account.PhoneNumber)
+	return &masked
+}
+
+// auditState encodes the state of an entity for an audit event
+func auditState(state interface{}) json.RawMessage {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// balanceAudit describes the balance change of a ledger entry
+func balanceAudit(trx *models.Transaction) *models.AuditEvent {
+	before := trx.BalanceAfter - trx.Amount
+	if trx.Direction == models.DirectionDebit {
+		before = trx.BalanceAfter + trx.Amount
+	}
+	return &models.AuditEvent{
+		Action:        models.AuditBalanceChanged,
+		EntityType:    models.EntityTransaction,
+		EntityID:      strconv.FormatInt(trx.ID, 10),
+		AccountNumber: trx.AccountNumber,
+		Before:        auditState(map[string]interface{}{"balance": before}),
+		After: auditState(map[string]interface{}{
+			"balance":   trx.BalanceAfter,
+			"reference": trx.Reference,
+			"type":      trx.Type,
+			"direction": trx.Direction,
+			"amount":    trx.Amount,
+		}),
+		Reason:    trx.Description,
+		CreatedAt: trx.CreatedAt,
+	}
+}
+
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
+
+// GetAuditEvents returns the audit events matching filter, newest first
+func (r *repository) GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events
+			 WHERE ($1 = '' OR account_number = $1)
+			   AND ($2 = '' OR actor = $2 OR split_part(actor, '/', 1) = $2 OR split_part(actor, '/', 2) = $2)
+			   AND ($3::timestamp IS NULL OR created_at >= $3)
+			   AND ($4::timestamp IS NULL OR created_at < $4)
+			 ORDER BY created_at DESC, id DESC
+			 LIMIT $5 OFFSET $6`
+
+	r.log.LogOperation(ctx, "GetAuditEvents", "start", map[string]interface{}{
+		"account_id": filter.AccountNumber,
+		"actor":      filter.Actor,
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query,
+		filter.AccountNumber,
+		filter.Actor,
+		nullTime(filter.From),
+		nullTime(filter.To),
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetAuditEvents", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var before, after []byte
+		err := rows.Scan(
+			&event.ID,
+			&event.Action,
+			&event.EntityType,
+			&event.EntityID,
+			&event.AccountNumber,
+			&event.Actor,
+			&event.Channel,
+			&event.IP,
+			&event.RequestID,
+			&before,
+			&after,
+			&event.Reason,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			r.log.LogOperation(ctx, "GetAuditEvents", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		event.Before, event.After = before, after
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetAuditEvents", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetAuditEvents", "success", map[string]interface{}{
+		"count": len(events),
+	})
+	return events, nil
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
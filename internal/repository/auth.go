@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+func (r *repository) CreateCredential(ctx context.Context, credential *models.Credential) error {
+	query := `INSERT INTO account_credentials (account_number, password_hash, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4)`
+
+	r.log.LogOperation(ctx, "CreateCredential", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	_, err := r.DB.ExecContext(ctx, query,
+		credential.AccountNumber,
+		credential.PasswordHash,
+		credential.CreatedAt,
+		credential.UpdatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateCredential", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateCredential", "success", map[string]interface{}{
+		"account_id": credential.AccountNumber,
+	})
+	return nil
+}
+
+func (r *repository) GetCredential(ctx context.Context, accountNumber string) (*models.Credential, error) {
+	var credential models.Credential
+	query := `SELECT account_number, password_hash, created_at, updated_at
+			 FROM account_credentials WHERE account_number = $1`
+
+	r.log.LogOperation(ctx, "GetCredential", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.DB.QueryRowContext(ctx, query, accountNumber).Scan(
+		&credential.AccountNumber,
+		&credential.PasswordHash,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetCredential", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetCredential", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return &credential, nil
+}
+
+func (r *repository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (token_hash, account_number, expires_at, created_at)
+			 VALUES ($1, $2, $3, $4) RETURNING id`
+
+	r.log.LogOperation(ctx, "CreateRefreshToken", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.DB.QueryRowContext(ctx, query,
+		token.TokenHash,
+		token.AccountNumber,
+		token.ExpiresAt,
+		token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateRefreshToken", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateRefreshToken", "success", map[string]interface{}{
+		"account_id": token.AccountNumber,
+	})
+	return nil
+}
+
+func (r *repository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	query := `SELECT id, token_hash, account_number, expires_at, revoked_at, created_at
+			 FROM refresh_tokens WHERE token_hash = $1`
+
+	r.log.LogOperation(ctx, "GetRefreshToken", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.AccountNumber,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetRefreshToken", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetRefreshToken", "success", map[string]interface{}{
+		"account_id": token.AccountNumber,
+	})
+	return &token, nil
+}
+
+func (r *repository) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			 WHERE token_hash = $1 AND revoked_at IS NULL`
+
+	r.log.LogOperation(ctx, "RevokeRefreshToken", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	result, err := r.DB.ExecContext(ctx, query, tokenHash)
+	if err != nil {
+		r.log.LogOperation(ctx, "RevokeRefreshToken", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	if rowAffected == 0 {
+		r.log.LogOperation(ctx, "RevokeRefreshToken", "error", map[string]interface{}{
+			"error": "refresh token already revoked",
+		})
+		return errors.New("refresh token already revoked")
+	}
+
+	r.log.LogOperation(ctx, "RevokeRefreshToken", "success", map[string]interface{}{})
+	return nil
+}
+
+// RevokeAccountRefreshTokens revokes every active refresh token of an account
+func (r *repository) RevokeAccountRefreshTokens(ctx context.Context, accountNumber string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			 WHERE account_number = $1 AND revoked_at IS NULL`
+
+	r.log.LogOperation(ctx, "RevokeAccountRefreshTokens", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	_, err := r.DB.ExecContext(ctx, query, accountNumber)
+	if err != nil {
+		r.log.LogOperation(ctx, "RevokeAccountRefreshTokens", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "RevokeAccountRefreshTokens", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return nil
+}
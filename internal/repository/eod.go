@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// StartEODRun claims a business date for the end-of-day close. It reports
+// false when the date already has a run; a concurrent run waits on the
+// primary key until the first one commits or rolls back.
+func (r *repository) StartEODRun(ctx context.Context, run *models.EODRun) (bool, error) {
+	query := `INSERT INTO eod_runs (business_date, status, started_at) VALUES ($1, $2, $3)
+			 ON CONFLICT DO NOTHING`
+
+	r.log.LogOperation(ctx, "StartEODRun", "start", map[string]interface{}{
+		"business_date": run.BusinessDate,
+	})
+
+	run.Status = models.EODRunning
+	run.StartedAt = time.Now()
+	result, err := r.DB.ExecContext(ctx, query, run.BusinessDate, run.Status, run.StartedAt)
+	if err != nil {
+		r.log.LogOperation(ctx, "StartEODRun", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	r.log.LogOperation(ctx, "StartEODRun", "success", map[string]interface{}{
+		"business_date": run.BusinessDate,
+		"started":       rowAffected > 0,
+	})
+	return rowAffected > 0, nil
+}
+
+// CreateBalanceSnapshots stores the closing balance of every account opened
+// before dayEnd, from the last ledger entry before it. It is a single
+// statement, so every balance comes from the same database snapshot.
+func (r *repository) CreateBalanceSnapshots(ctx context.Context, businessDate string, dayEnd time.Time) (int, error) {
+	query := `INSERT INTO balance_snapshots (business_date, account_number, balance)
+			 SELECT $1, a.account_number,
+			 COALESCE((SELECT t.balance_after FROM transactions t
+			 WHERE t.account_number = a.account_number AND t.created_at < $2
+			 ORDER BY t.created_at DESC, t.id DESC LIMIT 1), 0)
+			 FROM accounts a WHERE a.created_at < $2`
+
+	r.log.LogOperation(ctx, "CreateBalanceSnapshots", "start", map[string]interface{}{
+		"business_date": businessDate,
+	})
+
+	result, err := r.DB.ExecContext(ctx, query, businessDate, dayEnd)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateBalanceSnapshots", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	r.log.LogOperation(ctx, "CreateBalanceSnapshots", "success", map[string]interface{}{
+		"business_date": businessDate,
+		"count":         rowAffected,
+	})
+	return int(rowAffected), nil
+}
+
+// CompleteEODRun fills in the totals of a run from its snapshots and the
+// ledger entries of the day, and marks it completed
+func (r *repository) CompleteEODRun(ctx context.Context, run *models.EODRun, dayStart, dayEnd time.Time) error {
+	snapshotQuery := `SELECT COUNT(*), COALESCE(SUM(balance), 0) FROM balance_snapshots WHERE business_date = $1`
+	ledgerQuery := `SELECT
+			 COALESCE(SUM(amount) FILTER (WHERE type = $3), 0),
+			 COALESCE(SUM(amount) FILTER (WHERE type = $4), 0),
+			 COALESCE(SUM(amount) FILTER (WHERE type IN ($5, $6)), 0)
+			 FROM transactions WHERE created_at >= $1 AND created_at < $2`
+	updateQuery := `UPDATE eod_runs SET status = $1, account_count = $2, total_balance = $3,
+			 total_deposits = $4, total_withdrawals = $5, total_fees = $6, completed_at = $7
+			 WHERE business_date = $8`
+
+	r.log.LogOperation(ctx, "CompleteEODRun", "start", map[string]interface{}{
+		"business_date": run.BusinessDate,
+	})
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		err := tx.DB.QueryRowContext(ctx, snapshotQuery, run.BusinessDate).Scan(&run.AccountCount, &run.TotalBalance)
+		if err != nil {
+			return err
+		}
+		err = tx.DB.QueryRowContext(ctx, ledgerQuery, dayStart, dayEnd,
+			models.TransactionDeposit,
+			models.TransactionWithdrawal,
+			models.TransactionFee,
+			models.TransactionAdminFee,
+		).Scan(&run.TotalDeposits, &run.TotalWithdrawals, &run.TotalFees)
+		if err != nil {
+			return err
+		}
+
+		completedAt := time.Now()
+		run.Status = models.EODCompleted
+		run.CompletedAt = &completedAt
+		_, err = tx.DB.ExecContext(ctx, updateQuery,
+			run.Status,
+			run.AccountCount,
+			run.TotalBalance,
+			run.TotalDeposits,
+			run.TotalWithdrawals,
+			run.TotalFees,
+			completedAt,
+			run.BusinessDate,
+		)
+		return err
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CompleteEODRun", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CompleteEODRun", "success", map[string]interface{}{
+		"business_date": run.BusinessDate,
+	})
+	return nil
+}
+
+// GetLatestSnapshot returns the account's most recent snapshot on or before
+// businessDate, or nil when there is none
+func (r *repository) GetLatestSnapshot(ctx context.Context, accountNumber, businessDate string) (*models.BalanceSnapshot, error) {
+	query := `SELECT to_char(business_date, 'YYYY-MM-DD'), account_number, balance FROM balance_snapshots
+			 WHERE account_number = $1 AND business_date <= $2
+			 ORDER BY business_date DESC LIMIT 1`
+
+	r.log.LogOperation(ctx, "GetLatestSnapshot", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	var snapshot models.BalanceSnapshot
+	err := r.DB.QueryRowContext(ctx, query, accountNumber, businessDate).Scan(
+		&snapshot.BusinessDate,
+		&snapshot.AccountNumber,
+		&snapshot.Balance,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetLatestSnapshot", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetLatestSnapshot", "success", map[string]interface{}{
+		"account_id":    accountNumber,
+		"business_date": snapshot.BusinessDate,
+	})
+	return &snapshot, nil
+}
+
+// GetLedgerNet returns credits minus debits of an account in [from, to)
+func (r *repository) GetLedgerNet(ctx context.Context, accountNumber string, from, to time.Time) (float64, error) {
+	query := `SELECT COALESCE(SUM(CASE WHEN direction = $4 THEN amount ELSE -amount END), 0)
+			 FROM transactions WHERE account_number = $1 AND created_at >= $2 AND created_at < $3`
+
+	r.log.LogOperation(ctx, "GetLedgerNet", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	var net float64
+	if err := r.DB.QueryRowContext(ctx, query, accountNumber, from, to, models.DirectionCredit).Scan(&net); err != nil {
+		r.log.LogOperation(ctx, "GetLedgerNet", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	r.log.LogOperation(ctx, "GetLedgerNet", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return net, nil
+}
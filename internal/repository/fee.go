@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// GetOpenAccounts returns up to limit accounts that are not closed, in
+// account number order after afterAccount
+func (r *repository) GetOpenAccounts(ctx context.Context, afterAccount string, limit int) ([]models.Account, error) {
+	query := `SELECT id, account_number, name, nik, phone_number, balance, held_amount, status, account_type, product, kyc_tier, created_at, updated_at
+			 FROM accounts WHERE status <> 'CLOSED' AND account_number > $1
+			 ORDER BY account_number LIMIT $2`
+	return r.listAccounts(ctx, "GetOpenAccounts", query, afterAccount, limit)
+}
+
+// GetStatementAccounts returns up to limit accounts that were open at some
+// point between from and to, including those closed in between, in account
+// number order after afterAccount
+func (r *repository) GetStatementAccounts(ctx context.Context, from, to time.Time, afterAccount string, limit int) ([]models.Account, error) {
+	query := `SELECT id, account_number, name, nik, phone_number, balance, held_amount, status, account_type, product, kyc_tier, created_at, updated_at
+			 FROM accounts WHERE created_at < $2 AND (status <> 'CLOSED' OR closed_at >= $1)
+			 AND account_number > $3
+			 ORDER BY account_number LIMIT $4`
+	return r.listAccounts(ctx, "GetStatementAccounts", query, from, to, afterAccount, limit)
+}
+
+// listAccounts runs an account query selecting the columns of
+// GetOpenAccounts, logging it as op
+func (r *repository) listAccounts(ctx context.Context, op, query string, args ...interface{}) ([]models.Account, error) {
+	r.log.LogOperation(ctx, op, "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		var account models.Account
+		if err := rows.Scan(
+			&account.ID,
+			&account.AccountNumber,
+			&account.Name,
+			&account.NIK,
+			&account.PhoneNumber,
+			&account.Balance,
+			&account.HeldAmount,
+			&account.Status,
+			&account.AccountType,
+			&account.Product,
+			&account.KYCTier,
+			&account.CreatedAt,
+			&account.UpdatedAt,
+		); err != nil {
+			r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, op, "success", map[string]interface{}{
+		"count": len(accounts),
+	})
+	return accounts, nil
+}
+
+// CreateAdminFeeCharge records a monthly admin fee. It reports false when the
+// account was already charged for that period.
+func (r *repository) CreateAdminFeeCharge(ctx context.Context, charge *models.AdminFeeCharge) (bool, error) {
+	query := `INSERT INTO admin_fee_charges (account_number, period, amount, reference, charged_at)
+			 VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`
+
+	r.log.LogOperation(ctx, "CreateAdminFeeCharge", "start", map[string]interface{}{
+		"account_id": charge.AccountNumber,
+		"period":     charge.Period,
+	})
+
+	if charge.ChargedAt.IsZero() {
+		charge.ChargedAt = time.Now()
+	}
+
+	result, err := r.DB.ExecContext(ctx, query,
+		charge.AccountNumber,
+		charge.Period,
+		charge.Amount,
+		charge.Reference,
+		charge.ChargedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateAdminFeeCharge", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	r.log.LogOperation(ctx, "CreateAdminFeeCharge", "success", map[string]interface{}{
+		"account_id": charge.AccountNumber,
+		"created":    rowAffected > 0,
+	})
+	return rowAffected > 0, nil
+}
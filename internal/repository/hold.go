@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrHoldNotFound = errcode.New("HOLD_NOT_FOUND", "hold not found")
+
+const holdColumns = `id, account_number, amount, captured_amount, status, description, expires_at, created_at, updated_at`
+
+// CreateHold reserves hold.Amount of the account's available balance. The
+// available balance and status checks are part of the UPDATE, like Debit.
+func (r *repository) CreateHold(ctx context.Context, hold *models.Hold) error {
+	reserveQuery := `UPDATE accounts SET held_amount = held_amount + $1
+			 WHERE account_number = $2 AND balance - held_amount >= $1 AND status = ANY($3)`
+	insertQuery := `INSERT INTO holds (id, account_number, amount, captured_amount, status, description, expires_at, created_at, updated_at)
+			 VALUES ($1, $2, $3, 0, $4, $5, $6, $7, $7)`
+
+	r.log.LogOperation(ctx, "CreateHold", "start", map[string]interface{}{
+		"account_id": hold.AccountNumber,
+		"amount":     hold.Amount,
+	})
+
+	now := time.Now()
+	hold.Status = models.HoldActive
+	hold.CreatedAt = now
+	hold.UpdatedAt = now
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		result, err := tx.DB.ExecContext(ctx, reserveQuery, hold.Amount, hold.AccountNumber, pq.Array(r.statusPolicy.DebitStatuses()))
+		if err != nil {
+			return err
+		}
+		rowAffected, _ := result.RowsAffected()
+		if rowAffected == 0 {
+			return tx.debitError(ctx, hold.AccountNumber)
+		}
+
+		_, err = tx.DB.ExecContext(ctx, insertQuery,
+			hold.ID,
+			hold.AccountNumber,
+			hold.Amount,
+			hold.Status,
+			hold.Description,
+			hold.ExpiresAt,
+			now,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, &models.AuditEvent{
+			Action:        models.AuditHoldCreated,
+			EntityType:    models.EntityHold,
+			EntityID:      hold.ID,
+			AccountNumber: hold.AccountNumber,
+			After:         auditState(hold),
+			Reason:        hold.Description,
+			CreatedAt:     now,
+		})
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateHold", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateHold", "success", map[string]interface{}{
+		"account_id": hold.AccountNumber,
+		"hold_id":    hold.ID,
+	})
+	return nil
+}
+
+// GetHoldForUpdate loads a hold and locks it until the surrounding
+// transaction ends
+func (r *repository) GetHoldForUpdate(ctx context.Context, id string) (*models.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE id = $1 FOR UPDATE`
+
+	r.log.LogOperation(ctx, "GetHoldForUpdate", "start", map[string]interface{}{
+		"hold_id": id,
+	})
+
+	hold, err := scanHold(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrHoldNotFound
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetHoldForUpdate", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetHoldForUpdate", "success", map[string]interface{}{
+		"hold_id": id,
+	})
+	return hold, nil
+}
+
+// CaptureHold turns amount of a locked hold into a debit. The money was
+// reserved when the hold was created, so only the hold is checked here.
+func (r *repository) CaptureHold(ctx context.Context, hold *models.Hold, amount float64, description string) (*models.Transaction, error) {
+	accountQuery := `UPDATE accounts SET balance = balance - $1, held_amount = held_amount - $1
+			 WHERE account_number = $2 RETURNING balance`
+	holdQuery := `UPDATE holds SET captured_amount = $1, status = $2, updated_at = $3 WHERE id = $4`
+
+	r.log.LogOperation(ctx, "CaptureHold", "start", map[string]interface{}{
+		"hold_id": hold.ID,
+		"amount":  amount,
+	})
+
+	var trx *models.Transaction
+	err := r.inTx(ctx, func(tx *repository) error {
+		var balance float64
+		err := tx.DB.QueryRowContext(ctx, accountQuery, amount, hold.AccountNumber).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAccountNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		hold.CapturedAmount += amount
+		if hold.Remaining() <= 0 {
+			hold.Status = models.HoldCaptured
+		}
+		hold.UpdatedAt = time.Now()
+		if _, err := tx.DB.ExecContext(ctx, holdQuery, hold.CapturedAmount, hold.Status, hold.UpdatedAt, hold.ID); err != nil {
+			return err
+		}
+
+		trx = &models.Transaction{
+			Reference:     hold.ID,
+			AccountNumber: hold.AccountNumber,
+			Type:          models.TransactionHoldCapture,
+			Direction:     models.DirectionDebit,
+			Amount:        amount,
+			BalanceAfter:  balance,
+			Description:   description,
+		}
+		return tx.CreateTransaction(ctx, trx)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CaptureHold", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "CaptureHold", "success", map[string]interface{}{
+		"hold_id": hold.ID,
+		"status":  hold.Status,
+	})
+	return trx, nil
+}
+
+// ReleaseHold gives the remaining amount of a locked hold back to the
+// available balance and ends the hold with status (RELEASED or EXPIRED)
+func (r *repository) ReleaseHold(ctx context.Context, hold *models.Hold, status string) error {
+	accountQuery := `UPDATE accounts SET held_amount = held_amount - $1 WHERE account_number = $2`
+	holdQuery := `UPDATE holds SET status = $1, updated_at = $2 WHERE id = $3`
+
+	r.log.LogOperation(ctx, "ReleaseHold", "start", map[string]interface{}{
+		"hold_id": hold.ID,
+		"status":  status,
+	})
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		if _, err := tx.DB.ExecContext(ctx, accountQuery, hold.Remaining(), hold.AccountNumber); err != nil {
+			return err
+		}
+
+		event := &models.AuditEvent{
+			Action:        models.AuditHoldReleased,
+			EntityType:    models.EntityHold,
+			EntityID:      hold.ID,
+			AccountNumber: hold.AccountNumber,
+			Before:        auditState(map[string]interface{}{"status": hold.Status, "remaining": hold.Remaining()}),
+			After:         auditState(map[string]interface{}{"status": status}),
+		}
+
+		hold.Status = status
+		hold.UpdatedAt = time.Now()
+		if _, err := tx.DB.ExecContext(ctx, holdQuery, hold.Status, hold.UpdatedAt, hold.ID); err != nil {
+			return err
+		}
+		event.CreatedAt = hold.UpdatedAt
+		return tx.recordAudit(ctx, event)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "ReleaseHold", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "ReleaseHold", "success", map[string]interface{}{
+		"hold_id": hold.ID,
+	})
+	return nil
+}
+
+// GetExpiredHolds locks up to limit active holds that expired before now.
+// Rows locked by another sweeper are skipped. Must run inside a transaction.
+func (r *repository) GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds
+			 WHERE status = $1 AND expires_at <= $2
+			 ORDER BY expires_at LIMIT $3 FOR UPDATE SKIP LOCKED`
+
+	r.log.LogOperation(ctx, "GetExpiredHolds", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, models.HoldActive, now, limit)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetExpiredHolds", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []models.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			r.log.LogOperation(ctx, "GetExpiredHolds", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		holds = append(holds, *hold)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetExpiredHolds", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetExpiredHolds", "success", map[string]interface{}{
+		"count": len(holds),
+	})
+	return holds, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHold(row rowScanner) (*models.Hold, error) {
+	var hold models.Hold
+	err := row.Scan(
+		&hold.ID,
+		&hold.AccountNumber,
+		&hold.Amount,
+		&hold.CapturedAmount,
+		&hold.Status,
+		&hold.Description,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
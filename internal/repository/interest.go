@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// GetEndOfDayBalances returns, in account number order, up to limit open
+// accounts after afterAccount with their ledger balance at dayEnd, taken
+// from the last ledger entry before it
+func (r *repository) GetEndOfDayBalances(ctx context.Context, dayEnd time.Time, afterAccount string, limit int) ([]models.EndOfDayBalance, error) {
+	query := `SELECT a.account_number, a.product,
+			 COALESCE((SELECT t.balance_after FROM transactions t
+			 WHERE t.account_number = a.account_number AND t.created_at < $1
+			 ORDER BY t.created_at DESC, t.id DESC LIMIT 1), 0)
+			 FROM accounts a
+			 WHERE a.created_at < $1 AND a.status <> 'CLOSED' AND a.account_number > $2
+			 ORDER BY a.account_number LIMIT $3`
+
+	r.log.LogOperation(ctx, "GetEndOfDayBalances", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, dayEnd, afterAccount, limit)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetEndOfDayBalances", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []models.EndOfDayBalance{}
+	for rows.Next() {
+		var balance models.EndOfDayBalance
+		if err := rows.Scan(&balance.AccountNumber, &balance.Product, &balance.Balance); err != nil {
+			r.log.LogOperation(ctx, "GetEndOfDayBalances", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetEndOfDayBalances", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetEndOfDayBalances", "success", map[string]interface{}{
+		"count": len(balances),
+	})
+	return balances, nil
+}
+
+// CreateAccruals stores daily accruals, skipping accounts already accrued
+// for that business date, and returns how many were new
+func (r *repository) CreateAccruals(ctx context.Context, accruals []models.InterestAccrual) (int, error) {
+	query := `INSERT INTO interest_accruals (account_number, business_date, product, balance, annual_rate, amount)
+			 VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING`
+
+	r.log.LogOperation(ctx, "CreateAccruals", "start", map[string]interface{}{
+		"count": len(accruals),
+	})
+
+	created := 0
+	err := r.inTx(ctx, func(tx *repository) error {
+		for _, accrual := range accruals {
+			result, err := tx.DB.ExecContext(ctx, query,
+				accrual.AccountNumber,
+				accrual.BusinessDate,
+				accrual.Product,
+				accrual.Balance,
+				accrual.AnnualRate,
+				accrual.Amount,
+			)
+			if err != nil {
+				return err
+			}
+			rowAffected, _ := result.RowsAffected()
+			created += int(rowAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateAccruals", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	r.log.LogOperation(ctx, "CreateAccruals", "success", map[string]interface{}{
+		"created": created,
+	})
+	return created, nil
+}
+
+// GetUnpostedInterest sums the unposted accruals between two business dates
+// per account, in account number order after afterAccount. Accounts closed
+// since they accrued are left out, their accruals are never posted.
+func (r *repository) GetUnpostedInterest(ctx context.Context, fromDate, toDate, afterAccount string, limit int) ([]models.InterestPosting, error) {
+	query := `SELECT i.account_number, SUM(i.amount) FROM interest_accruals i
+			 JOIN accounts a ON a.account_number = i.account_number
+			 WHERE i.business_date BETWEEN $1 AND $2 AND i.posting_reference IS NULL AND i.account_number > $3
+			 AND a.status <> 'CLOSED'
+			 GROUP BY i.account_number ORDER BY i.account_number LIMIT $4`
+
+	r.log.LogOperation(ctx, "GetUnpostedInterest", "start", map[string]interface{}{
+		"from": fromDate,
+		"to":   toDate,
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, fromDate, toDate, afterAccount, limit)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetUnpostedInterest", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	postings := []models.InterestPosting{}
+	for rows.Next() {
+		var posting models.InterestPosting
+		if err := rows.Scan(&posting.AccountNumber, &posting.Gross); err != nil {
+			r.log.LogOperation(ctx, "GetUnpostedInterest", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		postings = append(postings, posting)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetUnpostedInterest", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetUnpostedInterest", "success", map[string]interface{}{
+		"count": len(postings),
+	})
+	return postings, nil
+}
+
+// CreateInterestPosting records a monthly posting. It reports false when the
+// account was already posted for that period.
+func (r *repository) CreateInterestPosting(ctx context.Context, posting *models.InterestPosting) (bool, error) {
+	query := `INSERT INTO interest_postings (account_number, period, gross, tax, net, reference, posted_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`
+
+	r.log.LogOperation(ctx, "CreateInterestPosting", "start", map[string]interface{}{
+		"account_id": posting.AccountNumber,
+		"period":     posting.Period,
+	})
+
+	if posting.PostedAt.IsZero() {
+		posting.PostedAt = time.Now()
+	}
+
+	result, err := r.DB.ExecContext(ctx, query,
+		posting.AccountNumber,
+		posting.Period,
+		posting.Gross,
+		posting.Tax,
+		posting.Net,
+		posting.Reference,
+		posting.PostedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateInterestPosting", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	r.log.LogOperation(ctx, "CreateInterestPosting", "success", map[string]interface{}{
+		"account_id": posting.AccountNumber,
+		"created":    rowAffected > 0,
+	})
+	return rowAffected > 0, nil
+}
+
+// MarkAccrualsPosted links an account's accruals between two business dates
+// to the posting that credited them
+func (r *repository) MarkAccrualsPosted(ctx context.Context, accountNumber, fromDate, toDate, reference string) error {
+	query := `UPDATE interest_accruals SET posting_reference = $1
+			 WHERE account_number = $2 AND business_date BETWEEN $3 AND $4 AND posting_reference IS NULL`
+
+	r.log.LogOperation(ctx, "MarkAccrualsPosted", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	_, err := r.DB.ExecContext(ctx, query, reference, accountNumber, fromDate, toDate)
+	if err != nil {
+		r.log.LogOperation(ctx, "MarkAccrualsPosted", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "MarkAccrualsPosted", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return nil
+}
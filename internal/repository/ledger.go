@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/alfaa19/service-account-test/internal/ledger"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// chainTimestamp renders created_at the same way for hashing and verifying,
+// whatever the session's DateStyle
+const chainTimestamp = `to_char(created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US')`
+
+// chainEntry links a freshly inserted entry into both chains. The global
+// head row is locked until commit, which serialises ledger writes so the
+// chain order is the commit order.
+func (r *repository) chainEntry(ctx context.Context, entry *models.LedgerEntry) error {
+	headQuery := `SELECT seq, hash FROM ledger_chain WHERE id = 1 FOR UPDATE`
+	accountQuery := `SELECT COALESCE(ledger_hash, '') FROM accounts WHERE account_number = $1`
+	entryQuery := `UPDATE transactions SET chain_seq = $1, account_hash = $2, global_hash = $3 WHERE id = $4`
+	accountHeadQuery := `UPDATE accounts SET ledger_hash = $1 WHERE account_number = $2`
+	globalHeadQuery := `UPDATE ledger_chain SET seq = $1, hash = $2 WHERE id = 1`
+
+	var seq int64
+	var globalPrev, accountPrev string
+	if err := r.DB.QueryRowContext(ctx, headQuery).Scan(&seq, &globalPrev); err != nil {
+		return err
+	}
+	if err := r.DB.QueryRowContext(ctx, accountQuery, entry.AccountNumber).Scan(&accountPrev); err != nil {
+		return err
+	}
+	if accountPrev == "" {
+		accountPrev = ledger.GenesisHash
+	}
+
+	entry.Seq = seq + 1
+	entry.AccountHash = ledger.Hash(accountPrev, entry)
+	entry.GlobalHash = ledger.Hash(globalPrev, entry)
+
+	if _, err := r.DB.ExecContext(ctx, entryQuery, entry.Seq, entry.AccountHash, entry.GlobalHash, entry.TransactionID); err != nil {
+		return err
+	}
+	if _, err := r.DB.ExecContext(ctx, accountHeadQuery, entry.AccountHash, entry.AccountNumber); err != nil {
+		return err
+	}
+	_, err := r.DB.ExecContext(ctx, globalHeadQuery, entry.Seq, entry.GlobalHash)
+	return err
+}
+
+// GetChainEntries returns up to limit chained entries after seq, in chain
+// order
+func (r *repository) GetChainEntries(ctx context.Context, afterSeq int64, limit int) ([]models.LedgerEntry, error) {
+	query := `SELECT chain_seq, id, reference, account_number, type, direction, amount::text, balance_after::text,
+			 description, ` + chainTimestamp + `, account_hash, global_hash
+			 FROM transactions WHERE chain_seq > $1 ORDER BY chain_seq LIMIT $2`
+
+	r.log.LogOperation(ctx, "GetChainEntries", "start", map[string]interface{}{
+		"after_seq": afterSeq,
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, afterSeq, limit)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetChainEntries", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var entry models.LedgerEntry
+		if err := rows.Scan(
+			&entry.Seq,
+			&entry.TransactionID,
+			&entry.Reference,
+			&entry.AccountNumber,
+			&entry.Type,
+			&entry.Direction,
+			&entry.Amount,
+			&entry.BalanceAfter,
+			&entry.Description,
+			&entry.CreatedAt,
+			&entry.AccountHash,
+			&entry.GlobalHash,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetChainEntries", "success", map[string]interface{}{
+		"count": len(entries),
+	})
+	return entries, nil
+}
+
+// GetChainHead returns the sequence number and hash of the last chained
+// entry
+func (r *repository) GetChainHead(ctx context.Context) (int64, string, error) {
+	query := `SELECT seq, hash FROM ledger_chain WHERE id = 1`
+
+	var seq int64
+	var hash string
+	if err := r.DB.QueryRowContext(ctx, query).Scan(&seq, &hash); err != nil {
+		r.log.LogOperation(ctx, "GetChainHead", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, "", err
+	}
+	return seq, hash, nil
+}
+
+// CountUnchainedEntries counts ledger entries posted before the chain existed
+func (r *repository) CountUnchainedEntries(ctx context.Context) (int64, error) {
+	query := `SELECT COUNT(*) FROM transactions WHERE chain_seq IS NULL`
+
+	var count int64
+	if err := r.DB.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		r.log.LogOperation(ctx, "CountUnchainedEntries", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateCheckpoint stores a signed checkpoint. It reports false when the
+// head it covers already has one.
+func (r *repository) CreateCheckpoint(ctx context.Context, cp *models.LedgerCheckpoint) (bool, error) {
+	query := `INSERT INTO ledger_checkpoints (seq, global_hash, signature, created_at) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT DO NOTHING`
+
+	r.log.LogOperation(ctx, "CreateCheckpoint", "start", map[string]interface{}{
+		"seq": cp.Seq,
+	})
+
+	result, err := r.DB.ExecContext(ctx, query, cp.Seq, cp.GlobalHash, cp.Signature, cp.CreatedAt)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateCheckpoint", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	r.log.LogOperation(ctx, "CreateCheckpoint", "success", map[string]interface{}{
+		"seq":     cp.Seq,
+		"created": rowAffected > 0,
+	})
+	return rowAffected > 0, nil
+}
+
+// GetCheckpoints returns all checkpoints in chain order
+func (r *repository) GetCheckpoints(ctx context.Context) ([]models.LedgerCheckpoint, error) {
+	query := `SELECT seq, global_hash, signature, created_at FROM ledger_checkpoints ORDER BY seq`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetCheckpoints", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []models.LedgerCheckpoint
+	for rows.Next() {
+		var cp models.LedgerCheckpoint
+		if err := rows.Scan(&cp.Seq, &cp.GlobalHash, &cp.Signature, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
+
+// GetLatestCheckpoint returns the most recent checkpoint, or nil when none
+// has been made
+func (r *repository) GetLatestCheckpoint(ctx context.Context) (*models.LedgerCheckpoint, error) {
+	query := `SELECT seq, global_hash, signature, created_at FROM ledger_checkpoints ORDER BY seq DESC LIMIT 1`
+
+	var cp models.LedgerCheckpoint
+	err := r.DB.QueryRowContext(ctx, query).Scan(&cp.Seq, &cp.GlobalHash, &cp.Signature, &cp.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetLatestCheckpoint", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	return &cp, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// GetWithdrawalUsageForUpdate returns the usage of an account for one
+// business day and channel, creating an empty row first, and locks it so
+// concurrent withdrawals are checked one after another
+func (r *repository) GetWithdrawalUsageForUpdate(ctx context.Context, accountNumber, businessDate, channel string) (*models.WithdrawalUsage, error) {
+	insertQuery := `INSERT INTO withdrawal_usage (account_number, business_date, channel)
+			 VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	selectQuery := `SELECT total_amount, tx_count FROM withdrawal_usage
+			 WHERE account_number = $1 AND business_date = $2 AND channel = $3 FOR UPDATE`
+
+	r.log.LogOperation(ctx, "GetWithdrawalUsageForUpdate", "start", map[string]interface{}{
+		"account_id": accountNumber,
+		"channel":    channel,
+	})
+
+	usage := &models.WithdrawalUsage{
+		AccountNumber: accountNumber,
+		BusinessDate:  businessDate,
+		Channel:       channel,
+	}
+	err := r.inTx(ctx, func(tx *repository) error {
+		if _, err := tx.DB.ExecContext(ctx, insertQuery, accountNumber, businessDate, channel); err != nil {
+			return err
+		}
+		return tx.DB.QueryRowContext(ctx, selectQuery, accountNumber, businessDate, channel).Scan(&usage.TotalAmount, &usage.Count)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "GetWithdrawalUsageForUpdate", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetWithdrawalUsageForUpdate", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"total":      usage.TotalAmount,
+		"count":      usage.Count,
+	})
+	return usage, nil
+}
+
+// AddWithdrawalUsage adds one withdrawal of amount to a usage row
+func (r *repository) AddWithdrawalUsage(ctx context.Context, usage *models.WithdrawalUsage, amount float64) error {
+	query := `UPDATE withdrawal_usage SET total_amount = total_amount + $1, tx_count = tx_count + 1
+			 WHERE account_number = $2 AND business_date = $3 AND channel = $4`
+
+	r.log.LogOperation(ctx, "AddWithdrawalUsage", "start", map[string]interface{}{
+		"account_id": usage.AccountNumber,
+		"channel":    usage.Channel,
+	})
+
+	_, err := r.DB.ExecContext(ctx, query, amount, usage.AccountNumber, usage.BusinessDate, usage.Channel)
+	if err != nil {
+		r.log.LogOperation(ctx, "AddWithdrawalUsage", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	usage.TotalAmount += amount
+	usage.Count++
+	r.log.LogOperation(ctx, "AddWithdrawalUsage", "success", map[string]interface{}{
+		"account_id": usage.AccountNumber,
+	})
+	return nil
+}
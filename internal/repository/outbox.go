@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+const outboxColumns = `id, event_id, event_type, schema_version, aggregate_id, payload, attempts, last_error, next_attempt_at, published_at, created_at`
+
+// ledgerEvents maps the ledger entry types other services follow to the event
+// they publish
+var ledgerEvents = map[string]string{
+	models.TransactionDeposit:    events.Deposited,
+	models.TransactionWithdrawal: events.Withdrawn,
+}
+
+// enqueueEvent writes an event to the outbox, and a delivery to every webhook
+// subscribed to it, in the repository's transaction, so it is published
+// exactly when the change it describes is committed
+func (r *repository) enqueueEvent(ctx context.Context, eventType, aggregateID string, data interface{}, occurredAt time.Time) error {
+	query := `INSERT INTO outbox_events (event_id, event_type, schema_version, aggregate_id, payload, next_attempt_at, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $6)`
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := &events.Envelope{
+		ID:          models.NewReference(),
+		Type:        eventType,
+		Version:     events.Versions[eventType],
+		AggregateID: aggregateID,
+		OccurredAt:  occurredAt,
+		Data:        payload,
+	}
+	_, err = r.DB.ExecContext(ctx, query,
+		event.ID,
+		event.Type,
+		event.Version,
+		event.AggregateID,
+		[]byte(event.Data),
+		event.OccurredAt,
+	)
+	if err == nil {
+		err = r.enqueueWebhookDeliveries(ctx, event)
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "EnqueueEvent", "error", map[string]interface{}{
+			"error":      err.Error(),
+			"event_type": eventType,
+		})
+		return err
+	}
+	return nil
+}
+
+// enqueueLedgerEvents publishes BalanceChanged for a ledger entry, and the
+// event of its type if it has one
+func (r *repository) enqueueLedgerEvents(ctx context.Context, trx *models.Transaction) error {
+	data := events.BalanceChangedV1{
+		AccountNumber: trx.AccountNumber,
+		TransactionID: trx.ID,
+		Reference:     trx.Reference,
+		Type:          trx.Type,
+		Direction:     trx.Direction,
+		Amount:        trx.Amount,
+		BalanceAfter:  trx.BalanceAfter,
+		Description:   trx.Description,
+		OccurredAt:    trx.CreatedAt,
+	}
+	if eventType, ok := ledgerEvents[trx.Type]; ok {
+		if err := r.enqueueEvent(ctx, eventType, trx.AccountNumber, data, trx.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return r.enqueueEvent(ctx, events.BalanceChanged, trx.AccountNumber, data, trx.CreatedAt)
+}
+
+// ClaimOutboxEvents leases up to limit unpublished events that are due until
+// leaseUntil, oldest first, by moving their next attempt there. The claim
+// commits on its own, so no lock is held while the events are published;
+// another relay only picks an event up again once its lease ran out.
+func (r *repository) ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	query := `UPDATE outbox_events SET next_attempt_at = $2
+			 WHERE id IN (SELECT id FROM outbox_events
+			              WHERE published_at IS NULL AND next_attempt_at <= $1
+			              ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED)
+			 RETURNING ` + outboxColumns
+
+	rows, err := r.DB.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		r.log.LogOperation(ctx, "ClaimOutboxEvents", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := []models.OutboxEvent{}
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		err := rows.Scan(
+			&event.ID,
+			&event.EventID,
+			&event.EventType,
+			&event.SchemaVersion,
+			&event.AggregateID,
+			&payload,
+			&event.Attempts,
+			&event.LastError,
+			&event.NextAttemptAt,
+			&event.PublishedAt,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			r.log.LogOperation(ctx, "ClaimOutboxEvents", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		event.Payload = payload
+		claimed = append(claimed, event)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "ClaimOutboxEvents", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].ID < claimed[j].ID })
+	return claimed, nil
+}
+
+// MarkOutboxPublished records that an event was delivered
+func (r *repository) MarkOutboxPublished(ctx context.Context, id int64, publishedAt time.Time) error {
+	query := `UPDATE outbox_events SET published_at = $1, attempts = attempts + 1, last_error = '' WHERE id = $2`
+
+	if _, err := r.DB.ExecContext(ctx, query, publishedAt, id); err != nil {
+		r.log.LogOperation(ctx, "MarkOutboxPublished", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// MarkOutboxFailed records a failed delivery and when to try again
+func (r *repository) MarkOutboxFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3`
+
+	if _, err := r.DB.ExecContext(ctx, query, lastError, nextAttemptAt, id); err != nil {
+		r.log.LogOperation(ctx, "MarkOutboxFailed", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
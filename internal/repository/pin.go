@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+func (r *repository) CreatePin(ctx context.Context, pin *models.AccountPin) error {
+	query := `INSERT INTO account_pins (account_number, pin_hash, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4)`
+
+	r.log.LogOperation(ctx, "CreatePin", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	_, err := r.DB.ExecContext(ctx, query, pin.AccountNumber, pin.PinHash, pin.CreatedAt, pin.UpdatedAt)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreatePin", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreatePin", "success", map[string]interface{}{
+		"account_id": pin.AccountNumber,
+	})
+	return nil
+}
+
+// GetPinForUpdate loads the PIN row and locks it until the surrounding
+// transaction ends, so concurrent attempts are counted one after another
+func (r *repository) GetPinForUpdate(ctx context.Context, accountNumber string) (*models.AccountPin, error) {
+	var pin models.AccountPin
+	query := `SELECT account_number, pin_hash, failed_attempts, locked_until, created_at, updated_at
+			 FROM account_pins WHERE account_number = $1 FOR UPDATE`
+
+	r.log.LogOperation(ctx, "GetPinForUpdate", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.DB.QueryRowContext(ctx, query, accountNumber).Scan(
+		&pin.AccountNumber,
+		&pin.PinHash,
+		&pin.FailedAttempts,
+		&pin.LockedUntil,
+		&pin.CreatedAt,
+		&pin.UpdatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetPinForUpdate", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetPinForUpdate", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return &pin, nil
+}
+
+// UpdatePin persists the hash and lockout state of a PIN
+func (r *repository) UpdatePin(ctx context.Context, pin *models.AccountPin) error {
+	query := `UPDATE account_pins SET pin_hash = $1, failed_attempts = $2, locked_until = $3, updated_at = CURRENT_TIMESTAMP
+			 WHERE account_number = $4`
+
+	r.log.LogOperation(ctx, "UpdatePin", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	_, err := r.DB.ExecContext(ctx, query, pin.PinHash, pin.FailedAttempts, pin.LockedUntil, pin.AccountNumber)
+	if err != nil {
+		r.log.LogOperation(ctx, "UpdatePin", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "UpdatePin", "success", map[string]interface{}{
+		"account_id": pin.AccountNumber,
+	})
+	return nil
+}
+
+func (r *repository) CreatePinAttempt(ctx context.Context, attempt *models.PinAttempt) error {
+	query := `INSERT INTO pin_attempts (account_number, operation, success, reason, created_at)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	err := r.DB.QueryRowContext(ctx, query,
+		attempt.AccountNumber,
+		attempt.Operation,
+		attempt.Success,
+		attempt.Reason,
+		attempt.CreatedAt,
+	).Scan(&attempt.ID)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreatePinAttempt", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreatePinAttempt", "success", map[string]interface{}{
+		"account_id":    attempt.AccountNumber,
+		"pin_operation": attempt.Operation,
+		"success":       attempt.Success,
+		"reason":        attempt.Reason,
+	})
+	return nil
+}
+
+// CountFailedPinAttempts counts the failed attempts on an account since a
+// point in time. It takes a transaction-scoped lock on the account's attempts
+// first, so concurrent attempts are counted one after another.
+func (r *repository) CountFailedPinAttempts(ctx context.Context, accountNumber string, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM pin_attempts WHERE account_number = $1 AND success = FALSE AND created_at >= $2`
+
+	r.log.LogOperation(ctx, "CountFailedPinAttempts", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		if _, err := tx.DB.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('pin_attempts:' || $1::text))`, accountNumber); err != nil {
+			return err
+		}
+		return tx.DB.QueryRowContext(ctx, query, accountNumber, since).Scan(&count)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CountFailedPinAttempts", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	r.log.LogOperation(ctx, "CountFailedPinAttempts", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"count":      count,
+	})
+	return count, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// recordingDriver records the statements run on its connections, in order,
+// and answers every query with a single row holding count, or with the rows
+// returned by answer when it is set
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []string
+	count      int64
+	answer     func(query string) driver.Rows
+}
+
+func (d *recordingDriver) record(statement string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, strings.Join(strings.Fields(statement), " "))
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.d, query}, nil
+}
+func (c recordingConn) Close() error { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return recordingTx{c.d}, nil
+}
+
+type recordingTx struct{ d *recordingDriver }
+
+func (tx recordingTx) Commit() error   { tx.d.record("COMMIT"); return nil }
+func (tx recordingTx) Rollback() error { tx.d.record("ROLLBACK"); return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.d.record(s.query)
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.record(s.query)
+	if s.d.answer != nil {
+		return s.d.answer(s.query), nil
+	}
+	return &countRows{count: s.d.count}, nil
+}
+
+type countRows struct {
+	count int64
+	done  bool
+}
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.count
+	return nil
+}
+
+func TestCountFailedPinAttemptsHoldsAdvisoryLock(t *testing.T) {
+	d := &recordingDriver{count: 2}
+	sql.Register("recording-pin", d)
+	db, err := sql.Open("recording-pin", "")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	repo := NewRepository(db, models.StatusPolicy{}, log)
+
+	count, err := repo.CountFailedPinAttempts(context.Background(), "1000000001", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	// accounts without a PIN row have nothing to lock FOR UPDATE, so the
+	// count must run after taking the account's advisory lock in the same
+	// transaction, which holds it until the attempt is recorded and committed
+	want := []string{
+		"BEGIN",
+		"SELECT pg_advisory_xact_lock(hashtext('pin_attempts:' || $1::text))",
+		"SELECT COUNT(*) FROM pin_attempts WHERE account_number = $1 AND success = FALSE AND created_at >= $2",
+		"COMMIT",
+	}
+	if strings.Join(d.statements, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements =\n%s\nwant\n%s", strings.Join(d.statements, "\n"), strings.Join(want, "\n"))
+	}
+}
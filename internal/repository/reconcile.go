@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// GetLedgerBalances returns up to limit accounts after the given account
+// number with their stored balance and the balance summed from their ledger.
+// Each chunk is one plain read, so it takes no row locks and sees the balance
+// and the ledger at the same point in time.
+func (r *repository) GetLedgerBalances(ctx context.Context, after string, limit int) ([]models.LedgerBalance, error) {
+	query := `SELECT a.account_number, a.status, a.balance,
+			 COALESCE(SUM(CASE WHEN t.direction = $3 THEN t.amount ELSE -t.amount END), 0),
+			 COUNT(t.id)
+			 FROM accounts a LEFT JOIN transactions t ON t.account_number = a.account_number
+			 WHERE a.account_number > $1
+			 GROUP BY a.account_number, a.status, a.balance
+			 ORDER BY a.account_number LIMIT $2`
+
+	r.log.LogOperation(ctx, "GetLedgerBalances", "start", map[string]interface{}{
+		"after": after,
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, after, limit, models.DirectionCredit)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetLedgerBalances", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.LedgerBalance
+	for rows.Next() {
+		var balance models.LedgerBalance
+		if err := rows.Scan(
+			&balance.AccountNumber,
+			&balance.Status,
+			&balance.Balance,
+			&balance.LedgerBalance,
+			&balance.EntryCount,
+		); err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetLedgerBalances", "success", map[string]interface{}{
+		"count": len(balances),
+	})
+	return balances, nil
+}
+
+// GetAccountLedgerBalance sums all ledger entries of one account
+func (r *repository) GetAccountLedgerBalance(ctx context.Context, accountNumber string) (float64, error) {
+	query := `SELECT COALESCE(SUM(CASE WHEN direction = $2 THEN amount ELSE -amount END), 0)
+			 FROM transactions WHERE account_number = $1`
+
+	r.log.LogOperation(ctx, "GetAccountLedgerBalance", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	var balance float64
+	if err := r.DB.QueryRowContext(ctx, query, accountNumber, models.DirectionCredit).Scan(&balance); err != nil {
+		r.log.LogOperation(ctx, "GetAccountLedgerBalance", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	r.log.LogOperation(ctx, "GetAccountLedgerBalance", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return balance, nil
+}
@@ -4,35 +4,214 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/events"
 	"github.com/alfaa19/service-account-test/internal/models"
 	logger "github.com/alfaa19/service-account-test/pkg/logrus"
 )
 
+var (
+	ErrInsufficientBalance = errcode.New("INSUFFICIENT_BALANCE", "insufficient balance")
+	ErrSaldoNotUpdated     = errcode.New("BALANCE_NOT_UPDATED", "saldo not updated")
+	ErrAccountNotFound     = errcode.New("ACCOUNT_NOT_FOUND", "account not found")
+	ErrAccountFrozen       = errcode.New("ACCOUNT_FROZEN", "account is frozen")
+	ErrAccountDormant      = errcode.New("ACCOUNT_DORMANT", "account is dormant")
+	ErrAccountClosed       = errcode.New("ACCOUNT_CLOSED", "account is closed")
+)
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx so the same repository code
+// can run standalone or inside a transaction started by WithTx
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type repository struct {
-	DB  *sql.DB
-	log *logger.CustomLogger
+	DB           dbtx
+	statusPolicy models.StatusPolicy
+	listener     LedgerListener
+	log          *logger.CustomLogger
+
+	// committed collects the ledger entries of a transaction started by inTx,
+	// handed to the listener once it commits
+	committed []models.Transaction
+}
+
+// LedgerListener is told about ledger entries after their database
+// transaction has committed. It is called synchronously and must not block.
+type LedgerListener interface {
+	LedgerCommitted(entries []models.Transaction)
+}
+
+// Option configures the repository
+type Option func(*repository)
+
+// WithLedgerListener has committed ledger entries reported to listener
+func WithLedgerListener(listener LedgerListener) Option {
+	return func(r *repository) {
+		r.listener = listener
+	}
 }
 
 type Repository interface {
+	WithTx(ctx context.Context, fn func(repo Repository) error) error
+
 	GetAccountByNoRekening(ctx context.Context, noRekening string) (*models.Account, error)
-	NIKExist(ctx context.Context, nik string) (bool, error)
-	PhoneNumberExist(ctx context.Context, phoneNumber string) (bool, error)
+	NIKExist(ctx context.Context, nik string, closedAfter time.Time) (bool, error)
+	PhoneNumberExist(ctx context.Context, phoneNumber string, closedAfter time.Time) (bool, error)
 	CreateAccount(ctx context.Context, account *models.Account) (*models.Account, error)
 	UpdateBalanceWithdraw(ctx context.Context, accountNumber string, amount float64) error
 	UpdateBalanceDeposit(ctx context.Context, accountNumber string, amount float64) error
+	GetAccountForUpdate(ctx context.Context, accountNumber string) (*models.Account, error)
+	UpdateAccountStatus(ctx context.Context, accountNumber, status string) error
+	CreateStatusChange(ctx context.Context, change *models.AccountStatusChange) error
+
+	CreateCredential(ctx context.Context, credential *models.Credential) error
+	GetCredential(ctx context.Context, accountNumber string) (*models.Credential, error)
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	RevokeAccountRefreshTokens(ctx context.Context, accountNumber string) error
+
+	CreatePin(ctx context.Context, pin *models.AccountPin) error
+	GetPinForUpdate(ctx context.Context, accountNumber string) (*models.AccountPin, error)
+	UpdatePin(ctx context.Context, pin *models.AccountPin) error
+	CreatePinAttempt(ctx context.Context, attempt *models.PinAttempt) error
+	CountFailedPinAttempts(ctx context.Context, accountNumber string, since time.Time) (int, error)
+
+	CreateAPIClient(ctx context.Context, client *models.APIClient) error
+	GetAPIClient(ctx context.Context, clientID string) (*models.APIClient, error)
+	UseNonce(ctx context.Context, clientID, nonce string, expiresAt time.Time) (bool, error)
+	UseExternalID(ctx context.Context, partnerID, externalID string, businessDate time.Time) (bool, error)
+
+	CreateTransaction(ctx context.Context, trx *models.Transaction) error
+	Debit(ctx context.Context, reference, accountNumber string, amount float64, trxType, description string) (*models.Transaction, error)
+	Credit(ctx context.Context, reference, accountNumber string, amount float64, trxType, description string) (*models.Transaction, error)
+	GetBalanceAt(ctx context.Context, accountNumber string, at time.Time) (float64, error)
+	GetLedger(ctx context.Context, accountNumber string, from, to time.Time) ([]models.Transaction, error)
+	PostSystemEntry(ctx context.Context, reference, accountNumber, direction string, amount float64, trxType, description string) (*models.Transaction, error)
+	GetEndOfDayBalances(ctx context.Context, dayEnd time.Time, afterAccount string, limit int) ([]models.EndOfDayBalance, error)
+	CreateAccruals(ctx context.Context, accruals []models.InterestAccrual) (int, error)
+	GetUnpostedInterest(ctx context.Context, fromDate, toDate, afterAccount string, limit int) ([]models.InterestPosting, error)
+	CreateInterestPosting(ctx context.Context, posting *models.InterestPosting) (bool, error)
+	MarkAccrualsPosted(ctx context.Context, accountNumber, fromDate, toDate, reference string) error
+	StartEODRun(ctx context.Context, run *models.EODRun) (bool, error)
+	CreateBalanceSnapshots(ctx context.Context, businessDate string, dayEnd time.Time) (int, error)
+	CompleteEODRun(ctx context.Context, run *models.EODRun, dayStart, dayEnd time.Time) error
+	GetLatestSnapshot(ctx context.Context, accountNumber, businessDate string) (*models.BalanceSnapshot, error)
+	GetLedgerNet(ctx context.Context, accountNumber string, from, to time.Time) (float64, error)
+	GetLedgerBalances(ctx context.Context, after string, limit int) ([]models.LedgerBalance, error)
+	GetAccountLedgerBalance(ctx context.Context, accountNumber string) (float64, error)
+	GetChainEntries(ctx context.Context, afterSeq int64, limit int) ([]models.LedgerEntry, error)
+	GetChainHead(ctx context.Context) (int64, string, error)
+	CountUnchainedEntries(ctx context.Context) (int64, error)
+	CreateCheckpoint(ctx context.Context, cp *models.LedgerCheckpoint) (bool, error)
+	GetCheckpoints(ctx context.Context) ([]models.LedgerCheckpoint, error)
+	GetLatestCheckpoint(ctx context.Context) (*models.LedgerCheckpoint, error)
+	GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error)
+	GetTransactionsByReference(ctx context.Context, reference string) ([]models.Transaction, error)
+	IsReversed(ctx context.Context, id int64) (bool, error)
+	PostReversal(ctx context.Context, original *models.Transaction, reference, description string, allowOverdraft bool) (*models.Transaction, error)
+	CreateReversal(ctx context.Context, reversal *models.Reversal) error
+	CreatePendingOperation(ctx context.Context, op *models.PendingOperation) error
+	GetPendingOperationForUpdate(ctx context.Context, id string) (*models.PendingOperation, error)
+	UpdatePendingOperation(ctx context.Context, op *models.PendingOperation) error
+	GetPendingOperations(ctx context.Context, status string, limit int) ([]models.PendingOperation, error)
+	GetExpiredPendingOperations(ctx context.Context, now time.Time, limit int) ([]models.PendingOperation, error)
+	CreatePendingOperationEvent(ctx context.Context, event *models.PendingOperationEvent) error
+	GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+	ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkOutboxPublished(ctx context.Context, id int64, publishedAt time.Time) error
+	MarkOutboxFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error
+	CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	GetWebhookSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error)
+	GetWebhookSubscriptions(ctx context.Context, clientID string) ([]models.WebhookSubscription, error)
+	DeactivateWebhookSubscription(ctx context.Context, id, clientID string) error
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, clientID, status string, limit, offset int) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id int64, clientID string) (*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	RedeliverWebhook(ctx context.Context, id int64, clientID string, now time.Time) (*models.WebhookDelivery, error)
+	CreateWebhookAttempt(ctx context.Context, attempt *models.WebhookAttempt) error
+	GetWebhookAttempts(ctx context.Context, deliveryID int64) ([]models.WebhookAttempt, error)
+	GetOpenAccounts(ctx context.Context, afterAccount string, limit int) ([]models.Account, error)
+	GetStatementAccounts(ctx context.Context, from, to time.Time, afterAccount string, limit int) ([]models.Account, error)
+	CreateAdminFeeCharge(ctx context.Context, charge *models.AdminFeeCharge) (bool, error)
+	GetWithdrawalUsageForUpdate(ctx context.Context, accountNumber, businessDate, channel string) (*models.WithdrawalUsage, error)
+	AddWithdrawalUsage(ctx context.Context, usage *models.WithdrawalUsage, amount float64) error
+	CreateHold(ctx context.Context, hold *models.Hold) error
+	GetHoldForUpdate(ctx context.Context, id string) (*models.Hold, error)
+	CaptureHold(ctx context.Context, hold *models.Hold, amount float64, description string) (*models.Transaction, error)
+	ReleaseHold(ctx context.Context, hold *models.Hold, status string) error
+	GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error)
+	Transfer(ctx context.Context, reference, fromAccount, toAccount string, amount float64, description string) (*models.Transaction, error)
+	GetTransactions(ctx context.Context, accountNumber string, from, to time.Time, limit, offset int) ([]models.Transaction, error)
+	GetTransactionsAfter(ctx context.Context, accountNumber string, afterID int64, limit int) ([]models.Transaction, error)
 }
 
-func NewRepository(db *sql.DB, log *logger.CustomLogger) Repository {
-	return &repository{
-		DB:  db,
-		log: log,
+// NewRepository creates the repository. statusPolicy decides which account
+// statuses balance updates accept; it is enforced in the UPDATE statements.
+func NewRepository(db *sql.DB, statusPolicy models.StatusPolicy, log *logger.CustomLogger, opts ...Option) Repository {
+	r := &repository{
+		DB:           db,
+		statusPolicy: statusPolicy,
+		log:          log,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithTx runs fn against a repository bound to a single database transaction.
+// The transaction is committed when fn returns nil and rolled back otherwise.
+// Calling WithTx on a repository that is already inside a transaction reuses it.
+func (r *repository) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	return r.inTx(ctx, func(tx *repository) error {
+		return fn(tx)
+	})
+}
+
+func (r *repository) inTx(ctx context.Context, fn func(tx *repository) error) error {
+	db, ok := r.DB.(*sql.DB)
+	if !ok {
+		return fn(r)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		r.log.LogOperation(ctx, "WithTx", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	txRepo := *r
+	txRepo.DB = tx
+	if err := fn(&txRepo); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			r.log.LogOperation(ctx, "WithTx", "error", map[string]interface{}{
+				"error": rbErr.Error(),
+			})
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if r.listener != nil && len(txRepo.committed) > 0 {
+		r.listener.LedgerCommitted(txRepo.committed)
+	}
+	return nil
 }
 
 func (r *repository) GetAccountByNoRekening(ctx context.Context, noRekening string) (*models.Account, error) {
 	var account models.Account
-	query := `SELECT id, account_number, name, nik, phone_number, balance, created_at, updated_at 
+	query := `SELECT id, account_number, name, nik, phone_number, balance, held_amount, status, account_type, product, kyc_tier, created_at, updated_at 
 			 FROM accounts WHERE account_number = $1`
 
 	r.log.LogOperation(ctx, "GetAccountByNoRekening", "start", map[string]interface{}{
@@ -46,6 +225,11 @@ func (r *repository) GetAccountByNoRekening(ctx context.Context, noRekening stri
 		&account.NIK,
 		&account.PhoneNumber,
 		&account.Balance,
+		&account.HeldAmount,
+		&account.Status,
+		&account.AccountType,
+		&account.Product,
+		&account.KYCTier,
 		&account.CreatedAt,
 		&account.UpdatedAt,
 	)
@@ -62,13 +246,16 @@ func (r *repository) GetAccountByNoRekening(ctx context.Context, noRekening stri
 	return &account, nil
 }
 
-func (r *repository) NIKExist(ctx context.Context, nik string) (bool, error) {
+// NIKExist reports whether nik still belongs to an account. Closed accounts
+// only hold on to it when they were closed after closedAfter.
+func (r *repository) NIKExist(ctx context.Context, nik string, closedAfter time.Time) (bool, error) {
 	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM accounts WHERE nik = $1)`
+	query := `SELECT EXISTS(SELECT 1 FROM accounts WHERE nik = $1
+			 AND (status <> 'CLOSED' OR closed_at > $2))`
 
 	r.log.LogOperation(ctx, "GetAccountByNIK", "start", map[string]interface{}{})
 
-	err := r.DB.QueryRowContext(ctx, query, nik).Scan(&exists)
+	err := r.DB.QueryRowContext(ctx, query, nik, closedAfter).Scan(&exists)
 	if err != nil {
 		r.log.LogOperation(ctx, "GetAccountByNIK", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -80,13 +267,15 @@ func (r *repository) NIKExist(ctx context.Context, nik string) (bool, error) {
 	return exists, nil
 }
 
-func (r *repository) PhoneNumberExist(ctx context.Context, phoneNumber string) (bool, error) {
+// PhoneNumberExist works like NIKExist for phone numbers
+func (r *repository) PhoneNumberExist(ctx context.Context, phoneNumber string, closedAfter time.Time) (bool, error) {
 	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM accounts WHERE phone_number = $1)`
+	query := `SELECT EXISTS(SELECT 1 FROM accounts WHERE phone_number = $1
+			 AND (status <> 'CLOSED' OR closed_at > $2))`
 
 	r.log.LogOperation(ctx, "GetAccountByPhoneNumber", "start", map[string]interface{}{})
 
-	err := r.DB.QueryRowContext(ctx, query, phoneNumber).Scan(&exists)
+	err := r.DB.QueryRowContext(ctx, query, phoneNumber, closedAfter).Scan(&exists)
 	if err != nil {
 		r.log.LogOperation(ctx, "GetAccountByPhoneNumber", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -99,20 +288,61 @@ func (r *repository) PhoneNumberExist(ctx context.Context, phoneNumber string) (
 }
 
 func (r *repository) CreateAccount(ctx context.Context, account *models.Account) (*models.Account, error) {
-	query := `INSERT INTO accounts (account_number, name, nik, phone_number, balance, created_at, updated_at) 
-			 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	query := `INSERT INTO accounts (account_number, name, nik, phone_number, balance, status, account_type, product, kyc_tier, created_at, updated_at) 
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+
+	if account.Status == "" {
+		account.Status = models.StatusActive
+	}
+	if account.AccountType == "" {
+		account.AccountType = models.AccountTypeRegular
+	}
+	if account.Product == "" {
+		account.Product = models.ProductTabungan
+	}
+	if account.KYCTier == "" {
+		account.KYCTier = models.KYCTierBasic
+	}
 
 	r.log.LogOperation(ctx, "CreateAccount", "start", map[string]interface{}{})
 
-	err := r.DB.QueryRowContext(ctx, query,
-		account.AccountNumber,
-		account.Name,
-		account.NIK,
-		account.PhoneNumber,
-		account.Balance,
-		account.CreatedAt,
-		account.UpdatedAt,
-	).Scan(&account.ID)
+	err := r.inTx(ctx, func(tx *repository) error {
+		err := tx.DB.QueryRowContext(ctx, query,
+			account.AccountNumber,
+			account.Name,
+			account.NIK,
+			account.PhoneNumber,
+			account.Balance,
+			account.Status,
+			account.AccountType,
+			account.Product,
+			account.KYCTier,
+			account.CreatedAt,
+			account.UpdatedAt,
+		).Scan(&account.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.recordAudit(ctx, &models.AuditEvent{
+			Action:        models.AuditAccountCreated,
+			EntityType:    models.EntityAccount,
+			EntityID:      account.AccountNumber,
+			AccountNumber: account.AccountNumber,
+			After:         auditState(maskedAccount(account)),
+			CreatedAt:     account.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.enqueueEvent(ctx, events.AccountCreated, account.AccountNumber, events.AccountCreatedV1{
+			AccountNumber: account.AccountNumber,
+			Name:          account.Name,
+			Product:       account.Product,
+			AccountType:   account.AccountType,
+			KYCTier:       account.KYCTier,
+			CreatedAt:     account.CreatedAt,
+		}, account.CreatedAt)
+	})
 	if err != nil {
 		r.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -128,61 +358,63 @@ func (r *repository) CreateAccount(ctx context.Context, account *models.Account)
 }
 
 func (r *repository) UpdateBalanceWithdraw(ctx context.Context, accountNumber string, amount float64) error {
-	query := `UPDATE accounts SET balance = balance - $1 WHERE account_number = $2 AND balance >= $1`
+	_, err := r.Debit(ctx, models.NewReference(), accountNumber, amount, models.TransactionWithdrawal, "")
+	return err
+}
 
-	r.log.LogOperation(ctx, "UpdateBalanceWithdraw", "start", map[string]interface{}{
-		"account_id": accountNumber,
-		"amount":     amount,
-	})
+func (r *repository) UpdateBalanceDeposit(ctx context.Context, accountNumber string, amount float64) error {
+	_, err := r.Credit(ctx, models.NewReference(), accountNumber, amount, models.TransactionDeposit, "")
+	return err
+}
 
-	result, err := r.DB.ExecContext(ctx, query, amount, accountNumber)
+// debitError explains why a guarded debit UPDATE matched no row
+func (r *repository) debitError(ctx context.Context, accountNumber string) error {
+	account, err := r.GetAccountByNoRekening(ctx, accountNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAccountNotFound
+	}
 	if err != nil {
-		r.log.LogOperation(ctx, "UpdateBalanceWithdraw", "error", map[string]interface{}{
-			"error": err.Error(),
-		})
 		return err
 	}
-	rowAffected, _ := result.RowsAffected()
-	if rowAffected == 0 {
-		r.log.LogOperation(ctx, "UpdateBalanceWithdraw", "error", map[string]interface{}{
-			"error": "insufficient balance",
-		})
-		return errors.New("insufficient balance")
-
+	if !containsStatus(r.statusPolicy.DebitStatuses(), account.Status) {
+		return statusError(account.Status)
 	}
-
-	r.log.LogOperation(ctx, "UpdateBalanceWithdraw", "success", map[string]interface{}{
-		"account_id": accountNumber,
-	})
-	return nil
+	return ErrInsufficientBalance
 }
 
-func (r *repository) UpdateBalanceDeposit(ctx context.Context, accountNumber string, amount float64) error {
-	query := `UPDATE accounts SET balance = balance + $1 WHERE  account_number = $2`
-
-	r.log.LogOperation(ctx, "UpdateBalanceDeposit", "start", map[string]interface{}{
-		"account_id": accountNumber,
-		"amount":     amount,
-	})
-
-	result, err := r.DB.ExecContext(ctx, query, amount, accountNumber)
+// creditError explains why a guarded credit UPDATE matched no row
+func (r *repository) creditError(ctx context.Context, accountNumber string) error {
+	account, err := r.GetAccountByNoRekening(ctx, accountNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAccountNotFound
+	}
 	if err != nil {
-		r.log.LogOperation(ctx, "UpdateBalanceDeposit", "error", map[string]interface{}{
-			"error": err.Error(),
-		})
 		return err
 	}
+	if !containsStatus(r.statusPolicy.CreditStatuses(), account.Status) {
+		return statusError(account.Status)
+	}
+	return ErrSaldoNotUpdated
+}
 
-	rowAffected, _ := result.RowsAffected()
-	if rowAffected == 0 {
-		r.log.LogOperation(ctx, "UpdateBalanceWithdraw", "error", map[string]interface{}{
-			"error": "saldo not updated",
-		})
-		return errors.New("saldo not updated")
+func statusError(status string) error {
+	switch status {
+	case models.StatusFrozen:
+		return ErrAccountFrozen
+	case models.StatusDormant:
+		return ErrAccountDormant
+	case models.StatusClosed:
+		return ErrAccountClosed
+	default:
+		return ErrSaldoNotUpdated
+	}
+}
 
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
 	}
-	r.log.LogOperation(ctx, "UpdateBalanceDeposit", "success", map[string]interface{}{
-		"account_id": accountNumber,
-	})
-	return nil
+	return false
 }
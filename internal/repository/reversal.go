@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTransactionNotFound = errcode.New("TRANSACTION_NOT_FOUND", "transaction not found")
+	ErrAlreadyReversed     = errcode.New("TRANSACTION_ALREADY_REVERSED", "transaction has already been reversed")
+)
+
+const transactionColumns = `id, reference, account_number, type, direction, amount, balance_after, description, reversal_of, created_at`
+
+func scanTransaction(row rowScanner) (*models.Transaction, error) {
+	var trx models.Transaction
+	var reversalOf sql.NullInt64
+	err := row.Scan(
+		&trx.ID,
+		&trx.Reference,
+		&trx.AccountNumber,
+		&trx.Type,
+		&trx.Direction,
+		&trx.Amount,
+		&trx.BalanceAfter,
+		&trx.Description,
+		&reversalOf,
+		&trx.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if reversalOf.Valid {
+		trx.ReversalOf = &reversalOf.Int64
+	}
+	return &trx, nil
+}
+
+// GetTransactionByID returns one ledger entry
+func (r *repository) GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1`
+
+	r.log.LogOperation(ctx, "GetTransactionByID", "start", map[string]interface{}{
+		"transaction_id": id,
+	})
+
+	trx, err := scanTransaction(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrTransactionNotFound
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetTransactionByID", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetTransactionByID", "success", map[string]interface{}{
+		"transaction_id": id,
+	})
+	return trx, nil
+}
+
+// GetTransactionsByReference returns every ledger entry posted under a
+// reference, in posting order
+func (r *repository) GetTransactionsByReference(ctx context.Context, reference string) ([]models.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE reference = $1 ORDER BY id`
+
+	r.log.LogOperation(ctx, "GetTransactionsByReference", "start", map[string]interface{}{
+		"reference": reference,
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, reference)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetTransactionsByReference", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		trx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *trx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetTransactionsByReference", "success", map[string]interface{}{
+		"reference": reference,
+		"count":     len(transactions),
+	})
+	return transactions, nil
+}
+
+// IsReversed reports whether a ledger entry already has a compensating entry
+func (r *repository) IsReversed(ctx context.Context, id int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM transactions WHERE reversal_of = $1)`
+
+	var reversed bool
+	if err := r.DB.QueryRowContext(ctx, query, id).Scan(&reversed); err != nil {
+		r.log.LogOperation(ctx, "IsReversed", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return false, err
+	}
+	return reversed, nil
+}
+
+// PostReversal posts the compensating entry of original: the same amount in
+// the other direction, linked through reversal_of. Closed accounts are
+// refused; otherwise, whatever the status, a reversal that debits needs
+// enough available balance unless allowOverdraft is set.
+func (r *repository) PostReversal(ctx context.Context, original *models.Transaction, reference, description string, allowOverdraft bool) (*models.Transaction, error) {
+	debitQuery := `UPDATE accounts SET balance = balance - $1
+			 WHERE account_number = $2 AND status <> 'CLOSED' AND ($3 OR balance - held_amount >= $1) RETURNING balance`
+	creditQuery := `UPDATE accounts SET balance = balance + $1
+			 WHERE account_number = $2 AND status <> 'CLOSED' RETURNING balance`
+
+	r.log.LogOperation(ctx, "PostReversal", "start", map[string]interface{}{
+		"account_id":     original.AccountNumber,
+		"transaction_id": original.ID,
+	})
+
+	direction := models.DirectionDebit
+	if original.Direction == models.DirectionDebit {
+		direction = models.DirectionCredit
+	}
+
+	var trx *models.Transaction
+	err := r.inTx(ctx, func(tx *repository) error {
+		var balance float64
+		var err error
+		if direction == models.DirectionDebit {
+			err = tx.DB.QueryRowContext(ctx, debitQuery, original.Amount, original.AccountNumber, allowOverdraft).Scan(&balance)
+		} else {
+			err = tx.DB.QueryRowContext(ctx, creditQuery, original.Amount, original.AccountNumber).Scan(&balance)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			account, err := tx.GetAccountByNoRekening(ctx, original.AccountNumber)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrAccountNotFound
+			case err != nil:
+				return err
+			case account.Status == models.StatusClosed:
+				return ErrAccountClosed
+			}
+			return ErrInsufficientBalance
+		}
+		if err != nil {
+			return err
+		}
+
+		reversalOf := original.ID
+		trx = &models.Transaction{
+			Reference:     reference,
+			AccountNumber: original.AccountNumber,
+			Type:          models.TransactionReversal,
+			Direction:     direction,
+			Amount:        original.Amount,
+			BalanceAfter:  balance,
+			Description:   description,
+			ReversalOf:    &reversalOf,
+		}
+		err = tx.CreateTransaction(ctx, trx)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrAlreadyReversed
+		}
+		return err
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "PostReversal", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "PostReversal", "success", map[string]interface{}{
+		"account_id": original.AccountNumber,
+		"reference":  reference,
+	})
+	return trx, nil
+}
+
+// CreateReversal records why and by whom a transaction was reversed
+func (r *repository) CreateReversal(ctx context.Context, reversal *models.Reversal) error {
+	query := `INSERT INTO reversals (reference, transaction_id, reason, actor, forced, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 RETURNING (SELECT account_number FROM transactions WHERE id = $2)`
+
+	r.log.LogOperation(ctx, "CreateReversal", "start", map[string]interface{}{
+		"reference": reversal.Reference,
+	})
+
+	if reversal.CreatedAt.IsZero() {
+		reversal.CreatedAt = time.Now()
+	}
+	err := r.inTx(ctx, func(tx *repository) error {
+		var accountNumber string
+		err := tx.DB.QueryRowContext(ctx, query,
+			reversal.Reference,
+			reversal.TransactionID,
+			reversal.Reason,
+			reversal.Actor,
+			reversal.Forced,
+			reversal.CreatedAt,
+		).Scan(&accountNumber)
+		if err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, &models.AuditEvent{
+			Action:        models.AuditReversalCreated,
+			EntityType:    models.EntityReversal,
+			EntityID:      reversal.Reference,
+			AccountNumber: accountNumber,
+			Actor:         reversal.Actor,
+			After: auditState(map[string]interface{}{
+				"transaction_id": reversal.TransactionID,
+				"forced":         reversal.Forced,
+			}),
+			Reason:    reversal.Reason,
+			CreatedAt: reversal.CreatedAt,
+		})
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateReversal", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateReversal", "success", map[string]interface{}{
+		"reference": reversal.Reference,
+	})
+	return nil
+}
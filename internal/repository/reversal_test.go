@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// tableRows answers a query with fixed rows
+type tableRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *tableRows) Columns() []string { return r.columns }
+func (r *tableRows) Close() error      { return nil }
+func (r *tableRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestPostReversalRefusesClosedAccount(t *testing.T) {
+	// the guarded UPDATE matches no row and the account reads back CLOSED
+	d := &recordingDriver{answer: func(query string) driver.Rows {
+		if strings.HasPrefix(strings.TrimSpace(query), "UPDATE accounts") {
+			return &tableRows{columns: []string{"balance"}}
+		}
+		now := time.Now()
+		return &tableRows{
+			columns: []string{"id", "account_number", "name", "nik", "phone_number", "balance", "held_amount",
+				"status", "account_type", "product", "kyc_tier", "created_at", "updated_at"},
+			rows: [][]driver.Value{{int64(1), "1000000001", "Budi", "3171010101010001", "081234567890", 50000.0, 0.0,
+				models.StatusClosed, models.AccountTypeRegular, models.ProductTabungan, models.KYCTierBasic, now, now}},
+		}
+	}}
+	sql.Register("recording-reversal", d)
+	db, err := sql.Open("recording-reversal", "")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	repo := NewRepository(db, models.StatusPolicy{}, log)
+
+	// a reversal credits back a debit and debits back a credit; closed
+	// accounts refuse both, even when overdraft is allowed
+	for _, direction := range []string{models.DirectionDebit, models.DirectionCredit} {
+		d.statements = nil
+		original := &models.Transaction{ID: 7, AccountNumber: "1000000001", Direction: direction, Amount: 20000}
+		_, err := repo.PostReversal(context.Background(), original, models.NewReference(), "Koreksi", true)
+		if !errors.Is(err, ErrAccountClosed) {
+			t.Fatalf("reversal of a %s entry: got %v, want ErrAccountClosed", direction, err)
+		}
+		statements := strings.Join(d.statements, "\n")
+		if strings.Contains(statements, "INSERT INTO transactions") || !strings.HasSuffix(statements, "ROLLBACK") {
+			t.Fatalf("reversal of a %s entry ran\n%s\nwant no ledger entry and a rollback", direction, statements)
+		}
+	}
+}
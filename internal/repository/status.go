@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// GetAccountForUpdate loads an account and locks its row until the
+// surrounding transaction ends
+func (r *repository) GetAccountForUpdate(ctx context.Context, accountNumber string) (*models.Account, error) {
+	var account models.Account
+	query := `SELECT id, account_number, name, nik, phone_number, balance, held_amount, status, account_type, product, kyc_tier, created_at, updated_at
+			 FROM accounts WHERE account_number = $1 FOR UPDATE`
+
+	r.log.LogOperation(ctx, "GetAccountForUpdate", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	err := r.DB.QueryRowContext(ctx, query, accountNumber).Scan(
+		&account.ID,
+		&account.AccountNumber,
+		&account.Name,
+		&account.NIK,
+		&account.PhoneNumber,
+		&account.Balance,
+		&account.HeldAmount,
+		&account.Status,
+		&account.AccountType,
+		&account.Product,
+		&account.KYCTier,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetAccountForUpdate", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetAccountForUpdate", "success", map[string]interface{}{
+		"account_id": account.ID,
+	})
+	return &account, nil
+}
+
+// UpdateAccountStatus sets the status and stamps closed_at when the account
+// is closed, which the identity reuse policy counts from
+func (r *repository) UpdateAccountStatus(ctx context.Context, accountNumber, status string) error {
+	query := `UPDATE accounts SET status = $1, closed_at = $3 WHERE account_number = $2`
+
+	r.log.LogOperation(ctx, "UpdateAccountStatus", "start", map[string]interface{}{
+		"account_id": accountNumber,
+		"status":     status,
+	})
+
+	var closedAt sql.NullTime
+	if status == models.StatusClosed {
+		closedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, status, accountNumber, closedAt)
+	if err != nil {
+		r.log.LogOperation(ctx, "UpdateAccountStatus", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	rowAffected, _ := result.RowsAffected()
+	if rowAffected == 0 {
+		r.log.LogOperation(ctx, "UpdateAccountStatus", "error", map[string]interface{}{
+			"error": ErrAccountNotFound.Error(),
+		})
+		return ErrAccountNotFound
+	}
+
+	r.log.LogOperation(ctx, "UpdateAccountStatus", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return nil
+}
+
+func (r *repository) CreateStatusChange(ctx context.Context, change *models.AccountStatusChange) error {
+	query := `INSERT INTO account_status_history (account_number, from_status, to_status, reason, actor, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		err := tx.DB.QueryRowContext(ctx, query,
+			change.AccountNumber,
+			change.FromStatus,
+			change.ToStatus,
+			change.Reason,
+			change.Actor,
+			change.CreatedAt,
+		).Scan(&change.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.recordAudit(ctx, &models.AuditEvent{
+			Action:        models.AuditStatusChanged,
+			EntityType:    models.EntityAccount,
+			EntityID:      change.AccountNumber,
+			AccountNumber: change.AccountNumber,
+			Actor:         change.Actor,
+			Before:        auditState(map[string]string{"status": change.FromStatus}),
+			After:         auditState(map[string]string{"status": change.ToStatus}),
+			Reason:        change.Reason,
+			CreatedAt:     change.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.enqueueEvent(ctx, events.AccountStatusChanged, change.AccountNumber, events.AccountStatusChangedV1{
+			AccountNumber: change.AccountNumber,
+			FromStatus:    change.FromStatus,
+			ToStatus:      change.ToStatus,
+			Reason:        change.Reason,
+			ChangedAt:     change.CreatedAt,
+		}, change.CreatedAt)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateStatusChange", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateStatusChange", "success", map[string]interface{}{
+		"account_id":  change.AccountNumber,
+		"from_status": change.FromStatus,
+		"to_status":   change.ToStatus,
+		"actor":       change.Actor,
+	})
+	return nil
+}
@@ -0,0 +1,450 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/lib/pq"
+)
+
+// CreateTransaction inserts a ledger entry, links it into the account and
+// global hash chains, audits the balance change and queues its domain events.
+// The caller must already hold the account's row lock.
+func (r *repository) CreateTransaction(ctx context.Context, trx *models.Transaction) error {
+	query := `INSERT INTO transactions (reference, account_number, type, direction, amount, balance_after, description, reversal_of, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			 RETURNING id, amount::text, balance_after::text, ` + chainTimestamp
+
+	if trx.CreatedAt.IsZero() {
+		trx.CreatedAt = time.Now()
+	}
+
+	err := r.inTx(ctx, func(tx *repository) error {
+		entry := &models.LedgerEntry{
+			Reference:     trx.Reference,
+			AccountNumber: trx.AccountNumber,
+			Type:          trx.Type,
+			Direction:     trx.Direction,
+			Description:   trx.Description,
+		}
+		err := tx.DB.QueryRowContext(ctx, query,
+			trx.Reference,
+			trx.AccountNumber,
+			trx.Type,
+			trx.Direction,
+			trx.Amount,
+			trx.BalanceAfter,
+			trx.Description,
+			trx.ReversalOf,
+			trx.CreatedAt,
+		).Scan(&trx.ID, &entry.Amount, &entry.BalanceAfter, &entry.CreatedAt)
+		if err != nil {
+			return err
+		}
+		entry.TransactionID = trx.ID
+		if err := tx.chainEntry(ctx, entry); err != nil {
+			return err
+		}
+		if err := tx.recordAudit(ctx, balanceAudit(trx)); err != nil {
+			return err
+		}
+		if err := tx.enqueueLedgerEvents(ctx, trx); err != nil {
+			return err
+		}
+		tx.committed = append(tx.committed, *trx)
+		return nil
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateTransaction", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateTransaction", "success", map[string]interface{}{
+		"account_id":     trx.AccountNumber,
+		"transaction_id": trx.ID,
+	})
+	return nil
+}
+
+// Debit takes amount from an account and records the ledger entry. The
+// available balance and status checks are part of the UPDATE itself.
+func (r *repository) Debit(ctx context.Context, reference, accountNumber string, amount float64, trxType, description string) (*models.Transaction, error) {
+	query := `UPDATE accounts SET balance = balance - $1
+			 WHERE account_number = $2 AND balance - held_amount >= $1 AND status = ANY($3) RETURNING balance`
+
+	r.log.LogOperation(ctx, "Debit", "start", map[string]interface{}{
+		"account_id":       accountNumber,
+		"amount":           amount,
+		"transaction_type": trxType,
+	})
+
+	var trx *models.Transaction
+	err := r.inTx(ctx, func(tx *repository) error {
+		var balance float64
+		err := tx.DB.QueryRowContext(ctx, query, amount, accountNumber, pq.Array(r.statusPolicy.DebitStatuses())).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return tx.debitError(ctx, accountNumber)
+		}
+		if err != nil {
+			return err
+		}
+
+		trx = &models.Transaction{
+			Reference:     reference,
+			AccountNumber: accountNumber,
+			Type:          trxType,
+			Direction:     models.DirectionDebit,
+			Amount:        amount,
+			BalanceAfter:  balance,
+			Description:   description,
+		}
+		return tx.CreateTransaction(ctx, trx)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "Debit", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "Debit", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"reference":  reference,
+	})
+	return trx, nil
+}
+
+// Credit adds amount to an account and records the ledger entry
+func (r *repository) Credit(ctx context.Context, reference, accountNumber string, amount float64, trxType, description string) (*models.Transaction, error) {
+	query := `UPDATE accounts SET balance = balance + $1
+			 WHERE account_number = $2 AND status = ANY($3) RETURNING balance`
+
+	r.log.LogOperation(ctx, "Credit", "start", map[string]interface{}{
+		"account_id":       accountNumber,
+		"amount":           amount,
+		"transaction_type": trxType,
+	})
+
+	var trx *models.Transaction
+	err := r.inTx(ctx, func(tx *repository) error {
+		var balance float64
+		err := tx.DB.QueryRowContext(ctx, query, amount, accountNumber, pq.Array(r.statusPolicy.CreditStatuses())).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return tx.creditError(ctx, accountNumber)
+		}
+		if err != nil {
+			return err
+		}
+
+		trx = &models.Transaction{
+			Reference:     reference,
+			AccountNumber: accountNumber,
+			Type:          trxType,
+			Direction:     models.DirectionCredit,
+			Amount:        amount,
+			BalanceAfter:  balance,
+			Description:   description,
+		}
+		return tx.CreateTransaction(ctx, trx)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "Credit", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "Credit", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"reference":  reference,
+	})
+	return trx, nil
+}
+
+// PostSystemEntry posts a bank initiated entry such as interest, tax or a
+// fee. It applies whatever the account status is, but a debit can only take
+// the available balance, leaving the funds reserved by holds alone.
+func (r *repository) PostSystemEntry(ctx context.Context, reference, accountNumber, direction string, amount float64, trxType, description string) (*models.Transaction, error) {
+	debitQuery := `UPDATE accounts SET balance = balance - $1
+			 WHERE account_number = $2 AND balance - held_amount >= $1 RETURNING balance`
+	creditQuery := `UPDATE accounts SET balance = balance + $1
+			 WHERE account_number = $2 RETURNING balance`
+
+	r.log.LogOperation(ctx, "PostSystemEntry", "start", map[string]interface{}{
+		"account_id":       accountNumber,
+		"amount":           amount,
+		"transaction_type": trxType,
+	})
+
+	query := creditQuery
+	if direction == models.DirectionDebit {
+		query = debitQuery
+	}
+
+	var trx *models.Transaction
+	err := r.inTx(ctx, func(tx *repository) error {
+		var balance float64
+		err := tx.DB.QueryRowContext(ctx, query, amount, accountNumber).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := tx.GetAccountByNoRekening(ctx, accountNumber); errors.Is(err, sql.ErrNoRows) {
+				return ErrAccountNotFound
+			}
+			return ErrInsufficientBalance
+		}
+		if err != nil {
+			return err
+		}
+
+		trx = &models.Transaction{
+			Reference:     reference,
+			AccountNumber: accountNumber,
+			Type:          trxType,
+			Direction:     direction,
+			Amount:        amount,
+			BalanceAfter:  balance,
+			Description:   description,
+		}
+		return tx.CreateTransaction(ctx, trx)
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "PostSystemEntry", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "PostSystemEntry", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"reference":  reference,
+	})
+	return trx, nil
+}
+
+// Transfer moves amount between two accounts in one transaction and returns
+// the debit entry. Both rows are locked in account number order first so
+// opposing transfers cannot deadlock.
+func (r *repository) Transfer(ctx context.Context, reference, fromAccount, toAccount string, amount float64, description string) (*models.Transaction, error) {
+	lockQuery := `SELECT account_number FROM accounts WHERE account_number IN ($1, $2)
+			 ORDER BY account_number FOR UPDATE`
+
+	r.log.LogOperation(ctx, "Transfer", "start", map[string]interface{}{
+		"account_id": fromAccount,
+		"amount":     amount,
+	})
+
+	var debit *models.Transaction
+	err := r.inTx(ctx, func(tx *repository) error {
+		rows, err := tx.DB.QueryContext(ctx, lockQuery, fromAccount, toAccount)
+		if err != nil {
+			return err
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if locked != 2 {
+			return ErrAccountNotFound
+		}
+
+		debit, err = tx.Debit(ctx, reference, fromAccount, amount, models.TransactionTransferOut, description)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Credit(ctx, reference, toAccount, amount, models.TransactionTransferIn, description)
+		return err
+	})
+	if err != nil {
+		r.log.LogOperation(ctx, "Transfer", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "Transfer", "success", map[string]interface{}{
+		"account_id": fromAccount,
+		"reference":  reference,
+	})
+	return debit, nil
+}
+
+// GetTransactions lists ledger entries of an account in [from, to), newest first
+func (r *repository) GetTransactions(ctx context.Context, accountNumber string, from, to time.Time, limit, offset int) ([]models.Transaction, error) {
+	query := `SELECT id, reference, account_number, type, direction, amount, balance_after, description, created_at
+			 FROM transactions
+			 WHERE account_number = $1 AND created_at >= $2 AND created_at < $3
+			 ORDER BY created_at DESC, id DESC
+			 LIMIT $4 OFFSET $5`
+
+	r.log.LogOperation(ctx, "GetTransactions", "start", map[string]interface{}{
+		"type": "repository",
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, accountNumber, from, to, limit, offset)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetTransactions", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		var trx models.Transaction
+		if err := rows.Scan(
+			&trx.ID,
+			&trx.Reference,
+			&trx.AccountNumber,
+			&trx.Type,
+			&trx.Direction,
+			&trx.Amount,
+			&trx.BalanceAfter,
+			&trx.Description,
+			&trx.CreatedAt,
+		); err != nil {
+			r.log.LogOperation(ctx, "GetTransactions", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		transactions = append(transactions, trx)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetTransactions", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetTransactions", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"count":      len(transactions),
+	})
+	return transactions, nil
+}
+
+// GetTransactionsAfter returns up to limit ledger entries of an account with
+// an ID above afterID, oldest first, for clients resuming a balance stream
+func (r *repository) GetTransactionsAfter(ctx context.Context, accountNumber string, afterID int64, limit int) ([]models.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions
+			 WHERE account_number = $1 AND id > $2
+			 ORDER BY id LIMIT $3`
+
+	rows, err := r.DB.QueryContext(ctx, query, accountNumber, afterID, limit)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetTransactionsAfter", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		trx, err := scanTransaction(rows)
+		if err != nil {
+			r.log.LogOperation(ctx, "GetTransactionsAfter", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		transactions = append(transactions, *trx)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetTransactionsAfter", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// GetBalanceAt returns an account's ledger balance just before at, taken
+// from the last ledger entry before it
+func (r *repository) GetBalanceAt(ctx context.Context, accountNumber string, at time.Time) (float64, error) {
+	query := `SELECT COALESCE((SELECT balance_after FROM transactions
+			 WHERE account_number = $1 AND created_at < $2
+			 ORDER BY created_at DESC, id DESC LIMIT 1), 0)`
+
+	r.log.LogOperation(ctx, "GetBalanceAt", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	var balance float64
+	if err := r.DB.QueryRowContext(ctx, query, accountNumber, at).Scan(&balance); err != nil {
+		r.log.LogOperation(ctx, "GetBalanceAt", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return 0, err
+	}
+
+	r.log.LogOperation(ctx, "GetBalanceAt", "success", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return balance, nil
+}
+
+// GetLedger lists every ledger entry of an account in [from, to), oldest
+// first
+func (r *repository) GetLedger(ctx context.Context, accountNumber string, from, to time.Time) ([]models.Transaction, error) {
+	query := `SELECT id, reference, account_number, type, direction, amount, balance_after, description, created_at
+			 FROM transactions
+			 WHERE account_number = $1 AND created_at >= $2 AND created_at < $3
+			 ORDER BY created_at, id`
+
+	r.log.LogOperation(ctx, "GetLedger", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+
+	rows, err := r.DB.QueryContext(ctx, query, accountNumber, from, to)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetLedger", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		var trx models.Transaction
+		if err := rows.Scan(
+			&trx.ID,
+			&trx.Reference,
+			&trx.AccountNumber,
+			&trx.Type,
+			&trx.Direction,
+			&trx.Amount,
+			&trx.BalanceAfter,
+			&trx.Description,
+			&trx.CreatedAt,
+		); err != nil {
+			r.log.LogOperation(ctx, "GetLedger", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		transactions = append(transactions, trx)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, "GetLedger", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "GetLedger", "success", map[string]interface{}{
+		"account_id": accountNumber,
+		"count":      len(transactions),
+	})
+	return transactions, nil
+}
@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+var (
+	ErrWebhookSubscriptionNotFound = errcode.New("WEBHOOK_SUBSCRIPTION_NOT_FOUND", "webhook subscription not found")
+	ErrWebhookDeliveryNotFound     = errcode.New("WEBHOOK_DELIVERY_NOT_FOUND", "webhook delivery not found")
+)
+
+const webhookSubscriptionColumns = `id, client_id, url, event_types, account_numbers, signing_secret, active, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, last_error, last_status_code, next_attempt_at, delivered_at, created_at`
+
+func (r *repository) CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	query := `INSERT INTO webhook_subscriptions (id, client_id, url, event_types, account_numbers, signing_secret, active, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	r.log.LogOperation(ctx, "CreateWebhookSubscription", "start", map[string]interface{}{
+		"api_client_id": sub.ClientID,
+	})
+
+	_, err := r.DB.ExecContext(ctx, query,
+		sub.ID,
+		sub.ClientID,
+		sub.URL,
+		strings.Join(sub.EventTypes, ","),
+		strings.Join(sub.AccountNumbers, ","),
+		sub.SigningSecret,
+		sub.Active,
+		sub.CreatedAt,
+		sub.UpdatedAt,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateWebhookSubscription", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	r.log.LogOperation(ctx, "CreateWebhookSubscription", "success", map[string]interface{}{
+		"subscription_id": sub.ID,
+	})
+	return nil
+}
+
+func (r *repository) GetWebhookSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+
+	sub, err := scanWebhookSubscription(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrWebhookSubscriptionNotFound
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetWebhookSubscription", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetWebhookSubscriptions returns the active subscriptions of a partner
+func (r *repository) GetWebhookSubscriptions(ctx context.Context, clientID string) ([]models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions
+			 WHERE client_id = $1 AND active ORDER BY created_at`
+
+	rows, err := r.DB.QueryContext(ctx, query, clientID)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetWebhookSubscriptions", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			r.log.LogOperation(ctx, "GetWebhookSubscriptions", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// DeactivateWebhookSubscription stops a partner's subscription. Deliveries
+// already queued for it are not sent anymore.
+func (r *repository) DeactivateWebhookSubscription(ctx context.Context, id, clientID string) error {
+	query := `UPDATE webhook_subscriptions SET active = FALSE, updated_at = $3
+			 WHERE id = $1 AND client_id = $2 AND active`
+
+	result, err := r.DB.ExecContext(ctx, query, id, clientID, time.Now())
+	if err != nil {
+		r.log.LogOperation(ctx, "DeactivateWebhookSubscription", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	if rowAffected, _ := result.RowsAffected(); rowAffected == 0 {
+		return ErrWebhookSubscriptionNotFound
+	}
+
+	r.log.LogOperation(ctx, "DeactivateWebhookSubscription", "success", map[string]interface{}{
+		"subscription_id": id,
+	})
+	return nil
+}
+
+// enqueueWebhookDeliveries queues an event for every active subscription
+// whose event type filter matches it and that lists its account
+func (r *repository) enqueueWebhookDeliveries(ctx context.Context, event *events.Envelope) error {
+	query := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+			 SELECT id, $1, $2, $3, $4, $5, $5 FROM webhook_subscriptions
+			 WHERE active
+			   AND (event_types = '' OR $2 = ANY(string_to_array(event_types, ',')))
+			   AND $6 = ANY(string_to_array(account_numbers, ','))
+			 ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.ExecContext(ctx, query,
+		event.ID,
+		event.Type,
+		payload,
+		models.WebhookPending,
+		event.OccurredAt,
+		event.AggregateID,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "EnqueueWebhookDeliveries", "error", map[string]interface{}{
+			"error":      err.Error(),
+			"event_type": event.Type,
+		})
+		return err
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries leases up to limit pending deliveries that are due
+// until leaseUntil, oldest first, by moving their next attempt there. The
+// claim commits on its own, so no lock is held while the deliveries are sent;
+// another dispatcher only picks a delivery up again once its lease ran out.
+func (r *repository) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = $3
+			 WHERE id IN (SELECT id FROM webhook_deliveries
+			              WHERE status = $1 AND next_attempt_at <= $2
+			              ORDER BY id LIMIT $4 FOR UPDATE SKIP LOCKED)
+			 RETURNING ` + webhookDeliveryColumns
+
+	deliveries, err := r.queryWebhookDeliveries(ctx, "ClaimWebhookDeliveries", query, models.WebhookPending, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+// GetWebhookDeliveries returns a partner's deliveries, newest first, in any
+// status when status is empty
+func (r *repository) GetWebhookDeliveries(ctx context.Context, clientID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+			 WHERE subscription_id IN (SELECT id FROM webhook_subscriptions WHERE client_id = $1)
+			   AND ($2 = '' OR status = $2)
+			 ORDER BY id DESC LIMIT $3 OFFSET $4`
+
+	return r.queryWebhookDeliveries(ctx, "GetWebhookDeliveries", query, clientID, status, limit, offset)
+}
+
+// GetWebhookDelivery returns one of a partner's deliveries
+func (r *repository) GetWebhookDelivery(ctx context.Context, id int64, clientID string) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+			 WHERE id = $1 AND subscription_id IN (SELECT id FROM webhook_subscriptions WHERE client_id = $2)`
+
+	delivery, err := scanWebhookDelivery(r.DB.QueryRowContext(ctx, query, id, clientID))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "GetWebhookDelivery", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// UpdateWebhookDelivery stores the outcome of a delivery attempt
+func (r *repository) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries
+			 SET status = $1, attempts = $2, last_error = $3, last_status_code = $4, next_attempt_at = $5, delivered_at = $6
+			 WHERE id = $7`
+
+	_, err := r.DB.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastError,
+		delivery.LastStatusCode,
+		delivery.NextAttemptAt,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		r.log.LogOperation(ctx, "UpdateWebhookDelivery", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// RedeliverWebhook queues one of a partner's deliveries again with a fresh
+// attempt budget, whatever its status
+func (r *repository) RedeliverWebhook(ctx context.Context, id int64, clientID string, now time.Time) (*models.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = $2
+			 WHERE id = $3 AND subscription_id IN (SELECT id FROM webhook_subscriptions WHERE client_id = $4)
+			 RETURNING ` + webhookDeliveryColumns
+
+	delivery, err := scanWebhookDelivery(r.DB.QueryRowContext(ctx, query, models.WebhookPending, now, id, clientID))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		r.log.LogOperation(ctx, "RedeliverWebhook", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	r.log.LogOperation(ctx, "RedeliverWebhook", "success", map[string]interface{}{
+		"delivery_id": id,
+	})
+	return delivery, nil
+}
+
+// CreateWebhookAttempt appends an attempt to the delivery log
+func (r *repository) CreateWebhookAttempt(ctx context.Context, attempt *models.WebhookAttempt) error {
+	query := `INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	err := r.DB.QueryRowContext(ctx, query,
+		attempt.DeliveryID,
+		attempt.Attempt,
+		attempt.StatusCode,
+		attempt.Error,
+		attempt.DurationMs,
+		attempt.CreatedAt,
+	).Scan(&attempt.ID)
+	if err != nil {
+		r.log.LogOperation(ctx, "CreateWebhookAttempt", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// GetWebhookAttempts returns the delivery log of a delivery, oldest first
+func (r *repository) GetWebhookAttempts(ctx context.Context, deliveryID int64) ([]models.WebhookAttempt, error) {
+	query := `SELECT id, delivery_id, attempt, status_code, error, duration_ms, created_at
+			 FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY id`
+
+	rows, err := r.DB.QueryContext(ctx, query, deliveryID)
+	if err != nil {
+		r.log.LogOperation(ctx, "GetWebhookAttempts", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.WebhookAttempt{}
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		err := rows.Scan(
+			&attempt.ID,
+			&attempt.DeliveryID,
+			&attempt.Attempt,
+			&attempt.StatusCode,
+			&attempt.Error,
+			&attempt.DurationMs,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+func (r *repository) queryWebhookDeliveries(ctx context.Context, op, query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.LogOperation(ctx, op, "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var eventTypes, accountNumbers string
+	err := row.Scan(
+		&sub.ID,
+		&sub.ClientID,
+		&sub.URL,
+		&eventTypes,
+		&accountNumbers,
+		&sub.SigningSecret,
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	sub.EventTypes = splitList(eventTypes)
+	sub.AccountNumbers = splitList(accountNumbers)
+	return &sub, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload []byte
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.LastError,
+		&delivery.LastStatusCode,
+		&delivery.NextAttemptAt,
+		&delivery.DeliveredAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return &delivery, nil
+}
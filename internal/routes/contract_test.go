@@ -0,0 +1,460 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/handler"
+	"github.com/alfaa19/service-account-test/internal/i18n"
+	appmw "github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	testAccount   = "1234567890"
+	otherAccount  = "0987654321"
+	testPin       = "123456"
+	approvalLimit = 1000000
+)
+
+var testDeprecation = appmw.Deprecation{
+	Since:  time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+	Sunset: time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// accountService keeps accounts in memory. Methods the account routes do not
+// use fall through to the embedded nil interface and panic.
+type accountService struct {
+	service.Service
+	accounts map[string]*models.Account
+}
+
+func (s *accountService) GetAccountByNoRekening(_ context.Context, noRekening string) (*models.Account, error) {
+	account, ok := s.accounts[noRekening]
+	if !ok {
+		return nil, repository.ErrAccountNotFound
+	}
+	return account, nil
+}
+
+func (s *accountService) CreateAccount(_ context.Context, req *dto.AccountRegistration) (*models.Account, error) {
+	account := &models.Account{AccountNumber: "5550001111", Name: req.Nama, Status: models.StatusActive}
+	s.accounts[account.AccountNumber] = account
+	return account, nil
+}
+
+func (s *accountService) UpdateBalanceDeposit(ctx context.Context, noRekening string, amount float64) error {
+	account, err := s.GetAccountByNoRekening(ctx, noRekening)
+	if err != nil {
+		return err
+	}
+	account.Balance += amount
+	return nil
+}
+
+func (s *accountService) GetBalanceOnDate(ctx context.Context, noRekening, _ string) (float64, error) {
+	if _, err := s.GetAccountByNoRekening(ctx, noRekening); err != nil {
+		return 0, err
+	}
+	return 75000, nil
+}
+
+func (s *accountService) ChangePin(ctx context.Context, noRekening, oldPin, _ string) error {
+	if _, err := s.GetAccountByNoRekening(ctx, noRekening); err != nil {
+		return err
+	}
+	if oldPin != testPin {
+		return service.ErrInvalidPin
+	}
+	return nil
+}
+
+func (s *accountService) CloseAccount(ctx context.Context, noRekening, _, method, beneficiary, _ string) (*models.AccountClosure, error) {
+	account, err := s.GetAccountByNoRekening(ctx, noRekening)
+	if err != nil {
+		return nil, err
+	}
+	closure := &models.AccountClosure{
+		AccountNumber: noRekening,
+		PayoutMethod:  method,
+		PayoutAmount:  account.Balance,
+		Beneficiary:   beneficiary,
+		Reference:     "CLS-1",
+	}
+	account.Balance, account.Status = 0, models.StatusClosed
+	return closure, nil
+}
+
+func (s *accountService) GetStatement(ctx context.Context, noRekening, period string) (*models.Statement, error) {
+	account, err := s.GetAccountByNoRekening(ctx, noRekening)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Statement{
+		AccountNumber:  noRekening,
+		Name:           account.Name,
+		Period:         period,
+		OpeningBalance: account.Balance,
+		ClosingBalance: account.Balance,
+	}, nil
+}
+
+// approvalService withdraws directly, or puts amounts over approvalLimit up
+// for approval
+type approvalService struct {
+	service.ApprovalService
+	accounts *accountService
+}
+
+func (s *approvalService) Withdraw(ctx context.Context, noRekening string, amount float64, pin, maker string) (*models.PendingOperation, error) {
+	account, err := s.accounts.GetAccountByNoRekening(ctx, noRekening)
+	if err != nil {
+		return nil, err
+	}
+	if pin != testPin {
+		return nil, service.ErrInvalidPin
+	}
+	if amount > approvalLimit {
+		return &models.PendingOperation{
+			ID:            "op-1",
+			Operation:     models.OperationWithdrawal,
+			AccountNumber: noRekening,
+			Amount:        amount,
+			Status:        models.PendingStatusPending,
+			Maker:         maker,
+			ExpiresAt:     time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		}, nil
+	}
+	account.Balance -= amount
+	return nil, nil
+}
+
+type contractServer struct {
+	e     *echo.Echo
+	token string
+}
+
+func newContractServer(t *testing.T, tokens *auth.TokenManager) *contractServer {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+
+	accounts := &accountService{accounts: map[string]*models.Account{
+		testAccount:  {AccountNumber: testAccount, Name: "Sari", Balance: 100000, HeldAmount: 20000, Status: models.StatusActive},
+		otherAccount: {AccountNumber: otherAccount, Name: "Budi", Balance: 5000, Status: models.StatusActive},
+	}}
+	approvals := &approvalService{accounts: accounts}
+	docs, err := handler.NewDocsHandler(Spec())
+	if err != nil {
+		t.Fatalf("encode spec: %v", err)
+	}
+
+	e := echo.New()
+	NewRouter(Dependencies{
+		Account:  handler.NewAccountHandler(accounts, approvals, log),
+		V1:       handler.NewAccountV1Handler(accounts, approvals, log),
+		Auth:     handler.NewAuthHandler(nil, log),
+		Snap:     handler.NewSnapHandler(nil, nil, log),
+		Hold:     handler.NewHoldHandler(nil, log),
+		Approval: handler.NewApprovalHandler(nil, log),
+		Audit:    handler.NewAuditHandler(nil, log),
+		Webhook:  handler.NewWebhookHandler(nil, log),
+		Stream:   handler.NewStreamHandler(nil, time.Second, log),
+		Docs:     docs,
+		Tokens:   tokens,
+		Log:      log,
+		Legacy:   testDeprecation,
+	}, e)
+
+	token, _, err := tokens.IssueAccessToken(testAccount)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return &contractServer{e: e, token: token}
+}
+
+func (s *contractServer) do(tc contractCase) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+	if tc.body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if tc.authorized {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+s.token)
+	}
+	if tc.language != "" {
+		req.Header.Set(i18n.HeaderAcceptLanguage, tc.language)
+	}
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+type contractCase struct {
+	name   string
+	method string
+	path   string
+	body   string
+	// authorized sends a customer token for testAccount
+	authorized bool
+	// language is sent as Accept-Language
+	language string
+	status   int
+	// response is the expected JSON body, compared field by field
+	response string
+	// successor is the Link of a deprecated route, empty for /v1 routes
+	successor string
+	// headers are further response headers the route must set
+	headers map[string]string
+}
+
+// legacyCases pin the unversioned routes to the request and response bodies
+// their existing clients rely on
+var legacyCases = []contractCase{
+	{
+		name: "open account", method: http.MethodPost, path: "/daftar",
+		body:   `{"nama":"Dewi","nik":"3171","no_hp":"0812","password":"secret","pin":"123456"}`,
+		status: http.StatusCreated, response: `{"no_rekening":"5550001111"}`,
+		successor: "/v1/accounts",
+	},
+	{
+		name: "balance", method: http.MethodGet, path: "/saldo/" + testAccount, authorized: true,
+		status: http.StatusOK, response: `{"saldo":100000,"saldo_tersedia":80000}`,
+		successor: "/v1/accounts/" + testAccount + "/balance",
+	},
+	{
+		name: "historical balance", method: http.MethodGet, path: "/saldo/" + testAccount + "?tanggal=2026-10-15", authorized: true,
+		status: http.StatusOK, response: `{"tanggal":"2026-10-15","saldo":75000}`,
+		successor: "/v1/accounts/" + testAccount + "/balance",
+	},
+	{
+		name: "balance of another account", method: http.MethodGet, path: "/saldo/" + otherAccount, authorized: true,
+		status: http.StatusForbidden, response: `{"code":"ACCOUNT_NOT_OWNED","remark":"Account does not belong to token holder"}`,
+		successor: "/v1/accounts/" + otherAccount + "/balance",
+	},
+	{
+		name: "deposit", method: http.MethodPost, path: "/tabung",
+		body:   `{"no_rekening":"` + testAccount + `","saldo":50000}`,
+		status: http.StatusOK, response: `{"saldo":150000,"saldo_tersedia":130000}`,
+		successor: "/v1/accounts/" + testAccount + "/deposits",
+	},
+	{
+		name: "deposit to unknown account", method: http.MethodPost, path: "/tabung",
+		body:   `{"no_rekening":"1111111111","saldo":50000}`,
+		status: http.StatusNotFound, response: `{"code":"ACCOUNT_NOT_FOUND","remark":"Account not found"}`,
+		successor: "/v1/accounts/1111111111/deposits",
+	},
+	{
+		name: "withdraw", method: http.MethodPost, path: "/tarik", authorized: true,
+		body:   `{"no_rekening":"` + testAccount + `","saldo":30000,"pin":"123456"}`,
+		status: http.StatusOK, response: `{"saldo":70000,"saldo_tersedia":50000}`,
+		successor: "/v1/accounts/" + testAccount + "/withdrawals",
+	},
+	{
+		name: "withdraw for approval", method: http.MethodPost, path: "/tarik", authorized: true,
+		body:   `{"no_rekening":"` + testAccount + `","saldo":2000000,"pin":"123456","aktor":"teller.sari"}`,
+		status: http.StatusAccepted,
+		response: `{"id_persetujuan":"op-1","operasi":"WITHDRAWAL","no_rekening":"` + testAccount + `","jumlah":2000000,` +
+			`"status":"PENDING","pembuat":"customer:` + testAccount + `/teller.sari","berlaku_sampai":"2026-10-19T00:00:00Z"}`,
+		successor: "/v1/accounts/" + testAccount + "/withdrawals",
+	},
+	{
+		name: "withdraw with wrong PIN", method: http.MethodPost, path: "/tarik", authorized: true,
+		body:   `{"no_rekening":"` + testAccount + `","saldo":30000,"pin":"000000"}`,
+		status: http.StatusUnauthorized, response: `{"code":"INVALID_PIN","remark":"Invalid PIN"}`,
+		successor: "/v1/accounts/" + testAccount + "/withdrawals",
+	},
+	{
+		name: "change PIN", method: http.MethodPost, path: "/ubah-pin", authorized: true,
+		body:      `{"no_rekening":"` + testAccount + `","pin_lama":"123456","pin_baru":"654321"}`,
+		status:    http.StatusNoContent,
+		successor: "/v1/accounts/" + testAccount + "/pin",
+	},
+	{
+		name: "close account", method: http.MethodPost, path: "/tutup-rekening", authorized: true,
+		body:   `{"no_rekening":"` + testAccount + `","pin":"123456","metode_pencairan":"TUNAI","alasan":"pindah"}`,
+		status: http.StatusOK,
+		response: `{"no_rekening":"` + testAccount + `","status":"CLOSED","metode_pencairan":"TUNAI",` +
+			`"saldo_dicairkan":100000,"referensi":"CLS-1"}`,
+		successor: "/v1/accounts/" + testAccount + "/closure",
+	},
+	{
+		name: "statement", method: http.MethodGet, path: "/rekening-koran/" + testAccount + "?periode=2026-09&format=csv", authorized: true,
+		status:    http.StatusOK,
+		successor: "/v1/accounts/" + testAccount + "/statements/2026-09",
+		headers:   map[string]string{echo.HeaderContentType: "text/csv"},
+	},
+	{
+		name: "balance stream without token", method: http.MethodGet, path: "/saldo/" + testAccount + "/pantau",
+		status: http.StatusUnauthorized, response: `{"code":"MISSING_TOKEN","remark":"Missing bearer token"}`,
+		successor: "/v1/accounts/" + testAccount + "/balance/stream",
+	},
+}
+
+// v1Cases pin the /v1 routes
+var v1Cases = []contractCase{
+	{
+		name: "open account", method: http.MethodPost, path: "/v1/accounts",
+		body:   `{"nama":"Dewi","nik":"3171","no_hp":"0812","password":"secret","pin":"123456"}`,
+		status: http.StatusCreated, response: `{"no_rekening":"5550001111"}`,
+		headers: map[string]string{echo.HeaderLocation: "/v1/accounts/5550001111"},
+	},
+	{
+		name: "balance", method: http.MethodGet, path: "/v1/accounts/" + testAccount + "/balance", authorized: true,
+		status: http.StatusOK, response: `{"saldo":100000,"saldo_tersedia":80000}`,
+	},
+	{
+		name: "historical balance", method: http.MethodGet, path: "/v1/accounts/" + testAccount + "/balance?tanggal=2026-10-15", authorized: true,
+		status: http.StatusOK, response: `{"tanggal":"2026-10-15","saldo":75000}`,
+	},
+	{
+		name: "balance of another account", method: http.MethodGet, path: "/v1/accounts/" + otherAccount + "/balance", authorized: true,
+		status: http.StatusForbidden, response: `{"code":"ACCOUNT_NOT_OWNED","remark":"Account does not belong to token holder"}`,
+	},
+	{
+		name: "deposit", method: http.MethodPost, path: "/v1/accounts/" + testAccount + "/deposits",
+		body:   `{"jumlah":50000}`,
+		status: http.StatusOK, response: `{"no_rekening":"` + testAccount + `","jumlah":50000,"saldo":150000,"saldo_tersedia":130000}`,
+	},
+	{
+		name: "deposit to unknown account", method: http.MethodPost, path: "/v1/accounts/1111111111/deposits",
+		body:   `{"jumlah":50000}`,
+		status: http.StatusNotFound, response: `{"code":"ACCOUNT_NOT_FOUND","remark":"Account not found"}`,
+	},
+	{
+		name: "withdraw", method: http.MethodPost, path: "/v1/accounts/" + testAccount + "/withdrawals", authorized: true,
+		body:   `{"jumlah":30000,"pin":"123456"}`,
+		status: http.StatusOK, response: `{"no_rekening":"` + testAccount + `","jumlah":30000,"saldo":70000,"saldo_tersedia":50000}`,
+	},
+	{
+		name: "withdraw for approval", method: http.MethodPost, path: "/v1/accounts/" + testAccount + "/withdrawals", authorized: true,
+		body:   `{"jumlah":2000000,"pin":"123456","aktor":"teller.sari"}`,
+		status: http.StatusAccepted,
+		response: `{"id_persetujuan":"op-1","operasi":"WITHDRAWAL","no_rekening":"` + testAccount + `","jumlah":2000000,` +
+			`"status":"PENDING","pembuat":"customer:` + testAccount + `/teller.sari","berlaku_sampai":"2026-10-19T00:00:00Z"}`,
+	},
+	{
+		name: "withdraw with wrong PIN", method: http.MethodPost, path: "/v1/accounts/" + testAccount + "/withdrawals", authorized: true,
+		body:   `{"jumlah":30000,"pin":"000000"}`,
+		status: http.StatusUnauthorized, response: `{"code":"INVALID_PIN","remark":"Invalid PIN"}`,
+	},
+	{
+		name: "withdraw with wrong PIN in Indonesian", method: http.MethodPost, path: "/v1/accounts/" + testAccount + "/withdrawals", authorized: true,
+		body: `{"jumlah":30000,"pin":"000000"}`, language: "id-ID,id;q=0.9,en;q=0.8",
+		status: http.StatusUnauthorized, response: `{"code":"INVALID_PIN","remark":"PIN salah"}`,
+		headers: map[string]string{i18n.HeaderContentLanguage: i18n.Indonesian},
+	},
+	{
+		name: "withdraw from another account", method: http.MethodPost, path: "/v1/accounts/" + otherAccount + "/withdrawals", authorized: true,
+		body:   `{"jumlah":1000,"pin":"123456"}`,
+		status: http.StatusForbidden, response: `{"code":"ACCOUNT_NOT_OWNED","remark":"Account does not belong to token holder"}`,
+	},
+	{
+		name: "change PIN", method: http.MethodPut, path: "/v1/accounts/" + testAccount + "/pin", authorized: true,
+		body:   `{"pin_lama":"123456","pin_baru":"654321"}`,
+		status: http.StatusNoContent,
+	},
+	{
+		name: "close account", method: http.MethodPost, path: "/v1/accounts/" + testAccount + "/closure", authorized: true,
+		body:   `{"pin":"123456","metode_pencairan":"TUNAI","alasan":"pindah"}`,
+		status: http.StatusOK,
+		response: `{"no_rekening":"` + testAccount + `","status":"CLOSED","metode_pencairan":"TUNAI",` +
+			`"saldo_dicairkan":100000,"referensi":"CLS-1"}`,
+	},
+	{
+		name: "statement", method: http.MethodGet, path: "/v1/accounts/" + testAccount + "/statements/2026-09?format=csv", authorized: true,
+		status:  http.StatusOK,
+		headers: map[string]string{echo.HeaderContentType: "text/csv"},
+	},
+	{
+		name: "balance stream without token", method: http.MethodGet, path: "/v1/accounts/" + testAccount + "/balance/stream",
+		status: http.StatusUnauthorized, response: `{"code":"MISSING_TOKEN","remark":"Missing bearer token"}`,
+	},
+}
+
+func TestLegacyRoutesContract(t *testing.T) {
+	runContract(t, legacyCases, true)
+}
+
+func TestV1RoutesContract(t *testing.T) {
+	runContract(t, v1Cases, false)
+}
+
+func runContract(t *testing.T, cases []contractCase, deprecated bool) {
+	tokens, err := auth.NewTokenManager(auth.Config{Issuer: "test", AccessTokenTTL: time.Minute, AllowEphemeralKey: true})
+	if err != nil {
+		t.Fatalf("token manager: %v", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newContractServer(t, tokens)
+			rec := srv.do(tc)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.response != "" {
+				assertJSON(t, rec.Body.Bytes(), tc.response)
+			}
+			for name, want := range tc.headers {
+				if got := rec.Header().Get(name); !strings.HasPrefix(got, want) {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			assertDeprecation(t, rec.Header(), deprecated, tc.successor)
+		})
+	}
+}
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("decode response %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("decode expected response: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("response = %s, want %s", got, want)
+	}
+}
+
+func assertDeprecation(t *testing.T, header http.Header, deprecated bool, successor string) {
+	t.Helper()
+	if !deprecated {
+		for _, name := range []string{"Deprecation", "Sunset", "Link"} {
+			if got := header.Get(name); got != "" {
+				t.Errorf("%s = %q on a /v1 route", name, got)
+			}
+		}
+		return
+	}
+
+	if got, want := header.Get("Deprecation"), "@"+strconv.FormatInt(testDeprecation.Since.Unix(), 10); got != want {
+		t.Errorf("Deprecation = %q, want %q", got, want)
+	}
+	if got, want := header.Get("Sunset"), "Sat, 01 May 2027 00:00:00 GMT"; got != want {
+		t.Errorf("Sunset = %q, want %q", got, want)
+	}
+	if got, want := header.Get("Link"), "<"+successor+`>; rel="successor-version"`; got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+}
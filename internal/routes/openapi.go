@@ -0,0 +1,545 @@
+package routes
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/openapi"
+)
+
+// Security schemes of the routes
+const (
+	securityPartner  = "partnerSignature"
+	securityCustomer = "customerToken"
+	securitySnap     = "snapToken"
+	securitySnapKey  = "snapClientSignature"
+)
+
+// Tags grouping the routes in the docs
+const (
+	tagAuth     = "Authentication"
+	tagAccount  = "Accounts"
+	tagLegacy   = "Accounts (deprecated)"
+	tagBackOff  = "Back office"
+	tagHold     = "Holds"
+	tagApproval = "Approvals"
+	tagAudit    = "Audit"
+	tagWebhook  = "Webhooks"
+	tagSnap     = "SNAP"
+	tagDocs     = "Documentation"
+)
+
+// errorDescriptions explains what each error status of the REST API means
+var errorDescriptions = map[int]string{
+	http.StatusBadRequest:          "Malformed request, or rejected by a business rule",
+	http.StatusUnauthorized:        "Missing or invalid partner signature, access token, or a wrong PIN",
+	http.StatusForbidden:           "Not allowed: route, IP or role not granted, account not owned by the token holder, or account frozen, dormant or closed",
+	http.StatusNotFound:            "Account, transaction, hold, operation or webhook not found",
+	http.StatusConflict:            "Conflicts with the current state, e.g. a hold no longer active or an operation already decided",
+	http.StatusUnprocessableEntity: "Withdrawal limit exceeded, or the reversal would overdraw the account",
+	http.StatusLocked:              "PIN locked after repeated wrong attempts",
+	http.StatusTooManyRequests:     "Rate limit exceeded; retry after the Retry-After header",
+	http.StatusInternalServerError: "Internal error",
+}
+
+// errs lists the error responses of a route, all with an ErrorResponse body
+func errs(statuses ...int) []openapi.Response {
+	sort.Ints(statuses)
+	responses := make([]openapi.Response, 0, len(statuses))
+	for _, status := range statuses {
+		responses = append(responses, openapi.Response{
+			Status:      status,
+			Description: errorDescriptions[status],
+			Body:        dto.ErrorResponse{},
+		})
+	}
+	return responses
+}
+
+// snapErrs lists the error responses of a SNAP route, whose responseCode
+// combines the status, service code and case code
+func snapErrs(statuses ...int) []openapi.Response {
+	responses := make([]openapi.Response, 0, len(statuses))
+	for _, status := range statuses {
+		responses = append(responses, openapi.Response{
+			Status:      status,
+			Description: http.StatusText(status) + "; responseCode and responseMessage tell the SNAP case",
+			Body:        dto.SnapResponse{},
+		})
+	}
+	return responses
+}
+
+func ok(status int, body interface{}, description string) openapi.Response {
+	return openapi.Response{Status: status, Body: body, Description: description}
+}
+
+func with(responses ...[]openapi.Response) []openapi.Response {
+	var all []openapi.Response
+	for _, r := range responses {
+		all = append(all, r...)
+	}
+	return all
+}
+
+// legacy marks op as a deprecated unversioned route replaced by successor
+func legacy(op openapi.Operation, successor string) openapi.Operation {
+	op.Tag = tagLegacy
+	op.Deprecated = true
+	notice := "Deprecated, use " + successor + ". Responses carry the Deprecation and Sunset headers and a " +
+		"successor-version Link."
+	if op.Description != "" {
+		notice += "\n\n" + op.Description
+	}
+	op.Description = notice
+	return op
+}
+
+var (
+	public         = []string{securityPartner}
+	owner          = []string{securityPartner, securityCustomer}
+	backOfficeAuth = []string{securityPartner}
+	snapAuth       = []string{securitySnap}
+)
+
+var pageParams = []openapi.Param{
+	{Name: "limit", Type: "integer", Description: "Page size"},
+	{Name: "offset", Type: "integer", Description: "Entries to skip"},
+}
+
+var snapHeaders = []openapi.Param{
+	{Name: "X-TIMESTAMP", Required: true, Description: "Request time, ISO 8601"},
+	{Name: "X-SIGNATURE", Required: true, Description: "Symmetric SNAP signature of the request"},
+	{Name: "X-PARTNER-ID", Required: true, Description: "Partner ID, the client ID of the access token"},
+	{Name: "X-EXTERNAL-ID", Required: true, Description: "Unique request ID of the day"},
+	{Name: "CHANNEL-ID", Description: "Channel of the partner"},
+}
+
+// operations documents every route NewRouter registers. The drift test in
+// this package fails when the two disagree.
+var operations = []openapi.Operation{
+	{
+		Method: http.MethodPost, Path: "/login", Tag: tagAuth, Security: public,
+		Summary: "Sign a customer in with account number and password",
+		Request: dto.LoginRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.TokenResponse{}, "Access and refresh token")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/token/refresh", Tag: tagAuth, Security: public,
+		Summary: "Exchange a refresh token for a new token pair",
+		Request: dto.RefreshTokenRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.TokenResponse{}, "New access and refresh token")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/v1/accounts", Tag: tagAccount, Security: public,
+		Summary:     "Open an account",
+		Description: "Location points at the new account.",
+		Request:     dto.AccountRegistration{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusCreated, dto.AccountResponse{}, "Account opened")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/v1/accounts/:noRekening/balance", Tag: tagAccount, Security: owner,
+		Summary:     "Get the balance of an account",
+		Description: "With tanggal, the ledger balance at the end of that business date instead.",
+		Query:       []openapi.Param{{Name: "tanggal", Description: "Business date, YYYY-MM-DD"}},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, openapi.OneOf{dto.BalanceResponse{}, dto.HistoricalBalanceResponse{}},
+				"Current balance, or the balance at the end of tanggal")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/v1/accounts/:noRekening/balance/stream", Tag: tagAccount, Security: owner,
+		Summary:     "Follow balance changes as Server-Sent Events",
+		Description: "Each event is named saldo, carries the transaction ID as event ID and a BalanceChangeEvent as data. A comment line is sent as heartbeat.",
+		Headers:     []openapi.Param{{Name: "Last-Event-ID", Type: "integer", Description: "Resume after this transaction ID"}},
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusOK, Description: "Event stream", Body: dto.BalanceChangeEvent{},
+				ContentTypes: []string{"text/event-stream"}}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/v1/accounts/:noRekening/withdrawals", Tag: tagAccount, Security: owner,
+		Summary:     "Withdraw from an account",
+		Description: "Withdrawals above APPROVAL_THRESHOLD wait for a checker and answer 202.",
+		Request:     dto.WithdrawalRequest{},
+		Responses: with(
+			[]openapi.Response{
+				ok(http.StatusOK, dto.MutationResponse{}, "Withdrawn; the amount and the balance after it"),
+				ok(http.StatusAccepted, dto.PendingOperationResponse{}, "Waiting for a checker's approval"),
+			},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusUnprocessableEntity, http.StatusLocked, http.StatusTooManyRequests),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/v1/accounts/:noRekening/deposits", Tag: tagAccount, Security: public,
+		Summary: "Deposit to an account",
+		Request: dto.DepositRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.MutationResponse{}, "Deposited; the amount and the balance after it")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		),
+	},
+	{
+		Method: http.MethodPut, Path: "/v1/accounts/:noRekening/pin", Tag: tagAccount, Security: owner,
+		Summary: "Change the transaction PIN",
+		Request: dto.UpdatePinRequest{},
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusNoContent, Description: "PIN changed"}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusLocked),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/v1/accounts/:noRekening/closure", Tag: tagAccount, Security: owner,
+		Summary: "Close an account and pay out its balance",
+		Request: dto.AccountClosureRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.CloseAccountResponse{}, "Account closed")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusLocked),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/v1/accounts/:noRekening/statements/:periode", Tag: tagAccount, Security: owner,
+		Summary:     "Download the statement of a month",
+		Description: "periode is the month, YYYY-MM.",
+		Query:       []openapi.Param{{Name: "format", Description: "pdf (default) or csv"}},
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusOK, Description: "Statement file",
+				ContentTypes: []string{"application/pdf", "text/csv"}}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusInternalServerError),
+		),
+	},
+	legacy(openapi.Operation{
+		Method: http.MethodPost, Path: "/daftar", Tag: tagAccount, Security: public,
+		Summary: "Open an account",
+		Request: dto.AccountRegistration{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusCreated, dto.AccountResponse{}, "Account opened")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests),
+		),
+	}, "POST /v1/accounts"),
+	legacy(openapi.Operation{
+		Method: http.MethodGet, Path: "/saldo/:noRekening", Tag: tagAccount, Security: owner,
+		Summary:     "Get the balance of an account",
+		Description: "With tanggal, the ledger balance at the end of that business date instead.",
+		Query:       []openapi.Param{{Name: "tanggal", Description: "Business date, YYYY-MM-DD"}},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, openapi.OneOf{dto.BalanceResponse{}, dto.HistoricalBalanceResponse{}},
+				"Current balance, or the balance at the end of tanggal")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests),
+		),
+	}, "GET /v1/accounts/{noRekening}/balance"),
+	legacy(openapi.Operation{
+		Method: http.MethodGet, Path: "/saldo/:noRekening/pantau", Tag: tagAccount, Security: owner,
+		Summary:     "Follow balance changes as Server-Sent Events",
+		Description: "Each event is named saldo, carries the transaction ID as event ID and a BalanceChangeEvent as data. A comment line is sent as heartbeat.",
+		Headers:     []openapi.Param{{Name: "Last-Event-ID", Type: "integer", Description: "Resume after this transaction ID"}},
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusOK, Description: "Event stream", Body: dto.BalanceChangeEvent{},
+				ContentTypes: []string{"text/event-stream"}}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests),
+		),
+	}, "GET /v1/accounts/{noRekening}/balance/stream"),
+	legacy(openapi.Operation{
+		Method: http.MethodPost, Path: "/tarik", Tag: tagAccount, Security: owner,
+		Summary:     "Withdraw from an account",
+		Description: "Withdrawals above APPROVAL_THRESHOLD wait for a checker and answer 202.",
+		Request:     dto.WithdrawDepositRequest{},
+		Responses: with(
+			[]openapi.Response{
+				ok(http.StatusOK, dto.BalanceResponse{}, "Withdrawn; the balance after it"),
+				ok(http.StatusAccepted, dto.PendingOperationResponse{}, "Waiting for a checker's approval"),
+			},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusUnprocessableEntity, http.StatusLocked, http.StatusTooManyRequests),
+		),
+	}, "POST /v1/accounts/{noRekening}/withdrawals"),
+	legacy(openapi.Operation{
+		Method: http.MethodPost, Path: "/tabung", Tag: tagAccount, Security: public,
+		Summary: "Deposit to an account",
+		Request: dto.WithdrawDepositRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.BalanceResponse{}, "Deposited; the balance after it")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		),
+	}, "POST /v1/accounts/{noRekening}/deposits"),
+	legacy(openapi.Operation{
+		Method: http.MethodPost, Path: "/ubah-pin", Tag: tagAccount, Security: owner,
+		Summary: "Change the transaction PIN",
+		Request: dto.ChangePinRequest{},
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusNoContent, Description: "PIN changed"}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusLocked),
+		),
+	}, "PUT /v1/accounts/{noRekening}/pin"),
+	legacy(openapi.Operation{
+		Method: http.MethodPost, Path: "/tutup-rekening", Tag: tagAccount, Security: owner,
+		Summary: "Close an account and pay out its balance",
+		Request: dto.CloseAccountRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.CloseAccountResponse{}, "Account closed")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusLocked),
+		),
+	}, "POST /v1/accounts/{noRekening}/closure"),
+	legacy(openapi.Operation{
+		Method: http.MethodGet, Path: "/rekening-koran/:noRekening", Tag: tagAccount, Security: owner,
+		Summary: "Download the statement of a month",
+		Query: []openapi.Param{
+			{Name: "periode", Description: "Month, YYYY-MM; the previous month when empty"},
+			{Name: "format", Description: "pdf (default) or csv"},
+		},
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusOK, Description: "Statement file",
+				ContentTypes: []string{"application/pdf", "text/csv"}}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusInternalServerError),
+		),
+	}, "GET /v1/accounts/{noRekening}/statements/{periode}"),
+	{
+		Method: http.MethodPut, Path: "/rekening/:noRekening/status", Tag: tagBackOff, Security: backOfficeAuth,
+		Summary: "Change the status of an account",
+		Request: dto.ChangeStatusRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.AccountStatusResponse{}, "Status changed")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/transaksi/:idTransaksi/reversal", Tag: tagBackOff, Security: backOfficeAuth,
+		Summary:     "Reverse a transaction with compensating entries",
+		Description: "Needs the supervisor role. Reversals above APPROVAL_THRESHOLD wait for a checker and answer 202.",
+		Request:     dto.ReversalRequest{},
+		Responses: with(
+			[]openapi.Response{
+				ok(http.StatusCreated, dto.ReversalResponse{}, "Reversed"),
+				ok(http.StatusAccepted, dto.PendingOperationResponse{}, "Waiting for a checker's approval"),
+			},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusUnprocessableEntity),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/hold", Tag: tagHold, Security: backOfficeAuth,
+		Summary: "Reserve funds of an account",
+		Request: dto.CreateHoldRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusCreated, dto.HoldResponse{}, "Hold placed")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/hold/:idHold/capture", Tag: tagHold, Security: backOfficeAuth,
+		Summary: "Debit part or all of a hold",
+		Request: dto.CaptureHoldRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.HoldResponse{}, "Captured; referensi names the ledger entry")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/hold/:idHold/release", Tag: tagHold, Security: backOfficeAuth,
+		Summary: "Release the rest of a hold",
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.HoldResponse{}, "Released")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/persetujuan", Tag: tagApproval, Security: backOfficeAuth,
+		Summary: "List operations waiting for approval",
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, []dto.PendingOperationResponse{}, "Pending operations")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/persetujuan/:idPersetujuan/setujui", Tag: tagApproval, Security: backOfficeAuth,
+		Summary:     "Approve and execute a pending operation",
+		Description: "Needs the checker role; the checker must not be the maker.",
+		Request:     dto.DecisionRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.PendingOperationResponse{}, "Approved; hasil tells the outcome")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusUnprocessableEntity, http.StatusLocked),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/persetujuan/:idPersetujuan/tolak", Tag: tagApproval, Security: backOfficeAuth,
+		Summary:     "Reject a pending operation",
+		Description: "Needs the checker role.",
+		Request:     dto.DecisionRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.PendingOperationResponse{}, "Rejected")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/audit", Tag: tagAudit, Security: backOfficeAuth,
+		Summary:     "Search the audit log, newest first",
+		Description: "Needs the compliance role.",
+		Query: append([]openapi.Param{
+			{Name: "no_rekening", Description: "Account number"},
+			{Name: "aktor", Description: "Whole actor, partner or named operator"},
+			{Name: "dari", Description: "From, RFC 3339 time or YYYY-MM-DD"},
+			{Name: "sampai", Description: "Until, RFC 3339 time or YYYY-MM-DD including the whole day"},
+		}, pageParams...),
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, []dto.AuditEventResponse{}, "Audit events")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/webhook/langganan", Tag: tagWebhook, Security: backOfficeAuth,
+		Summary: "Subscribe to events",
+		Request: dto.WebhookSubscriptionRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusCreated, dto.WebhookSubscriptionResponse{}, "Subscribed")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/webhook/langganan", Tag: tagWebhook, Security: backOfficeAuth,
+		Summary: "List the partner's subscriptions",
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, []dto.WebhookSubscriptionResponse{}, "Active subscriptions")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodDelete, Path: "/webhook/langganan/:idLangganan", Tag: tagWebhook, Security: backOfficeAuth,
+		Summary: "End a subscription",
+		Responses: with(
+			[]openapi.Response{{Status: http.StatusNoContent, Description: "Unsubscribed"}},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/webhook/pengiriman", Tag: tagWebhook, Security: backOfficeAuth,
+		Summary: "List webhook deliveries, newest first",
+		Query: append([]openapi.Param{
+			{Name: "status", Description: "PENDING, DELIVERED or DEAD (the dead-letter list)"},
+		}, pageParams...),
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, []dto.WebhookDeliveryResponse{}, "Deliveries")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/webhook/pengiriman/:idPengiriman", Tag: tagWebhook, Security: backOfficeAuth,
+		Summary: "Get a delivery with its payload and attempt log",
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.WebhookDeliveryResponse{}, "Delivery")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/webhook/pengiriman/:idPengiriman/kirim-ulang", Tag: tagWebhook, Security: backOfficeAuth,
+		Summary: "Send a delivery again with a fresh attempt budget",
+		Responses: with(
+			[]openapi.Response{ok(http.StatusAccepted, dto.WebhookDeliveryResponse{}, "Queued for delivery")},
+			errs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/snap/v1.0/access-token/b2b", Tag: tagSnap, Security: []string{securitySnapKey},
+		Summary: "Issue a SNAP B2B access token",
+		Headers: []openapi.Param{
+			{Name: "X-CLIENT-KEY", Required: true, Description: "Client ID"},
+			{Name: "X-TIMESTAMP", Required: true, Description: "Request time, ISO 8601"},
+		},
+		Request: dto.SnapAccessTokenRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.SnapAccessTokenResponse{}, "Access token")},
+			snapErrs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/snap/v1.0/balance-inquiry", Tag: tagSnap, Security: snapAuth,
+		Summary: "SNAP balance inquiry",
+		Headers: snapHeaders,
+		Request: dto.SnapBalanceInquiryRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.SnapBalanceInquiryResponse{}, "Balance")},
+			snapErrs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusInternalServerError),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/snap/v1.0/transfer-intrabank", Tag: tagSnap, Security: snapAuth,
+		Summary: "SNAP intrabank transfer",
+		Headers: snapHeaders,
+		Request: dto.SnapTransferIntrabankRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.SnapTransferIntrabankResponse{}, "Transferred")},
+			snapErrs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusInternalServerError),
+		),
+	},
+	{
+		Method: http.MethodPost, Path: "/snap/v1.0/transaction-history-list", Tag: tagSnap, Security: snapAuth,
+		Summary: "SNAP transaction history",
+		Headers: snapHeaders,
+		Request: dto.SnapTransactionHistoryRequest{},
+		Responses: with(
+			[]openapi.Response{ok(http.StatusOK, dto.SnapTransactionHistoryResponse{}, "Transactions")},
+			snapErrs(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+				http.StatusConflict, http.StatusInternalServerError),
+		),
+	},
+	{
+		Method: http.MethodGet, Path: "/openapi.json", Tag: tagDocs,
+		Summary:   "This OpenAPI document",
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "OpenAPI 3 document", ContentTypes: []string{"application/json"}}},
+	},
+	{
+		Method: http.MethodGet, Path: "/docs", Tag: tagDocs,
+		Summary:   "Interactive API documentation",
+		Responses: []openapi.Response{{Status: http.StatusOK, Description: "HTML page", ContentTypes: []string{"text/html"}}},
+	},
+}
+
+var securitySchemes = map[string]openapi.SecurityScheme{
+	securityPartner: {
+		Type: "apiKey", In: "header", Name: "X-Signature",
+		Description: "Partner HMAC-SHA256 signature, sent with X-Client-ID, X-Timestamp and X-Nonce; see Partner " +
+			"Authentication in the README. Required on every route with PARTNER_AUTH_ENABLED, and always on the " +
+			"back-office routes.",
+	},
+	securityCustomer: {
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+		Description: "Customer access token from /login, for the customer's own account",
+	},
+	securitySnap: {
+		Type: "http", Scheme: "bearer",
+		Description: "SNAP B2B access token, with the symmetric X-SIGNATURE of the request",
+	},
+	securitySnapKey: {
+		Type: "apiKey", In: "header", Name: "X-SIGNATURE",
+		Description: "Asymmetric SNAP signature of X-CLIENT-KEY and X-TIMESTAMP with the partner's private key",
+	},
+}
+
+// Spec returns the OpenAPI document of the routes NewRouter registers
+func Spec() *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:   "Banking Service API",
+		Version: "1.0.0",
+		Description: "Accounts, balances, deposits and withdrawals, with back-office and SNAP BI routes. " +
+			"Errors carry a stable code and a remark in the language asked for with Accept-Language (id-ID or en-US).",
+	}, securitySchemes, operations)
+}
@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alfaa19/service-account-test/internal/handler"
+	"github.com/alfaa19/service-account-test/internal/openapi"
+	"github.com/labstack/echo/v4"
+)
+
+// newTestRouter mounts the routes with handlers that have no collaborators;
+// the tests only look at what is registered, not at what it does
+func newTestRouter(t *testing.T) *echo.Echo {
+	t.Helper()
+	docs, err := handler.NewDocsHandler(Spec())
+	if err != nil {
+		t.Fatalf("encode spec: %v", err)
+	}
+	e := echo.New()
+	NewRouter(Dependencies{
+		Account:  handler.NewAccountHandler(nil, nil, nil),
+		V1:       handler.NewAccountV1Handler(nil, nil, nil),
+		Auth:     handler.NewAuthHandler(nil, nil),
+		Snap:     handler.NewSnapHandler(nil, nil, nil),
+		Hold:     handler.NewHoldHandler(nil, nil),
+		Approval: handler.NewApprovalHandler(nil, nil),
+		Audit:    handler.NewAuditHandler(nil, nil),
+		Webhook:  handler.NewWebhookHandler(nil, nil),
+		Stream:   handler.NewStreamHandler(nil, 0, nil),
+		Docs:     docs,
+	}, e)
+	return e
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	e := newTestRouter(t)
+
+	registered := map[string]bool{}
+	for _, r := range e.Routes() {
+		// echo registers catch-all routes of its own for unmatched paths
+		if r.Method == echo.RouteNotFound || strings.HasSuffix(r.Path, "*") {
+			continue
+		}
+		registered[r.Method+" "+openapi.Path(r.Path)] = true
+	}
+
+	documented := map[string]bool{}
+	for path, item := range Spec().Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, route := range sortedKeys(registered) {
+		if !documented[route] {
+			t.Errorf("route %s is not in the OpenAPI document", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !registered[route] {
+			t.Errorf("OpenAPI document has %s, which the router does not register", route)
+		}
+	}
+}
+
+func TestSpecReferencesResolve(t *testing.T) {
+	doc := Spec()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("encode spec: %v", err)
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+
+	walk(tree, func(ref string) {
+		name, found := strings.CutPrefix(ref, "#/components/schemas/")
+		if !found {
+			t.Errorf("unexpected $ref %q", ref)
+			return
+		}
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("$ref %q has no schema", ref)
+		}
+	})
+}
+
+func TestSpecServed(t *testing.T) {
+	e := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode /openapi.json: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || len(doc.Paths) != len(Spec().Paths) {
+		t.Errorf("served document: openapi %q with %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/openapi.json") {
+		t.Errorf("GET /docs = %d, does not load /openapi.json", rec.Code)
+	}
+}
+
+// walk calls fn with every $ref in the decoded JSON document v
+func walk(v interface{}, fn func(ref string)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				fn(ref)
+				continue
+			}
+			walk(child, fn)
+		}
+	case []interface{}:
+		for _, child := range v {
+			walk(child, fn)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
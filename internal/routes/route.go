@@ -1,21 +1,163 @@
 package routes
 
 import (
+	"github.com/alfaa19/service-account-test/internal/auth"
 	"github.com/alfaa19/service-account-test/internal/handler"
+	appmw "github.com/alfaa19/service-account-test/internal/middleware"
+	"github.com/alfaa19/service-account-test/internal/ratelimit"
+	"github.com/alfaa19/service-account-test/internal/service"
+	"github.com/alfaa19/service-account-test/internal/snap"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-func NewRouter(h handler.AccountHandler, e *echo.Echo) {
+// Dependencies groups the handlers and collaborators mounted by NewRouter
+type Dependencies struct {
+	Account  handler.AccountHandler
+	V1       handler.AccountV1Handler
+	Auth     handler.AuthHandler
+	Snap     handler.SnapHandler
+	Hold     handler.HoldHandler
+	Approval handler.ApprovalHandler
+	Audit    handler.AuditHandler
+	Webhook  handler.WebhookHandler
+	Stream   handler.StreamHandler
+	Docs     handler.DocsHandler
+	Tokens   *auth.TokenManager
+	Log      *logger.CustomLogger
+
+	// Partners verifies HMAC-signed partner requests and SNAP signatures
+	Partners           service.PartnerService
+	PartnerAuthEnabled bool
+
+	RateLimits RateLimits
+
+	// Legacy is the deprecation announced on the unversioned account routes
+	Legacy appmw.Deprecation
+}
+
+// RateLimits holds the per-route rate limit policies; a nil Store disables
+// rate limiting
+type RateLimits struct {
+	Store        ratelimit.Store
+	Register     ratelimit.Policy
+	Login        ratelimit.Policy
+	LoginAccount ratelimit.Policy
+	Balance      ratelimit.Policy
+	Withdraw     ratelimit.Policy
+}
+
+// limit returns the rate limit middleware for a policy, or none when disabled
+func (r RateLimits) limit(policy ratelimit.Policy, key appmw.KeyFunc, log *logger.CustomLogger) []echo.MiddlewareFunc {
+	if r.Store == nil {
+		return nil
+	}
+	return []echo.MiddlewareFunc{appmw.RateLimit(r.Store, policy, key, log)}
+}
+
+func NewRouter(deps Dependencies, e *echo.Echo) {
 	// Middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
+	e.Use(middleware.RequestID())
+	e.Use(appmw.RequestContext())
+
+	api := e.Group("")
+	if deps.PartnerAuthEnabled {
+		api.Use(appmw.PartnerSignature(deps.Partners))
+	}
+
+	// requireOwner authenticates the account owner before running m, so the
+	// per-account limits only count requests of the account's owner
+	requireOwner := func(m ...echo.MiddlewareFunc) []echo.MiddlewareFunc {
+		return append([]echo.MiddlewareFunc{
+			appmw.JWTAuth(deps.Tokens, deps.Log),
+			appmw.AccountOwner(deps.Log),
+		}, m...)
+	}
+	registerLimit := deps.RateLimits.limit(deps.RateLimits.Register, appmw.KeyByIP, deps.Log)
+	balanceLimit := deps.RateLimits.limit(deps.RateLimits.Balance, appmw.KeyByAccount, deps.Log)
+	withdrawLimit := deps.RateLimits.limit(deps.RateLimits.Withdraw, appmw.KeyByAccount, deps.Log)
+	loginLimit := append(
+		deps.RateLimits.limit(deps.RateLimits.Login, appmw.KeyByIP, deps.Log),
+		deps.RateLimits.limit(deps.RateLimits.LoginAccount, appmw.KeyByAccount, deps.Log)...)
+
+	// Auth routes
+	api.POST("/login", deps.Auth.Login, loginLimit...)
+	api.POST("/token/refresh", deps.Auth.Refresh)
 
 	// Account routes
-	e.POST("/daftar", h.CreateAccount)
-	e.GET("/saldo/:noRekening", h.GetSaldo)
-	e.POST("/tarik", h.Withdraw)
-	e.POST("/tabung", h.Deposit)
+	accounts := api.Group("/v1/accounts")
+	accounts.POST("", deps.V1.CreateAccount, registerLimit...)
+	accounts.GET("/:noRekening/balance", deps.V1.GetBalance, requireOwner(balanceLimit...)...)
+	accounts.GET("/:noRekening/balance/stream", deps.Stream.StreamBalance, requireOwner(balanceLimit...)...)
+	accounts.POST("/:noRekening/withdrawals", deps.V1.Withdraw, requireOwner(withdrawLimit...)...)
+	accounts.POST("/:noRekening/deposits", deps.V1.Deposit)
+	accounts.PUT("/:noRekening/pin", deps.V1.UpdatePin, requireOwner()...)
+	accounts.POST("/:noRekening/closure", deps.V1.CloseAccount, requireOwner()...)
+	accounts.GET("/:noRekening/statements/:periode", deps.V1.GetStatement, requireOwner()...)
+
+	// Unversioned account routes, deprecated in favour of the /v1 routes above
+	legacy := func(successor string, m ...echo.MiddlewareFunc) []echo.MiddlewareFunc {
+		return append([]echo.MiddlewareFunc{appmw.Deprecated(deps.Legacy, successor)}, m...)
+	}
+	api.POST("/daftar", deps.Account.CreateAccount, legacy("/v1/accounts", registerLimit...)...)
+	api.GET("/saldo/:noRekening", deps.Account.GetSaldo,
+		legacy("/v1/accounts/:noRekening/balance", requireOwner(balanceLimit...)...)...)
+	api.GET("/saldo/:noRekening/pantau", deps.Stream.StreamBalance,
+		legacy("/v1/accounts/:noRekening/balance/stream", requireOwner(balanceLimit...)...)...)
+	api.POST("/tarik", deps.Account.Withdraw,
+		legacy("/v1/accounts/:noRekening/withdrawals", requireOwner(withdrawLimit...)...)...)
+	api.POST("/tabung", deps.Account.Deposit, legacy("/v1/accounts/:noRekening/deposits")...)
+	api.POST("/ubah-pin", deps.Account.ChangePin, legacy("/v1/accounts/:noRekening/pin", requireOwner()...)...)
+	api.POST("/tutup-rekening", deps.Account.CloseAccount, legacy("/v1/accounts/:noRekening/closure", requireOwner()...)...)
+	api.GET("/rekening-koran/:noRekening", deps.Account.GetStatement,
+		legacy("/v1/accounts/:noRekening/statements/:periode", requireOwner()...)...)
+
+	// Back-office routes, only reachable by partners granted them. They keep
+	// requiring a partner signature even when it is off for the public routes.
+	var backOffice []echo.MiddlewareFunc
+	if !deps.PartnerAuthEnabled {
+		backOffice = append(backOffice, appmw.PartnerSignature(deps.Partners))
+	}
+	api.PUT("/rekening/:noRekening/status", deps.Account.ChangeStatus, backOffice...)
+	api.POST("/transaksi/:idTransaksi/reversal", deps.Account.ReverseTransaction, backOffice...)
+
+	// Fund holds for card and merchant flows, also partner only
+	api.POST("/hold", deps.Hold.CreateHold, backOffice...)
+	api.POST("/hold/:idHold/capture", deps.Hold.CaptureHold, backOffice...)
+	api.POST("/hold/:idHold/release", deps.Hold.ReleaseHold, backOffice...)
+
+	// Maker-checker approvals; approving and rejecting need the checker role
+	api.GET("/persetujuan", deps.Approval.ListPending, backOffice...)
+	api.POST("/persetujuan/:idPersetujuan/setujui", deps.Approval.Approve, backOffice...)
+	api.POST("/persetujuan/:idPersetujuan/tolak", deps.Approval.Reject, backOffice...)
+
+	// Audit log for compliance, needs the compliance role
+	api.GET("/audit", deps.Audit.SearchEvents, backOffice...)
+
+	// Webhook subscriptions and the delivery log of the calling partner
+	api.POST("/webhook/langganan", deps.Webhook.Subscribe, backOffice...)
+	api.GET("/webhook/langganan", deps.Webhook.ListSubscriptions, backOffice...)
+	api.DELETE("/webhook/langganan/:idLangganan", deps.Webhook.Unsubscribe, backOffice...)
+	api.GET("/webhook/pengiriman", deps.Webhook.ListDeliveries, backOffice...)
+	api.GET("/webhook/pengiriman/:idPengiriman", deps.Webhook.GetDelivery, backOffice...)
+	api.POST("/webhook/pengiriman/:idPengiriman/kirim-ulang", deps.Webhook.Redeliver, backOffice...)
+
+	// SNAP BI routes, authenticated with SNAP signatures instead of the
+	// partner HMAC headers
+	snapAPI := e.Group("/snap/v1.0")
+	snapAPI.POST("/access-token/b2b", deps.Snap.AccessToken)
+	snapAPI.POST("/balance-inquiry", deps.Snap.BalanceInquiry,
+		appmw.SnapAuth(deps.Partners, snap.ServiceBalanceInquiry))
+	snapAPI.POST("/transfer-intrabank", deps.Snap.TransferIntrabank,
+		appmw.SnapAuth(deps.Partners, snap.ServiceTransferIntrabank))
+	snapAPI.POST("/transaction-history-list", deps.Snap.TransactionHistory,
+		appmw.SnapAuth(deps.Partners, snap.ServiceTransactionHistory))
 
+	// API documentation, open to everyone
+	e.GET("/openapi.json", deps.Docs.Spec)
+	e.GET("/docs", deps.Docs.UI)
 }
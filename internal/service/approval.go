@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+var (
+	ErrOperationNotPending = errcode.New("OPERATION_NOT_PENDING", "operation is no longer pending")
+	ErrOperationExpired    = errcode.New("OPERATION_EXPIRED", "operation has expired")
+	ErrSameMakerChecker    = errcode.New("SAME_MAKER_CHECKER", "an operation must be approved by someone other than its maker")
+	ErrCheckerRequired     = errcode.New("CHECKER_REQUIRED", "approving operations requires the checker role")
+	ErrMakerRequired       = errcode.New("MAKER_REQUIRED", "maker is required")
+)
+
+// systemActor records steps taken by the service itself in audit trails
+const systemActor = "system"
+
+// pendingListLimit caps how many pending operations are listed at once
+const pendingListLimit = 100
+
+// ApprovalPolicy sends withdrawals and reversals above Threshold to a checker.
+// A zero Threshold turns maker-checker off. Pending operations expire after
+// TTL.
+type ApprovalPolicy struct {
+	Threshold float64
+	TTL       time.Duration
+}
+
+func (p ApprovalPolicy) requiresApproval(amount float64) bool {
+	return p.Threshold > 0 && amount > p.Threshold
+}
+
+type approvalService struct {
+	repo    repository.Repository
+	service Service
+	policy  ApprovalPolicy
+	log     *logger.CustomLogger
+}
+
+type ApprovalService interface {
+	Withdraw(ctx context.Context, accountNumber string, amount float64, pin, maker string) (*models.PendingOperation, error)
+	ReverseTransaction(ctx context.Context, transactionID int64, reason, actor string, force bool, maker string) (*models.Reversal, *models.PendingOperation, error)
+	Approve(ctx context.Context, id, checker string) (*models.PendingOperation, error)
+	Reject(ctx context.Context, id, checker, note string) (*models.PendingOperation, error)
+	ListPending(ctx context.Context) ([]models.PendingOperation, error)
+	ExpirePending(ctx context.Context, batchSize int) (int, error)
+}
+
+// NewApprovalService puts maker-checker in front of svc. Approved operations
+// are executed through svc, exactly as they would have been without approval.
+func NewApprovalService(repo repository.Repository, svc Service, policy ApprovalPolicy, log *logger.CustomLogger) ApprovalService {
+	return &approvalService{
+		repo:    repo,
+		service: svc,
+		policy:  policy,
+		log:     log,
+	}
+}
+
+// Withdraw withdraws right away up to the threshold. Above it the PIN is
+// checked now and the withdrawal is stored as pending; the returned
+// operation is nil when the withdrawal was posted.
+func (s *approvalService) Withdraw(ctx context.Context, accountNumber string, amount float64, pin, maker string) (*models.PendingOperation, error) {
+	if !s.policy.requiresApproval(amount) {
+		return nil, s.service.UpdateBalanceWithdraw(ctx, accountNumber, amount, pin)
+	}
+	if err := s.service.VerifyPin(ctx, accountNumber, pin, PinOperationWithdraw); err != nil {
+		return nil, err
+	}
+
+	payload := models.WithdrawalPayload{AccountNumber: accountNumber, Amount: amount}
+	return s.submit(ctx, models.OperationWithdrawal, accountNumber, amount, payload, maker)
+}
+
+// ReverseTransaction reverses right away when the reversed amount is up to
+// the threshold and stores the reversal as pending above it. Exactly one of
+// the returned reversal and operation is set.
+func (s *approvalService) ReverseTransaction(ctx context.Context, transactionID int64, reason, actor string, force bool, maker string) (*models.Reversal, *models.PendingOperation, error) {
+	original, err := s.repo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		return nil, nil, s.approvalError(ctx, "ReverseTransaction", err)
+	}
+	if !s.policy.requiresApproval(original.Amount) {
+		reversal, err := s.service.ReverseTransaction(ctx, transactionID, reason, actor, force)
+		return reversal, nil, err
+	}
+	switch {
+	case strings.TrimSpace(reason) == "":
+		return nil, nil, s.approvalError(ctx, "ReverseTransaction", ErrReasonRequired)
+	case strings.TrimSpace(actor) == "":
+		return nil, nil, s.approvalError(ctx, "ReverseTransaction", ErrActorRequired)
+	}
+
+	payload := models.ReversalPayload{TransactionID: transactionID, Reason: reason, Actor: actor, Force: force}
+	op, err := s.submit(ctx, models.OperationReversal, original.AccountNumber, original.Amount, payload, maker)
+	return nil, op, err
+}
+
+func (s *approvalService) submit(ctx context.Context, operation, accountNumber string, amount float64, payload interface{}, maker string) (*models.PendingOperation, error) {
+	if strings.TrimSpace(maker) == "" {
+		return nil, s.approvalError(ctx, "SubmitOperation", ErrMakerRequired)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, s.approvalError(ctx, "SubmitOperation", err)
+	}
+
+	now := time.Now()
+	op := &models.PendingOperation{
+		ID:            models.NewReference(),
+		Operation:     operation,
+		AccountNumber: accountNumber,
+		Amount:        amount,
+		Payload:       data,
+		Status:        models.PendingStatusPending,
+		Maker:         maker,
+		ExpiresAt:     now.Add(s.policy.TTL),
+		CreatedAt:     now,
+	}
+	err = s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		if err := repo.CreatePendingOperation(ctx, op); err != nil {
+			return err
+		}
+		return repo.CreatePendingOperationEvent(ctx, &models.PendingOperationEvent{
+			OperationID: op.ID,
+			Status:      op.Status,
+			Actor:       maker,
+			CreatedAt:   now,
+		})
+	})
+	if err != nil {
+		return nil, s.approvalError(ctx, "SubmitOperation", err)
+	}
+
+	s.log.LogOperation(ctx, "SubmitOperation", "success", map[string]interface{}{
+		"type":       "service",
+		"pending_id": op.ID,
+		"operation":  operation,
+		"account_id": accountNumber,
+		"maker":      maker,
+	})
+	return op, nil
+}
+
+// Approve decides for the checker and executes a pending operation through
+// the account service in one database transaction, so an operation is never
+// left approved but unexecuted. The operation row stays locked meanwhile, so
+// two checkers can never execute it twice. A failed execution is rolled back
+// and recorded on the operation rather than returned as an error.
+func (s *approvalService) Approve(ctx context.Context, id, checker string) (*models.PendingOperation, error) {
+	var op *models.PendingOperation
+	var expired bool
+	var execErr error
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		var err error
+		op, expired, err = decideIn(ctx, repo, id, checker, models.PendingStatusApproved, "")
+		if err != nil || expired {
+			return err
+		}
+
+		result, err := s.execute(ctx, s.service.WithRepository(repo), op)
+		if err != nil {
+			execErr = err
+			return err
+		}
+		op.Status, op.Result = models.PendingStatusExecuted, result
+		return finish(ctx, repo, op)
+	})
+	if execErr != nil {
+		op, err = s.recordFailure(ctx, id, checker, execErr)
+	}
+	if err == nil && expired {
+		err = ErrOperationExpired
+	}
+	if err != nil {
+		return nil, s.approvalError(ctx, "ApproveOperation", err)
+	}
+
+	s.log.LogOperation(ctx, "ApproveOperation", "success", map[string]interface{}{
+		"type":       "service",
+		"pending_id": op.ID,
+		"checker":    checker,
+		"status":     op.Status,
+	})
+	return op, nil
+}
+
+// recordFailure records the approval of an operation whose execution failed
+// and was rolled back. The operation is decided again, as another checker may
+// have decided on it in the meantime.
+func (s *approvalService) recordFailure(ctx context.Context, id, checker string, execErr error) (*models.PendingOperation, error) {
+	var op *models.PendingOperation
+	var expired bool
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		var err error
+		op, expired, err = decideIn(ctx, repo, id, checker, models.PendingStatusApproved, "")
+		if err != nil || expired {
+			return err
+		}
+		op.Status, op.Result = models.PendingStatusFailed, execErr.Error()
+		return finish(ctx, repo, op)
+	})
+	if err == nil && expired {
+		err = ErrOperationExpired
+	}
+	return op, err
+}
+
+// finish stores the outcome of an approved operation
+func finish(ctx context.Context, repo repository.Repository, op *models.PendingOperation) error {
+	if err := repo.UpdatePendingOperation(ctx, op); err != nil {
+		return err
+	}
+	return repo.CreatePendingOperationEvent(ctx, &models.PendingOperationEvent{
+		OperationID: op.ID,
+		Status:      op.Status,
+		Actor:       systemActor,
+		Note:        op.Result,
+	})
+}
+
+// Reject closes a pending operation without executing it
+func (s *approvalService) Reject(ctx context.Context, id, checker, note string) (*models.PendingOperation, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, s.approvalError(ctx, "RejectOperation", ErrReasonRequired)
+	}
+	op, err := s.decide(ctx, id, checker, models.PendingStatusRejected, note)
+	if err != nil {
+		return nil, s.approvalError(ctx, "RejectOperation", err)
+	}
+
+	s.log.LogOperation(ctx, "RejectOperation", "success", map[string]interface{}{
+		"type":       "service",
+		"pending_id": op.ID,
+		"checker":    checker,
+	})
+	return op, nil
+}
+
+// decide moves a pending operation to status on behalf of checker in its own
+// database transaction. An operation found past its expiry is expired
+// instead.
+func (s *approvalService) decide(ctx context.Context, id, checker, status, note string) (*models.PendingOperation, error) {
+	var op *models.PendingOperation
+	var expired bool
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		var err error
+		op, expired, err = decideIn(ctx, repo, id, checker, status, note)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrOperationExpired
+	}
+	return op, nil
+}
+
+// decideIn locks a pending operation in repo's transaction and moves it to
+// status on behalf of checker. An operation found past its expiry is expired
+// instead and reported as such. The maker and checker are compared by the
+// authenticated principal only, so naming another operator does not make a
+// maker their own checker.
+func decideIn(ctx context.Context, repo repository.Repository, id, checker, status, note string) (*models.PendingOperation, bool, error) {
+	if strings.TrimSpace(checker) == "" {
+		return nil, false, ErrActorRequired
+	}
+
+	op, err := repo.GetPendingOperationForUpdate(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if op.Status != models.PendingStatusPending {
+		return nil, false, ErrOperationNotPending
+	}
+	now := time.Now()
+	if !now.Before(op.ExpiresAt) {
+		return op, true, expire(ctx, repo, op, now)
+	}
+	if principal(checker) == principal(op.Maker) {
+		return nil, false, ErrSameMakerChecker
+	}
+
+	op.Status, op.Checker, op.Note, op.DecidedAt = status, checker, note, &now
+	if err := repo.UpdatePendingOperation(ctx, op); err != nil {
+		return nil, false, err
+	}
+	err = repo.CreatePendingOperationEvent(ctx, &models.PendingOperationEvent{
+		OperationID: op.ID,
+		Status:      status,
+		Actor:       checker,
+		Note:        note,
+		CreatedAt:   now,
+	})
+	return op, false, err
+}
+
+// principal returns the authenticated part of an actor, e.g. "partner:teller"
+// of "partner:teller/sari", without the operator the request named
+func principal(actor string) string {
+	authenticated, _, _ := strings.Cut(actor, "/")
+	return authenticated
+}
+
+// execute runs an approved operation through svc and returns a reference to
+// its result
+func (s *approvalService) execute(ctx context.Context, svc Service, op *models.PendingOperation) (string, error) {
+	switch op.Operation {
+	case models.OperationWithdrawal:
+		var payload models.WithdrawalPayload
+		if err := json.Unmarshal(op.Payload, &payload); err != nil {
+			return "", err
+		}
+		return "", svc.WithdrawVerified(ctx, payload.AccountNumber, payload.Amount)
+	case models.OperationReversal:
+		var payload models.ReversalPayload
+		if err := json.Unmarshal(op.Payload, &payload); err != nil {
+			return "", err
+		}
+		reversal, err := svc.ReverseTransaction(ctx, payload.TransactionID, payload.Reason, payload.Actor, payload.Force)
+		if err != nil {
+			return "", err
+		}
+		return reversal.Reference, nil
+	default:
+		return "", fmt.Errorf("unknown operation %q", op.Operation)
+	}
+}
+
+// ListPending returns the operations waiting for a checker, oldest first
+func (s *approvalService) ListPending(ctx context.Context) ([]models.PendingOperation, error) {
+	ops, err := s.repo.GetPendingOperations(ctx, models.PendingStatusPending, pendingListLimit)
+	if err != nil {
+		return nil, s.approvalError(ctx, "ListPending", err)
+	}
+	return ops, nil
+}
+
+// ExpirePending expires up to batchSize operations nobody decided on in time
+// and returns how many were expired
+func (s *approvalService) ExpirePending(ctx context.Context, batchSize int) (int, error) {
+	expired := 0
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		now := time.Now()
+		ops, err := repo.GetExpiredPendingOperations(ctx, now, batchSize)
+		if err != nil {
+			return err
+		}
+		for i := range ops {
+			if err := expire(ctx, repo, &ops[i], now); err != nil {
+				return err
+			}
+		}
+		expired = len(ops)
+		return nil
+	})
+	if err != nil {
+		return 0, s.approvalError(ctx, "ExpirePending", err)
+	}
+
+	if expired > 0 {
+		s.log.LogOperation(ctx, "ExpirePending", "success", map[string]interface{}{
+			"type":  "service",
+			"count": expired,
+		})
+	}
+	return expired, nil
+}
+
+func expire(ctx context.Context, repo repository.Repository, op *models.PendingOperation, now time.Time) error {
+	op.Status, op.DecidedAt = models.PendingStatusExpired, &now
+	if err := repo.UpdatePendingOperation(ctx, op); err != nil {
+		return err
+	}
+	return repo.CreatePendingOperationEvent(ctx, &models.PendingOperationEvent{
+		OperationID: op.ID,
+		Status:      op.Status,
+		Actor:       systemActor,
+		CreatedAt:   now,
+	})
+}
+
+func (s *approvalService) approvalError(ctx context.Context, op string, err error) error {
+	s.log.LogOperation(ctx, op, "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
+
+// ApprovalSweeper periodically expires pending operations nobody decided on
+type ApprovalSweeper struct {
+	service   ApprovalService
+	interval  time.Duration
+	batchSize int
+	log       *logger.CustomLogger
+}
+
+func NewApprovalSweeper(service ApprovalService, interval time.Duration, batchSize int, log *logger.CustomLogger) *ApprovalSweeper {
+	return &ApprovalSweeper{
+		service:   service,
+		interval:  interval,
+		batchSize: batchSize,
+		log:       log,
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (w *ApprovalSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				expired, err := w.service.ExpirePending(ctx, w.batchSize)
+				if err != nil {
+					w.log.LogWarning(ctx, "Pending operation sweep failed", map[string]interface{}{
+						"error": err.Error(),
+					})
+					break
+				}
+				if expired < w.batchSize {
+					break
+				}
+			}
+		}
+	}
+}
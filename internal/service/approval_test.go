@@ -0,0 +1,21 @@
+package service
+
+import "testing"
+
+func TestPrincipalIgnoresNamedOperator(t *testing.T) {
+	tests := []struct {
+		maker, checker string
+		same           bool
+	}{
+		{"partner:teller/sari", "partner:teller/budi", true},
+		{"partner:teller", "partner:teller/budi", true},
+		{"customer:1234567890", "customer:1234567890", true},
+		{"partner:teller/sari", "partner:spv/sari", false},
+		{"customer:1234567890", "partner:spv", false},
+	}
+	for _, tt := range tests {
+		if got := principal(tt.maker) == principal(tt.checker); got != tt.same {
+			t.Errorf("principal(%q) == principal(%q) = %v, want %v", tt.maker, tt.checker, got, tt.same)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+var (
+	ErrInvalidTimeRange   = errcode.New("INVALID_TIME_RANGE", "start of the time range must be before its end")
+	ErrComplianceRequired = errcode.New("COMPLIANCE_REQUIRED", "reading the audit log requires the compliance role")
+)
+
+// Page sizes of audit log queries
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditService struct {
+	repo repository.Repository
+	log  *logger.CustomLogger
+}
+
+type AuditService interface {
+	SearchEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+func NewAuditService(repo repository.Repository, log *logger.CustomLogger) AuditService {
+	return &auditService{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// SearchEvents returns a page of the audit log matching filter, newest first.
+// The page holds 100 events unless filter.Limit asks for up to 1000.
+func (s *auditService) SearchEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, s.auditError(ctx, ErrInvalidTimeRange)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	events, err := s.repo.GetAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, s.auditError(ctx, err)
+	}
+
+	s.log.LogOperation(ctx, "SearchAuditEvents", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": filter.AccountNumber,
+		"actor":      filter.Actor,
+		"count":      len(events),
+	})
+	return events, nil
+}
+
+func (s *auditService) auditError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "SearchAuditEvents", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+const minPasswordLength = 6
+
+var (
+	ErrPasswordTooShort    = errcode.New("PASSWORD_TOO_SHORT", "password must be at least 6 characters")
+	ErrInvalidCredentials  = errcode.New("INVALID_CREDENTIALS", "invalid account number or password")
+	ErrInvalidRefreshToken = errcode.New("INVALID_REFRESH_TOKEN", "invalid or expired refresh token")
+)
+
+type authService struct {
+	repo   repository.Repository
+	tokens *auth.TokenManager
+	log    *logger.CustomLogger
+}
+
+type AuthService interface {
+	Login(ctx context.Context, req *dto.LoginRequest) (*dto.TokenResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*dto.TokenResponse, error)
+}
+
+func NewAuthService(repo repository.Repository, tokens *auth.TokenManager, log *logger.CustomLogger) AuthService {
+	return &authService{
+		repo:   repo,
+		tokens: tokens,
+		log:    log,
+	}
+}
+
+func (s *authService) Login(ctx context.Context, req *dto.LoginRequest) (*dto.TokenResponse, error) {
+	credential, err := s.repo.GetCredential(ctx, req.NoRekening)
+	if err != nil {
+		s.log.LogOperation(ctx, "Login", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if !auth.CheckPassword(credential.PasswordHash, req.Password) {
+		s.log.LogOperation(ctx, "Login", "error", map[string]interface{}{
+			"error":      ErrInvalidCredentials.Error(),
+			"account_id": req.NoRekening,
+		})
+		return nil, ErrInvalidCredentials
+	}
+
+	// Closed accounts are kept for retention but can no longer sign in
+	account, err := s.repo.GetAccountByNoRekening(ctx, credential.AccountNumber)
+	if err != nil {
+		s.log.LogOperation(ctx, "Login", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	if account.Status == models.StatusClosed {
+		s.log.LogOperation(ctx, "Login", "error", map[string]interface{}{
+			"error":      repository.ErrAccountClosed.Error(),
+			"account_id": credential.AccountNumber,
+		})
+		return nil, repository.ErrAccountClosed
+	}
+
+	tokens, err := s.issueTokens(ctx, s.repo, credential.AccountNumber)
+	if err != nil {
+		s.log.LogOperation(ctx, "Login", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	s.log.LogOperation(ctx, "Login", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": credential.AccountNumber,
+	})
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Refresh tokens are
+// single use: the presented token is revoked in the same transaction that
+// stores its replacement.
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*dto.TokenResponse, error) {
+	var tokens *dto.TokenResponse
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		stored, err := repo.GetRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+		if err := repo.RevokeRefreshToken(ctx, stored.TokenHash); err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		tokens, err = s.issueTokens(ctx, repo, stored.AccountNumber)
+		return err
+	})
+	if err != nil {
+		s.log.LogOperation(ctx, "Refresh", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	s.log.LogOperation(ctx, "Refresh", "success", map[string]interface{}{
+		"type": "service",
+	})
+	return tokens, nil
+}
+
+func (s *authService) issueTokens(ctx context.Context, repo repository.Repository, accountNumber string) (*dto.TokenResponse, error) {
+	accessToken, _, err := s.tokens.IssueAccessToken(accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, refreshExpiresAt, err := s.tokens.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.CreateRefreshToken(ctx, &models.RefreshToken{
+		TokenHash:     refreshHash,
+		AccountNumber: accountNumber,
+		ExpiresAt:     refreshExpiresAt,
+		CreatedAt:     time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.tokens.AccessTokenTTL().Seconds()),
+	}, nil
+}
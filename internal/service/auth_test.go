@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// authRepo holds credentials, accounts and refresh tokens in memory
+type authRepo struct {
+	repository.Repository
+	credentials map[string]*models.Credential
+	accounts    map[string]*models.Account
+	refresh     map[string]*models.RefreshToken
+}
+
+func newAuthRepo(t *testing.T, accountNumber, password, status string) *authRepo {
+	t.Helper()
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	return &authRepo{
+		credentials: map[string]*models.Credential{accountNumber: {AccountNumber: accountNumber, PasswordHash: hash}},
+		accounts:    map[string]*models.Account{accountNumber: {AccountNumber: accountNumber, Status: status}},
+		refresh:     map[string]*models.RefreshToken{},
+	}
+}
+
+func (r *authRepo) WithTx(ctx context.Context, fn func(repo repository.Repository) error) error {
+	return fn(r)
+}
+
+func (r *authRepo) GetCredential(ctx context.Context, accountNumber string) (*models.Credential, error) {
+	credential, ok := r.credentials[accountNumber]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return credential, nil
+}
+
+func (r *authRepo) GetAccountByNoRekening(ctx context.Context, noRekening string) (*models.Account, error) {
+	account, ok := r.accounts[noRekening]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return account, nil
+}
+
+func (r *authRepo) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	stored := *token
+	r.refresh[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *authRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token, ok := r.refresh[tokenHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	stored := *token
+	return &stored, nil
+}
+
+func (r *authRepo) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	token, ok := r.refresh[tokenHash]
+	if !ok || token.RevokedAt != nil {
+		return errors.New("refresh token already revoked")
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	return nil
+}
+
+func testTokens(t *testing.T) *auth.TokenManager {
+	t.Helper()
+	tokens, err := auth.NewTokenManager(auth.Config{
+		Issuer:            "test",
+		AccessTokenTTL:    15 * time.Minute,
+		RefreshTokenTTL:   24 * time.Hour,
+		AllowEphemeralKey: true,
+	})
+	if err != nil {
+		t.Fatalf("token manager: %v", err)
+	}
+	return tokens
+}
+
+func TestLoginIssuesTokensForAccount(t *testing.T) {
+	repo := newAuthRepo(t, "1000000001", "rahasia123", models.StatusActive)
+	tokens := testTokens(t)
+	s := NewAuthService(repo, tokens, testLogger(t))
+
+	resp, err := s.Login(context.Background(), &dto.LoginRequest{NoRekening: "1000000001", Password: "rahasia123"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	claims, err := tokens.ParseAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	if claims.Subject != "1000000001" || resp.TokenType != "Bearer" || resp.ExpiresIn != 900 {
+		t.Errorf("login = subject %q, %s, expires in %d; want 1000000001, Bearer, 900", claims.Subject, resp.TokenType, resp.ExpiresIn)
+	}
+	stored := repo.refresh[auth.HashRefreshToken(resp.RefreshToken)]
+	if stored == nil || stored.AccountNumber != "1000000001" {
+		t.Errorf("refresh token stored as %+v, want it stored by hash for the account", stored)
+	}
+}
+
+func TestLoginRejectsBadCredentials(t *testing.T) {
+	repo := newAuthRepo(t, "1000000001", "rahasia123", models.StatusActive)
+	s := NewAuthService(repo, testTokens(t), testLogger(t))
+
+	for _, req := range []dto.LoginRequest{
+		{NoRekening: "1000000001", Password: "salah123"},
+		{NoRekening: "1000000009", Password: "rahasia123"},
+	} {
+		if _, err := s.Login(context.Background(), &req); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("login %s: err = %v, want %v", req.NoRekening, err, ErrInvalidCredentials)
+		}
+	}
+	if len(repo.refresh) != 0 {
+		t.Errorf("%d refresh tokens issued for failed logins", len(repo.refresh))
+	}
+}
+
+func TestLoginRefusesClosedAccount(t *testing.T) {
+	repo := newAuthRepo(t, "1000000001", "rahasia123", models.StatusClosed)
+	s := NewAuthService(repo, testTokens(t), testLogger(t))
+
+	_, err := s.Login(context.Background(), &dto.LoginRequest{NoRekening: "1000000001", Password: "rahasia123"})
+	if !errors.Is(err, repository.ErrAccountClosed) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrAccountClosed)
+	}
+	if len(repo.refresh) != 0 {
+		t.Errorf("%d refresh tokens issued for a closed account", len(repo.refresh))
+	}
+}
+
+func TestRefreshRotatesSingleUseTokens(t *testing.T) {
+	repo := newAuthRepo(t, "1000000001", "rahasia123", models.StatusActive)
+	s := NewAuthService(repo, testTokens(t), testLogger(t))
+	ctx := context.Background()
+
+	login, err := s.Login(ctx, &dto.LoginRequest{NoRekening: "1000000001", Password: "rahasia123"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	rotated, err := s.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if rotated.RefreshToken == login.RefreshToken {
+		t.Fatal("refresh returned the presented refresh token")
+	}
+	if repo.refresh[auth.HashRefreshToken(login.RefreshToken)].RevokedAt == nil {
+		t.Error("presented refresh token not revoked")
+	}
+
+	// the used token is spent; its replacement still works once
+	if _, err := s.Refresh(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("reusing a refresh token: err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := s.Refresh(ctx, rotated.RefreshToken); err != nil {
+		t.Errorf("refresh with the rotated token: %v", err)
+	}
+}
+
+func TestRefreshRejectsRevokedExpiredAndUnknownTokens(t *testing.T) {
+	repo := newAuthRepo(t, "1000000001", "rahasia123", models.StatusActive)
+	s := NewAuthService(repo, testTokens(t), testLogger(t))
+	ctx := context.Background()
+
+	revokedAt := time.Now().Add(-time.Minute)
+	repo.refresh[auth.HashRefreshToken("revoked")] = &models.RefreshToken{
+		TokenHash: auth.HashRefreshToken("revoked"), AccountNumber: "1000000001",
+		ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt,
+	}
+	repo.refresh[auth.HashRefreshToken("expired")] = &models.RefreshToken{
+		TokenHash: auth.HashRefreshToken("expired"), AccountNumber: "1000000001",
+		ExpiresAt: time.Now().Add(-time.Second),
+	}
+
+	for _, token := range []string{"revoked", "expired", "unknown"} {
+		if _, err := s.Refresh(ctx, token); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refresh with %s token: err = %v, want %v", token, err, ErrInvalidRefreshToken)
+		}
+	}
+	if len(repo.refresh) != 2 {
+		t.Errorf("%d refresh tokens stored, want no new ones", len(repo.refresh))
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// Identity reuse modes for the NIK and phone number of closed accounts
+const (
+	IdentityReuseNever     = "never"
+	IdentityReuseImmediate = "immediate"
+	IdentityReuseCooldown  = "cooldown"
+)
+
+var (
+	ErrInvalidPayoutMethod  = errcode.New("INVALID_PAYOUT_METHOD", "payout method must be TRANSFER or TUNAI")
+	ErrBeneficiaryRequired  = errcode.New("BENEFICIARY_REQUIRED", "beneficiary account is required for a transfer payout")
+	ErrUnknownIdentityReuse = errcode.New("UNKNOWN_IDENTITY_REUSE_POLICY", "identity reuse policy must be never, immediate or cooldown")
+)
+
+// IdentityReusePolicy decides when the NIK and phone number of a closed
+// account may be registered again
+type IdentityReusePolicy struct {
+	Mode     string
+	Cooldown time.Duration
+}
+
+// Validate reports whether the policy mode is known
+func (p IdentityReusePolicy) Validate() error {
+	switch p.Mode {
+	case IdentityReuseNever, IdentityReuseImmediate, IdentityReuseCooldown:
+		return nil
+	default:
+		return ErrUnknownIdentityReuse
+	}
+}
+
+// closedAfter returns the cutoff passed to the repository: accounts closed
+// after it still hold their NIK and phone number
+func (p IdentityReusePolicy) closedAfter(now time.Time) time.Time {
+	switch p.Mode {
+	case IdentityReuseImmediate:
+		return now
+	case IdentityReuseCooldown:
+		return now.Add(-p.Cooldown)
+	default:
+		return time.Time{}
+	}
+}
+
+// CloseAccount pays out the remaining balance, either to another account or
+// as cash, and closes the account in the same transaction. The account row is
+// kept for retention.
+func (s *service) CloseAccount(ctx context.Context, accountNumber, pin, method, beneficiary, reason string) (*models.AccountClosure, error) {
+	method = strings.ToUpper(method)
+	switch {
+	case method != models.PayoutTransfer && method != models.PayoutCash:
+		return nil, s.closeError(ctx, ErrInvalidPayoutMethod)
+	case method == models.PayoutTransfer && beneficiary == "":
+		return nil, s.closeError(ctx, ErrBeneficiaryRequired)
+	case method == models.PayoutTransfer && beneficiary == accountNumber:
+		return nil, s.closeError(ctx, ErrSameAccount)
+	case strings.TrimSpace(reason) == "":
+		return nil, s.closeError(ctx, ErrReasonRequired)
+	}
+	if method == models.PayoutCash {
+		beneficiary = ""
+	}
+
+	if err := s.verifyPin(ctx, accountNumber, pin, PinOperationClose); err != nil {
+		return nil, err
+	}
+
+	closure := &models.AccountClosure{
+		AccountNumber: accountNumber,
+		PayoutMethod:  method,
+		Beneficiary:   beneficiary,
+	}
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountForUpdate(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		if err := customerStatusError(account.Status); err != nil {
+			return err
+		}
+		if account.HeldAmount > 0 {
+			return ErrActiveHolds
+		}
+
+		if account.Balance > 0 {
+			closure.PayoutAmount = account.Balance
+			closure.Reference = models.NewReference()
+			if method == models.PayoutTransfer {
+				_, err = repo.Transfer(ctx, closure.Reference, accountNumber, beneficiary, account.Balance, "Penutupan rekening")
+			} else {
+				_, err = repo.Debit(ctx, closure.Reference, accountNumber, account.Balance, models.TransactionClosurePayout, "Pencairan tunai penutupan rekening")
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		change, err := applyStatusChange(ctx, repo, account, models.StatusClosed, reason, "customer:"+accountNumber)
+		if err != nil {
+			return err
+		}
+		closure.ClosedAt = change.CreatedAt
+
+		return repo.RevokeAccountRefreshTokens(ctx, accountNumber)
+	})
+	if err != nil {
+		return nil, s.closeError(ctx, err)
+	}
+
+	s.log.LogOperation(ctx, "CloseAccount", "success", map[string]interface{}{
+		"type":          "service",
+		"account_id":    accountNumber,
+		"payout_method": method,
+		"payout_amount": closure.PayoutAmount,
+		"reference":     closure.Reference,
+	})
+	return closure, nil
+}
+
+// customerStatusError returns the error for an account whose status does not allow
+// customer initiated operations
+func customerStatusError(status string) error {
+	switch status {
+	case models.StatusFrozen:
+		return repository.ErrAccountFrozen
+	case models.StatusDormant:
+		return repository.ErrAccountDormant
+	case models.StatusClosed:
+		return repository.ErrAccountClosed
+	default:
+		return nil
+	}
+}
+
+func (s *service) closeError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "CloseAccount", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+var (
+	ErrInvalidDate     = errcode.New("INVALID_DATE", "date must be formatted YYYY-MM-DD")
+	ErrFutureDate      = errcode.New("FUTURE_DATE", "date has not started yet")
+	ErrBusinessDayOpen = errcode.New("BUSINESS_DAY_OPEN", "business date has not ended yet")
+	ErrEODAlreadyRun   = errcode.New("EOD_ALREADY_RUN", "end of day has already run for this business date")
+)
+
+type eodService struct {
+	repo repository.Repository
+	log  *logger.CustomLogger
+}
+
+type EODService interface {
+	RunEndOfDay(ctx context.Context, businessDate string) (*models.EODRun, error)
+}
+
+func NewEODService(repo repository.Repository, log *logger.CustomLogger) EODService {
+	return &eodService{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// RunEndOfDay closes a business date: it snapshots every account's balance
+// at the end of the day and records the day's deposits, withdrawals and fees.
+// The whole close is one transaction, so a failed run leaves nothing behind
+// and a date that has been closed cannot be closed again.
+func (s *eodService) RunEndOfDay(ctx context.Context, businessDate string) (*models.EODRun, error) {
+	dayStart, dayEnd, err := models.BusinessDayBounds(businessDate)
+	if err != nil {
+		return nil, s.eodError(ctx, ErrInvalidDate)
+	}
+	if dayEnd.After(time.Now()) {
+		return nil, s.eodError(ctx, ErrBusinessDayOpen)
+	}
+
+	run := &models.EODRun{BusinessDate: businessDate}
+	err = s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		started, err := repo.StartEODRun(ctx, run)
+		if err != nil {
+			return err
+		}
+		if !started {
+			return ErrEODAlreadyRun
+		}
+		if _, err := repo.CreateBalanceSnapshots(ctx, businessDate, dayEnd); err != nil {
+			return err
+		}
+		return repo.CompleteEODRun(ctx, run, dayStart, dayEnd)
+	})
+	if err != nil {
+		return nil, s.eodError(ctx, err)
+	}
+
+	s.log.LogOperation(ctx, "RunEndOfDay", "success", map[string]interface{}{
+		"type":          "service",
+		"business_date": businessDate,
+		"accounts":      run.AccountCount,
+	})
+	return run, nil
+}
+
+func (s *eodService) eodError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "RunEndOfDay", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
+
+// GetBalanceOnDate returns an account's balance at the end of a YYYY-MM-DD
+// business date: the latest end-of-day snapshot on or before it plus the
+// ledger entries posted after that snapshot. Without a snapshot the whole
+// ledger is summed. For today it is the balance so far.
+func (s *service) GetBalanceOnDate(ctx context.Context, accountNumber, businessDate string) (float64, error) {
+	dayStart, dayEnd, err := models.BusinessDayBounds(businessDate)
+	if err != nil {
+		return 0, s.balanceOnDateError(ctx, ErrInvalidDate)
+	}
+	if dayStart.After(time.Now()) {
+		return 0, s.balanceOnDateError(ctx, ErrFutureDate)
+	}
+
+	if _, err := s.repo.GetAccountByNoRekening(ctx, accountNumber); err != nil {
+		return 0, s.balanceOnDateError(ctx, err)
+	}
+	snapshot, err := s.repo.GetLatestSnapshot(ctx, accountNumber, businessDate)
+	if err != nil {
+		return 0, s.balanceOnDateError(ctx, err)
+	}
+
+	balance := 0.0
+	var from time.Time
+	if snapshot != nil {
+		balance = snapshot.Balance
+		if _, from, err = models.BusinessDayBounds(snapshot.BusinessDate); err != nil {
+			return 0, s.balanceOnDateError(ctx, err)
+		}
+	}
+	if from.Before(dayEnd) {
+		net, err := s.repo.GetLedgerNet(ctx, accountNumber, from, dayEnd)
+		if err != nil {
+			return 0, s.balanceOnDateError(ctx, err)
+		}
+		balance += net
+	}
+
+	s.log.LogOperation(ctx, "GetBalanceOnDate", "success", map[string]interface{}{
+		"type":          "service",
+		"account_id":    accountNumber,
+		"business_date": businessDate,
+	})
+	return roundAmount(balance), nil
+}
+
+func (s *service) balanceOnDateError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "GetBalanceOnDate", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
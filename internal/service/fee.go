@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// Fee types of a FeeRule
+const (
+	FeeFlat    = "FLAT"
+	FeePercent = "PERCENT"
+)
+
+// FeeRule charges a fee on transactions of a product through a channel
+// (CASH, TRANSFER or DEPOSIT). Product may be "*". A PERCENT fee is Value
+// percent of the amount, kept between MinFee and MaxFee when they are set.
+type FeeRule struct {
+	Product string  `json:"product"`
+	Channel string  `json:"channel"`
+	Type    string  `json:"type"`
+	Value   float64 `json:"value"`
+	MinFee  float64 `json:"min_fee"`
+	MaxFee  float64 `json:"max_fee"`
+}
+
+// FeePolicy holds the transaction fee rules and the monthly admin fee of
+// each product
+type FeePolicy struct {
+	Rules        []FeeRule          `json:"rules"`
+	MonthlyAdmin map[string]float64 `json:"monthly_admin"`
+}
+
+// DefaultFeePolicy is used when no fee file is configured: no transaction
+// fees and a monthly admin fee on savings accounts
+func DefaultFeePolicy() FeePolicy {
+	return FeePolicy{
+		MonthlyAdmin: map[string]float64{
+			models.ProductTabungan: 10000,
+		},
+	}
+}
+
+// LoadFeePolicy reads a FeePolicy from a JSON file, or returns
+// DefaultFeePolicy when path is empty
+func LoadFeePolicy(path string) (FeePolicy, error) {
+	if path == "" {
+		return DefaultFeePolicy(), nil
+	}
+	var policy FeePolicy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, err
+	}
+	for _, rule := range policy.Rules {
+		if rule.Type != FeeFlat && rule.Type != FeePercent {
+			return policy, fmt.Errorf("unknown fee type %q", rule.Type)
+		}
+	}
+	return policy, nil
+}
+
+// feeFor returns the fee of a transaction, preferring a rule for the exact
+// product over a "*" rule
+func (p FeePolicy) feeFor(product, channel string, amount float64) float64 {
+	var match *FeeRule
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Channel != channel {
+			continue
+		}
+		if rule.Product == product {
+			match = rule
+			break
+		}
+		if rule.Product == RuleWildcard && match == nil {
+			match = rule
+		}
+	}
+	if match == nil {
+		return 0
+	}
+
+	fee := match.Value
+	if match.Type == FeePercent {
+		fee = amount * match.Value / 100
+		if match.MinFee > 0 && fee < match.MinFee {
+			fee = match.MinFee
+		}
+		if match.MaxFee > 0 && fee > match.MaxFee {
+			fee = match.MaxFee
+		}
+	}
+	return roundAmount(fee)
+}
+
+var feeDescriptions = map[string]string{
+	models.ChannelCash:     "Biaya tarik tunai",
+	models.ChannelTransfer: "Biaya transfer",
+	models.ChannelDeposit:  "Biaya setor tunai",
+}
+
+// chargeFee debits the transaction fee, if any, as its own ledger entry under
+// the transaction's reference. It runs in the transaction's database
+// transaction, so a balance that cannot cover amount plus fee rejects both.
+func (s *service) chargeFee(ctx context.Context, repo repository.Repository, account *models.Account, channel, reference string, amount float64) error {
+	fee := s.feePolicy.feeFor(account.Product, channel, amount)
+	if fee <= 0 {
+		return nil
+	}
+	_, err := repo.Debit(ctx, reference, account.AccountNumber, fee, models.TransactionFee, feeDescriptions[channel])
+	return err
+}
+
+type feeService struct {
+	repo   repository.Repository
+	policy FeePolicy
+	log    *logger.CustomLogger
+}
+
+type FeeService interface {
+	ChargeMonthlyAdminFees(ctx context.Context, period string) (int, error)
+}
+
+func NewFeeService(repo repository.Repository, policy FeePolicy, log *logger.CustomLogger) FeeService {
+	return &feeService{
+		repo:   repo,
+		policy: policy,
+		log:    log,
+	}
+}
+
+// ChargeMonthlyAdminFees charges every active account the admin fee of its
+// product for a YYYY-MM period. An account is charged at most once per
+// period; when its available balance is short only that balance is taken.
+// Frozen and dormant accounts are skipped.
+func (s *feeService) ChargeMonthlyAdminFees(ctx context.Context, period string) (int, error) {
+	if _, err := time.Parse("2006-01", period); err != nil {
+		return 0, s.feeError(ctx, fmt.Errorf("invalid period: %v", err))
+	}
+
+	charged := 0
+	after := ""
+	for {
+		accounts, err := s.repo.GetOpenAccounts(ctx, after, batchSize)
+		if err != nil {
+			return charged, s.feeError(ctx, err)
+		}
+		if len(accounts) == 0 {
+			break
+		}
+
+		for _, account := range accounts {
+			fee := s.policy.MonthlyAdmin[account.Product]
+			if fee <= 0 {
+				continue
+			}
+			ok, err := s.chargeAdminFee(ctx, account.AccountNumber, period, fee)
+			if err != nil {
+				return charged, s.feeError(ctx, err)
+			}
+			if ok {
+				charged++
+			}
+		}
+		after = accounts[len(accounts)-1].AccountNumber
+	}
+
+	s.log.LogOperation(ctx, "ChargeMonthlyAdminFees", "success", map[string]interface{}{
+		"type":    "service",
+		"period":  period,
+		"charged": charged,
+	})
+	return charged, nil
+}
+
+func (s *feeService) chargeAdminFee(ctx context.Context, accountNumber, period string, fee float64) (bool, error) {
+	created := false
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountForUpdate(ctx, accountNumber)
+		if err != nil || account.Status != models.StatusActive {
+			return err
+		}
+
+		charge := &models.AdminFeeCharge{
+			AccountNumber: accountNumber,
+			Period:        period,
+			Amount:        math.Min(fee, math.Max(account.AvailableBalance(), 0)),
+			Reference:     models.NewReference(),
+		}
+		created, err = repo.CreateAdminFeeCharge(ctx, charge)
+		if err != nil || !created || charge.Amount <= 0 {
+			return err
+		}
+
+		_, err = repo.PostSystemEntry(ctx, charge.Reference, accountNumber, models.DirectionDebit,
+			charge.Amount, models.TransactionAdminFee, "Biaya administrasi "+period)
+		return err
+	})
+	return created, err
+}
+
+func (s *feeService) feeError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "ChargeMonthlyAdminFees", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
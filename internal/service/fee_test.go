@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+func (r *ledgerRepo) Debit(ctx context.Context, reference, accountNumber string, amount float64, trxType, description string) (*models.Transaction, error) {
+	account, err := r.debitable(accountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+	account.Balance -= amount
+	return r.post(&models.Transaction{
+		Reference:     reference,
+		AccountNumber: accountNumber,
+		Type:          trxType,
+		Direction:     models.DirectionDebit,
+		Amount:        amount,
+		BalanceAfter:  account.Balance,
+		Description:   description,
+	}), nil
+}
+
+func (r *ledgerRepo) GetWithdrawalUsageForUpdate(ctx context.Context, accountNumber, businessDate, channel string) (*models.WithdrawalUsage, error) {
+	key := accountNumber + "/" + businessDate + "/" + channel
+	used, ok := r.usage[key]
+	if !ok {
+		used = &models.WithdrawalUsage{AccountNumber: accountNumber, BusinessDate: businessDate, Channel: channel}
+		r.usage[key] = used
+	}
+	copied := *used
+	return &copied, nil
+}
+
+func (r *ledgerRepo) AddWithdrawalUsage(ctx context.Context, usage *models.WithdrawalUsage, amount float64) error {
+	used := r.usage[usage.AccountNumber+"/"+usage.BusinessDate+"/"+usage.Channel]
+	used.TotalAmount += amount
+	used.Count++
+	*usage = *used
+	return nil
+}
+
+// usedToday sums the cash withdrawal usage recorded for an account
+func (r *ledgerRepo) usedToday(accountNumber string) models.WithdrawalUsage {
+	var total models.WithdrawalUsage
+	for _, used := range r.usage {
+		if used.AccountNumber == accountNumber && used.Channel == models.ChannelCash {
+			total.TotalAmount += used.TotalAmount
+			total.Count += used.Count
+		}
+	}
+	return total
+}
+
+func newFeeService(t *testing.T, repo repository.Repository) Service {
+	t.Helper()
+	return NewService(repo, Policies{
+		Limits: DefaultLimitPolicy(),
+		Fees: FeePolicy{Rules: []FeeRule{
+			{Product: RuleWildcard, Channel: models.ChannelCash, Type: FeeFlat, Value: 6500},
+		}},
+	}, testLogger(t))
+}
+
+func TestWithdrawChargesFeeUnderTheWithdrawalReference(t *testing.T) {
+	repo := newLedgerRepo(tabungan("1001", 100000))
+	svc := newFeeService(t, repo)
+
+	if err := svc.WithdrawVerified(context.Background(), "1001", 50000); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+
+	if len(repo.entries) != 2 {
+		t.Fatalf("posted %d entries, want the withdrawal and its fee", len(repo.entries))
+	}
+	withdrawal, fee := repo.entries[0], repo.entries[1]
+	if withdrawal.Type != models.TransactionWithdrawal || withdrawal.Amount != 50000 {
+		t.Fatalf("withdrawal entry = %+v", withdrawal)
+	}
+	if fee.Type != models.TransactionFee || fee.Amount != 6500 || fee.Description != feeDescriptions[models.ChannelCash] {
+		t.Fatalf("fee entry = %+v", fee)
+	}
+	if fee.Reference != withdrawal.Reference {
+		t.Fatalf("fee reference %q, want the withdrawal's %q", fee.Reference, withdrawal.Reference)
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 43500 {
+		t.Fatalf("balance = %v, want 43500", balance)
+	}
+	// the fee does not count towards the withdrawal limit
+	if used := repo.usedToday("1001"); used.Count != 1 || used.TotalAmount != 50000 {
+		t.Fatalf("usage = %+v, want one withdrawal of 50000", used)
+	}
+}
+
+func TestWithdrawRollsBackWhenFeeCannotBeCovered(t *testing.T) {
+	tests := []struct {
+		name   string
+		held   float64
+		amount float64
+	}{
+		// the withdrawal alone fits, the fee does not
+		{"short balance", 0, 95000},
+		// the fee must come out of the available balance, not held funds
+		{"held funds", 40000, 55000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := tabungan("1001", 100000)
+			account.HeldAmount = tt.held
+			repo := newLedgerRepo(account)
+			svc := newFeeService(t, repo)
+
+			err := svc.WithdrawVerified(context.Background(), "1001", tt.amount)
+			if !errors.Is(err, repository.ErrInsufficientBalance) {
+				t.Fatalf("got %v, want ErrInsufficientBalance", err)
+			}
+			if account.Balance != 100000 || account.HeldAmount != tt.held {
+				t.Fatalf("balance %v held %v, want 100000 and %v", account.Balance, account.HeldAmount, tt.held)
+			}
+			if len(repo.entries) != 0 {
+				t.Fatalf("posted %+v, want nothing", repo.entries)
+			}
+			if used := repo.usedToday("1001"); used.Count != 0 || used.TotalAmount != 0 {
+				t.Fatalf("usage = %+v, want the limit untouched", used)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+var (
+	ErrHoldNotActive      = errcode.New("HOLD_NOT_ACTIVE", "hold is no longer active")
+	ErrHoldExpired        = errcode.New("HOLD_EXPIRED", "hold has expired")
+	ErrCaptureExceedsHold = errcode.New("CAPTURE_EXCEEDS_HOLD", "capture amount exceeds the remaining hold")
+	ErrInvalidHoldTTL     = errcode.New("INVALID_HOLD_TTL", "hold expiry exceeds the maximum allowed")
+	ErrActiveHolds        = errcode.New("ACTIVE_HOLDS", "account still has active holds")
+)
+
+// HoldPolicy controls how long a hold may reserve funds
+type HoldPolicy struct {
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// CreateHold reserves amount of the account's available balance until it is
+// captured, released or expires. A zero ttl uses the policy default.
+func (s *service) CreateHold(ctx context.Context, accountNumber string, amount float64, ttl time.Duration, description string) (*models.Hold, error) {
+	if ttl == 0 {
+		ttl = s.holdPolicy.DefaultTTL
+	}
+	switch {
+	case amount <= 0:
+		return nil, s.holdError(ctx, "CreateHold", ErrInvalidAmount)
+	case ttl < 0 || ttl > s.holdPolicy.MaxTTL:
+		return nil, s.holdError(ctx, "CreateHold", ErrInvalidHoldTTL)
+	}
+
+	hold := &models.Hold{
+		ID:            models.NewReference(),
+		AccountNumber: accountNumber,
+		Amount:        amount,
+		Description:   description,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	if err := s.repo.CreateHold(ctx, hold); err != nil {
+		return nil, s.holdError(ctx, "CreateHold", err)
+	}
+
+	s.log.LogOperation(ctx, "CreateHold", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": accountNumber,
+		"hold_id":    hold.ID,
+	})
+	return hold, nil
+}
+
+// CaptureHold debits amount from an active hold. A capture smaller than the
+// remaining hold keeps the rest reserved until it is captured, released or
+// expires.
+func (s *service) CaptureHold(ctx context.Context, holdID string, amount float64, description string) (*models.Hold, *models.Transaction, error) {
+	if amount <= 0 {
+		return nil, nil, s.holdError(ctx, "CaptureHold", ErrInvalidAmount)
+	}
+
+	var hold *models.Hold
+	var trx *models.Transaction
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		var err error
+		hold, err = activeHold(ctx, repo, holdID)
+		if err != nil {
+			return err
+		}
+		if amount > hold.Remaining() {
+			return ErrCaptureExceedsHold
+		}
+
+		trx, err = repo.CaptureHold(ctx, hold, amount, description)
+		return err
+	})
+	if err != nil {
+		return nil, nil, s.holdError(ctx, "CaptureHold", err)
+	}
+
+	s.log.LogOperation(ctx, "CaptureHold", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": hold.AccountNumber,
+		"hold_id":    hold.ID,
+		"amount":     amount,
+	})
+	return hold, trx, nil
+}
+
+// ReleaseHold gives the remaining amount of an active hold back to the
+// available balance
+func (s *service) ReleaseHold(ctx context.Context, holdID string) (*models.Hold, error) {
+	var hold *models.Hold
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		var err error
+		hold, err = activeHold(ctx, repo, holdID)
+		if err != nil {
+			return err
+		}
+		return repo.ReleaseHold(ctx, hold, models.HoldReleased)
+	})
+	if err != nil {
+		return nil, s.holdError(ctx, "ReleaseHold", err)
+	}
+
+	s.log.LogOperation(ctx, "ReleaseHold", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": hold.AccountNumber,
+		"hold_id":    hold.ID,
+	})
+	return hold, nil
+}
+
+// ReleaseExpiredHolds releases up to batchSize expired holds and returns how
+// many were released
+func (s *service) ReleaseExpiredHolds(ctx context.Context, batchSize int) (int, error) {
+	released := 0
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		holds, err := repo.GetExpiredHolds(ctx, time.Now(), batchSize)
+		if err != nil {
+			return err
+		}
+		for i := range holds {
+			if err := repo.ReleaseHold(ctx, &holds[i], models.HoldExpired); err != nil {
+				return err
+			}
+		}
+		released = len(holds)
+		return nil
+	})
+	if err != nil {
+		return 0, s.holdError(ctx, "ReleaseExpiredHolds", err)
+	}
+
+	if released > 0 {
+		s.log.LogOperation(ctx, "ReleaseExpiredHolds", "success", map[string]interface{}{
+			"type":  "service",
+			"count": released,
+		})
+	}
+	return released, nil
+}
+
+// activeHold locks a hold and checks it can still be captured or released
+func activeHold(ctx context.Context, repo repository.Repository, holdID string) (*models.Hold, error) {
+	hold, err := repo.GetHoldForUpdate(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldActive {
+		return nil, ErrHoldNotActive
+	}
+	if !time.Now().Before(hold.ExpiresAt) {
+		return nil, ErrHoldExpired
+	}
+	return hold, nil
+}
+
+func (s *service) holdError(ctx context.Context, op string, err error) error {
+	s.log.LogOperation(ctx, op, "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// ledgerRepo keeps accounts, holds, withdrawal usage and ledger entries in
+// memory with the guards of the balance and held_amount UPDATEs. A failed WithTx rolls every
+// change back, like the database transaction it stands in for.
+type ledgerRepo struct {
+	repository.Repository
+	accounts map[string]*models.Account
+	holds    map[string]*models.Hold
+	usage    map[string]*models.WithdrawalUsage
+	entries  []models.Transaction
+}
+
+func newLedgerRepo(accounts ...*models.Account) *ledgerRepo {
+	r := &ledgerRepo{
+		accounts: map[string]*models.Account{},
+		holds:    map[string]*models.Hold{},
+		usage:    map[string]*models.WithdrawalUsage{},
+	}
+	for _, account := range accounts {
+		r.accounts[account.AccountNumber] = account
+	}
+	return r
+}
+
+func (r *ledgerRepo) WithTx(ctx context.Context, fn func(repo repository.Repository) error) error {
+	accounts := map[string]models.Account{}
+	for number, account := range r.accounts {
+		accounts[number] = *account
+	}
+	holds := map[string]models.Hold{}
+	for id, hold := range r.holds {
+		holds[id] = *hold
+	}
+	usage := map[string]models.WithdrawalUsage{}
+	for key, used := range r.usage {
+		usage[key] = *used
+	}
+	entries := len(r.entries)
+
+	err := fn(r)
+	if err != nil {
+		for number, account := range accounts {
+			*r.accounts[number] = account
+		}
+		for id := range r.holds {
+			if hold, ok := holds[id]; ok {
+				*r.holds[id] = hold
+			} else {
+				delete(r.holds, id)
+			}
+		}
+		for key := range r.usage {
+			if used, ok := usage[key]; ok {
+				*r.usage[key] = used
+			} else {
+				delete(r.usage, key)
+			}
+		}
+		r.entries = r.entries[:entries]
+	}
+	return err
+}
+
+func (r *ledgerRepo) GetAccountByNoRekening(ctx context.Context, accountNumber string) (*models.Account, error) {
+	account, ok := r.accounts[accountNumber]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *ledgerRepo) GetAccountForUpdate(ctx context.Context, accountNumber string) (*models.Account, error) {
+	return r.GetAccountByNoRekening(ctx, accountNumber)
+}
+
+// debitable mirrors debitError: the account must not be closed and must have
+// amount available beyond what is already held
+func (r *ledgerRepo) debitable(accountNumber string, amount float64) (*models.Account, error) {
+	account, ok := r.accounts[accountNumber]
+	switch {
+	case !ok:
+		return nil, repository.ErrAccountNotFound
+	case account.Status == models.StatusClosed:
+		return nil, repository.ErrAccountClosed
+	case account.AvailableBalance() < amount:
+		return nil, repository.ErrInsufficientBalance
+	}
+	return account, nil
+}
+
+func (r *ledgerRepo) post(trx *models.Transaction) *models.Transaction {
+	trx.ID = int64(len(r.entries) + 1)
+	trx.CreatedAt = time.Now()
+	r.entries = append(r.entries, *trx)
+	return trx
+}
+
+func (r *ledgerRepo) CreateHold(ctx context.Context, hold *models.Hold) error {
+	account, err := r.debitable(hold.AccountNumber, hold.Amount)
+	if err != nil {
+		return err
+	}
+	account.HeldAmount += hold.Amount
+	hold.Status = models.HoldActive
+	stored := *hold
+	r.holds[hold.ID] = &stored
+	return nil
+}
+
+func (r *ledgerRepo) GetHoldForUpdate(ctx context.Context, holdID string) (*models.Hold, error) {
+	hold, ok := r.holds[holdID]
+	if !ok {
+		return nil, repository.ErrHoldNotFound
+	}
+	copied := *hold
+	return &copied, nil
+}
+
+func (r *ledgerRepo) CaptureHold(ctx context.Context, hold *models.Hold, amount float64, description string) (*models.Transaction, error) {
+	account := r.accounts[hold.AccountNumber]
+	account.Balance -= amount
+	account.HeldAmount -= amount
+
+	hold.CapturedAmount += amount
+	if hold.Remaining() <= 0 {
+		hold.Status = models.HoldCaptured
+	}
+	*r.holds[hold.ID] = *hold
+
+	return r.post(&models.Transaction{
+		Reference:     hold.ID,
+		AccountNumber: hold.AccountNumber,
+		Type:          models.TransactionHoldCapture,
+		Direction:     models.DirectionDebit,
+		Amount:        amount,
+		BalanceAfter:  account.Balance,
+		Description:   description,
+	}), nil
+}
+
+func (r *ledgerRepo) ReleaseHold(ctx context.Context, hold *models.Hold, status string) error {
+	r.accounts[hold.AccountNumber].HeldAmount -= hold.Remaining()
+	hold.Status = status
+	*r.holds[hold.ID] = *hold
+	return nil
+}
+
+func (r *ledgerRepo) GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error) {
+	var holds []models.Hold
+	for _, hold := range r.holds {
+		if hold.Status == models.HoldActive && hold.ExpiresAt.Before(now) {
+			holds = append(holds, *hold)
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].ExpiresAt.Before(holds[j].ExpiresAt) })
+	if len(holds) > limit {
+		holds = holds[:limit]
+	}
+	return holds, nil
+}
+
+// checkHeld asserts the account's held_amount is exactly what its active
+// holds still reserve
+func (r *ledgerRepo) checkHeld(t *testing.T, accountNumber string, want float64) {
+	t.Helper()
+	reserved := 0.0
+	for _, hold := range r.holds {
+		if hold.AccountNumber == accountNumber && hold.Status == models.HoldActive {
+			reserved += hold.Remaining()
+		}
+	}
+	held := r.accounts[accountNumber].HeldAmount
+	if held != want || reserved != want {
+		t.Fatalf("held_amount = %v, active holds reserve %v, want %v", held, reserved, want)
+	}
+}
+
+func newHoldService(t *testing.T, repo repository.Repository) Service {
+	t.Helper()
+	return NewService(repo, Policies{
+		Hold: HoldPolicy{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour},
+	}, testLogger(t))
+}
+
+func TestCaptureHoldKeepsTheRestReserved(t *testing.T) {
+	ctx := context.Background()
+	repo := newLedgerRepo(tabungan("1001", 1000))
+	svc := newHoldService(t, repo)
+
+	hold, err := svc.CreateHold(ctx, "1001", 400, 0, "hotel")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	repo.checkHeld(t, "1001", 400)
+	if _, err := svc.CreateHold(ctx, "1001", 700, 0, "car"); !errors.Is(err, repository.ErrInsufficientBalance) {
+		t.Fatalf("hold over the available balance: got %v, want ErrInsufficientBalance", err)
+	}
+
+	captured, trx, err := svc.CaptureHold(ctx, hold.ID, 150, "night one")
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if captured.Status != models.HoldActive || captured.Remaining() != 250 {
+		t.Fatalf("hold after partial capture = %s with %v remaining, want ACTIVE with 250", captured.Status, captured.Remaining())
+	}
+	if trx.Reference != hold.ID || trx.Type != models.TransactionHoldCapture || trx.Amount != 150 {
+		t.Fatalf("capture entry = %+v", trx)
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 850 {
+		t.Fatalf("balance = %v, want 850", balance)
+	}
+	repo.checkHeld(t, "1001", 250)
+
+	if _, _, err := svc.CaptureHold(ctx, hold.ID, 300, "too much"); !errors.Is(err, ErrCaptureExceedsHold) {
+		t.Fatalf("capture over the remaining hold: got %v, want ErrCaptureExceedsHold", err)
+	}
+	repo.checkHeld(t, "1001", 250)
+
+	captured, _, err = svc.CaptureHold(ctx, hold.ID, 250, "night two")
+	if err != nil {
+		t.Fatalf("final capture: %v", err)
+	}
+	if captured.Status != models.HoldCaptured {
+		t.Fatalf("status after full capture = %s, want CAPTURED", captured.Status)
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 600 {
+		t.Fatalf("balance = %v, want 600", balance)
+	}
+	repo.checkHeld(t, "1001", 0)
+
+	if _, _, err := svc.CaptureHold(ctx, hold.ID, 1, "again"); !errors.Is(err, ErrHoldNotActive) {
+		t.Fatalf("capture of a captured hold: got %v, want ErrHoldNotActive", err)
+	}
+}
+
+func TestReleaseHoldFreesTheRemainder(t *testing.T) {
+	ctx := context.Background()
+	repo := newLedgerRepo(tabungan("1001", 1000))
+	svc := newHoldService(t, repo)
+
+	hold, err := svc.CreateHold(ctx, "1001", 500, 0, "deposit")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, _, err := svc.CaptureHold(ctx, hold.ID, 200, "damage"); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	released, err := svc.ReleaseHold(ctx, hold.ID)
+	if err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if released.Status != models.HoldReleased {
+		t.Fatalf("status = %s, want RELEASED", released.Status)
+	}
+	account := repo.accounts["1001"]
+	if account.Balance != 800 || account.AvailableBalance() != 800 {
+		t.Fatalf("balance %v, available %v, want 800 and 800", account.Balance, account.AvailableBalance())
+	}
+	repo.checkHeld(t, "1001", 0)
+
+	if _, err := svc.ReleaseHold(ctx, hold.ID); !errors.Is(err, ErrHoldNotActive) {
+		t.Fatalf("second release: got %v, want ErrHoldNotActive", err)
+	}
+	repo.checkHeld(t, "1001", 0)
+}
+
+func TestExpiredHoldsCannotBeCapturedAndAreReleased(t *testing.T) {
+	ctx := context.Background()
+	repo := newLedgerRepo(tabungan("1001", 1000))
+	svc := newHoldService(t, repo)
+
+	expired, err := svc.CreateHold(ctx, "1001", 300, 0, "stale")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	live, err := svc.CreateHold(ctx, "1001", 200, 0, "fresh")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	repo.holds[expired.ID].ExpiresAt = time.Now().Add(-time.Minute)
+
+	if _, _, err := svc.CaptureHold(ctx, expired.ID, 100, "late"); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("capture of an expired hold: got %v, want ErrHoldExpired", err)
+	}
+	if _, err := svc.ReleaseHold(ctx, expired.ID); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("release of an expired hold: got %v, want ErrHoldExpired", err)
+	}
+	repo.checkHeld(t, "1001", 500)
+
+	released, err := svc.ReleaseExpiredHolds(ctx, 10)
+	if err != nil {
+		t.Fatalf("release expired: %v", err)
+	}
+	if released != 1 {
+		t.Fatalf("released %d holds, want 1", released)
+	}
+	if status := repo.holds[expired.ID].Status; status != models.HoldExpired {
+		t.Fatalf("expired hold status = %s, want EXPIRED", status)
+	}
+	if status := repo.holds[live.ID].Status; status != models.HoldActive {
+		t.Fatalf("live hold status = %s, want ACTIVE", status)
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 1000 {
+		t.Fatalf("balance = %v, want 1000", balance)
+	}
+	repo.checkHeld(t, "1001", 200)
+
+	if released, err := svc.ReleaseExpiredHolds(ctx, 10); err != nil || released != 0 {
+		t.Fatalf("second sweep released %d (%v), want 0", released, err)
+	}
+	repo.checkHeld(t, "1001", 200)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// batchSize is how many accounts the batch jobs read per query
+const batchSize = 500
+
+// InterestTier applies AnnualRate to the whole balance from MinBalance up
+type InterestTier struct {
+	MinBalance float64 `json:"min_balance"`
+	AnnualRate float64 `json:"annual_rate"`
+}
+
+// InterestPolicy holds the tiered annual rates of each product and the final
+// tax withheld when interest is posted
+type InterestPolicy struct {
+	Products   map[string][]InterestTier `json:"products"`
+	TaxRate    float64                   `json:"tax_rate"`
+	DaysInYear int                       `json:"days_in_year"`
+}
+
+// DefaultInterestPolicy is used when no rates file is configured
+func DefaultInterestPolicy() InterestPolicy {
+	return InterestPolicy{
+		Products: map[string][]InterestTier{
+			models.ProductTabungan: {
+				{MinBalance: 0, AnnualRate: 0},
+				{MinBalance: 1000000, AnnualRate: 0.005},
+				{MinBalance: 10000000, AnnualRate: 0.01},
+				{MinBalance: 100000000, AnnualRate: 0.0175},
+			},
+		},
+		TaxRate:    0.20,
+		DaysInYear: 365,
+	}
+}
+
+// LoadInterestPolicy reads an InterestPolicy from a JSON file, or returns
+// DefaultInterestPolicy when path is empty. The tax rate and day count
+// default to those of DefaultInterestPolicy.
+func LoadInterestPolicy(path string) (InterestPolicy, error) {
+	defaults := DefaultInterestPolicy()
+	if path == "" {
+		return defaults, nil
+	}
+	policy := InterestPolicy{TaxRate: defaults.TaxRate, DaysInYear: defaults.DaysInYear}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, err
+	}
+	if policy.DaysInYear <= 0 {
+		return policy, fmt.Errorf("days_in_year must be positive")
+	}
+	return policy, nil
+}
+
+// rateFor returns the annual rate of the highest tier the balance reaches
+func (p InterestPolicy) rateFor(product string, balance float64) float64 {
+	tiers := append([]InterestTier(nil), p.Products[product]...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinBalance < tiers[j].MinBalance })
+
+	rate := 0.0
+	for _, tier := range tiers {
+		if balance < tier.MinBalance {
+			break
+		}
+		rate = tier.AnnualRate
+	}
+	return rate
+}
+
+// InterestBatchResult summarises one run of the interest batch
+type InterestBatchResult struct {
+	BusinessDate string `json:"business_date"`
+	Accrued      int    `json:"accrued"`
+	PostedPeriod string `json:"posted_period,omitempty"`
+	Posted       int    `json:"posted"`
+}
+
+type interestService struct {
+	repo   repository.Repository
+	policy InterestPolicy
+	log    *logger.CustomLogger
+}
+
+type InterestService interface {
+	RunBatch(ctx context.Context, businessDate string) (*InterestBatchResult, error)
+	AccrueInterest(ctx context.Context, businessDate string) (int, error)
+	PostInterest(ctx context.Context, period string) (int, error)
+}
+
+func NewInterestService(repo repository.Repository, policy InterestPolicy, log *logger.CustomLogger) InterestService {
+	return &interestService{
+		repo:   repo,
+		policy: policy,
+		log:    log,
+	}
+}
+
+// RunBatch accrues interest for businessDate and, on the last day of a month,
+// posts that month. Running it again for the same date changes nothing.
+func (s *interestService) RunBatch(ctx context.Context, businessDate string) (*InterestBatchResult, error) {
+	date, err := time.Parse("2006-01-02", businessDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business date: %v", err)
+	}
+
+	result := &InterestBatchResult{BusinessDate: businessDate}
+	result.Accrued, err = s.AccrueInterest(ctx, businessDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if date.AddDate(0, 0, 1).Month() != date.Month() {
+		result.PostedPeriod = date.Format("2006-01")
+		result.Posted, err = s.PostInterest(ctx, result.PostedPeriod)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// AccrueInterest stores one day of interest on each open account's
+// end-of-day balance and returns how many accruals were new
+func (s *interestService) AccrueInterest(ctx context.Context, businessDate string) (int, error) {
+	_, dayEnd, err := models.BusinessDayBounds(businessDate)
+	if err != nil {
+		return 0, s.interestError(ctx, "AccrueInterest", fmt.Errorf("invalid business date: %v", err))
+	}
+
+	accrued := 0
+	after := ""
+	for {
+		balances, err := s.repo.GetEndOfDayBalances(ctx, dayEnd, after, batchSize)
+		if err != nil {
+			return accrued, s.interestError(ctx, "AccrueInterest", err)
+		}
+		if len(balances) == 0 {
+			break
+		}
+
+		accruals := make([]models.InterestAccrual, 0, len(balances))
+		for _, balance := range balances {
+			rate := s.policy.rateFor(balance.Product, balance.Balance)
+			amount := balance.Balance * rate / float64(s.policy.DaysInYear)
+			if amount <= 0 {
+				continue
+			}
+			accruals = append(accruals, models.InterestAccrual{
+				AccountNumber: balance.AccountNumber,
+				BusinessDate:  businessDate,
+				Product:       balance.Product,
+				Balance:       balance.Balance,
+				AnnualRate:    rate,
+				Amount:        amount,
+			})
+		}
+
+		created, err := s.repo.CreateAccruals(ctx, accruals)
+		if err != nil {
+			return accrued, s.interestError(ctx, "AccrueInterest", err)
+		}
+		accrued += created
+		after = balances[len(balances)-1].AccountNumber
+	}
+
+	s.log.LogOperation(ctx, "AccrueInterest", "success", map[string]interface{}{
+		"type":          "service",
+		"business_date": businessDate,
+		"accrued":       accrued,
+	})
+	return accrued, nil
+}
+
+// PostInterest credits the unposted accruals of a YYYY-MM period and
+// withholds the final tax as its own ledger entry. Each account is posted in
+// its own transaction and at most once per period; accounts closed since
+// accruing are skipped.
+func (s *interestService) PostInterest(ctx context.Context, period string) (int, error) {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return 0, s.interestError(ctx, "PostInterest", fmt.Errorf("invalid period: %v", err))
+	}
+	fromDate := start.Format("2006-01-02")
+	toDate := start.AddDate(0, 1, -1).Format("2006-01-02")
+
+	posted := 0
+	after := ""
+	for {
+		totals, err := s.repo.GetUnpostedInterest(ctx, fromDate, toDate, after, batchSize)
+		if err != nil {
+			return posted, s.interestError(ctx, "PostInterest", err)
+		}
+		if len(totals) == 0 {
+			break
+		}
+
+		for i := range totals {
+			ok, err := s.postAccount(ctx, &totals[i], period, fromDate, toDate)
+			if err != nil {
+				return posted, s.interestError(ctx, "PostInterest", err)
+			}
+			if ok {
+				posted++
+			}
+		}
+		after = totals[len(totals)-1].AccountNumber
+	}
+
+	s.log.LogOperation(ctx, "PostInterest", "success", map[string]interface{}{
+		"type":   "service",
+		"period": period,
+		"posted": posted,
+	})
+	return posted, nil
+}
+
+func (s *interestService) postAccount(ctx context.Context, posting *models.InterestPosting, period, fromDate, toDate string) (bool, error) {
+	posting.Period = period
+	posting.Gross = roundAmount(posting.Gross)
+	posting.Tax = roundAmount(posting.Gross * s.policy.TaxRate)
+	posting.Net = posting.Gross - posting.Tax
+	posting.Reference = models.NewReference()
+
+	created := false
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		// Lock the account so it cannot be closed between the check and the
+		// credit; a closed account keeps its balance at zero
+		account, err := repo.GetAccountForUpdate(ctx, posting.AccountNumber)
+		if err != nil {
+			return err
+		}
+		if account.Status == models.StatusClosed {
+			return nil
+		}
+
+		created, err = repo.CreateInterestPosting(ctx, posting)
+		if err != nil || !created {
+			return err
+		}
+
+		if posting.Gross > 0 {
+			_, err = repo.PostSystemEntry(ctx, posting.Reference, posting.AccountNumber, models.DirectionCredit,
+				posting.Gross, models.TransactionInterest, "Bunga tabungan "+period)
+			if err != nil {
+				return err
+			}
+		}
+		if posting.Tax > 0 {
+			_, err = repo.PostSystemEntry(ctx, posting.Reference, posting.AccountNumber, models.DirectionDebit,
+				posting.Tax, models.TransactionInterestTax, fmt.Sprintf("Pajak bunga %.0f%% %s", s.policy.TaxRate*100, period))
+			if err != nil {
+				return err
+			}
+		}
+		return repo.MarkAccrualsPosted(ctx, posting.AccountNumber, fromDate, toDate, posting.Reference)
+	})
+	return created, err
+}
+
+// roundAmount rounds to whole cents
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func (s *interestService) interestError(ctx context.Context, op string, err error) error {
+	s.log.LogOperation(ctx, op, "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
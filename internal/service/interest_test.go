@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+func testLogger(t *testing.T) *logger.CustomLogger {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{LogLevel: logger.LevelCritical})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// interestRepo keeps accounts, accruals, postings and ledger entries in
+// memory with the uniqueness rules of the database
+type interestRepo struct {
+	repository.Repository
+	accounts map[string]*models.Account
+	accruals map[string]*models.InterestAccrual
+	postings map[string]*models.InterestPosting
+	entries  []models.Transaction
+}
+
+func newInterestRepo(accounts ...*models.Account) *interestRepo {
+	r := &interestRepo{
+		accounts: map[string]*models.Account{},
+		accruals: map[string]*models.InterestAccrual{},
+		postings: map[string]*models.InterestPosting{},
+	}
+	for _, account := range accounts {
+		r.accounts[account.AccountNumber] = account
+	}
+	return r
+}
+
+func (r *interestRepo) WithTx(ctx context.Context, fn func(repo repository.Repository) error) error {
+	return fn(r)
+}
+
+func (r *interestRepo) GetAccountForUpdate(ctx context.Context, accountNumber string) (*models.Account, error) {
+	account, ok := r.accounts[accountNumber]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return account, nil
+}
+
+func (r *interestRepo) GetEndOfDayBalances(ctx context.Context, dayEnd time.Time, afterAccount string, limit int) ([]models.EndOfDayBalance, error) {
+	var balances []models.EndOfDayBalance
+	for _, account := range r.accounts {
+		if account.Status != models.StatusClosed && account.AccountNumber > afterAccount {
+			balances = append(balances, models.EndOfDayBalance{
+				AccountNumber: account.AccountNumber,
+				Product:       account.Product,
+				Balance:       account.Balance,
+			})
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].AccountNumber < balances[j].AccountNumber })
+	if len(balances) > limit {
+		balances = balances[:limit]
+	}
+	return balances, nil
+}
+
+func (r *interestRepo) CreateAccruals(ctx context.Context, accruals []models.InterestAccrual) (int, error) {
+	created := 0
+	for i := range accruals {
+		key := accruals[i].AccountNumber + "/" + accruals[i].BusinessDate
+		if _, ok := r.accruals[key]; ok {
+			continue
+		}
+		accrual := accruals[i]
+		r.accruals[key] = &accrual
+		created++
+	}
+	return created, nil
+}
+
+func (r *interestRepo) GetUnpostedInterest(ctx context.Context, fromDate, toDate, afterAccount string, limit int) ([]models.InterestPosting, error) {
+	gross := map[string]float64{}
+	for _, accrual := range r.accruals {
+		if accrual.PostingReference == "" && accrual.AccountNumber > afterAccount &&
+			accrual.BusinessDate >= fromDate && accrual.BusinessDate <= toDate {
+			gross[accrual.AccountNumber] += accrual.Amount
+		}
+	}
+	var totals []models.InterestPosting
+	for accountNumber, amount := range gross {
+		totals = append(totals, models.InterestPosting{AccountNumber: accountNumber, Gross: amount})
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].AccountNumber < totals[j].AccountNumber })
+	if len(totals) > limit {
+		totals = totals[:limit]
+	}
+	return totals, nil
+}
+
+func (r *interestRepo) CreateInterestPosting(ctx context.Context, posting *models.InterestPosting) (bool, error) {
+	key := posting.AccountNumber + "/" + posting.Period
+	if _, ok := r.postings[key]; ok {
+		return false, nil
+	}
+	stored := *posting
+	r.postings[key] = &stored
+	return true, nil
+}
+
+func (r *interestRepo) PostSystemEntry(ctx context.Context, reference, accountNumber, direction string, amount float64, trxType, description string) (*models.Transaction, error) {
+	account := r.accounts[accountNumber]
+	if direction == models.DirectionDebit {
+		account.Balance -= amount
+	} else {
+		account.Balance += amount
+	}
+	trx := models.Transaction{
+		Reference:     reference,
+		AccountNumber: accountNumber,
+		Type:          trxType,
+		Direction:     direction,
+		Amount:        amount,
+		BalanceAfter:  account.Balance,
+		Description:   description,
+	}
+	r.entries = append(r.entries, trx)
+	return &trx, nil
+}
+
+func (r *interestRepo) MarkAccrualsPosted(ctx context.Context, accountNumber, fromDate, toDate, reference string) error {
+	for _, accrual := range r.accruals {
+		if accrual.AccountNumber == accountNumber && accrual.PostingReference == "" &&
+			accrual.BusinessDate >= fromDate && accrual.BusinessDate <= toDate {
+			accrual.PostingReference = reference
+		}
+	}
+	return nil
+}
+
+func tabungan(accountNumber string, balance float64) *models.Account {
+	return &models.Account{
+		AccountNumber: accountNumber,
+		Balance:       balance,
+		Status:        models.StatusActive,
+		Product:       models.ProductTabungan,
+	}
+}
+
+func TestInterestRateTiers(t *testing.T) {
+	policy := DefaultInterestPolicy()
+	// tiers may be listed in any order
+	tiers := policy.Products[models.ProductTabungan]
+	tiers[0], tiers[3] = tiers[3], tiers[0]
+
+	tests := []struct {
+		balance float64
+		want    float64
+	}{
+		{999999, 0},
+		{1000000, 0.005},
+		{9999999, 0.005},
+		{10000000, 0.01},
+		{250000000, 0.0175},
+	}
+	for _, tt := range tests {
+		if got := policy.rateFor(models.ProductTabungan, tt.balance); got != tt.want {
+			t.Errorf("rate for %.0f = %v, want %v", tt.balance, got, tt.want)
+		}
+	}
+	if got := policy.rateFor("GIRO", 250000000); got != 0 {
+		t.Errorf("rate of a product without tiers = %v, want 0", got)
+	}
+}
+
+func TestAccrueInterestIsIdempotentPerBusinessDate(t *testing.T) {
+	// 36.5 million earns 1% a year, 1000 a day; 500 thousand earns nothing
+	repo := newInterestRepo(tabungan("1000000001", 36500000), tabungan("1000000002", 500000))
+	s := NewInterestService(repo, DefaultInterestPolicy(), testLogger(t))
+	ctx := context.Background()
+
+	accrued, err := s.AccrueInterest(ctx, "2026-01-15")
+	if err != nil {
+		t.Fatalf("accrue: %v", err)
+	}
+	if accrued != 1 {
+		t.Fatalf("accrued = %d, want 1", accrued)
+	}
+	accrual := repo.accruals["1000000001/2026-01-15"]
+	if accrual == nil || accrual.Amount != 1000 || accrual.AnnualRate != 0.01 {
+		t.Fatalf("accrual = %+v, want 1000 at 1%%", accrual)
+	}
+
+	again, err := s.AccrueInterest(ctx, "2026-01-15")
+	if err != nil {
+		t.Fatalf("accrue again: %v", err)
+	}
+	if again != 0 || len(repo.accruals) != 1 {
+		t.Errorf("second accrual of the same date added %d, %d accruals stored, want none added", again, len(repo.accruals))
+	}
+}
+
+func TestRunBatchPostsOnlyAtMonthEnd(t *testing.T) {
+	repo := newInterestRepo(tabungan("1000000001", 36500000))
+	s := NewInterestService(repo, DefaultInterestPolicy(), testLogger(t))
+	ctx := context.Background()
+
+	for day := 1; day <= 30; day++ {
+		result, err := s.RunBatch(ctx, fmt.Sprintf("2026-01-%02d", day))
+		if err != nil {
+			t.Fatalf("run batch day %d: %v", day, err)
+		}
+		if result.PostedPeriod != "" || result.Posted != 0 {
+			t.Fatalf("day %d posted %q, want nothing before the month end", day, result.PostedPeriod)
+		}
+	}
+	if len(repo.entries) != 0 || repo.accounts["1000000001"].Balance != 36500000 {
+		t.Fatalf("balance %.2f with %d entries before the month end, want it untouched",
+			repo.accounts["1000000001"].Balance, len(repo.entries))
+	}
+
+	result, err := s.RunBatch(ctx, "2026-01-31")
+	if err != nil {
+		t.Fatalf("run batch at month end: %v", err)
+	}
+	if result.PostedPeriod != "2026-01" || result.Posted != 1 {
+		t.Fatalf("month end result = %+v, want 2026-01 posted for one account", result)
+	}
+
+	// a re-run of the month end posts nothing twice
+	if result, err = s.RunBatch(ctx, "2026-01-31"); err != nil || result.Accrued != 0 || result.Posted != 0 {
+		t.Fatalf("re-run = %+v, %v, want nothing accrued or posted", result, err)
+	}
+	if len(repo.entries) != 2 {
+		t.Errorf("%d ledger entries, want the interest credit and the tax debit once", len(repo.entries))
+	}
+}
+
+func TestPostInterestWithholdsTax(t *testing.T) {
+	repo := newInterestRepo(tabungan("1000000001", 36500000))
+	s := NewInterestService(repo, DefaultInterestPolicy(), testLogger(t))
+	ctx := context.Background()
+
+	for day := 1; day <= 31; day++ {
+		if _, err := s.AccrueInterest(ctx, fmt.Sprintf("2026-01-%02d", day)); err != nil {
+			t.Fatalf("accrue day %d: %v", day, err)
+		}
+	}
+	if _, err := s.PostInterest(ctx, "2026-01"); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+
+	posting := repo.postings["1000000001/2026-01"]
+	if posting == nil || posting.Gross != 31000 || posting.Tax != 6200 || posting.Net != 24800 {
+		t.Fatalf("posting = %+v, want gross 31000, tax 6200 and net 24800", posting)
+	}
+	if len(repo.entries) != 2 {
+		t.Fatalf("%d ledger entries, want 2", len(repo.entries))
+	}
+	credit, tax := repo.entries[0], repo.entries[1]
+	if credit.Type != models.TransactionInterest || credit.Direction != models.DirectionCredit || credit.Amount != 31000 {
+		t.Errorf("interest entry = %+v, want a 31000 credit", credit)
+	}
+	if tax.Type != models.TransactionInterestTax || tax.Direction != models.DirectionDebit || tax.Amount != 6200 {
+		t.Errorf("tax entry = %+v, want a 6200 debit", tax)
+	}
+	if credit.Reference != posting.Reference || tax.Reference != posting.Reference {
+		t.Errorf("entries reference %q and %q, want the posting reference %q", credit.Reference, tax.Reference, posting.Reference)
+	}
+	if balance := repo.accounts["1000000001"].Balance; balance != 36524800 {
+		t.Errorf("balance = %.2f, want 36524800", balance)
+	}
+	for key, accrual := range repo.accruals {
+		if accrual.PostingReference != posting.Reference {
+			t.Errorf("accrual %s left unposted", key)
+		}
+	}
+}
+
+func TestPostInterestSkipsClosedAccounts(t *testing.T) {
+	account := tabungan("1000000001", 36500000)
+	repo := newInterestRepo(account)
+	s := NewInterestService(repo, DefaultInterestPolicy(), testLogger(t))
+	ctx := context.Background()
+
+	if _, err := s.AccrueInterest(ctx, "2026-01-30"); err != nil {
+		t.Fatalf("accrue: %v", err)
+	}
+	account.Status, account.Balance = models.StatusClosed, 0
+
+	posted, err := s.PostInterest(ctx, "2026-01")
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if posted != 0 || len(repo.entries) != 0 || account.Balance != 0 {
+		t.Errorf("posted %d with %d entries, balance %.2f, want a closed account left alone",
+			posted, len(repo.entries), account.Balance)
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/ledger"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+var ErrNoSigningKey = errcode.New("NO_SIGNING_KEY", "no ledger signing key configured")
+
+type ledgerService struct {
+	repo       repository.Repository
+	signingKey *rsa.PrivateKey
+	log        *logger.CustomLogger
+}
+
+type LedgerService interface {
+	VerifyChain(ctx context.Context, publicKey *rsa.PublicKey) (*models.ChainVerification, error)
+	CreateCheckpoint(ctx context.Context) (*models.LedgerCheckpoint, bool, error)
+}
+
+// NewLedgerService creates the chain verifier and checkpoint signer. The
+// signing key may be nil when only verifying.
+func NewLedgerService(repo repository.Repository, signingKey *rsa.PrivateKey, log *logger.CustomLogger) LedgerService {
+	return &ledgerService{
+		repo:       repo,
+		signingKey: signingKey,
+		log:        log,
+	}
+}
+
+// VerifyChain walks the global chain from the first entry, recomputing both
+// hashes of every entry, and checks the stored chain head and every
+// checkpoint against it. It stops at the first broken link. Checkpoint
+// signatures are only checked when a public key is given.
+func (s *ledgerService) VerifyChain(ctx context.Context, publicKey *rsa.PublicKey) (*models.ChainVerification, error) {
+	result := &models.ChainVerification{HeadHash: ledger.GenesisHash}
+
+	checkpoints, err := s.repo.GetCheckpoints(ctx)
+	if err != nil {
+		return nil, s.ledgerError(ctx, "VerifyChain", err)
+	}
+	pending := make(map[int64]models.LedgerCheckpoint, len(checkpoints))
+	for _, cp := range checkpoints {
+		pending[cp.Seq] = cp
+	}
+	if result.UnchainedEntries, err = s.repo.CountUnchainedEntries(ctx); err != nil {
+		return nil, s.ledgerError(ctx, "VerifyChain", err)
+	}
+
+	accountHeads := make(map[string]string)
+	for result.Break == nil {
+		entries, err := s.repo.GetChainEntries(ctx, result.HeadSeq, batchSize)
+		if err != nil {
+			return nil, s.ledgerError(ctx, "VerifyChain", err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if result.Break = verifyEntry(entry, result.HeadSeq, result.HeadHash, accountHeads); result.Break != nil {
+				break
+			}
+			result.HeadSeq, result.HeadHash = entry.Seq, entry.GlobalHash
+			accountHeads[entry.AccountNumber] = entry.AccountHash
+			result.EntriesChecked++
+
+			if cp, ok := pending[entry.Seq]; ok {
+				if result.Break = verifyCheckpoint(&cp, entry.GlobalHash, publicKey); result.Break != nil {
+					break
+				}
+				delete(pending, entry.Seq)
+				result.CheckpointsChecked++
+			}
+		}
+	}
+
+	if result.Break == nil {
+		result.Break, err = s.verifyHead(ctx, result.HeadSeq, result.HeadHash)
+		if err != nil {
+			return nil, s.ledgerError(ctx, "VerifyChain", err)
+		}
+	}
+	if result.Break == nil {
+		for seq, cp := range pending {
+			result.Break = &models.ChainBreak{Kind: models.ChainBreakCheckpoint, Seq: seq, Expected: "entry", Actual: cp.GlobalHash}
+			break
+		}
+	}
+
+	s.log.LogOperation(ctx, "VerifyChain", "success", map[string]interface{}{
+		"type":    "service",
+		"entries": result.EntriesChecked,
+		"intact":  result.Break == nil,
+	})
+	return result, nil
+}
+
+// verifyEntry recomputes both hashes of the entry that follows prevSeq
+func verifyEntry(entry *models.LedgerEntry, prevSeq int64, prevHash string, accountHeads map[string]string) *models.ChainBreak {
+	brk := &models.ChainBreak{Seq: entry.Seq, TransactionID: entry.TransactionID, AccountNumber: entry.AccountNumber}
+	if entry.Seq != prevSeq+1 {
+		brk.Kind, brk.Seq = models.ChainBreakSequence, prevSeq+1
+		brk.Expected, brk.Actual = "entry", "missing"
+		return brk
+	}
+	if hash := ledger.Hash(prevHash, entry); hash != entry.GlobalHash {
+		brk.Kind, brk.Expected, brk.Actual = models.ChainBreakGlobal, hash, entry.GlobalHash
+		return brk
+	}
+	accountPrev, ok := accountHeads[entry.AccountNumber]
+	if !ok {
+		accountPrev = ledger.GenesisHash
+	}
+	if hash := ledger.Hash(accountPrev, entry); hash != entry.AccountHash {
+		brk.Kind, brk.Expected, brk.Actual = models.ChainBreakAccount, hash, entry.AccountHash
+		return brk
+	}
+	return nil
+}
+
+func verifyCheckpoint(cp *models.LedgerCheckpoint, globalHash string, publicKey *rsa.PublicKey) *models.ChainBreak {
+	brk := &models.ChainBreak{Kind: models.ChainBreakCheckpoint, Seq: cp.Seq}
+	if cp.GlobalHash != globalHash {
+		brk.Expected, brk.Actual = globalHash, cp.GlobalHash
+		return brk
+	}
+	if publicKey != nil {
+		if err := ledger.VerifyCheckpoint(publicKey, cp); err != nil {
+			brk.Expected, brk.Actual = "valid signature", err.Error()
+			return brk
+		}
+	}
+	return nil
+}
+
+// verifyHead catches entries removed from the end of the chain
+func (s *ledgerService) verifyHead(ctx context.Context, seq int64, hash string) (*models.ChainBreak, error) {
+	headSeq, headHash, err := s.repo.GetChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if headSeq != seq || headHash != hash {
+		return &models.ChainBreak{Kind: models.ChainBreakHead, Seq: headSeq, Expected: headHash, Actual: hash}, nil
+	}
+	return nil, nil
+}
+
+// CreateCheckpoint signs the current head of the global chain. It reports
+// false when the head has not moved since the last checkpoint.
+func (s *ledgerService) CreateCheckpoint(ctx context.Context) (*models.LedgerCheckpoint, bool, error) {
+	if s.signingKey == nil {
+		return nil, false, s.ledgerError(ctx, "CreateCheckpoint", ErrNoSigningKey)
+	}
+
+	seq, hash, err := s.repo.GetChainHead(ctx)
+	if err != nil {
+		return nil, false, s.ledgerError(ctx, "CreateCheckpoint", err)
+	}
+	latest, err := s.repo.GetLatestCheckpoint(ctx)
+	if err != nil {
+		return nil, false, s.ledgerError(ctx, "CreateCheckpoint", err)
+	}
+	if seq == 0 || (latest != nil && latest.Seq == seq) {
+		return latest, false, nil
+	}
+
+	cp := &models.LedgerCheckpoint{Seq: seq, GlobalHash: hash, CreatedAt: time.Now()}
+	if err := ledger.SignCheckpoint(s.signingKey, cp); err != nil {
+		return nil, false, s.ledgerError(ctx, "CreateCheckpoint", err)
+	}
+	created, err := s.repo.CreateCheckpoint(ctx, cp)
+	if err != nil {
+		return nil, false, s.ledgerError(ctx, "CreateCheckpoint", err)
+	}
+
+	s.log.LogOperation(ctx, "CreateCheckpoint", "success", map[string]interface{}{
+		"type": "service",
+		"seq":  seq,
+	})
+	return cp, created, nil
+}
+
+func (s *ledgerService) ledgerError(ctx context.Context, op string, err error) error {
+	s.log.LogOperation(ctx, op, "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
+
+// LedgerCheckpointer signs a checkpoint of the chain head every interval
+type LedgerCheckpointer struct {
+	service  LedgerService
+	interval time.Duration
+	log      *logger.CustomLogger
+}
+
+func NewLedgerCheckpointer(service LedgerService, interval time.Duration, log *logger.CustomLogger) *LedgerCheckpointer {
+	return &LedgerCheckpointer{
+		service:  service,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// Run signs checkpoints every interval until ctx is cancelled
+func (w *LedgerCheckpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, _, err := w.service.CreateCheckpoint(ctx); err != nil {
+				w.log.LogWarning(ctx, "Ledger checkpoint failed", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
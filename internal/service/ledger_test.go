@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/alfaa19/service-account-test/internal/ledger"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// chainRepo serves ledger entries in seq order with the chain head and
+// checkpoints the database would hold
+type chainRepo struct {
+	repository.Repository
+	entries     []models.LedgerEntry
+	checkpoints []models.LedgerCheckpoint
+	headSeq     int64
+	headHash    string
+}
+
+// newChainRepo chains count entries over two accounts
+func newChainRepo(count int) *chainRepo {
+	r := &chainRepo{headHash: ledger.GenesisHash}
+	accounts := map[string]string{}
+	for seq := int64(1); seq <= int64(count); seq++ {
+		entry := models.LedgerEntry{
+			Seq:           seq,
+			TransactionID: seq + 100,
+			Reference:     models.NewReference(),
+			AccountNumber: []string{"1001", "1002"}[seq%2],
+			Type:          models.TransactionDeposit,
+			Direction:     models.DirectionCredit,
+			Amount:        "10000.00",
+			BalanceAfter:  "10000.00",
+			CreatedAt:     "2026-10-01T09:00:00.000000",
+		}
+		previous, ok := accounts[entry.AccountNumber]
+		if !ok {
+			previous = ledger.GenesisHash
+		}
+		entry.AccountHash = ledger.Hash(previous, &entry)
+		entry.GlobalHash = ledger.Hash(r.headHash, &entry)
+		accounts[entry.AccountNumber] = entry.AccountHash
+		r.entries = append(r.entries, entry)
+		r.headSeq, r.headHash = entry.Seq, entry.GlobalHash
+	}
+	return r
+}
+
+func (r *chainRepo) GetChainEntries(ctx context.Context, afterSeq int64, limit int) ([]models.LedgerEntry, error) {
+	var entries []models.LedgerEntry
+	for _, entry := range r.entries {
+		if entry.Seq > afterSeq && len(entries) < limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *chainRepo) GetChainHead(ctx context.Context) (int64, string, error) {
+	return r.headSeq, r.headHash, nil
+}
+
+func (r *chainRepo) CountUnchainedEntries(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (r *chainRepo) GetCheckpoints(ctx context.Context) ([]models.LedgerCheckpoint, error) {
+	return r.checkpoints, nil
+}
+
+func (r *chainRepo) GetLatestCheckpoint(ctx context.Context) (*models.LedgerCheckpoint, error) {
+	if len(r.checkpoints) == 0 {
+		return nil, nil
+	}
+	latest := r.checkpoints[len(r.checkpoints)-1]
+	return &latest, nil
+}
+
+func (r *chainRepo) CreateCheckpoint(ctx context.Context, cp *models.LedgerCheckpoint) (bool, error) {
+	r.checkpoints = append(r.checkpoints, *cp)
+	return true, nil
+}
+
+func checkpointKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func verifyChain(t *testing.T, repo *chainRepo, publicKey *rsa.PublicKey) *models.ChainVerification {
+	t.Helper()
+	result, err := NewLedgerService(repo, nil, testLogger(t)).VerifyChain(context.Background(), publicKey)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	return result
+}
+
+func TestVerifyChainChecksSignedCheckpoints(t *testing.T) {
+	key := checkpointKey(t)
+	repo := newChainRepo(6)
+	svc := NewLedgerService(repo, key, testLogger(t))
+
+	cp, created, err := svc.CreateCheckpoint(context.Background())
+	if err != nil || !created {
+		t.Fatalf("checkpoint: created %v, %v", created, err)
+	}
+	if cp.Seq != 6 || cp.GlobalHash != repo.headHash {
+		t.Fatalf("checkpoint = %d %s, want the chain head", cp.Seq, cp.GlobalHash)
+	}
+	if _, created, _ := svc.CreateCheckpoint(context.Background()); created {
+		t.Fatal("checkpoint created again for an unchanged head")
+	}
+
+	result := verifyChain(t, repo, &key.PublicKey)
+	if result.Break != nil || result.EntriesChecked != 6 || result.CheckpointsChecked != 1 {
+		t.Fatalf("result = %+v, break %+v, want 6 entries and 1 checkpoint intact", result, result.Break)
+	}
+
+	// a checkpoint signed by another key is a break once a key is given
+	other := checkpointKey(t)
+	if err := ledger.SignCheckpoint(other, &repo.checkpoints[0]); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if brk := verifyChain(t, repo, nil).Break; brk != nil {
+		t.Fatalf("break without a key = %+v, want none", brk)
+	}
+	brk := verifyChain(t, repo, &key.PublicKey).Break
+	if brk == nil || brk.Kind != models.ChainBreakCheckpoint || brk.Seq != 6 {
+		t.Fatalf("break = %+v, want a checkpoint break at 6", brk)
+	}
+}
+
+func TestVerifyChainFindsTheFirstBrokenLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(r *chainRepo)
+		kind   string
+		seq    int64
+	}{
+		{"amount edited", func(r *chainRepo) { r.entries[2].Amount = "99999.00" }, models.ChainBreakGlobal, 3},
+		{"entries reordered", func(r *chainRepo) {
+			r.entries[2], r.entries[3] = r.entries[3], r.entries[2]
+			r.entries[2].Seq, r.entries[3].Seq = 3, 4
+		}, models.ChainBreakGlobal, 3},
+		{"entry deleted", func(r *chainRepo) {
+			r.entries = append(r.entries[:3], r.entries[4:]...)
+		}, models.ChainBreakSequence, 4},
+		{"tail deleted", func(r *chainRepo) { r.entries = r.entries[:4] }, models.ChainBreakHead, 6},
+		{"global hash rewritten", func(r *chainRepo) {
+			// rehashing the global chain from an edited entry on still
+			// leaves its account chain pointing at the original content
+			r.entries[2].Amount = "99999.00"
+			previous := r.entries[1].GlobalHash
+			for i := 2; i < len(r.entries); i++ {
+				r.entries[i].GlobalHash = ledger.Hash(previous, &r.entries[i])
+				previous = r.entries[i].GlobalHash
+			}
+			r.headHash = previous
+		}, models.ChainBreakAccount, 3},
+		{"checkpoint of another head", func(r *chainRepo) {
+			r.checkpoints = []models.LedgerCheckpoint{{Seq: 2, GlobalHash: r.entries[0].GlobalHash}}
+		}, models.ChainBreakCheckpoint, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newChainRepo(6)
+			tt.tamper(repo)
+			brk := verifyChain(t, repo, nil).Break
+			if brk == nil || brk.Kind != tt.kind || brk.Seq != tt.seq {
+				t.Fatalf("break = %+v, want %s at %d", brk, tt.kind, tt.seq)
+			}
+		})
+	}
+}
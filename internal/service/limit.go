@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// Names of the limits reported in a LimitError
+const (
+	LimitPerTransaction = "per_transaction"
+	LimitDailyTotal     = "daily_total"
+	LimitDailyCount     = "daily_count"
+)
+
+// RuleWildcard matches any value in limit and fee rules
+const RuleWildcard = "*"
+
+var ErrLimitExceeded = errcode.New("LIMIT_EXCEEDED", "withdrawal limit exceeded")
+
+// limitErrors give each breached limit its own code, whose translation names
+// the limit and the headroom
+var limitErrors = map[string]error{
+	LimitPerTransaction: errcode.New("PER_TRANSACTION_LIMIT_EXCEEDED", "per transaction limit exceeded"),
+	LimitDailyTotal:     errcode.New("DAILY_LIMIT_EXCEEDED", "daily withdrawal limit exceeded"),
+	LimitDailyCount:     errcode.New("DAILY_COUNT_LIMIT_EXCEEDED", "daily withdrawal count limit reached"),
+}
+
+// LimitError tells which limit a withdrawal breached and how much headroom
+// was left: the largest amount, or the number of withdrawals, still allowed
+type LimitError struct {
+	Channel  string
+	Limit    string
+	Max      float64
+	Headroom float64
+}
+
+func (e *LimitError) Error() string {
+	channel := strings.ToLower(e.Channel)
+	if e.Limit == LimitDailyCount {
+		return fmt.Sprintf("daily %s withdrawal count limit of %.0f reached, %.0f withdrawals left today", channel, e.Max, e.Headroom)
+	}
+	if e.Limit == LimitPerTransaction {
+		return fmt.Sprintf("%s withdrawal exceeds the per transaction limit of %.2f, at most %.2f allowed", channel, e.Max, e.Headroom)
+	}
+	return fmt.Sprintf("daily %s withdrawal limit of %.2f exceeded, %.2f left today", channel, e.Max, e.Headroom)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// As resolves e to the coded error of the breached limit
+func (e *LimitError) As(target interface{}) bool {
+	coded, ok := target.(**errcode.Error)
+	if !ok {
+		return false
+	}
+	limit, ok := errcode.Of(limitErrors[e.Limit])
+	if !ok {
+		limit, _ = errcode.Of(ErrLimitExceeded)
+	}
+	format := "%.2f"
+	if e.Limit == LimitDailyCount {
+		format = "%.0f"
+	}
+	*coded = limit.With(e.Error(), map[string]string{
+		"channel": strings.ToLower(e.Channel),
+		"max":     fmt.Sprintf(format, e.Max),
+		"left":    fmt.Sprintf(format, e.Headroom),
+	})
+	return true
+}
+
+// Limit caps withdrawals through one channel. Zero means no limit.
+type Limit struct {
+	MaxPerTransaction float64 `json:"max_per_transaction"`
+	MaxDailyTotal     float64 `json:"max_daily_total"`
+	MaxDailyCount     int     `json:"max_daily_count"`
+}
+
+// LimitRule holds the cash and transfer limits of an account type and KYC
+// tier; either may be "*"
+type LimitRule struct {
+	AccountType string `json:"account_type"`
+	KYCTier     string `json:"kyc_tier"`
+	Cash        Limit  `json:"cash"`
+	Transfer    Limit  `json:"transfer"`
+}
+
+// LimitPolicy picks the most specific rule for an account: exact type and
+// tier first, then type only, then tier only, then the catch-all rule
+type LimitPolicy struct {
+	Rules []LimitRule `json:"rules"`
+}
+
+// DefaultLimitPolicy is used when no limits file is configured
+func DefaultLimitPolicy() LimitPolicy {
+	return LimitPolicy{Rules: []LimitRule{
+		{
+			AccountType: RuleWildcard,
+			KYCTier:     RuleWildcard,
+			Cash:        Limit{MaxPerTransaction: 5000000, MaxDailyTotal: 10000000, MaxDailyCount: 10},
+			Transfer:    Limit{MaxPerTransaction: 25000000, MaxDailyTotal: 50000000, MaxDailyCount: 20},
+		},
+		{
+			AccountType: RuleWildcard,
+			KYCTier:     models.KYCTierBasic,
+			Cash:        Limit{MaxPerTransaction: 2500000, MaxDailyTotal: 5000000, MaxDailyCount: 5},
+			Transfer:    Limit{MaxPerTransaction: 5000000, MaxDailyTotal: 10000000, MaxDailyCount: 10},
+		},
+	}}
+}
+
+// LoadLimitPolicy reads a LimitPolicy from a JSON file, or returns
+// DefaultLimitPolicy when path is empty
+func LoadLimitPolicy(path string) (LimitPolicy, error) {
+	if path == "" {
+		return DefaultLimitPolicy(), nil
+	}
+	var policy LimitPolicy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+func (p LimitPolicy) limitFor(accountType, kycTier, channel string) Limit {
+	var best *LimitRule
+	bestScore := -1
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		score := 0
+		switch rule.AccountType {
+		case accountType:
+			score += 2
+		case RuleWildcard:
+		default:
+			continue
+		}
+		switch rule.KYCTier {
+		case kycTier:
+			score++
+		case RuleWildcard:
+		default:
+			continue
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+
+	if best == nil {
+		return Limit{}
+	}
+	if channel == models.ChannelCash {
+		return best.Cash
+	}
+	return best.Transfer
+}
+
+// check returns a LimitError when amount on top of usage breaks the limit
+func (l Limit) check(channel string, usage *models.WithdrawalUsage, amount float64) error {
+	switch {
+	case l.MaxPerTransaction > 0 && amount > l.MaxPerTransaction:
+		return &LimitError{Channel: channel, Limit: LimitPerTransaction, Max: l.MaxPerTransaction, Headroom: l.allowed(usage)}
+	case l.MaxDailyCount > 0 && usage.Count >= l.MaxDailyCount:
+		return &LimitError{Channel: channel, Limit: LimitDailyCount, Max: float64(l.MaxDailyCount), Headroom: 0}
+	case l.MaxDailyTotal > 0 && usage.TotalAmount+amount > l.MaxDailyTotal:
+		return &LimitError{Channel: channel, Limit: LimitDailyTotal, Max: l.MaxDailyTotal, Headroom: l.dailyHeadroom(usage)}
+	}
+	return nil
+}
+
+// allowed returns the largest single withdrawal l still allows after usage
+func (l Limit) allowed(usage *models.WithdrawalUsage) float64 {
+	if l.MaxDailyCount > 0 && usage.Count >= l.MaxDailyCount {
+		return 0
+	}
+	allowed := l.MaxPerTransaction
+	if l.MaxDailyTotal > 0 {
+		if headroom := l.dailyHeadroom(usage); allowed <= 0 || headroom < allowed {
+			allowed = headroom
+		}
+	}
+	return allowed
+}
+
+// dailyHeadroom returns what is left of the daily total after usage
+func (l Limit) dailyHeadroom(usage *models.WithdrawalUsage) float64 {
+	headroom := l.MaxDailyTotal - usage.TotalAmount
+	if headroom < 0 {
+		headroom = 0
+	}
+	return headroom
+}
+
+// consumeLimit checks a withdrawal against the account's limits and counts
+// it in today's usage. It must run in the same transaction as the debit so a
+// failed debit does not use up the limit.
+func (s *service) consumeLimit(ctx context.Context, repo repository.Repository, account *models.Account, channel string, amount float64) error {
+	usage, err := repo.GetWithdrawalUsageForUpdate(ctx, account.AccountNumber, models.BusinessDate(time.Now()), channel)
+	if err != nil {
+		return err
+	}
+
+	limit := s.limitPolicy.limitFor(account.AccountType, account.KYCTier, channel)
+	if err := limit.check(channel, usage, amount); err != nil {
+		return err
+	}
+	return repo.AddWithdrawalUsage(ctx, usage, amount)
+}
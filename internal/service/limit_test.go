@@ -0,0 +1,31 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+func TestLimitCheckPerTransactionHeadroom(t *testing.T) {
+	limit := Limit{MaxPerTransaction: 5000000, MaxDailyTotal: 10000000, MaxDailyCount: 3}
+	tests := []struct {
+		name  string
+		usage models.WithdrawalUsage
+		want  float64
+	}{
+		{"fresh day", models.WithdrawalUsage{}, 5000000},
+		{"daily total nearly used", models.WithdrawalUsage{Count: 2, TotalAmount: 8000000}, 2000000},
+		{"daily count used", models.WithdrawalUsage{Count: 3, TotalAmount: 3000000}, 0},
+	}
+	for _, tt := range tests {
+		err := limit.check(models.ChannelCash, &tt.usage, 6000000)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("%s: err = %v, want a LimitError", tt.name, err)
+		}
+		if limitErr.Limit != LimitPerTransaction || limitErr.Headroom != tt.want {
+			t.Errorf("%s: %s headroom = %.2f, want %s headroom %.2f", tt.name, limitErr.Limit, limitErr.Headroom, LimitPerTransaction, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// maxOutboxBackoff caps the wait between delivery attempts of an event
+const maxOutboxBackoff = 10 * time.Minute
+
+// outboxLease is how long a claimed event is left to its relay before
+// another relay may publish it
+const outboxLease = 5 * time.Minute
+
+// OutboxRelay publishes the events the repository writes to the outbox.
+// An event is marked published only after the publisher accepted it, so a
+// crash in between publishes it again: delivery is at least once. Several
+// relays can run side by side; each leases different rows.
+type OutboxRelay struct {
+	repo      repository.Repository
+	publisher events.Publisher
+	interval  time.Duration
+	batchSize int
+	log       *logger.CustomLogger
+}
+
+func NewOutboxRelay(repo repository.Repository, publisher events.Publisher, interval time.Duration, batchSize int, log *logger.CustomLogger) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+		log:       log,
+	}
+}
+
+// Run relays every interval until ctx is cancelled
+func (w *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.drain(ctx)
+		}
+	}
+}
+
+// drain keeps relaying full batches so a backlog clears in one tick
+func (w *OutboxRelay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed, err := w.RelayBatch(ctx)
+		if err != nil {
+			w.log.LogWarning(ctx, "Outbox relay failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+		if claimed < w.batchSize {
+			return
+		}
+	}
+}
+
+// RelayBatch leases up to a batch of due events, publishes them in order and
+// records each outcome. The lease commits before anything is published, so
+// a slow publisher holds no database locks. A failed event is retried after
+// an exponential backoff. It returns how many events were claimed.
+func (w *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	pending, err := w.repo.ClaimOutboxEvents(ctx, now, now.Add(outboxLease), w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for i := range pending {
+		event := &pending[i]
+		if err := w.publisher.Publish(ctx, envelope(event)); err != nil {
+			w.log.LogWarning(ctx, "Event not published", map[string]interface{}{
+				"event_id":   event.EventID,
+				"event_type": event.EventType,
+				"attempts":   event.Attempts + 1,
+				"error":      err.Error(),
+			})
+			next := time.Now().Add(outboxBackoff(event.Attempts + 1))
+			if err := w.repo.MarkOutboxFailed(ctx, event.ID, err.Error(), next); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := w.repo.MarkOutboxPublished(ctx, event.ID, time.Now()); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if published > 0 {
+		w.log.LogOperation(ctx, "RelayOutbox", "success", map[string]interface{}{
+			"type":      "service",
+			"claimed":   len(pending),
+			"published": published,
+		})
+	}
+	return len(pending), nil
+}
+
+func envelope(event *models.OutboxEvent) *events.Envelope {
+	return &events.Envelope{
+		ID:          event.EventID,
+		Type:        event.EventType,
+		Version:     event.SchemaVersion,
+		AggregateID: event.AggregateID,
+		OccurredAt:  event.CreatedAt,
+		Data:        event.Payload,
+	}
+}
+
+// outboxBackoff doubles the wait with every failed attempt: 1s, 2s, 4s and
+// so on up to maxOutboxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 20 {
+		return maxOutboxBackoff
+	}
+	backoff := time.Second << (attempts - 1)
+	if backoff > maxOutboxBackoff {
+		return maxOutboxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// outboxRepo leases and marks outbox events like the database does. elapsed
+// moves its clock ahead of the relay's, so leases and backoffs run out.
+type outboxRepo struct {
+	repository.Repository
+	events  []*models.OutboxEvent
+	elapsed time.Duration
+}
+
+func (r *outboxRepo) add(eventType, aggregateID string, data interface{}) *models.OutboxEvent {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	event := &models.OutboxEvent{
+		ID:            int64(len(r.events) + 1),
+		EventID:       models.NewReference(),
+		EventType:     eventType,
+		SchemaVersion: events.Versions[eventType],
+		AggregateID:   aggregateID,
+		Payload:       payload,
+		CreatedAt:     time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+	}
+	r.events = append(r.events, event)
+	return event
+}
+
+func (r *outboxRepo) ClaimOutboxEvents(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	now = now.Add(r.elapsed)
+	claimed := []models.OutboxEvent{}
+	for _, event := range r.events {
+		if len(claimed) == limit {
+			break
+		}
+		if event.PublishedAt == nil && !event.NextAttemptAt.After(now) {
+			event.NextAttemptAt = leaseUntil.Add(r.elapsed)
+			claimed = append(claimed, *event)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *outboxRepo) MarkOutboxPublished(ctx context.Context, id int64, publishedAt time.Time) error {
+	event := r.events[id-1]
+	event.Attempts++
+	event.LastError = ""
+	event.PublishedAt = &publishedAt
+	return nil
+}
+
+func (r *outboxRepo) MarkOutboxFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	event := r.events[id-1]
+	event.Attempts++
+	event.LastError = lastError
+	event.NextAttemptAt = nextAttemptAt.Add(r.elapsed)
+	return nil
+}
+
+// flakyPublisher fails its first publishes, as many as failures, and keeps
+// the events published after them
+type flakyPublisher struct {
+	failures  int
+	attempts  int
+	published []*events.Envelope
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event *events.Envelope) error {
+	p.attempts++
+	if p.attempts <= p.failures {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *flakyPublisher) Close() error { return nil }
+
+func relayOnce(t *testing.T, relay *OutboxRelay) int {
+	t.Helper()
+	claimed, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	return claimed
+}
+
+func TestRelayRedeliversAfterFailedPublish(t *testing.T) {
+	repo := &outboxRepo{}
+	event := repo.add(events.AccountCreated, "1000000001", events.AccountCreatedV1{AccountNumber: "1000000001"})
+	publisher := &flakyPublisher{failures: 1}
+	relay := NewOutboxRelay(repo, publisher, time.Second, 10, testLogger(t))
+
+	if claimed := relayOnce(t, relay); claimed != 1 {
+		t.Fatalf("claimed %d, want 1", claimed)
+	}
+	if event.PublishedAt != nil || event.Attempts != 1 || event.LastError != "broker unavailable" {
+		t.Fatalf("after a failed publish event = %+v, want it unpublished with the error recorded", event)
+	}
+
+	// the event waits out its backoff, then goes out again
+	if claimed := relayOnce(t, relay); claimed != 0 {
+		t.Fatalf("claimed %d during the backoff, want 0", claimed)
+	}
+	repo.elapsed = outboxBackoff(1) + time.Second
+	if claimed := relayOnce(t, relay); claimed != 1 {
+		t.Fatalf("claimed %d after the backoff, want 1", claimed)
+	}
+	if event.PublishedAt == nil || event.Attempts != 2 || event.LastError != "" {
+		t.Errorf("after the retry event = %+v, want it published on the second attempt", event)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].ID != event.EventID {
+		t.Errorf("published %d envelopes, want event %s once", len(publisher.published), event.EventID)
+	}
+}
+
+func TestRelayReclaimsExpiredLease(t *testing.T) {
+	repo := &outboxRepo{}
+	event := repo.add(events.AccountCreated, "1000000001", events.AccountCreatedV1{AccountNumber: "1000000001"})
+	publisher := &flakyPublisher{}
+	relay := NewOutboxRelay(repo, publisher, time.Second, 10, testLogger(t))
+
+	// another relay leases the event and dies before publishing it
+	now := time.Now()
+	if claimed, _ := repo.ClaimOutboxEvents(context.Background(), now, now.Add(outboxLease), 10); len(claimed) != 1 {
+		t.Fatalf("crashed relay claimed %d, want 1", len(claimed))
+	}
+
+	if claimed := relayOnce(t, relay); claimed != 0 {
+		t.Fatalf("claimed %d of a leased event, want 0", claimed)
+	}
+	repo.elapsed = outboxLease - time.Minute
+	if claimed := relayOnce(t, relay); claimed != 0 {
+		t.Fatalf("claimed %d before the lease ran out, want 0", claimed)
+	}
+	repo.elapsed = outboxLease + time.Second
+	if claimed := relayOnce(t, relay); claimed != 1 {
+		t.Fatalf("claimed %d after the lease ran out, want 1", claimed)
+	}
+	if event.PublishedAt == nil || len(publisher.published) != 1 {
+		t.Errorf("event published %d times, want once after the lease expired", len(publisher.published))
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{10, 512 * time.Second},
+		{11, maxOutboxBackoff},
+		{64, maxOutboxBackoff},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff after %d attempts = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRelayWritesLedgerEventsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher, err := events.NewPublisher("file", path)
+	if err != nil {
+		t.Fatalf("file publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	occurredAt := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	repo := &outboxRepo{}
+	deposit := repo.add(events.Deposited, "1000000001", events.BalanceChangedV1{
+		AccountNumber: "1000000001", TransactionID: 7, Reference: "REF-DEP", Type: models.TransactionDeposit,
+		Direction: models.DirectionCredit, Amount: 150000, BalanceAfter: 650000, Description: "Setor tunai", OccurredAt: occurredAt,
+	})
+	withdrawal := repo.add(events.Withdrawn, "1000000001", events.BalanceChangedV1{
+		AccountNumber: "1000000001", TransactionID: 8, Reference: "REF-WD", Type: models.TransactionWithdrawal,
+		Direction: models.DirectionDebit, Amount: 50000, BalanceAfter: 600000, OccurredAt: occurredAt,
+	})
+	relay := NewOutboxRelay(repo, publisher, time.Second, 10, testLogger(t))
+	if claimed := relayOnce(t, relay); claimed != 2 {
+		t.Fatalf("claimed %d, want 2", claimed)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open events file: %v", err)
+	}
+	defer file.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("event line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("%d events written, want 2", len(lines))
+	}
+
+	envelopeKeys := []string{"aggregate_id", "data", "id", "occurred_at", "type", "version"}
+	for i, want := range []struct {
+		event     *models.OutboxEvent
+		typ       string
+		direction string
+		amount    float64
+		dataKeys  []string
+	}{
+		{deposit, events.Deposited, models.DirectionCredit, 150000, []string{"account_number", "amount", "balance_after",
+			"description", "direction", "occurred_at", "reference", "transaction_id", "type"}},
+		{withdrawal, events.Withdrawn, models.DirectionDebit, 50000, []string{"account_number", "amount", "balance_after",
+			"direction", "occurred_at", "reference", "transaction_id", "type"}},
+	} {
+		line := lines[i]
+		if got := keys(line); !reflect.DeepEqual(got, envelopeKeys) {
+			t.Errorf("%s envelope fields = %v, want %v", want.typ, got, envelopeKeys)
+		}
+		if line["id"] != want.event.EventID || line["type"] != want.typ || line["version"] != 1.0 ||
+			line["aggregate_id"] != "1000000001" || line["occurred_at"] != "2026-10-01T09:30:00Z" {
+			t.Errorf("%s envelope = %v", want.typ, line)
+		}
+		data, _ := line["data"].(map[string]interface{})
+		if got := keys(data); !reflect.DeepEqual(got, want.dataKeys) {
+			t.Errorf("%s data fields = %v, want %v", want.typ, got, want.dataKeys)
+		}
+		if data["direction"] != want.direction || data["amount"] != want.amount || data["account_number"] != "1000000001" {
+			t.Errorf("%s data = %v", want.typ, data)
+		}
+	}
+}
+
+func keys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// AllRoutes grants a partner client access to every route
+const AllRoutes = "*"
+
+var (
+	ErrUnknownClient       = errcode.New("UNKNOWN_CLIENT", "unknown or inactive client")
+	ErrInvalidSignature    = errcode.New("INVALID_SIGNATURE", "invalid signature")
+	ErrStaleTimestamp      = errcode.New("STALE_TIMESTAMP", "timestamp outside the allowed window")
+	ErrNonceReused         = errcode.New("NONCE_REUSED", "nonce already used")
+	ErrRouteNotAllowed     = errcode.New("ROUTE_NOT_ALLOWED", "route not allowed for client")
+	ErrIPNotAllowed        = errcode.New("IP_NOT_ALLOWED", "IP address not allowed for client")
+	ErrMissingNonce        = errcode.New("MISSING_NONCE", "missing nonce")
+	ErrMalformedTimestamp  = errcode.New("MALFORMED_TIMESTAMP", "timestamp must be RFC3339")
+	ErrInvalidAccessToken  = errcode.New("INVALID_ACCESS_TOKEN", "invalid access token")
+	ErrPartnerMismatch     = errcode.New("PARTNER_MISMATCH", "partner ID does not match access token")
+	ErrMissingExternalID   = errcode.New("MISSING_EXTERNAL_ID", "missing external ID")
+	ErrDuplicateExternalID = errcode.New("DUPLICATE_EXTERNAL_ID", "external ID already used today")
+	ErrAccountNotAllowed   = errcode.New("ACCOUNT_NOT_ALLOWED", "account not allowed for client")
+)
+
+// SnapRequest carries the parts of a SNAP transactional request covered by
+// the symmetric signature and the SNAP headers
+type SnapRequest struct {
+	AccessToken string
+	PartnerID   string
+	ExternalID  string
+	Method      string
+	Route       string
+	Path        string
+	Body        []byte
+	Timestamp   string
+	Signature   string
+	IP          string
+}
+
+// PartnerRequest carries the parts of an incoming request covered by the
+// partner signature
+type PartnerRequest struct {
+	ClientID  string
+	Method    string
+	Route     string
+	Path      string
+	BodyHash  string
+	Timestamp string
+	Nonce     string
+	Signature string
+	IP        string
+}
+
+type partnerService struct {
+	repo    repository.Repository
+	tokens  *auth.TokenManager
+	maxSkew time.Duration
+	log     *logger.CustomLogger
+}
+
+type PartnerService interface {
+	Authenticate(ctx context.Context, req *PartnerRequest) (*models.APIClient, error)
+	RegisterClient(ctx context.Context, name string, allowedRoutes, ipAllowlist, roles, allowedAccounts []string, publicKey string) (*models.APIClient, string, error)
+	IssueSnapAccessToken(ctx context.Context, clientKey, timestamp, signature, ip string) (string, time.Duration, error)
+	AuthenticateSnap(ctx context.Context, req *SnapRequest) (*models.APIClient, error)
+}
+
+func NewPartnerService(repo repository.Repository, tokens *auth.TokenManager, maxSkew time.Duration, log *logger.CustomLogger) PartnerService {
+	return &partnerService{
+		repo:    repo,
+		tokens:  tokens,
+		maxSkew: maxSkew,
+		log:     log,
+	}
+}
+
+func (s *partnerService) Authenticate(ctx context.Context, req *PartnerRequest) (*models.APIClient, error) {
+	client, err := s.authenticate(ctx, req)
+	if err != nil {
+		s.log.LogOperation(ctx, "AuthenticatePartner", "error", map[string]interface{}{
+			"error":         err.Error(),
+			"api_client_id": req.ClientID,
+			"ip":            req.IP,
+		})
+		return nil, err
+	}
+
+	s.log.LogOperation(ctx, "AuthenticatePartner", "success", map[string]interface{}{
+		"type":          "service",
+		"api_client_id": client.ClientID,
+	})
+	return client, nil
+}
+
+func (s *partnerService) authenticate(ctx context.Context, req *PartnerRequest) (*models.APIClient, error) {
+	client, err := s.repo.GetAPIClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnknownClient
+		}
+		return nil, err
+	}
+	if !client.Active {
+		return nil, ErrUnknownClient
+	}
+
+	if !ipAllowed(client.IPAllowlist, req.IP) {
+		return nil, ErrIPNotAllowed
+	}
+	if !routeAllowed(client.AllowedRoutes, req.Method, req.Route) {
+		return nil, ErrRouteNotAllowed
+	}
+
+	timestamp, err := s.checkTimestamp(req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if req.Nonce == "" {
+		return nil, ErrMissingNonce
+	}
+
+	stringToSign := auth.StringToSign(req.Method, req.Path, req.BodyHash, req.Timestamp, req.Nonce)
+	if !auth.VerifySignature(client.SigningKey, stringToSign, req.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Only remember the nonce once the signature is valid, so unsigned
+	// garbage cannot burn nonces of a legitimate client
+	fresh, err := s.repo.UseNonce(ctx, client.ClientID, req.Nonce, timestamp.Add(s.maxSkew))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrNonceReused
+	}
+
+	return client, nil
+}
+
+// IssueSnapAccessToken verifies the asymmetric SNAP signature of an
+// access-token request and issues a partner access token
+func (s *partnerService) IssueSnapAccessToken(ctx context.Context, clientKey, timestamp, signature, ip string) (string, time.Duration, error) {
+	token, err := s.issueSnapAccessToken(ctx, clientKey, timestamp, signature, ip)
+	if err != nil {
+		s.log.LogOperation(ctx, "IssueSnapAccessToken", "error", map[string]interface{}{
+			"error":         err.Error(),
+			"api_client_id": clientKey,
+			"ip":            ip,
+		})
+		return "", 0, err
+	}
+
+	s.log.LogOperation(ctx, "IssueSnapAccessToken", "success", map[string]interface{}{
+		"type":          "service",
+		"api_client_id": clientKey,
+	})
+	return token, s.tokens.AccessTokenTTL(), nil
+}
+
+func (s *partnerService) issueSnapAccessToken(ctx context.Context, clientKey, timestamp, signature, ip string) (string, error) {
+	client, err := s.repo.GetAPIClient(ctx, clientKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrUnknownClient
+		}
+		return "", err
+	}
+	if !client.Active || client.PublicKey == "" {
+		return "", ErrUnknownClient
+	}
+	if !ipAllowed(client.IPAllowlist, ip) {
+		return "", ErrIPNotAllowed
+	}
+	if _, err := s.checkTimestamp(timestamp); err != nil {
+		return "", err
+	}
+
+	stringToSign := auth.SnapAccessTokenStringToSign(client.ClientID, timestamp)
+	if err := auth.VerifySnapAsymmetric(client.PublicKey, stringToSign, signature); err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	token, _, err := s.tokens.IssuePartnerToken(client.ClientID)
+	return token, err
+}
+
+// AuthenticateSnap verifies the bearer token, symmetric signature, timestamp
+// and X-EXTERNAL-ID uniqueness of a SNAP transactional request
+func (s *partnerService) AuthenticateSnap(ctx context.Context, req *SnapRequest) (*models.APIClient, error) {
+	client, err := s.authenticateSnap(ctx, req)
+	if err != nil {
+		s.log.LogOperation(ctx, "AuthenticateSnap", "error", map[string]interface{}{
+			"error":         err.Error(),
+			"api_client_id": req.PartnerID,
+			"ip":            req.IP,
+		})
+		return nil, err
+	}
+
+	s.log.LogOperation(ctx, "AuthenticateSnap", "success", map[string]interface{}{
+		"type":          "service",
+		"api_client_id": client.ClientID,
+	})
+	return client, nil
+}
+
+func (s *partnerService) authenticateSnap(ctx context.Context, req *SnapRequest) (*models.APIClient, error) {
+	claims, err := s.tokens.ParsePartnerToken(req.AccessToken)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	if claims.Subject != req.PartnerID {
+		return nil, ErrPartnerMismatch
+	}
+
+	client, err := s.repo.GetAPIClient(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnknownClient
+		}
+		return nil, err
+	}
+	if !client.Active {
+		return nil, ErrUnknownClient
+	}
+	if !ipAllowed(client.IPAllowlist, req.IP) {
+		return nil, ErrIPNotAllowed
+	}
+	if !routeAllowed(client.AllowedRoutes, req.Method, req.Route) {
+		return nil, ErrRouteNotAllowed
+	}
+
+	timestamp, err := s.checkTimestamp(req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	stringToSign, err := auth.SnapStringToSign(req.Method, req.Path, req.AccessToken, req.Body, req.Timestamp)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if !auth.VerifySnapSymmetric(client.SigningKey, stringToSign, req.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	if req.ExternalID == "" {
+		return nil, ErrMissingExternalID
+	}
+	fresh, err := s.repo.UseExternalID(ctx, client.ClientID, req.ExternalID, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrDuplicateExternalID
+	}
+
+	return client, nil
+}
+
+func (s *partnerService) checkTimestamp(value string) (time.Time, error) {
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ErrMalformedTimestamp
+	}
+	if skew := time.Since(timestamp); skew > s.maxSkew || skew < -s.maxSkew {
+		return time.Time{}, ErrStaleTimestamp
+	}
+	return timestamp, nil
+}
+
+// RegisterClient creates a partner client and returns its secret. The secret
+// is only available here; afterwards only its derived signing key is stored.
+// publicKey is the PEM public key used for SNAP access-token requests and may
+// be empty for partners that only use HMAC request signing.
+func (s *partnerService) RegisterClient(ctx context.Context, name string, allowedRoutes, ipAllowlist, roles, allowedAccounts []string, publicKey string) (*models.APIClient, string, error) {
+	clientID, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	client := &models.APIClient{
+		ClientID:        clientID,
+		Name:            name,
+		SigningKey:      auth.SigningKey(secret),
+		AllowedRoutes:   allowedRoutes,
+		IPAllowlist:     ipAllowlist,
+		Roles:           roles,
+		AllowedAccounts: allowedAccounts,
+		PublicKey:       publicKey,
+		Active:          true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.repo.CreateAPIClient(ctx, client); err != nil {
+		s.log.LogOperation(ctx, "RegisterClient", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, "", err
+	}
+
+	s.log.LogOperation(ctx, "RegisterClient", "success", map[string]interface{}{
+		"type":          "service",
+		"api_client_id": client.ClientID,
+	})
+	return client, secret, nil
+}
+
+// routeSuccessors maps the deprecated unversioned routes to the /v1 routes
+// replacing them. Granting either one grants both, so partners can move to
+// /v1 without being granted the new routes first.
+var routeSuccessors = map[string]string{
+	"POST /daftar":                    "POST /v1/accounts",
+	"GET /saldo/:noRekening":          "GET /v1/accounts/:noRekening/balance",
+	"GET /saldo/:noRekening/pantau":   "GET /v1/accounts/:noRekening/balance/stream",
+	"POST /tarik":                     "POST /v1/accounts/:noRekening/withdrawals",
+	"POST /tabung":                    "POST /v1/accounts/:noRekening/deposits",
+	"POST /ubah-pin":                  "PUT /v1/accounts/:noRekening/pin",
+	"POST /tutup-rekening":            "POST /v1/accounts/:noRekening/closure",
+	"GET /rekening-koran/:noRekening": "GET /v1/accounts/:noRekening/statements/:periode",
+}
+
+// routeAllowed matches "METHOD /route/:param" entries against the matched
+// echo route. An empty list denies everything.
+func routeAllowed(allowedRoutes []string, method, route string) bool {
+	requested := method + " " + route
+	for _, allowed := range allowedRoutes {
+		if allowed == AllRoutes || allowed == requested ||
+			routeSuccessors[allowed] == requested || routeSuccessors[requested] == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAllowed matches plain IPs and CIDR ranges. An empty allowlist allows any IP.
+func ipAllowed(allowlist []string, remoteIP string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range allowlist {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// PIN operations recorded in pin_attempts
+const (
+	PinOperationWithdraw = "withdraw"
+	PinOperationTransfer = "transfer"
+	PinOperationChange   = "change_pin"
+	PinOperationClose    = "close_account"
+)
+
+var (
+	ErrInvalidPinFormat = errcode.New("INVALID_PIN_FORMAT", "PIN must be exactly 6 digits")
+	ErrInvalidPin       = errcode.New("INVALID_PIN", "invalid PIN")
+	ErrPinLocked        = errcode.New("PIN_LOCKED", "PIN is locked, try again later")
+)
+
+// PinPolicy controls how many wrong PINs are tolerated before an account is
+// locked, and for how long
+type PinPolicy struct {
+	MaxAttempts  int
+	LockDuration time.Duration
+}
+
+func validatePinFormat(pin string) error {
+	if len(pin) != 6 {
+		return ErrInvalidPinFormat
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return ErrInvalidPinFormat
+		}
+	}
+	return nil
+}
+
+// VerifyPin checks a PIN for an operation that runs later, such as a
+// withdrawal waiting for approval
+func (s *service) VerifyPin(ctx context.Context, accountNumber, pin, operation string) error {
+	return s.verifyPin(ctx, accountNumber, pin, operation)
+}
+
+// verifyPin checks pin against the stored hash and records the attempt. The
+// attempt and the updated lockout state are committed even when the PIN is
+// wrong; the returned error tells the caller whether to proceed.
+func (s *service) verifyPin(ctx context.Context, accountNumber, pin, operation string) error {
+	var verifyErr error
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		now := time.Now()
+		stored, err := repo.GetPinForUpdate(ctx, accountNumber)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Without a PIN row there is no lockout state to keep, so the
+			// failed attempts of the lock duration decide whether to lock
+			failed, err := repo.CountFailedPinAttempts(ctx, accountNumber, now.Add(-s.pinPolicy.LockDuration))
+			if err != nil {
+				return err
+			}
+			verifyErr = ErrInvalidPin
+			reason := "no_pin"
+			if failed >= s.pinPolicy.MaxAttempts {
+				verifyErr, reason = ErrPinLocked, "locked"
+			}
+			return repo.CreatePinAttempt(ctx, &models.PinAttempt{
+				AccountNumber: accountNumber,
+				Operation:     operation,
+				Success:       false,
+				Reason:        reason,
+				CreatedAt:     now,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		reason := "ok"
+		switch {
+		case stored.LockedUntil != nil && now.Before(*stored.LockedUntil):
+			verifyErr = ErrPinLocked
+			reason = "locked"
+		case !auth.CheckPassword(stored.PinHash, pin):
+			verifyErr = ErrInvalidPin
+			reason = "invalid_pin"
+			stored.FailedAttempts++
+			if stored.FailedAttempts >= s.pinPolicy.MaxAttempts {
+				lockedUntil := now.Add(s.pinPolicy.LockDuration)
+				stored.LockedUntil = &lockedUntil
+				stored.FailedAttempts = 0
+				reason = "invalid_pin_locked"
+			}
+		default:
+			stored.FailedAttempts = 0
+			stored.LockedUntil = nil
+		}
+
+		if reason != "locked" {
+			if err := repo.UpdatePin(ctx, stored); err != nil {
+				return err
+			}
+		}
+
+		return repo.CreatePinAttempt(ctx, &models.PinAttempt{
+			AccountNumber: accountNumber,
+			Operation:     operation,
+			Success:       verifyErr == nil,
+			Reason:        reason,
+			CreatedAt:     now,
+		})
+	})
+	if err != nil {
+		s.log.LogOperation(ctx, "VerifyPin", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+	if verifyErr != nil {
+		s.log.LogOperation(ctx, "VerifyPin", "error", map[string]interface{}{
+			"error":      verifyErr.Error(),
+			"account_id": accountNumber,
+		})
+		return verifyErr
+	}
+
+	s.log.LogOperation(ctx, "VerifyPin", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": accountNumber,
+	})
+	return nil
+}
+
+func (s *service) ChangePin(ctx context.Context, accountNumber, oldPin, newPin string) error {
+	if err := validatePinFormat(newPin); err != nil {
+		s.log.LogOperation(ctx, "ChangePin", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	if err := s.verifyPin(ctx, accountNumber, oldPin, PinOperationChange); err != nil {
+		return err
+	}
+
+	pinHash, err := auth.HashPassword(newPin)
+	if err != nil {
+		s.log.LogOperation(ctx, "ChangePin", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	err = s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		stored, err := repo.GetPinForUpdate(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		stored.PinHash = pinHash
+		return repo.UpdatePin(ctx, stored)
+	})
+	if err != nil {
+		s.log.LogOperation(ctx, "ChangePin", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	s.log.LogOperation(ctx, "ChangePin", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": accountNumber,
+	})
+	return nil
+}
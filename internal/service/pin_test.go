@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+// pinRepo stores PINs and attempts in memory. Its transactions run one at a
+// time, as the row lock and the advisory lock of the database make PIN
+// checks of an account do.
+type pinRepo struct {
+	repository.Repository
+	tx       sync.Mutex
+	pins     map[string]*models.AccountPin
+	attempts []models.PinAttempt
+}
+
+func newPinRepo(t *testing.T, accountNumber, pin string) *pinRepo {
+	t.Helper()
+	r := &pinRepo{pins: map[string]*models.AccountPin{}}
+	if pin != "" {
+		hash, err := auth.HashPassword(pin)
+		if err != nil {
+			t.Fatalf("hash PIN: %v", err)
+		}
+		r.pins[accountNumber] = &models.AccountPin{AccountNumber: accountNumber, PinHash: hash}
+	}
+	return r
+}
+
+func (r *pinRepo) WithTx(ctx context.Context, fn func(repo repository.Repository) error) error {
+	r.tx.Lock()
+	defer r.tx.Unlock()
+	return fn(r)
+}
+
+func (r *pinRepo) GetPinForUpdate(ctx context.Context, accountNumber string) (*models.AccountPin, error) {
+	pin, ok := r.pins[accountNumber]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	stored := *pin
+	return &stored, nil
+}
+
+func (r *pinRepo) UpdatePin(ctx context.Context, pin *models.AccountPin) error {
+	stored := *pin
+	r.pins[pin.AccountNumber] = &stored
+	return nil
+}
+
+func (r *pinRepo) CreatePinAttempt(ctx context.Context, attempt *models.PinAttempt) error {
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *pinRepo) CountFailedPinAttempts(ctx context.Context, accountNumber string, since time.Time) (int, error) {
+	count := 0
+	for _, attempt := range r.attempts {
+		if attempt.AccountNumber == accountNumber && !attempt.Success && !attempt.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// elapse moves the stored lockouts and attempts d into the past
+func (r *pinRepo) elapse(d time.Duration) {
+	for _, pin := range r.pins {
+		if pin.LockedUntil != nil {
+			lockedUntil := pin.LockedUntil.Add(-d)
+			pin.LockedUntil = &lockedUntil
+		}
+	}
+	for i := range r.attempts {
+		r.attempts[i].CreatedAt = r.attempts[i].CreatedAt.Add(-d)
+	}
+}
+
+func newPinService(t *testing.T, repo repository.Repository, policy PinPolicy) Service {
+	t.Helper()
+	return NewService(repo, Policies{Pin: policy}, testLogger(t))
+}
+
+func TestVerifyPinLocksAfterMaxAttempts(t *testing.T) {
+	repo := newPinRepo(t, "1000000001", "123456")
+	s := newPinService(t, repo, PinPolicy{MaxAttempts: 3, LockDuration: time.Hour})
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		if err := s.VerifyPin(ctx, "1000000001", "000000", PinOperationWithdraw); !errors.Is(err, ErrInvalidPin) {
+			t.Fatalf("wrong PIN %d: err = %v, want %v", i, err, ErrInvalidPin)
+		}
+	}
+	if pin := repo.pins["1000000001"]; pin.LockedUntil == nil {
+		t.Fatalf("PIN not locked after 3 wrong attempts: %+v", pin)
+	}
+
+	// while locked even the right PIN is refused, without touching the lock
+	lockedUntil := *repo.pins["1000000001"].LockedUntil
+	if err := s.VerifyPin(ctx, "1000000001", "123456", PinOperationWithdraw); !errors.Is(err, ErrPinLocked) {
+		t.Errorf("right PIN while locked: err = %v, want %v", err, ErrPinLocked)
+	}
+	if got := *repo.pins["1000000001"].LockedUntil; !got.Equal(lockedUntil) {
+		t.Errorf("lock moved from %v to %v", lockedUntil, got)
+	}
+
+	reasons := make([]string, 0, len(repo.attempts))
+	for _, attempt := range repo.attempts {
+		reasons = append(reasons, attempt.Reason)
+	}
+	want := []string{"invalid_pin", "invalid_pin", "invalid_pin_locked", "locked"}
+	if len(reasons) != len(want) {
+		t.Fatalf("attempt reasons = %v, want %v", reasons, want)
+	}
+	for i := range want {
+		if reasons[i] != want[i] {
+			t.Errorf("attempt reasons = %v, want %v", reasons, want)
+			break
+		}
+	}
+}
+
+func TestVerifyPinUnlocksAfterLockDuration(t *testing.T) {
+	repo := newPinRepo(t, "1000000001", "123456")
+	s := newPinService(t, repo, PinPolicy{MaxAttempts: 2, LockDuration: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		s.VerifyPin(ctx, "1000000001", "000000", PinOperationWithdraw)
+	}
+	if err := s.VerifyPin(ctx, "1000000001", "123456", PinOperationWithdraw); !errors.Is(err, ErrPinLocked) {
+		t.Fatalf("right PIN while locked: err = %v, want %v", err, ErrPinLocked)
+	}
+
+	repo.elapse(time.Hour)
+	if err := s.VerifyPin(ctx, "1000000001", "123456", PinOperationWithdraw); err != nil {
+		t.Fatalf("right PIN after the lock ran out: %v", err)
+	}
+	if pin := repo.pins["1000000001"]; pin.LockedUntil != nil || pin.FailedAttempts != 0 {
+		t.Errorf("after a right PIN the lockout state is %+v, want it cleared", pin)
+	}
+
+	// the count starts over: one wrong PIN does not lock again
+	if err := s.VerifyPin(ctx, "1000000001", "000000", PinOperationWithdraw); !errors.Is(err, ErrInvalidPin) {
+		t.Errorf("wrong PIN after unlocking: err = %v, want %v", err, ErrInvalidPin)
+	}
+}
+
+func TestVerifyPinWithoutPinIsThrottled(t *testing.T) {
+	repo := newPinRepo(t, "1000000001", "")
+	s := newPinService(t, repo, PinPolicy{MaxAttempts: 3, LockDuration: time.Hour})
+	ctx := context.Background()
+
+	// concurrent guesses are counted one after another, so no more than
+	// MaxAttempts of them are answered as a mere wrong PIN
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- s.VerifyPin(ctx, "1000000001", "123456", PinOperationWithdraw)
+		}()
+	}
+	wg.Wait()
+	close(results)
+	invalid, locked := 0, 0
+	for err := range results {
+		switch {
+		case errors.Is(err, ErrInvalidPin):
+			invalid++
+		case errors.Is(err, ErrPinLocked):
+			locked++
+		default:
+			t.Errorf("err = %v, want %v or %v", err, ErrInvalidPin, ErrPinLocked)
+		}
+	}
+	if invalid != 3 || locked != 7 {
+		t.Errorf("%d invalid and %d locked, want 3 and 7", invalid, locked)
+	}
+	if len(repo.attempts) != 10 {
+		t.Errorf("%d attempts recorded, want 10", len(repo.attempts))
+	}
+
+	// attempts older than the lock duration no longer count
+	repo.elapse(time.Hour)
+	if err := s.VerifyPin(ctx, "1000000001", "123456", PinOperationWithdraw); !errors.Is(err, ErrInvalidPin) {
+		t.Errorf("after the lock duration: err = %v, want %v", err, ErrInvalidPin)
+	}
+}
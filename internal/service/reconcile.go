@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// ReconciliationActor is recorded as the actor of freezes made by the
+// reconciliation
+const ReconciliationActor = "system:reconciliation"
+
+type reconciliationService struct {
+	repo      repository.Repository
+	chunkSize int
+	log       *logger.CustomLogger
+}
+
+type ReconciliationService interface {
+	Reconcile(ctx context.Context, freeze bool) (*models.ReconciliationReport, error)
+}
+
+func NewReconciliationService(repo repository.Repository, chunkSize int, log *logger.CustomLogger) ReconciliationService {
+	if chunkSize <= 0 {
+		chunkSize = batchSize
+	}
+	return &reconciliationService{
+		repo:      repo,
+		chunkSize: chunkSize,
+		log:       log,
+	}
+}
+
+// Reconcile recomputes every account's balance from its ledger entries in
+// chunks and reports the accounts whose stored balance differs. With freeze,
+// each of them is frozen once the mismatch is confirmed under the account's
+// row lock.
+func (s *reconciliationService) Reconcile(ctx context.Context, freeze bool) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		StartedAt:     time.Now(),
+		Discrepancies: []models.Discrepancy{},
+	}
+
+	after := ""
+	for {
+		balances, err := s.repo.GetLedgerBalances(ctx, after, s.chunkSize)
+		if err != nil {
+			return nil, s.reconcileError(ctx, err)
+		}
+		if len(balances) == 0 {
+			break
+		}
+
+		for _, balance := range balances {
+			if balance.Difference() == 0 {
+				continue
+			}
+			discrepancy := models.Discrepancy{LedgerBalance: balance, Difference: balance.Difference()}
+			if freeze {
+				discrepancy.Frozen, err = s.freeze(ctx, balance.AccountNumber)
+				if err != nil {
+					return nil, s.reconcileError(ctx, err)
+				}
+			}
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+		}
+		report.AccountsChecked += len(balances)
+		after = balances[len(balances)-1].AccountNumber
+	}
+	report.FinishedAt = time.Now()
+
+	s.log.LogOperation(ctx, "Reconcile", "success", map[string]interface{}{
+		"type":          "service",
+		"checked":       report.AccountsChecked,
+		"discrepancies": len(report.Discrepancies),
+	})
+	return report, nil
+}
+
+// freeze locks the account, checks the mismatch still holds and freezes it.
+// Accounts that cannot move to FROZEN, such as closed ones, are left as they
+// are.
+func (s *reconciliationService) freeze(ctx context.Context, accountNumber string) (bool, error) {
+	frozen := false
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountForUpdate(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		ledger, err := repo.GetAccountLedgerBalance(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		balance := models.LedgerBalance{Balance: account.Balance, LedgerBalance: ledger}
+		if balance.Difference() == 0 || !models.CanTransition(account.Status, models.StatusFrozen) {
+			return nil
+		}
+
+		reason := fmt.Sprintf("Ledger mismatch: balance %.2f, ledger %.2f", account.Balance, ledger)
+		if _, err := applyStatusChange(ctx, repo, account, models.StatusFrozen, reason, ReconciliationActor); err != nil {
+			return err
+		}
+		frozen = true
+		return nil
+	})
+	return frozen, err
+}
+
+func (s *reconciliationService) reconcileError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "Reconcile", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+var (
+	ErrReversalOfReversal = errcode.New("REVERSAL_OF_REVERSAL", "a reversal cannot be reversed")
+	ErrReversalOverdraft  = errcode.New("REVERSAL_OVERDRAFT", "reversal would overdraw the account")
+	ErrSupervisorRequired = errcode.New("SUPERVISOR_REQUIRED", "forcing a reversal requires the supervisor role")
+)
+
+// ReverseTransaction posts compensating entries for a ledger transaction. A
+// transfer leg brings its other leg along and a transaction the fee charged
+// with it, so they are all reversed in the same database transaction. A reversal that would overdraw an account is refused
+// unless force is set; the caller is responsible for only letting
+// supervisors force.
+func (s *service) ReverseTransaction(ctx context.Context, transactionID int64, reason, actor string, force bool) (*models.Reversal, error) {
+	switch {
+	case strings.TrimSpace(reason) == "":
+		return nil, s.reversalError(ctx, ErrReasonRequired)
+	case strings.TrimSpace(actor) == "":
+		return nil, s.reversalError(ctx, ErrActorRequired)
+	}
+
+	reversal := &models.Reversal{
+		Reference:     models.NewReference(),
+		TransactionID: transactionID,
+		Reason:        reason,
+		Actor:         actor,
+		Forced:        force,
+		CreatedAt:     time.Now(),
+	}
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		legs, err := reversalLegs(ctx, repo, transactionID)
+		if err != nil {
+			return err
+		}
+		if err := lockAccounts(ctx, repo, legs); err != nil {
+			return err
+		}
+
+		for i := range legs {
+			reversed, err := repo.IsReversed(ctx, legs[i].ID)
+			if err != nil {
+				return err
+			}
+			if reversed {
+				return repository.ErrAlreadyReversed
+			}
+		}
+
+		for i := range legs {
+			description := fmt.Sprintf("Koreksi %s: %s", legs[i].Reference, reason)
+			trx, err := repo.PostReversal(ctx, &legs[i], reversal.Reference, description, force)
+			if errors.Is(err, repository.ErrInsufficientBalance) {
+				return ErrReversalOverdraft
+			}
+			if err != nil {
+				return err
+			}
+			reversal.Entries = append(reversal.Entries, *trx)
+		}
+		return repo.CreateReversal(ctx, reversal)
+	})
+	if err != nil {
+		return nil, s.reversalError(ctx, err)
+	}
+
+	s.log.LogOperation(ctx, "ReverseTransaction", "success", map[string]interface{}{
+		"type":           "service",
+		"transaction_id": transactionID,
+		"reference":      reversal.Reference,
+		"forced":         force,
+		"actor":          actor,
+	})
+	return reversal, nil
+}
+
+// reversalLegs returns the entry to reverse, for a transfer its other leg,
+// and the fees charged with it that were not waived on their own already
+func reversalLegs(ctx context.Context, repo repository.Repository, transactionID int64) ([]models.Transaction, error) {
+	original, err := repo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Type == models.TransactionReversal {
+		return nil, ErrReversalOfReversal
+	}
+
+	legs := []models.Transaction{*original}
+	if original.Type == models.TransactionFee {
+		return legs, nil
+	}
+	counterpart := map[string]string{
+		models.TransactionTransferOut: models.TransactionTransferIn,
+		models.TransactionTransferIn:  models.TransactionTransferOut,
+	}[original.Type]
+
+	entries, err := repo.GetTransactionsByReference(ctx, original.Reference)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.ID == original.ID:
+		case counterpart != "" && entry.Type == counterpart:
+			legs = append(legs, entry)
+		case entry.Type == models.TransactionFee:
+			reversed, err := repo.IsReversed(ctx, entry.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !reversed {
+				legs = append(legs, entry)
+			}
+		}
+	}
+	return legs, nil
+}
+
+// lockAccounts locks the accounts of the legs in account number order, the
+// same order Transfer uses, so reversals and transfers cannot deadlock
+func lockAccounts(ctx context.Context, repo repository.Repository, legs []models.Transaction) error {
+	var accounts []string
+	seen := make(map[string]bool)
+	for _, leg := range legs {
+		if !seen[leg.AccountNumber] {
+			seen[leg.AccountNumber] = true
+			accounts = append(accounts, leg.AccountNumber)
+		}
+	}
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		if _, err := repo.GetAccountForUpdate(ctx, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) reversalError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "ReverseTransaction", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+func (r *ledgerRepo) GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error) {
+	if id < 1 || id > int64(len(r.entries)) {
+		return nil, repository.ErrTransactionNotFound
+	}
+	trx := r.entries[id-1]
+	return &trx, nil
+}
+
+func (r *ledgerRepo) GetTransactionsByReference(ctx context.Context, reference string) ([]models.Transaction, error) {
+	var entries []models.Transaction
+	for _, trx := range r.entries {
+		if trx.Reference == reference {
+			entries = append(entries, trx)
+		}
+	}
+	return entries, nil
+}
+
+func (r *ledgerRepo) IsReversed(ctx context.Context, id int64) (bool, error) {
+	for _, trx := range r.entries {
+		if trx.ReversalOf != nil && *trx.ReversalOf == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PostReversal mirrors the guarded UPDATEs of the repository: closed
+// accounts are refused whatever the direction, and a debit needs available
+// balance unless allowOverdraft is set
+func (r *ledgerRepo) PostReversal(ctx context.Context, original *models.Transaction, reference, description string, allowOverdraft bool) (*models.Transaction, error) {
+	account, ok := r.accounts[original.AccountNumber]
+	switch {
+	case !ok:
+		return nil, repository.ErrAccountNotFound
+	case account.Status == models.StatusClosed:
+		return nil, repository.ErrAccountClosed
+	}
+	if reversed, _ := r.IsReversed(ctx, original.ID); reversed {
+		return nil, repository.ErrAlreadyReversed
+	}
+
+	direction := models.DirectionDebit
+	if original.Direction == models.DirectionDebit {
+		direction = models.DirectionCredit
+	}
+	if direction == models.DirectionDebit {
+		if !allowOverdraft && account.AvailableBalance() < original.Amount {
+			return nil, repository.ErrInsufficientBalance
+		}
+		account.Balance -= original.Amount
+	} else {
+		account.Balance += original.Amount
+	}
+
+	reversalOf := original.ID
+	return r.post(&models.Transaction{
+		Reference:     reference,
+		AccountNumber: original.AccountNumber,
+		Type:          models.TransactionReversal,
+		Direction:     direction,
+		Amount:        original.Amount,
+		BalanceAfter:  account.Balance,
+		Description:   description,
+		ReversalOf:    &reversalOf,
+	}), nil
+}
+
+func (r *ledgerRepo) CreateReversal(ctx context.Context, reversal *models.Reversal) error {
+	return nil
+}
+
+// withdrawWithFee posts a 50000 cash withdrawal and its 6500 fee from a
+// 100000 balance and returns the service and both entries
+func withdrawWithFee(t *testing.T) (Service, *ledgerRepo, models.Transaction, models.Transaction) {
+	t.Helper()
+	repo := newLedgerRepo(tabungan("1001", 100000))
+	svc := newFeeService(t, repo)
+	if err := svc.WithdrawVerified(context.Background(), "1001", 50000); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+	return svc, repo, repo.entries[0], repo.entries[1]
+}
+
+func TestReverseWithdrawalReversesItsFee(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, withdrawal, fee := withdrawWithFee(t)
+
+	reversal, err := svc.ReverseTransaction(ctx, withdrawal.ID, "salah input", "teller-01", false)
+	if err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if len(reversal.Entries) != 2 {
+		t.Fatalf("reversal posted %d entries, want the withdrawal and its fee", len(reversal.Entries))
+	}
+	for i, original := range []models.Transaction{withdrawal, fee} {
+		entry := reversal.Entries[i]
+		if entry.Reference != reversal.Reference || entry.Type != models.TransactionReversal {
+			t.Fatalf("entry %d = %+v, want a REVERSAL under %s", i, entry, reversal.Reference)
+		}
+		if entry.ReversalOf == nil || *entry.ReversalOf != original.ID {
+			t.Fatalf("entry %d reverses %v, want %d", i, entry.ReversalOf, original.ID)
+		}
+		if entry.Direction != models.DirectionCredit || entry.Amount != original.Amount {
+			t.Fatalf("entry %d = %s %v, want C %v", i, entry.Direction, entry.Amount, original.Amount)
+		}
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 100000 {
+		t.Fatalf("balance = %v, want 100000", balance)
+	}
+
+	if _, err := svc.ReverseTransaction(ctx, withdrawal.ID, "lagi", "teller-01", false); !errors.Is(err, repository.ErrAlreadyReversed) {
+		t.Fatalf("second reversal: got %v, want ErrAlreadyReversed", err)
+	}
+	if _, err := svc.ReverseTransaction(ctx, reversal.Entries[0].ID, "batal koreksi", "teller-01", false); !errors.Is(err, ErrReversalOfReversal) {
+		t.Fatalf("reversal of a reversal: got %v, want ErrReversalOfReversal", err)
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 100000 || len(repo.entries) != 4 {
+		t.Fatalf("balance %v with %d entries after refused reversals, want 100000 with 4", balance, len(repo.entries))
+	}
+}
+
+func TestReverseWithdrawalSkipsAWaivedFee(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, withdrawal, fee := withdrawWithFee(t)
+
+	waiver, err := svc.ReverseTransaction(ctx, fee.ID, "biaya dibebaskan", "teller-01", false)
+	if err != nil {
+		t.Fatalf("waive fee: %v", err)
+	}
+	if len(waiver.Entries) != 1 || *waiver.Entries[0].ReversalOf != fee.ID {
+		t.Fatalf("waiver entries = %+v, want only the fee", waiver.Entries)
+	}
+
+	reversal, err := svc.ReverseTransaction(ctx, withdrawal.ID, "salah input", "teller-01", false)
+	if err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if len(reversal.Entries) != 1 || *reversal.Entries[0].ReversalOf != withdrawal.ID {
+		t.Fatalf("reversal entries = %+v, want only the withdrawal", reversal.Entries)
+	}
+	if balance := repo.accounts["1001"].Balance; balance != 100000 {
+		t.Fatalf("balance = %v, want 100000", balance)
+	}
+}
+
+func TestReverseRefusesClosedAccount(t *testing.T) {
+	svc, repo, withdrawal, _ := withdrawWithFee(t)
+	account := repo.accounts["1001"]
+	account.Status = models.StatusClosed
+
+	_, err := svc.ReverseTransaction(context.Background(), withdrawal.ID, "salah input", "supervisor-01", true)
+	if !errors.Is(err, repository.ErrAccountClosed) {
+		t.Fatalf("got %v, want ErrAccountClosed", err)
+	}
+	if account.Balance != 43500 || len(repo.entries) != 2 {
+		t.Fatalf("balance %v with %d entries, want 43500 with the original 2", account.Balance, len(repo.entries))
+	}
+}
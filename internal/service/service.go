@@ -2,11 +2,12 @@ package service
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"math/rand"
 	"time"
 
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
 	"github.com/alfaa19/service-account-test/internal/models"
 	"github.com/alfaa19/service-account-test/internal/models/dto"
 	"github.com/alfaa19/service-account-test/internal/repository"
@@ -14,24 +15,74 @@ import (
 )
 
 type service struct {
-	repo repository.Repository
-	log  *logger.CustomLogger
+	repo          repository.Repository
+	pinPolicy     PinPolicy
+	identityReuse IdentityReusePolicy
+	holdPolicy    HoldPolicy
+	limitPolicy   LimitPolicy
+	feePolicy     FeePolicy
+	log           *logger.CustomLogger
+}
+
+// Policies groups the configurable rules the account service enforces
+type Policies struct {
+	Pin           PinPolicy
+	IdentityReuse IdentityReusePolicy
+	Hold          HoldPolicy
+	Limits        LimitPolicy
+	Fees          FeePolicy
 }
 
 type Service interface {
 	GetAccountByNoRekening(ctx context.Context, noRekening string) (*models.Account, error)
 	CreateAccount(ctx context.Context, reqAccount *dto.AccountRegistration) (*models.Account, error)
-	UpdateBalanceWithdraw(ctx context.Context, accountNumber string, amount float64) error
+	UpdateBalanceWithdraw(ctx context.Context, accountNumber string, amount float64, pin string) error
+	WithdrawVerified(ctx context.Context, accountNumber string, amount float64) error
 	UpdateBalanceDeposit(ctx context.Context, accountNumber string, amount float64) error
+	VerifyPin(ctx context.Context, accountNumber, pin, operation string) error
+	ChangePin(ctx context.Context, accountNumber, oldPin, newPin string) error
+	Transfer(ctx context.Context, fromAccount, toAccount string, amount float64, pin, description string) (*models.Transaction, error)
+	GetTransactionHistory(ctx context.Context, accountNumber string, from, to time.Time, limit, offset int) ([]models.Transaction, error)
+	ChangeAccountStatus(ctx context.Context, accountNumber, status, reason, actor string) (*models.AccountStatusChange, error)
+	CloseAccount(ctx context.Context, accountNumber, pin, method, beneficiary, reason string) (*models.AccountClosure, error)
+	GetStatement(ctx context.Context, accountNumber, period string) (*models.Statement, error)
+	GetBalanceOnDate(ctx context.Context, accountNumber, businessDate string) (float64, error)
+	ReverseTransaction(ctx context.Context, transactionID int64, reason, actor string, force bool) (*models.Reversal, error)
+	CreateHold(ctx context.Context, accountNumber string, amount float64, ttl time.Duration, description string) (*models.Hold, error)
+	CaptureHold(ctx context.Context, holdID string, amount float64, description string) (*models.Hold, *models.Transaction, error)
+	ReleaseHold(ctx context.Context, holdID string) (*models.Hold, error)
+	ReleaseExpiredHolds(ctx context.Context, batchSize int) (int, error)
+	WithRepository(repo repository.Repository) Service
 }
 
-func NewService(repo repository.Repository, log *logger.CustomLogger) Service {
+var (
+	ErrInvalidAmount = errcode.New("INVALID_AMOUNT", "amount must be greater than zero")
+	ErrSameAccount   = errcode.New("SAME_ACCOUNT", "source and beneficiary account must differ")
+
+	ErrNIKExists         = errcode.New("NIK_EXISTS", "NIK already exists")
+	ErrPhoneNumberExists = errcode.New("PHONE_NUMBER_EXISTS", "phone number already exists")
+)
+
+func NewService(repo repository.Repository, policies Policies, log *logger.CustomLogger) Service {
 	return &service{
-		repo: repo,
-		log:  log,
+		repo:          repo,
+		pinPolicy:     policies.Pin,
+		identityReuse: policies.IdentityReuse,
+		holdPolicy:    policies.Hold,
+		limitPolicy:   policies.Limits,
+		feePolicy:     policies.Fees,
+		log:           log,
 	}
 }
 
+// WithRepository returns the service running its operations on repo. Given
+// the repository of a caller's transaction, the operations join it.
+func (s *service) WithRepository(repo repository.Repository) Service {
+	bound := *s
+	bound.repo = repo
+	return &bound
+}
+
 func (s *service) GetAccountByNoRekening(ctx context.Context, noRekening string) (*models.Account, error) {
 	account, err := s.repo.GetAccountByNoRekening(ctx, noRekening)
 	if err != nil {
@@ -49,8 +100,23 @@ func (s *service) GetAccountByNoRekening(ctx context.Context, noRekening string)
 }
 
 func (s *service) CreateAccount(ctx context.Context, reqAccount *dto.AccountRegistration) (*models.Account, error) {
-	// Check if NIK already exists
-	existingAccountByNIK, err := s.repo.NIKExist(ctx, reqAccount.NIK)
+	if len(reqAccount.Password) < minPasswordLength {
+		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
+			"error": ErrPasswordTooShort.Error(),
+		})
+		return nil, ErrPasswordTooShort
+	}
+	if err := validatePinFormat(reqAccount.Pin); err != nil {
+		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	// Check if NIK already exists, closed accounts release it according to
+	// the identity reuse policy
+	closedAfter := s.identityReuse.closedAfter(time.Now())
+	existingAccountByNIK, err := s.repo.NIKExist(ctx, reqAccount.NIK, closedAfter)
 	if err != nil {
 		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -60,13 +126,13 @@ func (s *service) CreateAccount(ctx context.Context, reqAccount *dto.AccountRegi
 	if existingAccountByNIK {
 
 		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
-			"error": errors.New("NIK already exists"),
+			"error": ErrNIKExists.Error(),
 		})
-		return nil, errors.New("NIK already exists")
+		return nil, ErrNIKExists
 	}
 
 	// Check if phone number already exists
-	existingAccountByPhone, err := s.repo.PhoneNumberExist(ctx, reqAccount.NoHP)
+	existingAccountByPhone, err := s.repo.PhoneNumberExist(ctx, reqAccount.NoHP, closedAfter)
 	if err != nil {
 		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -75,21 +141,61 @@ func (s *service) CreateAccount(ctx context.Context, reqAccount *dto.AccountRegi
 	}
 	if existingAccountByPhone {
 		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
-			"error": errors.New("phone Number already exists"),
+			"error": ErrPhoneNumberExists.Error(),
 		})
-		return nil, errors.New("phone Number already exists")
+		return nil, ErrPhoneNumberExists
 	}
 
+	passwordHash, err := auth.HashPassword(reqAccount.Password)
+	if err != nil {
+		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	pinHash, err := auth.HashPassword(reqAccount.Pin)
+	if err != nil {
+		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	now := time.Now()
 	account := &models.Account{
 		AccountNumber: generateAccountNumber(),
 		Name:          reqAccount.Nama,
 		NIK:           reqAccount.NIK,
 		PhoneNumber:   reqAccount.NoHP,
 		Balance:       0.0,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
-	// Create the account
-	createdAccount, err := s.repo.CreateAccount(ctx, account)
+	// Create the account together with its login credential and PIN
+	var createdAccount *models.Account
+	err = s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		var err error
+		createdAccount, err = repo.CreateAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+		err = repo.CreateCredential(ctx, &models.Credential{
+			AccountNumber: createdAccount.AccountNumber,
+			PasswordHash:  passwordHash,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		return repo.CreatePin(ctx, &models.AccountPin{
+			AccountNumber: createdAccount.AccountNumber,
+			PinHash:       pinHash,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	})
 	if err != nil {
 		s.log.LogOperation(ctx, "CreateAccount", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -102,8 +208,45 @@ func (s *service) CreateAccount(ctx context.Context, reqAccount *dto.AccountRegi
 	})
 	return createdAccount, nil
 }
-func (s *service) UpdateBalanceWithdraw(ctx context.Context, accountNumber string, amount float64) error {
-	err := s.repo.UpdateBalanceWithdraw(ctx, accountNumber, amount)
+func (s *service) UpdateBalanceWithdraw(ctx context.Context, accountNumber string, amount float64, pin string) error {
+	if amount <= 0 {
+		s.log.LogOperation(ctx, "UpdateBalanceWithdraw", "error", map[string]interface{}{
+			"error": ErrInvalidAmount.Error(),
+		})
+		return ErrInvalidAmount
+	}
+	if err := s.verifyPin(ctx, accountNumber, pin, PinOperationWithdraw); err != nil {
+		return err
+	}
+	return s.WithdrawVerified(ctx, accountNumber, amount)
+}
+
+// WithdrawVerified posts a cash withdrawal whose PIN was already checked,
+// such as one approved by a checker after VerifyPin at submission
+func (s *service) WithdrawVerified(ctx context.Context, accountNumber string, amount float64) error {
+	if amount <= 0 {
+		s.log.LogOperation(ctx, "UpdateBalanceWithdraw", "error", map[string]interface{}{
+			"error": ErrInvalidAmount.Error(),
+		})
+		return ErrInvalidAmount
+	}
+
+	// The limit, the withdrawal and its fee commit or fail together
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountByNoRekening(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		if err := s.consumeLimit(ctx, repo, account, models.ChannelCash, amount); err != nil {
+			return err
+		}
+
+		reference := models.NewReference()
+		if _, err := repo.Debit(ctx, reference, accountNumber, amount, models.TransactionWithdrawal, ""); err != nil {
+			return err
+		}
+		return s.chargeFee(ctx, repo, account, models.ChannelCash, reference, amount)
+	})
 	if err != nil {
 		s.log.LogOperation(ctx, "UpdateBalanceWithdraw", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -118,7 +261,24 @@ func (s *service) UpdateBalanceWithdraw(ctx context.Context, accountNumber strin
 	return nil
 }
 func (s *service) UpdateBalanceDeposit(ctx context.Context, accountNumber string, amount float64) error {
-	err := s.repo.UpdateBalanceDeposit(ctx, accountNumber, amount)
+	if amount <= 0 {
+		s.log.LogOperation(ctx, "UpdateBalanceDeposit", "error", map[string]interface{}{
+			"error": ErrInvalidAmount.Error(),
+		})
+		return ErrInvalidAmount
+	}
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountByNoRekening(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+
+		reference := models.NewReference()
+		if _, err := repo.Credit(ctx, reference, accountNumber, amount, models.TransactionDeposit, ""); err != nil {
+			return err
+		}
+		return s.chargeFee(ctx, repo, account, models.ChannelDeposit, reference, amount)
+	})
 	if err != nil {
 		s.log.LogOperation(ctx, "UpdateBalanceDeposit", "error", map[string]interface{}{
 			"error": err.Error(),
@@ -133,6 +293,71 @@ func (s *service) UpdateBalanceDeposit(ctx context.Context, accountNumber string
 	return nil
 }
 
+func (s *service) Transfer(ctx context.Context, fromAccount, toAccount string, amount float64, pin, description string) (*models.Transaction, error) {
+	if amount <= 0 {
+		s.log.LogOperation(ctx, "Transfer", "error", map[string]interface{}{
+			"error": ErrInvalidAmount.Error(),
+		})
+		return nil, ErrInvalidAmount
+	}
+	if fromAccount == toAccount {
+		s.log.LogOperation(ctx, "Transfer", "error", map[string]interface{}{
+			"error": ErrSameAccount.Error(),
+		})
+		return nil, ErrSameAccount
+	}
+
+	if err := s.verifyPin(ctx, fromAccount, pin, PinOperationTransfer); err != nil {
+		return nil, err
+	}
+
+	var trx *models.Transaction
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountByNoRekening(ctx, fromAccount)
+		if err != nil {
+			return err
+		}
+		if err := s.consumeLimit(ctx, repo, account, models.ChannelTransfer, amount); err != nil {
+			return err
+		}
+
+		trx, err = repo.Transfer(ctx, models.NewReference(), fromAccount, toAccount, amount, description)
+		if err != nil {
+			return err
+		}
+		return s.chargeFee(ctx, repo, account, models.ChannelTransfer, trx.Reference, amount)
+	})
+	if err != nil {
+		s.log.LogOperation(ctx, "Transfer", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	s.log.LogOperation(ctx, "Transfer", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": fromAccount,
+		"reference":  trx.Reference,
+	})
+	return trx, nil
+}
+
+func (s *service) GetTransactionHistory(ctx context.Context, accountNumber string, from, to time.Time, limit, offset int) ([]models.Transaction, error) {
+	transactions, err := s.repo.GetTransactions(ctx, accountNumber, from, to, limit, offset)
+	if err != nil {
+		s.log.LogOperation(ctx, "GetTransactionHistory", "error", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	s.log.LogOperation(ctx, "GetTransactionHistory", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": accountNumber,
+	})
+	return transactions, nil
+}
+
 func generateAccountNumber() string {
 	rand.Seed(time.Now().UnixNano())
 	accountNumber := ""
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+var (
+	ErrInvalidPeriod = errcode.New("INVALID_PERIOD", "period must be formatted YYYY-MM")
+	ErrFuturePeriod  = errcode.New("FUTURE_PERIOD", "period has not started yet")
+)
+
+// GetStatement builds the statement of an account for a YYYY-MM period. The
+// opening balance is the balance after the last entry before the period.
+func (s *service) GetStatement(ctx context.Context, accountNumber, period string) (*models.Statement, error) {
+	from, to, err := models.PeriodBounds(period)
+	if err != nil {
+		return nil, s.statementError(ctx, ErrInvalidPeriod)
+	}
+	if from.After(time.Now()) {
+		return nil, s.statementError(ctx, ErrFuturePeriod)
+	}
+
+	account, err := s.repo.GetAccountByNoRekening(ctx, accountNumber)
+	if err != nil {
+		return nil, s.statementError(ctx, err)
+	}
+	opening, err := s.repo.GetBalanceAt(ctx, accountNumber, from)
+	if err != nil {
+		return nil, s.statementError(ctx, err)
+	}
+	transactions, err := s.repo.GetLedger(ctx, accountNumber, from, to)
+	if err != nil {
+		return nil, s.statementError(ctx, err)
+	}
+
+	statement := &models.Statement{
+		AccountNumber:  account.AccountNumber,
+		Name:           account.Name,
+		MaskedNIK:      models.MaskNIK(account.NIK),
+		Period:         period,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+		Transactions:   transactions,
+		GeneratedAt:    time.Now(),
+	}
+	for _, trx := range transactions {
+		if trx.Direction == models.DirectionDebit {
+			statement.TotalDebit += trx.Amount
+		} else {
+			statement.TotalCredit += trx.Amount
+		}
+		statement.ClosingBalance = trx.BalanceAfter
+	}
+
+	s.log.LogOperation(ctx, "GetStatement", "success", map[string]interface{}{
+		"type":       "service",
+		"account_id": accountNumber,
+		"period":     period,
+		"count":      len(transactions),
+	})
+	return statement, nil
+}
+
+func (s *service) statementError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "GetStatement", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+)
+
+var (
+	ErrUnknownStatus           = errcode.New("UNKNOWN_ACCOUNT_STATUS", "unknown account status")
+	ErrInvalidStatusTransition = errcode.New("STATUS_TRANSITION_NOT_ALLOWED", "status transition not allowed")
+	ErrReasonRequired          = errcode.New("REASON_REQUIRED", "reason is required")
+	ErrActorRequired           = errcode.New("ACTOR_REQUIRED", "actor is required")
+	ErrClosureRequired         = errcode.New("CLOSURE_REQUIRED", "accounts are closed through the account closure")
+)
+
+// ChangeAccountStatus moves an account to a new status following the
+// lifecycle in models.CanTransition and records who did it and why. Accounts
+// are never closed here: CloseAccount pays out the balance first.
+func (s *service) ChangeAccountStatus(ctx context.Context, accountNumber, status, reason, actor string) (*models.AccountStatusChange, error) {
+	status = strings.ToUpper(status)
+	switch {
+	case !models.IsValidStatus(status):
+		return nil, s.statusError(ctx, ErrUnknownStatus)
+	case status == models.StatusClosed:
+		return nil, s.statusError(ctx, ErrClosureRequired)
+	case strings.TrimSpace(reason) == "":
+		return nil, s.statusError(ctx, ErrReasonRequired)
+	case strings.TrimSpace(actor) == "":
+		return nil, s.statusError(ctx, ErrActorRequired)
+	}
+
+	var change *models.AccountStatusChange
+	err := s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		account, err := repo.GetAccountForUpdate(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		change, err = applyStatusChange(ctx, repo, account, status, reason, actor)
+		return err
+	})
+	if err != nil {
+		return nil, s.statusError(ctx, err)
+	}
+
+	s.log.LogOperation(ctx, "ChangeAccountStatus", "success", map[string]interface{}{
+		"type":        "service",
+		"account_id":  accountNumber,
+		"from_status": change.FromStatus,
+		"to_status":   change.ToStatus,
+		"actor":       actor,
+	})
+	return change, nil
+}
+
+// applyStatusChange moves a locked account to status and records the change
+// in the status history
+func applyStatusChange(ctx context.Context, repo repository.Repository, account *models.Account, status, reason, actor string) (*models.AccountStatusChange, error) {
+	if !models.CanTransition(account.Status, status) {
+		return nil, ErrInvalidStatusTransition
+	}
+
+	if err := repo.UpdateAccountStatus(ctx, account.AccountNumber, status); err != nil {
+		return nil, err
+	}
+
+	change := &models.AccountStatusChange{
+		AccountNumber: account.AccountNumber,
+		FromStatus:    account.Status,
+		ToStatus:      status,
+		Reason:        reason,
+		Actor:         actor,
+		CreatedAt:     time.Now(),
+	}
+	if err := repo.CreateStatusChange(ctx, change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (s *service) statusError(ctx context.Context, err error) error {
+	s.log.LogOperation(ctx, "ChangeAccountStatus", "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/stream"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// replayPageSize is how many missed ledger entries are read per query when a
+// client resumes a balance stream
+const replayPageSize = 500
+
+type balanceStreamService struct {
+	repo repository.Repository
+	bus  *stream.Bus
+	log  *logger.CustomLogger
+}
+
+type BalanceStreamService interface {
+	Follow(ctx context.Context, accountNumber string) *stream.Subscription
+	Replay(ctx context.Context, accountNumber string, afterID int64, fn func(trx *models.Transaction) error) (int64, error)
+	CheckAccess(ctx context.Context, accountNumber, subject string) error
+}
+
+func NewBalanceStreamService(repo repository.Repository, bus *stream.Bus, log *logger.CustomLogger) BalanceStreamService {
+	return &balanceStreamService{
+		repo: repo,
+		bus:  bus,
+		log:  log,
+	}
+}
+
+// Follow subscribes to the balance changes of an account as they commit
+func (s *balanceStreamService) Follow(ctx context.Context, accountNumber string) *stream.Subscription {
+	s.log.LogOperation(ctx, "FollowBalance", "start", map[string]interface{}{
+		"account_id": accountNumber,
+	})
+	return s.bus.Subscribe(accountNumber)
+}
+
+// Replay hands fn the ledger entries of an account after afterID, oldest
+// first, and returns the ID of the last one. Following before replaying
+// makes sure nothing committed in between is lost; the caller skips live
+// entries it already replayed.
+func (s *balanceStreamService) Replay(ctx context.Context, accountNumber string, afterID int64, fn func(trx *models.Transaction) error) (int64, error) {
+	for {
+		entries, err := s.repo.GetTransactionsAfter(ctx, accountNumber, afterID, replayPageSize)
+		if err != nil {
+			s.log.LogOperation(ctx, "ReplayBalance", "error", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return afterID, err
+		}
+		for i := range entries {
+			if err := fn(&entries[i]); err != nil {
+				return afterID, err
+			}
+			afterID = entries[i].ID
+		}
+		if len(entries) < replayPageSize {
+			return afterID, nil
+		}
+	}
+}
+
+// CheckAccess reports whether the holder of an access token for subject may
+// still follow an account: it must exist, belong to subject and not be
+// closed. Streams outlive the request that opened them, so this is checked
+// again while they run.
+func (s *balanceStreamService) CheckAccess(ctx context.Context, accountNumber, subject string) error {
+	account, err := s.repo.GetAccountByNoRekening(ctx, accountNumber)
+	switch {
+	case err != nil:
+	case account.AccountNumber != subject:
+		err = ErrInvalidAccessToken
+	case account.Status == models.StatusClosed:
+		err = repository.ErrAccountClosed
+	}
+	if err != nil {
+		s.log.LogOperation(ctx, "CheckStreamAccess", "error", map[string]interface{}{
+			"error":      err.Error(),
+			"account_id": accountNumber,
+		})
+		return err
+	}
+	return nil
+}
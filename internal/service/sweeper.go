@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+// HoldSweeper periodically releases holds that expired without being
+// captured or released
+type HoldSweeper struct {
+	service   Service
+	interval  time.Duration
+	batchSize int
+	log       *logger.CustomLogger
+}
+
+func NewHoldSweeper(service Service, interval time.Duration, batchSize int, log *logger.CustomLogger) *HoldSweeper {
+	return &HoldSweeper{
+		service:   service,
+		interval:  interval,
+		batchSize: batchSize,
+		log:       log,
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (w *HoldSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.sweep(ctx)
+		}
+	}
+}
+
+// sweep keeps releasing full batches so a backlog clears in one tick
+func (w *HoldSweeper) sweep(ctx context.Context) {
+	for ctx.Err() == nil {
+		released, err := w.service.ReleaseExpiredHolds(ctx, w.batchSize)
+		if err != nil {
+			w.log.LogWarning(ctx, "Hold sweep failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+		if released < w.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+	"github.com/alfaa19/service-account-test/internal/events"
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/webhook"
+	logger "github.com/alfaa19/service-account-test/pkg/logrus"
+)
+
+var (
+	ErrInvalidWebhookURL     = errcode.New("INVALID_WEBHOOK_URL", "webhook url must be an absolute http or https URL")
+	ErrUnknownEventType      = errcode.New("UNKNOWN_EVENT_TYPE", "unknown event type")
+	ErrUnknownWebhookStatus  = errcode.New("UNKNOWN_WEBHOOK_STATUS", "unknown webhook delivery status")
+	ErrSubscriptionNotActive = errcode.New("SUBSCRIPTION_NOT_ACTIVE", "webhook subscription is no longer active")
+	ErrWebhookAccountsNeeded = errcode.New("WEBHOOK_ACCOUNTS_REQUIRED", "webhook subscription must list at least one account")
+)
+
+// Page sizes of the delivery log
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WebhookPolicy controls retries. A delivery is attempted MaxAttempts times,
+// waiting RetryBase, then twice as long after every failure up to MaxDelay,
+// before it moves to the dead-letter list.
+type WebhookPolicy struct {
+	MaxAttempts int
+	RetryBase   time.Duration
+	MaxDelay    time.Duration
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (p WebhookPolicy) backoff(attempts int) time.Duration {
+	delay := p.RetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// webhookSender delivers messages and decides which hosts may receive them;
+// a *webhook.Sender outside tests
+type webhookSender interface {
+	Send(ctx context.Context, msg *webhook.Message, signingKey string) (webhook.Result, error)
+	CheckHost(ctx context.Context, host string) error
+	Timeout() time.Duration
+}
+
+type webhookService struct {
+	repo   repository.Repository
+	sender webhookSender
+	policy WebhookPolicy
+	log    *logger.CustomLogger
+}
+
+type WebhookService interface {
+	Subscribe(ctx context.Context, clientID, rawURL string, eventTypes, accountNumbers []string) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context, clientID string) ([]models.WebhookSubscription, error)
+	Unsubscribe(ctx context.Context, clientID, id string) error
+	ListDeliveries(ctx context.Context, clientID, status string, limit, offset int) ([]models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, clientID string, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error)
+	Redeliver(ctx context.Context, clientID string, id int64) (*models.WebhookDelivery, error)
+	DispatchBatch(ctx context.Context, batchSize int) (int, error)
+}
+
+func NewWebhookService(repo repository.Repository, sender *webhook.Sender, policy WebhookPolicy, log *logger.CustomLogger) WebhookService {
+	return &webhookService{
+		repo:   repo,
+		sender: sender,
+		policy: policy,
+		log:    log,
+	}
+}
+
+// Subscribe sends the partner the events of eventTypes on accountNumbers from
+// now on; an empty eventTypes subscribes to every event type. The partner
+// must be allowed to access every account, and the URL must resolve to
+// public addresses only. The subscription is returned with the secret its
+// deliveries are signed with, which is not shown again.
+func (s *webhookService) Subscribe(ctx context.Context, clientID, rawURL string, eventTypes, accountNumbers []string) (*models.WebhookSubscription, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, s.webhookError(ctx, "Subscribe", ErrInvalidWebhookURL)
+	}
+	for _, eventType := range eventTypes {
+		if _, ok := events.Versions[eventType]; !ok {
+			return nil, s.webhookError(ctx, "Subscribe", fmt.Errorf("%w: %s", ErrUnknownEventType, eventType))
+		}
+	}
+	if len(accountNumbers) == 0 {
+		return nil, s.webhookError(ctx, "Subscribe", ErrWebhookAccountsNeeded)
+	}
+	client, err := s.repo.GetAPIClient(ctx, clientID)
+	if err != nil {
+		return nil, s.webhookError(ctx, "Subscribe", err)
+	}
+	for _, accountNumber := range accountNumbers {
+		if !client.CanAccessAccount(accountNumber) {
+			return nil, s.webhookError(ctx, "Subscribe", fmt.Errorf("%w: %s", ErrAccountNotAllowed, accountNumber))
+		}
+	}
+	if err := s.sender.CheckHost(ctx, target.Hostname()); err != nil {
+		if !errors.Is(err, webhook.ErrForbiddenAddress) {
+			err = fmt.Errorf("%w: %v", ErrInvalidWebhookURL, err)
+		}
+		return nil, s.webhookError(ctx, "Subscribe", err)
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, s.webhookError(ctx, "Subscribe", err)
+	}
+
+	now := time.Now()
+	sub := &models.WebhookSubscription{
+		ID:             models.NewReference(),
+		ClientID:       clientID,
+		URL:            target.String(),
+		EventTypes:     eventTypes,
+		AccountNumbers: accountNumbers,
+		SigningSecret:  secret,
+		Active:         true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.repo.CreateWebhookSubscription(ctx, sub); err != nil {
+		return nil, s.webhookError(ctx, "Subscribe", err)
+	}
+
+	s.log.LogOperation(ctx, "Subscribe", "success", map[string]interface{}{
+		"type":            "service",
+		"api_client_id":   clientID,
+		"subscription_id": sub.ID,
+	})
+	return sub, nil
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context, clientID string) ([]models.WebhookSubscription, error) {
+	subs, err := s.repo.GetWebhookSubscriptions(ctx, clientID)
+	if err != nil {
+		return nil, s.webhookError(ctx, "ListSubscriptions", err)
+	}
+	return subs, nil
+}
+
+func (s *webhookService) Unsubscribe(ctx context.Context, clientID, id string) error {
+	if err := s.repo.DeactivateWebhookSubscription(ctx, id, clientID); err != nil {
+		return s.webhookError(ctx, "Unsubscribe", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the partner's deliveries, newest first. Status
+// DEAD lists the dead-letter deliveries.
+func (s *webhookService) ListDeliveries(ctx context.Context, clientID, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	switch status {
+	case "", models.WebhookPending, models.WebhookDelivered, models.WebhookDead:
+	default:
+		return nil, s.webhookError(ctx, "ListDeliveries", ErrUnknownWebhookStatus)
+	}
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := s.repo.GetWebhookDeliveries(ctx, clientID, status, limit, offset)
+	if err != nil {
+		return nil, s.webhookError(ctx, "ListDeliveries", err)
+	}
+	return deliveries, nil
+}
+
+// GetDelivery returns a delivery with its log of attempts
+func (s *webhookService) GetDelivery(ctx context.Context, clientID string, id int64) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	delivery, err := s.repo.GetWebhookDelivery(ctx, id, clientID)
+	if err != nil {
+		return nil, nil, s.webhookError(ctx, "GetDelivery", err)
+	}
+	attempts, err := s.repo.GetWebhookAttempts(ctx, id)
+	if err != nil {
+		return nil, nil, s.webhookError(ctx, "GetDelivery", err)
+	}
+	return delivery, attempts, nil
+}
+
+// Redeliver sends a delivery again, typically one from the dead-letter list,
+// with a fresh attempt budget
+func (s *webhookService) Redeliver(ctx context.Context, clientID string, id int64) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.RedeliverWebhook(ctx, id, clientID, time.Now())
+	if err != nil {
+		return nil, s.webhookError(ctx, "Redeliver", err)
+	}
+	return delivery, nil
+}
+
+// DispatchBatch leases up to batchSize due deliveries and sends them. The
+// lease commits before anything is sent, so slow partners hold no database
+// locks; it lasts long enough for every delivery of the batch to time out.
+// Each attempt is logged; a failed delivery is retried after a backoff until
+// it has failed MaxAttempts times and is dead-lettered. It returns how many
+// deliveries were claimed.
+func (s *webhookService) DispatchBatch(ctx context.Context, batchSize int) (int, error) {
+	now := time.Now()
+	lease := s.sender.Timeout() * time.Duration(batchSize+1)
+	deliveries, err := s.repo.ClaimWebhookDeliveries(ctx, now, now.Add(lease), batchSize)
+	if err != nil {
+		return 0, s.webhookError(ctx, "DispatchWebhooks", err)
+	}
+
+	delivered := 0
+	targets := newWebhookTargets(s.repo)
+	for i := range deliveries {
+		ok, err := s.dispatch(ctx, targets, &deliveries[i])
+		if err != nil {
+			return 0, s.webhookError(ctx, "DispatchWebhooks", err)
+		}
+		if ok {
+			delivered++
+		}
+	}
+
+	if len(deliveries) > 0 {
+		s.log.LogOperation(ctx, "DispatchWebhooks", "success", map[string]interface{}{
+			"type":      "service",
+			"claimed":   len(deliveries),
+			"delivered": delivered,
+		})
+	}
+	return len(deliveries), nil
+}
+
+// dispatch makes one attempt at a delivery and records its outcome. Only
+// database errors are returned. A delivery whose subscription ended, or whose
+// partner may no longer access the account, is dead-lettered unsent.
+func (s *webhookService) dispatch(ctx context.Context, targets *webhookTargets, delivery *models.WebhookDelivery) (bool, error) {
+	sub, client, err := targets.get(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return false, err
+	}
+
+	var result webhook.Result
+	var sendErr error
+	switch {
+	case !sub.Active || sub.SigningSecret == "" || client == nil || !client.Active:
+		sendErr = ErrSubscriptionNotActive
+	case !client.CanAccessAccount(aggregateID(delivery)):
+		sendErr = ErrAccountNotAllowed
+	default:
+		result, sendErr = s.sender.Send(ctx, &webhook.Message{
+			DeliveryID: strconv.FormatInt(delivery.ID, 10),
+			EventID:    delivery.EventID,
+			EventType:  delivery.EventType,
+			URL:        sub.URL,
+			Body:       delivery.Payload,
+		}, sub.SigningSecret)
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = result.StatusCode
+	attempt := &models.WebhookAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts,
+		StatusCode: result.StatusCode,
+		DurationMs: result.Duration.Milliseconds(),
+		CreatedAt:  now,
+	}
+
+	switch {
+	case sendErr == nil:
+		delivery.Status, delivery.LastError, delivery.DeliveredAt = models.WebhookDelivered, "", &now
+	case errors.Is(sendErr, ErrSubscriptionNotActive) || errors.Is(sendErr, ErrAccountNotAllowed) ||
+		delivery.Attempts >= s.policy.MaxAttempts:
+		delivery.Status, delivery.LastError = models.WebhookDead, sendErr.Error()
+	default:
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttemptAt = now.Add(s.policy.backoff(delivery.Attempts))
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+		s.log.LogWarning(ctx, "Webhook delivery failed", map[string]interface{}{
+			"delivery_id": delivery.ID,
+			"attempts":    delivery.Attempts,
+			"status":      delivery.Status,
+			"error":       sendErr.Error(),
+		})
+	}
+
+	err = s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		if err := repo.CreateWebhookAttempt(ctx, attempt); err != nil {
+			return err
+		}
+		return repo.UpdateWebhookDelivery(ctx, delivery)
+	})
+	if err != nil {
+		return false, err
+	}
+	return sendErr == nil, nil
+}
+
+// aggregateID returns the account a delivered event is about
+func aggregateID(delivery *models.WebhookDelivery) string {
+	var envelope events.Envelope
+	if err := json.Unmarshal(delivery.Payload, &envelope); err != nil {
+		return ""
+	}
+	return envelope.AggregateID
+}
+
+// webhookTargets caches the subscriptions and partners of a batch
+type webhookTargets struct {
+	repo    repository.Repository
+	subs    map[string]*models.WebhookSubscription
+	clients map[string]*models.APIClient
+}
+
+func newWebhookTargets(repo repository.Repository) *webhookTargets {
+	return &webhookTargets{
+		repo:    repo,
+		subs:    make(map[string]*models.WebhookSubscription),
+		clients: make(map[string]*models.APIClient),
+	}
+}
+
+// get returns a subscription and its partner. The partner is nil when it no
+// longer exists.
+func (t *webhookTargets) get(ctx context.Context, subscriptionID string) (*models.WebhookSubscription, *models.APIClient, error) {
+	sub, ok := t.subs[subscriptionID]
+	if !ok {
+		var err error
+		if sub, err = t.repo.GetWebhookSubscription(ctx, subscriptionID); err != nil {
+			return nil, nil, err
+		}
+		t.subs[subscriptionID] = sub
+	}
+
+	client, ok := t.clients[sub.ClientID]
+	if !ok {
+		var err error
+		client, err = t.repo.GetAPIClient(ctx, sub.ClientID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+		t.clients[sub.ClientID] = client
+	}
+	return sub, client, nil
+}
+
+func (s *webhookService) webhookError(ctx context.Context, op string, err error) error {
+	s.log.LogOperation(ctx, op, "error", map[string]interface{}{
+		"error": err.Error(),
+	})
+	return err
+}
+
+// WebhookDispatcher periodically sends due webhook deliveries
+type WebhookDispatcher struct {
+	service   WebhookService
+	interval  time.Duration
+	batchSize int
+	log       *logger.CustomLogger
+}
+
+func NewWebhookDispatcher(service WebhookService, interval time.Duration, batchSize int, log *logger.CustomLogger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		service:   service,
+		interval:  interval,
+		batchSize: batchSize,
+		log:       log,
+	}
+}
+
+// Run dispatches every interval until ctx is cancelled
+func (w *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.drain(ctx)
+		}
+	}
+}
+
+// drain keeps dispatching full batches so a backlog clears in one tick
+func (w *WebhookDispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed, err := w.service.DispatchBatch(ctx, w.batchSize)
+		if err != nil {
+			w.log.LogWarning(ctx, "Webhook dispatch failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+		if claimed < w.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/webhook"
+)
+
+const testSigningKey = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+// loopbackSender signs and posts deliveries like webhook.Sender but, unlike
+// it, reaches the loopback test servers
+type loopbackSender struct{}
+
+func (loopbackSender) Send(ctx context.Context, msg *webhook.Message, signingKey string) (webhook.Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return webhook.Result{}, err
+	}
+	if err := webhook.Sign(req, msg.Body, signingKey, time.Now()); err != nil {
+		return webhook.Result{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return webhook.Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return webhook.Result{StatusCode: resp.StatusCode}, fmt.Errorf("partner responded %s", resp.Status)
+	}
+	return webhook.Result{StatusCode: resp.StatusCode}, nil
+}
+
+func (loopbackSender) CheckHost(ctx context.Context, host string) error { return nil }
+
+func (loopbackSender) Timeout() time.Duration { return time.Second }
+
+// webhookRepo keeps one subscription, its partner and the due deliveries in
+// memory. Methods the dispatcher does not use fall through to the embedded nil
+// interface and panic.
+type webhookRepo struct {
+	repository.Repository
+	sub        models.WebhookSubscription
+	client     models.APIClient
+	deliveries map[int64]*models.WebhookDelivery
+	attempts   []models.WebhookAttempt
+}
+
+func newWebhookRepo(url string, payload string) *webhookRepo {
+	return &webhookRepo{
+		sub: models.WebhookSubscription{
+			ID:             "sub-1",
+			ClientID:       "partner-1",
+			URL:            url,
+			AccountNumbers: []string{"1234567890"},
+			SigningSecret:  testSigningKey,
+			Active:         true,
+		},
+		client: models.APIClient{ClientID: "partner-1", AllowedAccounts: []string{"1234567890"}, Active: true},
+		deliveries: map[int64]*models.WebhookDelivery{
+			1: {
+				ID:             1,
+				SubscriptionID: "sub-1",
+				EventID:        "evt-1",
+				EventType:      "Deposited",
+				Payload:        []byte(payload),
+				Status:         models.WebhookPending,
+			},
+		},
+	}
+}
+
+func (r *webhookRepo) WithTx(ctx context.Context, fn func(repo repository.Repository) error) error {
+	return fn(r)
+}
+
+func (r *webhookRepo) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var due []models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == models.WebhookPending && !d.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, *d)
+			d.NextAttemptAt = leaseUntil
+		}
+	}
+	return due, nil
+}
+
+func (r *webhookRepo) GetWebhookSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	if id != r.sub.ID {
+		return nil, repository.ErrWebhookSubscriptionNotFound
+	}
+	sub := r.sub
+	return &sub, nil
+}
+
+func (r *webhookRepo) GetAPIClient(ctx context.Context, clientID string) (*models.APIClient, error) {
+	client := r.client
+	return &client, nil
+}
+
+func (r *webhookRepo) CreateWebhookAttempt(ctx context.Context, attempt *models.WebhookAttempt) error {
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *webhookRepo) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	d := *delivery
+	r.deliveries[delivery.ID] = &d
+	return nil
+}
+
+// partnerEndpoint answers with status after checking the webhook signature
+func partnerEndpoint(t *testing.T, status int, calls *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(r, body, testSigningKey, time.Minute, time.Now()); err != nil {
+			t.Errorf("verify: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestWebhookService(t *testing.T, repo repository.Repository, policy WebhookPolicy) WebhookService {
+	t.Helper()
+	return &webhookService{repo: repo, sender: loopbackSender{}, policy: policy, log: testLogger(t)}
+}
+
+func TestDispatchDelivers(t *testing.T) {
+	var calls int32
+	srv := partnerEndpoint(t, http.StatusOK, &calls)
+	repo := newWebhookRepo(srv.URL, `{"id":"evt-1","aggregate_id":"1234567890"}`)
+	svc := newTestWebhookService(t, repo, WebhookPolicy{MaxAttempts: 3, RetryBase: time.Second, MaxDelay: time.Minute})
+
+	claimed, err := svc.DispatchBatch(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if claimed != 1 || calls != 1 {
+		t.Fatalf("claimed %d, partner called %d times; want 1 and 1", claimed, calls)
+	}
+
+	d := repo.deliveries[1]
+	if d.Status != models.WebhookDelivered || d.DeliveredAt == nil || d.Attempts != 1 {
+		t.Errorf("delivery = %+v, want delivered after one attempt", d)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].StatusCode != http.StatusOK || repo.attempts[0].Error != "" {
+		t.Errorf("attempt log = %+v, want one successful attempt", repo.attempts)
+	}
+}
+
+func TestDispatchBacksOffAndDeadLetters(t *testing.T) {
+	var calls int32
+	srv := partnerEndpoint(t, http.StatusInternalServerError, &calls)
+	repo := newWebhookRepo(srv.URL, `{"id":"evt-1","aggregate_id":"1234567890"}`)
+	policy := WebhookPolicy{MaxAttempts: 3, RetryBase: time.Second, MaxDelay: time.Minute}
+	svc := newTestWebhookService(t, repo, policy)
+
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		before := time.Now()
+		if _, err := svc.DispatchBatch(context.Background(), 10); err != nil {
+			t.Fatalf("dispatch %d: %v", attempt, err)
+		}
+
+		d := repo.deliveries[1]
+		if d.Attempts != attempt || d.LastStatusCode != http.StatusInternalServerError {
+			t.Fatalf("after attempt %d: delivery = %+v", attempt, d)
+		}
+		if attempt < policy.MaxAttempts {
+			if d.Status != models.WebhookPending {
+				t.Fatalf("after attempt %d: status %s, want %s", attempt, d.Status, models.WebhookPending)
+			}
+			wait := policy.backoff(attempt)
+			if d.NextAttemptAt.Before(before.Add(wait)) {
+				t.Errorf("after attempt %d: next attempt at %v, want at least %v later", attempt, d.NextAttemptAt, wait)
+			}
+			// make the retry due now
+			d.NextAttemptAt = time.Time{}
+		} else if d.Status != models.WebhookDead {
+			t.Fatalf("after attempt %d: status %s, want %s", attempt, d.Status, models.WebhookDead)
+		}
+	}
+
+	if claimed, _ := svc.DispatchBatch(context.Background(), 10); claimed != 0 {
+		t.Errorf("dead delivery claimed again")
+	}
+	if int(calls) != policy.MaxAttempts || len(repo.attempts) != policy.MaxAttempts {
+		t.Errorf("partner called %d times with %d attempts logged, want %d", calls, len(repo.attempts), policy.MaxAttempts)
+	}
+}
+
+func TestDispatchDeadLettersEndedSubscription(t *testing.T) {
+	var calls int32
+	srv := partnerEndpoint(t, http.StatusOK, &calls)
+	repo := newWebhookRepo(srv.URL, `{}`)
+	repo.sub.Active = false
+	svc := newTestWebhookService(t, repo, WebhookPolicy{MaxAttempts: 5, RetryBase: time.Second, MaxDelay: time.Minute})
+
+	if _, err := svc.DispatchBatch(context.Background(), 10); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("partner called %d times for an ended subscription", calls)
+	}
+	if d := repo.deliveries[1]; d.Status != models.WebhookDead {
+		t.Errorf("status %s, want %s", d.Status, models.WebhookDead)
+	}
+}
+
+func TestDispatchDeadLettersRevokedAccount(t *testing.T) {
+	var calls int32
+	srv := partnerEndpoint(t, http.StatusOK, &calls)
+	repo := newWebhookRepo(srv.URL, `{"id":"evt-1","aggregate_id":"1234567890"}`)
+	repo.client.AllowedAccounts = []string{"9999999999"}
+	svc := newTestWebhookService(t, repo, WebhookPolicy{MaxAttempts: 5, RetryBase: time.Second, MaxDelay: time.Minute})
+
+	if _, err := svc.DispatchBatch(context.Background(), 10); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("partner called %d times for an account it may no longer access", calls)
+	}
+	if d := repo.deliveries[1]; d.Status != models.WebhookDead {
+		t.Errorf("status %s, want %s", d.Status, models.WebhookDead)
+	}
+}
+
+func TestSubscribeRequiresAllowedAccounts(t *testing.T) {
+	repo := newWebhookRepo("", "")
+	svc := newTestWebhookService(t, repo, WebhookPolicy{})
+
+	for name, tc := range map[string]struct {
+		accounts []string
+		want     error
+	}{
+		"no accounts":     {nil, ErrWebhookAccountsNeeded},
+		"foreign account": {[]string{"1234567890", "9999999999"}, ErrAccountNotAllowed},
+	} {
+		_, err := svc.Subscribe(context.Background(), "partner-1", "https://partner.example.com/hooks", nil, tc.accounts)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: Subscribe = %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	policy := WebhookPolicy{RetryBase: time.Second, MaxDelay: 5 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := policy.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
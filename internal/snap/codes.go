@@ -0,0 +1,95 @@
+// Package snap holds the Bank Indonesia SNAP (Standar Nasional Open API
+// Pembayaran) response code conventions shared by the SNAP handlers and middleware.
+package snap
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/repository"
+	"github.com/alfaa19/service-account-test/internal/service"
+)
+
+// SNAP request headers
+const (
+	HeaderTimestamp  = "X-TIMESTAMP"
+	HeaderSignature  = "X-SIGNATURE"
+	HeaderPartnerID  = "X-PARTNER-ID"
+	HeaderExternalID = "X-EXTERNAL-ID"
+	HeaderClientKey  = "X-CLIENT-KEY"
+	HeaderChannelID  = "CHANNEL-ID"
+)
+
+// SNAP service codes
+const (
+	ServiceBalanceInquiry     = "11"
+	ServiceTransactionHistory = "12"
+	ServiceTransferIntrabank  = "17"
+	ServiceAccessToken        = "73"
+)
+
+// SNAP case codes
+const (
+	CaseSuccess               = "00"
+	CaseInvalidFieldFormat    = "01"
+	CaseInvalidMandatoryField = "02"
+	CaseInvalidToken          = "01"
+	CaseFeatureNotAllowed     = "01"
+	CaseExceedsAmountLimit    = "02"
+	CaseExceedsCountLimit     = "04"
+	CaseInsufficientFunds     = "14"
+	CaseNotPermitted          = "15"
+	CaseInvalidAccount        = "11"
+	CaseInactiveAccount       = "18"
+	CaseGeneral               = "00"
+)
+
+// ResponseCode builds the 7-digit SNAP response code: HTTP status, service
+// code and case code
+func ResponseCode(httpStatus int, serviceCode, caseCode string) string {
+	return fmt.Sprintf("%d%s%s", httpStatus, serviceCode, caseCode)
+}
+
+// Error maps a service or repository error to its HTTP status, SNAP case
+// code and response message
+func Error(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAccessToken):
+		return http.StatusUnauthorized, CaseInvalidToken, "Invalid Token (B2B)"
+	case errors.Is(err, service.ErrUnknownClient),
+		errors.Is(err, service.ErrInvalidSignature),
+		errors.Is(err, service.ErrPartnerMismatch),
+		errors.Is(err, service.ErrStaleTimestamp),
+		errors.Is(err, service.ErrMalformedTimestamp),
+		errors.Is(err, service.ErrInvalidPin):
+		return http.StatusUnauthorized, CaseGeneral, "Unauthorized. " + err.Error()
+	case errors.Is(err, service.ErrIPNotAllowed), errors.Is(err, service.ErrRouteNotAllowed):
+		return http.StatusForbidden, CaseFeatureNotAllowed, "Feature Not Allowed"
+	case errors.Is(err, service.ErrPinLocked), errors.Is(err, service.ErrAccountNotAllowed):
+		return http.StatusForbidden, CaseNotPermitted, "Transaction Not Permitted. " + err.Error()
+	case errors.Is(err, service.ErrMissingExternalID):
+		return http.StatusBadRequest, CaseInvalidMandatoryField, "Invalid Mandatory Field X-EXTERNAL-ID"
+	case errors.Is(err, service.ErrInvalidAmount), errors.Is(err, service.ErrSameAccount):
+		return http.StatusBadRequest, CaseInvalidFieldFormat, "Invalid Field Format. " + err.Error()
+	case errors.Is(err, service.ErrDuplicateExternalID):
+		return http.StatusConflict, CaseGeneral, "Conflict"
+	case errors.Is(err, repository.ErrAccountFrozen),
+		errors.Is(err, repository.ErrAccountDormant),
+		errors.Is(err, repository.ErrAccountClosed):
+		return http.StatusForbidden, CaseInactiveAccount, "Inactive Account"
+	case errors.Is(err, service.ErrLimitExceeded):
+		var limitErr *service.LimitError
+		if errors.As(err, &limitErr) && limitErr.Limit == service.LimitDailyCount {
+			return http.StatusForbidden, CaseExceedsCountLimit, "Activity Count Limit Exceeded. " + err.Error()
+		}
+		return http.StatusForbidden, CaseExceedsAmountLimit, "Exceeds Transaction Amount Limit. " + err.Error()
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		return http.StatusForbidden, CaseInsufficientFunds, "Insufficient Funds"
+	case errors.Is(err, repository.ErrAccountNotFound), errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, CaseInvalidAccount, "Invalid Account"
+	default:
+		return http.StatusInternalServerError, CaseGeneral, "General Error"
+	}
+}
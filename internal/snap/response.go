@@ -0,0 +1,37 @@
+package snap
+
+import (
+	"net/http"
+
+	"github.com/alfaa19/service-account-test/internal/models/dto"
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorResponse writes err as a SNAP error body with its response code
+func ErrorResponse(c echo.Context, serviceCode string, err error) error {
+	status, caseCode, message := Error(err)
+	return c.JSON(status, dto.SnapResponse{
+		ResponseCode:    ResponseCode(status, serviceCode, caseCode),
+		ResponseMessage: message,
+	})
+}
+
+// InvalidField writes a 400 SNAP response for a malformed or missing field
+func InvalidField(c echo.Context, serviceCode, caseCode, field string) error {
+	message := "Invalid Field Format " + field
+	if caseCode == CaseInvalidMandatoryField {
+		message = "Invalid Mandatory Field " + field
+	}
+	return c.JSON(http.StatusBadRequest, dto.SnapResponse{
+		ResponseCode:    ResponseCode(http.StatusBadRequest, serviceCode, caseCode),
+		ResponseMessage: message,
+	})
+}
+
+// Success returns the successful SNAP response header for a service
+func Success(serviceCode string) dto.SnapResponse {
+	return dto.SnapResponse{
+		ResponseCode:    ResponseCode(http.StatusOK, serviceCode, CaseSuccess),
+		ResponseMessage: "Successful",
+	}
+}
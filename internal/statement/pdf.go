@@ -0,0 +1,85 @@
+package statement
+
+import (
+	"io"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+	"github.com/go-pdf/fpdf"
+)
+
+// Column widths of the transaction table in millimetres, summing to the
+// printable width of an A4 page with 10mm margins
+var pdfColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"Tanggal", 32, "L"},
+	{"Referensi", 42, "L"},
+	{"Keterangan", 46, "L"},
+	{"Debit", 23, "R"},
+	{"Kredit", 23, "R"},
+	{"Saldo", 24, "R"},
+}
+
+// WritePDF renders the statement as an A4 PDF
+func WritePDF(w io.Writer, st *models.Statement) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle("Rekening Koran "+st.AccountNumber+" "+st.Period, true)
+	pdf.AddPage()
+	// The core fonts are cp1252; translate names and descriptions from UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, "Rekening Koran", "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range [][2]string{
+		{"Nama", tr(st.Name)},
+		{"No Rekening", st.AccountNumber},
+		{"NIK", st.MaskedNIK},
+		{"Periode", st.Period},
+	} {
+		pdf.CellFormat(30, 6, line[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, ": "+line[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 9)
+	for _, col := range pdfColumns {
+		pdf.CellFormat(col.width, 7, col.title, "1", 0, col.align, false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdfRow(pdf, "", "", "Saldo Awal", "", "", amount(st.OpeningBalance))
+	for _, trx := range st.Transactions {
+		debit, credit := entryAmounts(trx)
+		pdfRow(pdf,
+			models.BusinessTime(trx.CreatedAt).Format("2006-01-02 15:04"),
+			trx.Reference,
+			tr(description(trx)),
+			debit,
+			credit,
+			amount(trx.BalanceAfter),
+		)
+	}
+
+	pdf.SetFont("Helvetica", "B", 8)
+	pdfRow(pdf, "", "", "Saldo Akhir", amount(st.TotalDebit), amount(st.TotalCredit), amount(st.ClosingBalance))
+
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "I", 8)
+	pdf.CellFormat(0, 5, "Dicetak "+models.BusinessTime(st.GeneratedAt).Format("2006-01-02 15:04")+" WIB", "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+func pdfRow(pdf *fpdf.Fpdf, values ...string) {
+	for i, col := range pdfColumns {
+		pdf.CellFormat(col.width, 6, values[i], "1", 0, col.align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
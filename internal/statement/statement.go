@@ -0,0 +1,106 @@
+// Package statement renders account statements (rekening koran) as CSV and
+// PDF.
+package statement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// Output formats
+const (
+	FormatCSV = "csv"
+	FormatPDF = "pdf"
+)
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	if format == FormatPDF {
+		return "application/pdf"
+	}
+	return "text/csv"
+}
+
+// FileName returns the file name a statement is saved under
+func FileName(st *models.Statement, format string) string {
+	return fmt.Sprintf("rekening-koran-%s-%s.%s", st.AccountNumber, st.Period, format)
+}
+
+// Write renders a statement in the given format
+func Write(w io.Writer, st *models.Statement, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, st)
+	case FormatPDF:
+		return WritePDF(w, st)
+	default:
+		return fmt.Errorf("unknown statement format %q", format)
+	}
+}
+
+// WriteCSV writes the statement header, one row per ledger entry between the
+// opening and closing balance rows, and the totals
+func WriteCSV(w io.Writer, st *models.Statement) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"Nama", csvText(st.Name)},
+		{"No Rekening", st.AccountNumber},
+		{"NIK", st.MaskedNIK},
+		{"Periode", st.Period},
+		{},
+		{"Tanggal", "Referensi", "Keterangan", "Debit", "Kredit", "Saldo"},
+		{"", "", "Saldo Awal", "", "", amount(st.OpeningBalance)},
+	}
+	for _, trx := range st.Transactions {
+		debit, credit := entryAmounts(trx)
+		rows = append(rows, []string{
+			models.BusinessTime(trx.CreatedAt).Format("2006-01-02 15:04:05"),
+			csvText(trx.Reference),
+			csvText(description(trx)),
+			debit,
+			credit,
+			amount(trx.BalanceAfter),
+		})
+	}
+	rows = append(rows,
+		[]string{"", "", "Saldo Akhir", amount(st.TotalDebit), amount(st.TotalCredit), amount(st.ClosingBalance)},
+	)
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func entryAmounts(trx models.Transaction) (string, string) {
+	if trx.Direction == models.DirectionDebit {
+		return amount(trx.Amount), ""
+	}
+	return "", amount(trx.Amount)
+}
+
+// description falls back to the transaction type for entries posted
+// without one
+func description(trx models.Transaction) string {
+	if trx.Description != "" {
+		return trx.Description
+	}
+	return trx.Type
+}
+
+func amount(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
+
+// csvText quotes text cells a spreadsheet would otherwise evaluate as a
+// formula with a leading apostrophe
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
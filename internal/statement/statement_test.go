@@ -0,0 +1,47 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	st := &models.Statement{
+		AccountNumber: "1234567890",
+		Name:          "=HYPERLINK(\"http://evil\")",
+		Period:        "2026-09",
+		Transactions: []models.Transaction{{
+			Reference:    "@SUM(A1)",
+			Direction:    models.DirectionCredit,
+			Amount:       1000,
+			BalanceAfter: 1000,
+			Description:  "+62 transfer",
+			CreatedAt:    time.Date(2026, 9, 1, 3, 0, 0, 0, time.UTC),
+		}},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, st); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	reader := csv.NewReader(&buf)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+
+	if got := rows[0][1]; got != "'=HYPERLINK(\"http://evil\")" {
+		t.Errorf("name cell = %q", got)
+	}
+	entry := rows[len(rows)-2]
+	if entry[1] != "'@SUM(A1)" || entry[2] != "'+62 transfer" {
+		t.Errorf("entry cells = %q", entry)
+	}
+	if entry[4] != "1000.00" {
+		t.Errorf("credit cell = %q, amounts must stay numeric", entry[4])
+	}
+}
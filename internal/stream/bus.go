@@ -0,0 +1,113 @@
+// Package stream fans committed balance changes out to the clients following
+// an account. The bus is in-process: each server only sees the ledger entries
+// it commits itself, and clients resume anything they missed from the
+// database by the ID of the last entry they saw.
+package stream
+
+import (
+	"sync"
+
+	"github.com/alfaa19/service-account-test/internal/models"
+)
+
+// Subscription receives the ledger entries of one account. C is closed when
+// the subscriber falls too far behind or the bus closes; the client then
+// reconnects and resumes from the last entry it received.
+type Subscription struct {
+	C <-chan models.Transaction
+
+	c       chan models.Transaction
+	account string
+	bus     *Bus
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.bus.remove(s)
+}
+
+// Bus delivers ledger entries to the subscriptions of their account
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+// NewBus creates a bus whose subscribers may lag buffer entries behind
+func NewBus(buffer int) *Bus {
+	return &Bus{
+		subs:   make(map[string]map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscribe follows the entries of an account. Subscribing to a closed bus
+// returns a subscription whose channel is already closed.
+func (b *Bus) Subscribe(accountNumber string) *Subscription {
+	c := make(chan models.Transaction, b.buffer)
+	sub := &Subscription{C: c, c: c, account: accountNumber, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return sub
+	}
+	if b.subs[accountNumber] == nil {
+		b.subs[accountNumber] = make(map[*Subscription]struct{})
+	}
+	b.subs[accountNumber][sub] = struct{}{}
+	return sub
+}
+
+// LedgerCommitted publishes entries once their database transaction has
+// committed. It never blocks: a subscriber whose buffer is full is dropped.
+func (b *Bus) LedgerCommitted(entries []models.Transaction) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, entry := range entries {
+		for sub := range b.subs[entry.AccountNumber] {
+			select {
+			case sub.c <- entry:
+			default:
+				b.drop(sub)
+			}
+		}
+	}
+}
+
+// Close ends every subscription, letting their streams finish before the
+// HTTP server shuts down
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.drop(sub)
+		}
+	}
+}
+
+func (b *Bus) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+// drop removes a subscription and closes its channel. b.mu must be held.
+func (b *Bus) drop(sub *Subscription) {
+	subs, ok := b.subs[sub.account]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.account)
+	}
+	close(sub.c)
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/alfaa19/service-account-test/internal/errcode"
+)
+
+// ErrForbiddenAddress rejects webhook URLs pointing into the service's own
+// network, which would let a partner make the service call internal
+// endpoints
+var ErrForbiddenAddress = errcode.New("WEBHOOK_ADDRESS_FORBIDDEN", "webhook url resolves to a loopback, private or link-local address")
+
+// CheckHost resolves host and rejects it when the sender would refuse any of
+// its addresses
+func (s *Sender) CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := s.check(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIP rejects loopback, private, link-local, multicast and unspecified
+// addresses
+func checkIP(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// dialControl checks the address a delivery actually connects to, after DNS
+// resolution and for every redirect, so a host cannot be rebound to an
+// internal address once its subscription was accepted
+func dialControl(check func(net.IP) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+		}
+		return check(ip)
+	}
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxErrorBody is how much of a failed response is kept in the delivery log
+const maxErrorBody = 512
+
+// Message is one delivery of an event to a partner URL
+type Message struct {
+	DeliveryID string
+	EventID    string
+	EventType  string
+	URL        string
+	Body       []byte
+}
+
+// Result describes one delivery attempt
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+// Sender posts signed messages
+type Sender struct {
+	client *http.Client
+	check  func(net.IP) error
+}
+
+// NewSender creates a sender whose deliveries time out after timeout. It
+// only connects to public addresses.
+func NewSender(timeout time.Duration) *Sender {
+	return newSender(timeout, checkIP)
+}
+
+// newSender creates a sender connecting only to addresses check accepts
+func newSender(timeout time.Duration, check func(net.IP) error) *Sender {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialControl(check)}
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		},
+		check: check,
+	}
+}
+
+// Timeout returns how long a single delivery may take
+func (s *Sender) Timeout() time.Duration {
+	return s.client.Timeout
+}
+
+// Send POSTs msg signed with signingKey. Only a 2xx response delivers it;
+// anything else is returned as an error together with the status code, if
+// the partner answered at all.
+func (s *Sender) Send(ctx context.Context, msg *Message, signingKey string) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, msg.DeliveryID)
+	req.Header.Set(HeaderEventID, msg.EventID)
+	req.Header.Set(HeaderEventType, msg.EventType)
+	if err := Sign(req, msg.Body, signingKey, time.Now()); err != nil {
+		return Result{}, err
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("partner responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+// Package webhook delivers signed event notifications to partners. Deliveries
+// are signed like partner requests: an HMAC-SHA256 with the subscription's
+// secret over the method, path, body hash, timestamp and nonce, so partners
+// verify them with the code they already sign their own requests with.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/alfaa19/service-account-test/internal/auth"
+	"github.com/alfaa19/service-account-test/internal/errcode"
+)
+
+// Headers of a webhook delivery
+const (
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+	HeaderID        = "X-Webhook-ID"
+	HeaderEventID   = "X-Event-ID"
+	HeaderEventType = "X-Event-Type"
+)
+
+var (
+	ErrMissingSignature = errcode.New("WEBHOOK_SIGNATURE_MISSING", "webhook signature headers missing")
+	ErrInvalidSignature = errcode.New("WEBHOOK_SIGNATURE_INVALID", "invalid webhook signature")
+	ErrStaleTimestamp   = errcode.New("WEBHOOK_TIMESTAMP_STALE", "webhook timestamp outside the allowed window")
+)
+
+// Sign sets the timestamp, nonce and signature headers of req, whose body is
+// body
+func Sign(req *http.Request, body []byte, signingKey string, now time.Time) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := now.Format(time.RFC3339)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, auth.Sign(signingKey, stringToSign(req, body, timestamp, nonce)))
+	return nil
+}
+
+// Verify checks the signature of a received delivery and that it was signed
+// within window of now. Receivers should also reject nonces they have seen.
+func Verify(req *http.Request, body []byte, signingKey string, window time.Duration, now time.Time) error {
+	timestamp := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	signature := req.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	signedAt, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil || now.Sub(signedAt) > window || signedAt.Sub(now) > window {
+		return ErrStaleTimestamp
+	}
+	if !auth.VerifySignature(signingKey, stringToSign(req, body, timestamp, nonce), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func stringToSign(req *http.Request, body []byte, timestamp, nonce string) string {
+	return auth.StringToSign(req.Method, req.URL.RequestURI(), auth.BodyHash(body), timestamp, nonce)
+}
+
+func newNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
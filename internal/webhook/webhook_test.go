@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testKey = "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+
+// testSender sends to the loopback test servers NewSender refuses
+func testSender() *Sender {
+	return newSender(time.Second, func(net.IP) error { return nil })
+}
+
+// receiver is a partner endpoint that verifies every webhook it receives
+func receiver(t *testing.T, status int, got chan<- *http.Request) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		if err := Verify(r, body, testKey, time.Minute, time.Now()); err != nil {
+			t.Errorf("verify: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got != nil {
+			got <- r
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, "nope")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	got := make(chan *http.Request, 1)
+	srv := receiver(t, http.StatusNoContent, got)
+
+	result, err := testSender().Send(context.Background(), &Message{
+		DeliveryID: "7",
+		EventID:    "evt-1",
+		EventType:  "Deposited",
+		URL:        srv.URL + "/hooks?partner=teller",
+		Body:       []byte(`{"id":"evt-1"}`),
+	}, testKey)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if result.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", result.StatusCode, http.StatusNoContent)
+	}
+
+	req := <-got
+	for header, want := range map[string]string{HeaderID: "7", HeaderEventID: "evt-1", HeaderEventType: "Deposited"} {
+		if v := req.Header.Get(header); v != want {
+			t.Errorf("%s = %q, want %q", header, v, want)
+		}
+	}
+}
+
+func TestSendFailsOnNon2xx(t *testing.T) {
+	srv := receiver(t, http.StatusServiceUnavailable, nil)
+
+	result, err := testSender().Send(context.Background(), &Message{
+		DeliveryID: "8",
+		URL:        srv.URL,
+		Body:       []byte(`{}`),
+	}, testKey)
+	if err == nil {
+		t.Fatal("expected an error for a 503 response")
+	}
+	if result.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", result.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	srv := receiver(t, http.StatusNoContent, nil)
+
+	_, err := NewSender(time.Second).Send(context.Background(), &Message{
+		DeliveryID: "9",
+		URL:        srv.URL,
+		Body:       []byte(`{}`),
+	}, testKey)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("send to %s = %v, want %v", srv.URL, err, ErrForbiddenAddress)
+	}
+
+	sender := NewSender(time.Second)
+	for _, host := range []string{"127.0.0.1", "10.1.2.3", "169.254.169.254", "::1", "0.0.0.0"} {
+		if err := sender.CheckHost(context.Background(), host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%s) = %v, want %v", host, err, ErrForbiddenAddress)
+		}
+	}
+	if err := sender.CheckHost(context.Background(), "8.8.8.8"); err != nil {
+		t.Errorf("CheckHost(8.8.8.8) = %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	body := []byte(`{"amount":100}`)
+	now := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/hooks", nil)
+	if err := Sign(req, body, testKey, now); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		body []byte
+		key  string
+		now  time.Time
+		want error
+	}{
+		{"valid", body, testKey, now, nil},
+		{"tampered body", []byte(`{"amount":900}`), testKey, now, ErrInvalidSignature},
+		{"wrong key", body, "other", now, ErrInvalidSignature},
+		{"stale", body, testKey, now.Add(time.Hour), ErrStaleTimestamp},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(req, tc.body, tc.key, 5*time.Minute, tc.now)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	req.Header.Del(HeaderSignature)
+	if err := Verify(req, body, testKey, 5*time.Minute, now); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("Verify without signature = %v, want %v", err, ErrMissingSignature)
+	}
+}
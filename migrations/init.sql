@@ -2,8 +2,8 @@ CREATE TABLE IF NOT EXISTS accounts (
     id SERIAL PRIMARY KEY,
     account_number VARCHAR(20) NOT NULL UNIQUE,
     name VARCHAR(100) NOT NULL,
-    nik VARCHAR(20) NOT NULL UNIQUE,
-    phone_number VARCHAR(15) NOT NULL UNIQUE,
+    nik VARCHAR(20) NOT NULL,
+    phone_number VARCHAR(15) NOT NULL,
     balance DECIMAL(15,2) NOT NULL DEFAULT 0.00,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
@@ -23,7 +23,430 @@ BEGIN
 END;
 $$ language 'plpgsql';
 
+DROP TRIGGER IF EXISTS update_accounts_updated_at ON accounts;
 CREATE TRIGGER update_accounts_updated_at
     BEFORE UPDATE ON accounts
     FOR EACH ROW
-    EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Login credentials (PIN or password), stored as bcrypt hashes
+CREATE TABLE IF NOT EXISTS account_credentials (
+    account_number VARCHAR(20) PRIMARY KEY REFERENCES accounts(account_number),
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Refresh tokens are opaque random strings, only their SHA-256 hash is kept
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_account_number ON refresh_tokens(account_number);
+
+-- 6-digit transaction PIN, stored as a bcrypt hash, with lockout state
+CREATE TABLE IF NOT EXISTS account_pins (
+    account_number VARCHAR(20) PRIMARY KEY REFERENCES accounts(account_number),
+    pin_hash VARCHAR(255) NOT NULL,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Audit trail of every PIN verification attempt
+CREATE TABLE IF NOT EXISTS pin_attempts (
+    id SERIAL PRIMARY KEY,
+    account_number VARCHAR(20) NOT NULL,
+    operation VARCHAR(50) NOT NULL,
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pin_attempts_account_number ON pin_attempts(account_number);
+
+-- Registry of partner systems calling the API server-to-server. The HMAC
+-- signing key is SHA-256(secret), stored unencrypted. It is a plaintext
+-- equivalent of the secret: it signs requests just like the secret, so
+-- access to this table and its backups must be restricted accordingly.
+CREATE TABLE IF NOT EXISTS api_clients (
+    client_id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    signing_key VARCHAR(64) NOT NULL,
+    allowed_routes TEXT NOT NULL DEFAULT '',
+    ip_allowlist TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Nonces seen within the signature validity window, for replay protection
+CREATE TABLE IF NOT EXISTS api_nonces (
+    client_id VARCHAR(64) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (client_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_nonces_expires_at ON api_nonces(expires_at);
+
+-- Databases created before signing_key was named for what it holds
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'api_clients' AND column_name = 'secret_hash') THEN
+        ALTER TABLE api_clients RENAME COLUMN secret_hash TO signing_key;
+    END IF;
+END $$;
+
+-- Ledger of every balance movement. Entries of one business transaction
+-- (e.g. both legs of a transfer) share the same reference.
+CREATE TABLE IF NOT EXISTS transactions (
+    id BIGSERIAL PRIMARY KEY,
+    reference VARCHAR(64) NOT NULL,
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    type VARCHAR(30) NOT NULL,
+    direction CHAR(1) NOT NULL CHECK (direction IN ('D', 'C')),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    balance_after DECIMAL(15,2) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_account_created ON transactions(account_number, created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_reference ON transactions(reference);
+
+-- Accounts opened before the ledger existed start it with one OPENING_BALANCE
+-- entry for their balance, so their ledger sums to the stored balance.
+-- Accounts that already have entries are left alone.
+INSERT INTO transactions (reference, account_number, type, direction, amount, balance_after, description, created_at)
+SELECT 'OPENING-' || a.account_number, a.account_number, 'OPENING_BALANCE', 'C', a.balance, a.balance,
+       'Saldo awal', a.created_at
+FROM accounts a
+WHERE a.balance > 0
+  AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.account_number = a.account_number);
+
+-- SNAP public key of partners using the asymmetric access-token signature
+ALTER TABLE api_clients ADD COLUMN IF NOT EXISTS public_key TEXT NOT NULL DEFAULT '';
+
+-- X-EXTERNAL-ID values seen per partner and day, SNAP requires them to be unique
+CREATE TABLE IF NOT EXISTS external_ids (
+    partner_id VARCHAR(64) NOT NULL,
+    external_id VARCHAR(64) NOT NULL,
+    business_date DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (partner_id, external_id, business_date)
+);
+
+-- Account status lifecycle
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE'
+    CHECK (status IN ('ACTIVE', 'FROZEN', 'DORMANT', 'CLOSED'));
+
+-- Every status transition with its reason and the actor who made it
+CREATE TABLE IF NOT EXISTS account_status_history (
+    id SERIAL PRIMARY KEY,
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_status_history_account_number ON account_status_history(account_number);
+
+-- Account closure. Closed accounts are kept for retention; NIK and phone
+-- number stay unique only among accounts that are not closed, the service
+-- decides when a closed account's identity may be reused.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_nik_key;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_phone_number_key;
+UPDATE accounts SET closed_at = updated_at WHERE status = 'CLOSED' AND closed_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_open_nik ON accounts(nik) WHERE status <> 'CLOSED';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_open_phone_number ON accounts(phone_number) WHERE status <> 'CLOSED';
+
+-- Fund holds. held_amount is the sum of the remaining amount of active holds;
+-- the available balance is balance - held_amount.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00
+    CHECK (held_amount >= 0);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id VARCHAR(40) PRIMARY KEY,
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    captured_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('ACTIVE', 'CAPTURED', 'RELEASED', 'EXPIRED')),
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (captured_amount <= amount)
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_account_number ON holds(account_number);
+CREATE INDEX IF NOT EXISTS idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'ACTIVE';
+
+-- Withdrawal limits. Limit rules are chosen by account type and KYC tier;
+-- usage is counted per business day (Asia/Jakarta) and channel.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS account_type VARCHAR(20) NOT NULL DEFAULT 'REGULAR';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS kyc_tier VARCHAR(20) NOT NULL DEFAULT 'BASIC';
+
+CREATE TABLE IF NOT EXISTS withdrawal_usage (
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    business_date DATE NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('CASH', 'TRANSFER')),
+    total_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    tx_count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_number, business_date, channel)
+);
+
+-- Interest. Each account accrues once per business date on its end-of-day
+-- balance; accruals are credited once per month, with the final tax withheld
+-- as a separate ledger entry.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS product VARCHAR(30) NOT NULL DEFAULT 'TABUNGAN';
+
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    business_date DATE NOT NULL,
+    product VARCHAR(30) NOT NULL,
+    balance DECIMAL(15,2) NOT NULL,
+    annual_rate DECIMAL(7,6) NOT NULL,
+    amount DECIMAL(18,6) NOT NULL,
+    posting_reference VARCHAR(40),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_number, business_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_interest_accruals_unposted ON interest_accruals(business_date) WHERE posting_reference IS NULL;
+
+CREATE TABLE IF NOT EXISTS interest_postings (
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    period CHAR(7) NOT NULL,
+    gross DECIMAL(15,2) NOT NULL,
+    tax DECIMAL(15,2) NOT NULL,
+    net DECIMAL(15,2) NOT NULL,
+    reference VARCHAR(40) NOT NULL,
+    posted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_number, period)
+);
+
+-- Monthly admin fees, charged at most once per account and period. Per
+-- transaction fees are FEE entries in the transactions ledger.
+CREATE TABLE IF NOT EXISTS admin_fee_charges (
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    period CHAR(7) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    reference VARCHAR(40) NOT NULL,
+    charged_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_number, period)
+);
+
+-- End-of-day close. One run per business date; each run stores every
+-- account's closing balance and the day's totals.
+CREATE TABLE IF NOT EXISTS eod_runs (
+    business_date DATE PRIMARY KEY,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('RUNNING', 'COMPLETED')),
+    account_count INT NOT NULL DEFAULT 0,
+    total_balance DECIMAL(18,2) NOT NULL DEFAULT 0.00,
+    total_deposits DECIMAL(18,2) NOT NULL DEFAULT 0.00,
+    total_withdrawals DECIMAL(18,2) NOT NULL DEFAULT 0.00,
+    total_fees DECIMAL(18,2) NOT NULL DEFAULT 0.00,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    business_date DATE NOT NULL REFERENCES eod_runs(business_date),
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    balance DECIMAL(15,2) NOT NULL,
+    PRIMARY KEY (account_number, business_date)
+);
+
+-- Hash-chained ledger. Every entry is hashed onto the previous entry of its
+-- account (account_hash, head kept in accounts.ledger_hash) and of the whole
+-- ledger (global_hash, head kept in ledger_chain). Entries posted before
+-- this migration have no chain_seq and are outside the chain.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS chain_seq BIGINT UNIQUE;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS account_hash CHAR(64);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS global_hash CHAR(64);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS ledger_hash CHAR(64);
+
+CREATE TABLE IF NOT EXISTS ledger_chain (
+    id SMALLINT PRIMARY KEY CHECK (id = 1),
+    seq BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL
+);
+
+INSERT INTO ledger_chain (id, seq, hash) VALUES (1, 0, repeat('0', 64)) ON CONFLICT DO NOTHING;
+
+-- Signed statements of the global chain head
+CREATE TABLE IF NOT EXISTS ledger_checkpoints (
+    seq BIGINT PRIMARY KEY,
+    global_hash CHAR(64) NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Back-office roles of partner clients, comma separated
+ALTER TABLE api_clients ADD COLUMN IF NOT EXISTS roles TEXT NOT NULL DEFAULT '';
+
+-- Account numbers a partner client may read over SNAP and subscribe to,
+-- comma separated, '*' for all. Existing clients start with none.
+ALTER TABLE api_clients ADD COLUMN IF NOT EXISTS allowed_accounts TEXT NOT NULL DEFAULT '';
+
+-- Reversals. A compensating entry points at the entry it reverses; the unique
+-- index makes sure no entry is reversed twice.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of BIGINT REFERENCES transactions(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_reversal_of ON transactions(reversal_of) WHERE reversal_of IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS reversals (
+    reference VARCHAR(64) PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES transactions(id),
+    reason TEXT NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    forced BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Maker-checker. High-value operations wait here until a checker other than
+-- the maker approves or rejects them; every step is kept in
+-- pending_operation_events.
+CREATE TABLE IF NOT EXISTS pending_operations (
+    id VARCHAR(64) PRIMARY KEY,
+    operation VARCHAR(20) NOT NULL CHECK (operation IN ('WITHDRAWAL', 'REVERSAL')),
+    account_number VARCHAR(20) NOT NULL REFERENCES accounts(account_number),
+    amount DECIMAL(15,2) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('PENDING', 'APPROVED', 'EXECUTED', 'FAILED', 'REJECTED', 'EXPIRED')),
+    maker VARCHAR(150) NOT NULL,
+    checker VARCHAR(150) NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    result TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_operations_status ON pending_operations(status, expires_at);
+
+CREATE TABLE IF NOT EXISTS pending_operation_events (
+    id BIGSERIAL PRIMARY KEY,
+    operation_id VARCHAR(64) NOT NULL REFERENCES pending_operations(id),
+    status VARCHAR(20) NOT NULL,
+    actor VARCHAR(150) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_operation_events_operation ON pending_operation_events(operation_id);
+
+-- Audit log of every state change, written in the transaction of the change.
+-- Rows can only be added; the trigger rejects updates and deletes.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    account_number VARCHAR(20) NOT NULL DEFAULT '',
+    actor VARCHAR(300) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    before_state JSONB,
+    after_state JSONB,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_account ON audit_events(account_number, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+
+CREATE OR REPLACE FUNCTION reject_audit_event_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_audit_event_change();
+
+-- Transactional outbox. Domain events are written in the transaction of the
+-- change they describe and published by the relay worker afterwards.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(64) NOT NULL UNIQUE,
+    event_type VARCHAR(50) NOT NULL,
+    schema_version INT NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events(next_attempt_at, id) WHERE published_at IS NULL;
+
+-- Webhooks. Every event is queued for each matching subscription in the
+-- transaction that writes it to the outbox; deliveries failing too often end
+-- as DEAD, the dead-letter list. Each attempt is kept in the delivery log.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id VARCHAR(64) PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL REFERENCES api_clients(client_id),
+    url TEXT NOT NULL,
+    event_types TEXT NOT NULL DEFAULT '',
+    account_numbers TEXT NOT NULL DEFAULT '',
+    signing_secret TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Deliveries are signed with a secret of their own subscription. Older
+-- subscriptions have none and no longer receive deliveries.
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS signing_secret TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_client ON webhook_subscriptions(client_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id VARCHAR(64) NOT NULL REFERENCES webhook_subscriptions(id),
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_status_code INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at, id) WHERE status = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id),
+    attempt INT NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id);
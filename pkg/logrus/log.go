@@ -107,12 +107,16 @@ func (l *CustomLogger) WithRequestID(requestID string) *logrus.Entry {
 	return l.WithField("request_id", requestID)
 }
 
-// WithContext extracts request ID from context and adds it to log entry
+// WithContext extracts request ID and calling client from context and adds them to log entry
 func (l *CustomLogger) WithContext(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
 	if requestID, ok := ctx.Value("request_id").(string); ok && requestID != "" {
-		return l.WithRequestID(requestID)
+		fields["request_id"] = requestID
+	}
+	if clientID := GetClientID(ctx); clientID != "" {
+		fields["client_id"] = clientID
 	}
-	return l.WithFields(logrus.Fields{})
+	return l.WithFields(fields)
 }
 
 // LogOperation logs an operation with consistent format and context
@@ -188,10 +192,10 @@ func (l *CustomLogger) LogCritical(ctx context.Context, msg string, data map[str
 	l.WithContext(ctx).WithFields(fields).Fatal(msg)
 }
 
-// // ContextWithRequestID adds a request ID to the context
-// func ContextWithRequestID(ctx context.Context, requestId string) context.Context {
-// 	return context.WithValue(ctx, "request_id", requestId)
-// }
+// ContextWithRequestID adds a request ID to the context
+func ContextWithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, "request_id", requestId)
+}
 
 // GetRequestID retrieves the request ID from the context
 func GetRequestID(ctx context.Context) string {
@@ -201,6 +205,23 @@ func GetRequestID(ctx context.Context) string {
 	return ""
 }
 
+type contextKey string
+
+const clientIDKey contextKey = "client_id"
+
+// ContextWithClientID adds the authenticated partner client ID to the context
+func ContextWithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey, clientID)
+}
+
+// GetClientID retrieves the partner client ID from the context
+func GetClientID(ctx context.Context) string {
+	if clientID, ok := ctx.Value(clientIDKey).(string); ok {
+		return clientID
+	}
+	return ""
+}
+
 // Helper function to convert our level to logrus level
 func convertLevel(level Level) logrus.Level {
 	switch level {